@@ -0,0 +1,26 @@
+package common
+
+import (
+	"dbms/services/policy"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SendPolicyError answers 403 with the reason when the role policy denied
+// the request, 404 when the item it checked does not exist, and 500
+// otherwise.
+func SendPolicyError(c *fiber.Ctx, err error) error {
+	var deniedErr *policy.Denied
+	if errors.As(err, &deniedErr) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": deniedErr.Reason,
+		})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "record not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+}
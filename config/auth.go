@@ -0,0 +1,106 @@
+package config
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Credential is a service credential allowed to call the route groups listed
+// in Scopes ("*" for every group). For API keys Secret is the key itself, for
+// HMAC credentials it is the shared signing secret.
+type Credential struct {
+	ID     string
+	Secret string
+	Scopes []string
+}
+
+type AuthConfig struct {
+	// Disabled turns authentication off, e.g. for local development.
+	Disabled     bool
+	APIKeys      []Credential
+	HMACKeys     []Credential
+	MaxClockSkew time.Duration
+}
+
+// LoadAuthConfig reads the service credentials from the loaded config:
+//
+//	AUTH.DISABLED=false
+//	AUTH.API_KEYS=core:key1:*,transcriber:key2:schedule
+//	AUTH.HMAC_KEYS=core:secret1:user|workspace
+//	AUTH.MAX_CLOCK_SKEW=300
+//
+// Each credential is id:secret:scopes with scopes separated by "|". Listing
+// the same id twice with different secrets keeps both valid while a key is
+// being rotated.
+func LoadAuthConfig() AuthConfig {
+	skew := viper.GetInt("AUTH.MAX_CLOCK_SKEW")
+	if skew <= 0 {
+		skew = 300
+	}
+	return AuthConfig{
+		Disabled:     viper.GetBool("AUTH.DISABLED"),
+		APIKeys:      parseCredentials(viper.GetString("AUTH.API_KEYS")),
+		HMACKeys:     parseCredentials(viper.GetString("AUTH.HMAC_KEYS")),
+		MaxClockSkew: time.Duration(skew) * time.Second,
+	}
+}
+
+// parseCredentials skips malformed items. They are logged by position, and by
+// ID when they have one, never with their secret.
+func parseCredentials(value string) []Credential {
+	var credentials []Credential
+	for i, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			switch {
+			case strings.TrimSpace(item) == "":
+			case len(parts) > 1 && parts[0] != "":
+				log.Printf("Ignoring malformed credential #%d with ID %q", i+1, parts[0])
+			default:
+				log.Printf("Ignoring malformed credential #%d", i+1)
+			}
+			continue
+		}
+		credentials = append(credentials, Credential{
+			ID:     parts[0],
+			Secret: parts[1],
+			Scopes: strings.Split(parts[2], "|"),
+		})
+	}
+	return credentials
+}
+
+var watchOnce sync.Once
+
+// Watch calls onChange whenever the config file changes on disk or the
+// process receives SIGHUP, so that credentials can be rotated without a
+// restart.
+func Watch(onChange func()) {
+	watchOnce.Do(func() {
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			log.Printf("Config file changed: %s", e.Name)
+			onChange()
+		})
+		viper.WatchConfig()
+
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for range hangup {
+				if err := viper.ReadInConfig(); err != nil {
+					log.Printf("Error reading config file, %s", err)
+					continue
+				}
+				log.Println("Config reloaded on SIGHUP")
+				onChange()
+			}
+		}()
+	})
+}
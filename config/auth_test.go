@@ -0,0 +1,38 @@
+package config
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestParseCredentials(t *testing.T) {
+	var logged bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&logged)
+	defer log.SetOutput(output)
+
+	credentials := parseCredentials("core:key1:*, sk_live_abc,transcriber:key2:schedule|user,lonely:sk_live_def,,:sk_live_ghi:*")
+
+	if len(credentials) != 2 {
+		t.Fatalf("parsed %d credentials, want 2: %+v", len(credentials), credentials)
+	}
+	if credentials[0].ID != "core" || credentials[0].Secret != "key1" || strings.Join(credentials[0].Scopes, "|") != "*" {
+		t.Errorf("credentials[0] = %+v", credentials[0])
+	}
+	if credentials[1].ID != "transcriber" || strings.Join(credentials[1].Scopes, "|") != "schedule|user" {
+		t.Errorf("credentials[1] = %+v", credentials[1])
+	}
+
+	for _, secret := range []string{"sk_live_abc", "sk_live_def", "sk_live_ghi"} {
+		if strings.Contains(logged.String(), secret) {
+			t.Errorf("the log reveals secret %s: %s", secret, logged.String())
+		}
+	}
+	for _, want := range []string{"#2", `#4 with ID "lonely"`, "#6"} {
+		if !strings.Contains(logged.String(), want) {
+			t.Errorf("the log does not mention credential %s: %s", want, logged.String())
+		}
+	}
+}
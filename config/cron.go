@@ -0,0 +1,84 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+const (
+	CronModeDB   = "db"
+	CronModeHTTP = "http"
+)
+
+// Catch-up policies for reminders that became due while no worker was
+// running.
+const (
+	// CatchUpAll sends every missed reminder, however late.
+	CatchUpAll = "all"
+	// CatchUpWindow sends missed reminders that are at most
+	// ReminderCatchUpWindow late and skips older ones.
+	CatchUpWindow = "window"
+	// CatchUpNone skips every reminder missed by more than one run.
+	CatchUpNone = "none"
+)
+
+type CronConfig struct {
+	// Mode is CronModeDB to use the database of the loaded config directly,
+	// or CronModeHTTP to go through the dbms API at DMSBaseURL.
+	Mode       string
+	DMSBaseURL string
+	APIKey     string
+
+	ReminderCatchUp       string
+	ReminderCatchUpWindow time.Duration
+	// LeaderTTL is how long the elected worker keeps the jobs without
+	// renewing its lease, i.e. the longest failover time.
+	LeaderTTL time.Duration
+	// JobRunRetention is how long the job run history is kept.
+	JobRunRetention time.Duration
+}
+
+// LoadCronConfig reads the cron worker settings from the loaded config:
+//
+//	CRON.MODE=db
+//	CRON.DMS_BASE_URL=http://localhost:8089
+//	CRON.API_KEY=your-api-key
+//	CRON.REMINDER_CATCH_UP=window
+//	CRON.REMINDER_CATCH_UP_WINDOW=1h
+//	CRON.LEADER_TTL=30s
+//	CRON.JOB_RUN_RETENTION=720h
+//
+// The mode defaults to db, so a worker never reaches another environment
+// unless it is told to.
+func LoadCronConfig() CronConfig {
+	mode := strings.ToLower(strings.TrimSpace(viper.GetString("CRON.MODE")))
+	if mode == "" {
+		mode = CronModeDB
+	}
+	catchUp := strings.ToLower(strings.TrimSpace(viper.GetString("CRON.REMINDER_CATCH_UP")))
+	if catchUp == "" {
+		catchUp = CatchUpWindow
+	}
+	window, err := time.ParseDuration(viper.GetString("CRON.REMINDER_CATCH_UP_WINDOW"))
+	if err != nil || window <= 0 {
+		window = time.Hour
+	}
+	leaderTTL, err := time.ParseDuration(viper.GetString("CRON.LEADER_TTL"))
+	if err != nil || leaderTTL < 5*time.Second || leaderTTL > 10*time.Minute {
+		leaderTTL = 30 * time.Second
+	}
+	retention, err := time.ParseDuration(viper.GetString("CRON.JOB_RUN_RETENTION"))
+	if err != nil || retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	return CronConfig{
+		Mode:                  mode,
+		DMSBaseURL:            strings.TrimRight(viper.GetString("CRON.DMS_BASE_URL"), "/"),
+		APIKey:                viper.GetString("CRON.API_KEY"),
+		ReminderCatchUp:       catchUp,
+		ReminderCatchUpWindow: window,
+		LeaderTTL:             leaderTTL,
+		JobRunRetention:       retention,
+	}
+}
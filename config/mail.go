@@ -0,0 +1,67 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+)
+
+const (
+	MailBackendSMTP   = "smtp"
+	MailBackendFile   = "file"
+	MailBackendMemory = "memory"
+)
+
+type MailConfig struct {
+	Backend string
+	From    string
+	// Dir is where the file backend writes its maildir.
+	Dir string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPTLS is "starttls" (default), "tls" for implicit TLS or "none".
+	SMTPTLS string
+}
+
+// LoadMailConfig reads the email delivery settings from the loaded config:
+//
+//	MAIL.BACKEND=smtp
+//	MAIL.FROM=timewise.space@gmail.com
+//	MAIL.DIR=./mail_out
+//	SMTP.HOST=smtp.gmail.com
+//	SMTP.PORT=587
+//	SMTP.USERNAME=timewise.space@gmail.com
+//	SMTP.PASSWORD=your-app-password
+//	SMTP.TLS=starttls
+//
+// MAIL.FROM defaults to the SMTP username.
+func LoadMailConfig() MailConfig {
+	cfg := MailConfig{
+		Backend:      strings.ToLower(strings.TrimSpace(viper.GetString("MAIL.BACKEND"))),
+		From:         viper.GetString("MAIL.FROM"),
+		Dir:          viper.GetString("MAIL.DIR"),
+		SMTPHost:     viper.GetString("SMTP.HOST"),
+		SMTPPort:     viper.GetInt("SMTP.PORT"),
+		SMTPUsername: viper.GetString("SMTP.USERNAME"),
+		SMTPPassword: viper.GetString("SMTP.PASSWORD"),
+		SMTPTLS:      strings.ToLower(strings.TrimSpace(viper.GetString("SMTP.TLS"))),
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = MailBackendSMTP
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.SMTPUsername
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "mail_out"
+	}
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 587
+	}
+	if cfg.SMTPTLS == "" {
+		cfg.SMTPTLS = "starttls"
+	}
+	return cfg
+}
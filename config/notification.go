@@ -0,0 +1,43 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"time"
+)
+
+type NotificationConfig struct {
+	// MaxAttempts is the number of failed deliveries after which a
+	// notification is dead-lettered.
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+}
+
+// LoadNotificationConfig reads the notification delivery retry policy from
+// the loaded config:
+//
+//	NOTIFICATION.MAX_ATTEMPTS=5
+//	NOTIFICATION.RETRY_BASE=1m
+//	NOTIFICATION.RETRY_MAX=1h
+//
+// The n-th retry waits RETRY_BASE * 2^(n-1), at most RETRY_MAX.
+func LoadNotificationConfig() NotificationConfig {
+	cfg := NotificationConfig{
+		MaxAttempts: viper.GetInt("NOTIFICATION.MAX_ATTEMPTS"),
+		RetryBase:   time.Minute,
+		RetryMax:    time.Hour,
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if base, err := time.ParseDuration(viper.GetString("NOTIFICATION.RETRY_BASE")); err == nil && base > 0 {
+		cfg.RetryBase = base
+	}
+	if max, err := time.ParseDuration(viper.GetString("NOTIFICATION.RETRY_MAX")); err == nil && max > 0 {
+		cfg.RetryMax = max
+	}
+	if cfg.RetryMax < cfg.RetryBase {
+		cfg.RetryMax = cfg.RetryBase
+	}
+	return cfg
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+type ReminderConfig struct {
+	// EscalationWindow is how long the participants of a reminder have to
+	// acknowledge it before the schedule creator is notified; zero turns
+	// escalation off.
+	EscalationWindow time.Duration
+	// EscalationPriorities are the schedule priorities whose reminders are
+	// escalated, lower case.
+	EscalationPriorities []string
+	// MaxSnooze is the longest a reminder can be snoozed.
+	MaxSnooze time.Duration
+}
+
+// LoadReminderConfig reads the reminder escalation and snooze settings from
+// the loaded config:
+//
+//	REMINDER.ESCALATION_WINDOW=30m
+//	REMINDER.ESCALATION_PRIORITIES=high
+//	REMINDER.MAX_SNOOZE=168h
+func LoadReminderConfig() ReminderConfig {
+	cfg := ReminderConfig{
+		EscalationWindow: 30 * time.Minute,
+		MaxSnooze:        7 * 24 * time.Hour,
+	}
+	if value := strings.TrimSpace(viper.GetString("REMINDER.ESCALATION_WINDOW")); value != "" {
+		if window, err := time.ParseDuration(value); err == nil && window >= 0 {
+			cfg.EscalationWindow = window
+		}
+	}
+	priorities := viper.GetString("REMINDER.ESCALATION_PRIORITIES")
+	if priorities == "" {
+		priorities = "high"
+	}
+	for _, priority := range strings.Split(priorities, ",") {
+		if priority = strings.ToLower(strings.TrimSpace(priority)); priority != "" {
+			cfg.EscalationPriorities = append(cfg.EscalationPriorities, priority)
+		}
+	}
+	if snooze, err := time.ParseDuration(viper.GetString("REMINDER.MAX_SNOOZE")); err == nil && snooze > 0 {
+		cfg.MaxSnooze = snooze
+	}
+	return cfg
+}
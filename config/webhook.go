@@ -0,0 +1,50 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"time"
+)
+
+type WebhookConfig struct {
+	// MaxAttempts is the number of failed deliveries after which a webhook
+	// delivery is given up.
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+	// Timeout bounds one delivery request.
+	Timeout time.Duration
+}
+
+// LoadWebhookConfig reads the webhook delivery retry policy from the loaded
+// config:
+//
+//	WEBHOOK.MAX_ATTEMPTS=8
+//	WEBHOOK.RETRY_BASE=1m
+//	WEBHOOK.RETRY_MAX=6h
+//	WEBHOOK.TIMEOUT=10s
+//
+// The n-th retry waits RETRY_BASE * 2^(n-1), at most RETRY_MAX.
+func LoadWebhookConfig() WebhookConfig {
+	cfg := WebhookConfig{
+		MaxAttempts: viper.GetInt("WEBHOOK.MAX_ATTEMPTS"),
+		RetryBase:   time.Minute,
+		RetryMax:    6 * time.Hour,
+		Timeout:     10 * time.Second,
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if base, err := time.ParseDuration(viper.GetString("WEBHOOK.RETRY_BASE")); err == nil && base > 0 {
+		cfg.RetryBase = base
+	}
+	if max, err := time.ParseDuration(viper.GetString("WEBHOOK.RETRY_MAX")); err == nil && max > 0 {
+		cfg.RetryMax = max
+	}
+	if cfg.RetryMax < cfg.RetryBase {
+		cfg.RetryMax = cfg.RetryBase
+	}
+	if timeout, err := time.ParseDuration(viper.GetString("WEBHOOK.TIMEOUT")); err == nil && timeout > 0 {
+		cfg.Timeout = timeout
+	}
+	return cfg
+}
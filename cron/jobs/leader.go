@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// workerName identifies this worker in the lease and the delivery records.
+var workerName = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
+// Elector keeps this worker in the leader election. Only the leader runs
+// the jobs; when it stops renewing its lease, e.g. because it crashed,
+// another worker takes over once the lease expires.
+type Elector struct {
+	store Store
+	ttl   time.Duration
+
+	mu sync.Mutex
+	// validUntil is when this worker's lease expires at the latest,
+	// measured from before the request was sent.
+	validUntil time.Time
+	leader     bool
+}
+
+func NewElector(store Store, ttl time.Duration) *Elector {
+	return &Elector{store: store, ttl: ttl}
+}
+
+// IsLeader reports whether this worker holds a lease that has not expired.
+// A leader that cannot renew its lease steps down by itself.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Now().Before(e.validUntil)
+}
+
+// Run campaigns for and renews the lease every third of its duration until
+// stop is closed, then releases it.
+func (e *Elector) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign()
+		select {
+		case <-stop:
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) campaign() {
+	started := time.Now()
+	isLeader, err := e.store.AcquireLeadership(workerName, e.ttl)
+	if err != nil {
+		log.Println("Error renewing leadership:", err)
+		return
+	}
+	wasLeader := e.IsLeader()
+
+	e.mu.Lock()
+	e.leader = isLeader
+	if isLeader {
+		e.validUntil = started.Add(e.ttl)
+	}
+	e.mu.Unlock()
+
+	if isLeader && !wasLeader {
+		log.Println("Worker", workerName, "is now the leader")
+	} else if !isLeader && wasLeader {
+		log.Println("Worker", workerName, "lost the leadership")
+	}
+}
+
+func (e *Elector) resign() {
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+	if !wasLeader {
+		return
+	}
+	if err := e.store.ReleaseLeadership(workerName); err != nil {
+		log.Println("Error releasing leadership:", err)
+		return
+	}
+	log.Println("Worker", workerName, "released the leadership")
+}
@@ -1,463 +1,236 @@
 package jobs
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
+	"context"
+	"dbms/config"
+	"dbms/mail"
 	"fmt"
-	"github.com/robfig/cron/v3"
-	"github.com/timewise-team/timewise-models/dtos/core_dtos/schedule_participant_dtos"
 	"github.com/timewise-team/timewise-models/models"
-	"gopkg.in/gomail.v2"
-	"io/ioutil"
-	"net/http"
+	"log"
 	"time"
 )
 
-// RegisterJobs registers all cron jobs
-func RegisterJobs() {
-	c := cron.New()
-
-	// Add a sample job
-	_, err := c.AddFunc("@every 1m", func() {
-		checkReminder()
-		sendNotification()
-	})
-	if err != nil {
-		fmt.Println("Error adding cron job:", err)
-		return
+// RegisterJobs runs the cron jobs against the given store and mail sender
+// until ctx is cancelled, then waits for the runs in progress to finish.
+// Every replica schedules the jobs, but only the one elected by elector runs
+// them.
+func RegisterJobs(ctx context.Context, store Store, sender mail.Sender, catchUp CatchUp, elector *Elector, runRetention time.Duration) error {
+	registry := NewRegistry(store, elector)
+	webhookClient := NewWebhookClient(config.LoadWebhookConfig().Timeout)
+	jobs := []struct {
+		name string
+		spec string
+		run  func() RunReport
+	}{
+		{"check_reminder", "@every 1m", func() RunReport { return CheckReminder(store, sender, catchUp) }},
+		{"send_notification", "@every 1m", func() RunReport { return SendNotification(store, sender) }},
+		{"send_digest", "@every 1m", func() RunReport { return SendDigests(store, sender) }},
+		{"deliver_webhooks", "@every 30s", func() RunReport { return DeliverWebhooks(store, webhookClient) }},
+		{"escalate_reminders", "@every 1m", func() RunReport { return EscalateReminders(store) }},
+		{"sync_reminder_rules", "@every 1h", func() RunReport { return SyncReminderRules(store) }},
+		{"clear_expired_link_email_requests", "@every 10m", func() RunReport { return ClearExpiredLinkEmailRequests(store) }},
+		{"prune_job_runs", "@daily", func() RunReport { return PruneJobRuns(store, runRetention) }},
+	}
+	for _, job := range jobs {
+		if err := registry.Add(job.name, job.spec, job.run); err != nil {
+			return fmt.Errorf("adding cron job %s: %w", job.name, err)
+		}
 	}
 
-	_, err = c.AddFunc("@every 10m", func() {
-		clearExpiredLinkEmailRequests()
-	})
-
-	if err != nil {
-		fmt.Println("Error adding cron job:", err)
-		return
+	stopElector := make(chan struct{})
+	electorDone := make(chan struct{})
+	go func() {
+		elector.Run(stopElector)
+		close(electorDone)
+	}()
+	if err := registry.Start(); err != nil {
+		close(stopElector)
+		<-electorDone
+		return err
 	}
 
-	c.Start()
-	fmt.Println("Cron jobs started")
-
-	// Keep the program running
-	select {}
+	<-ctx.Done()
+	log.Println("Shutting down, waiting for running jobs to finish")
+	registry.Stop()
+	close(stopElector)
+	<-electorDone
+	return nil
 }
-func createMessage(reminder models.TwReminder) string {
-	// Kiểm tra xem StartTime và EndTime có nil không trước khi format
-	var startTime, endTime string
-	if reminder.Schedule.StartTime != nil {
-		startTime = reminder.Schedule.StartTime.Format("02/01/2006 15:04")
-	} else {
-		startTime = "N/A" // Hoặc có thể để là một giá trị mặc định khác
-	}
-	if reminder.Schedule.EndTime != nil {
-		endTime = reminder.Schedule.EndTime.Format("02/01/2006 15:04")
-	} else {
-		endTime = "N/A" // Hoặc có thể để là một giá trị mặc định khác
-	}
-
-	return fmt.Sprintf(`
-    <!DOCTYPE html>
-    <html lang="en">
-    <head>
-        <meta charset="UTF-8">
-        <meta name="viewport" content="width=device-width, initial-scale=1.0">
-        <title>Reminder Email</title>
-        <style>
-            body {
-                font-family: Arial, sans-serif;
-                background-color: #f4f4f9;
-                margin: 0;
-                padding: 0;
-                color: #333;
-            }
-
-            .email-container {
-                max-width: 600px;
-                margin: 0 auto;
-                padding: 20px;
-                background-color: #ffffff;
-                border-radius: 8px;
-                box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
-            }
-
-            .email-header {
-                font-size: 24px;
-                font-weight: bold;
-                color: #4CAF50;
-                margin-bottom: 20px;
-                text-align: center;
-            }
 
-            .email-body {
-                font-size: 16px;
-                line-height: 1.5;
-                margin-bottom: 30px;
-            }
-
-            .email-footer {
-                font-size: 14px;
-                color: #777;
-                text-align: center;
-            }
-
-            .message-text {
-                color: #333;
-                font-size: 16px;
-                margin: 20px 0;
-                padding: 10px;
-                background-color: #f9f9f9;
-                border-left: 5px solid #4CAF50;
-            }
+// notificationTemplates maps the notification types to the email template
+// that renders them; other types get mail.TemplateNotification.
+var notificationTemplates = map[string]string{
+	"mention":              mail.TemplateMention,
+	"tag":                  mail.TemplateMention,
+	"assign":               mail.TemplateAssignment,
+	"assignment":           mail.TemplateAssignment,
+	"invite":               mail.TemplateInvitation,
+	"invitation":           mail.TemplateInvitation,
+	"workspace_invitation": mail.TemplateInvitation,
+}
 
-            .highlight {
-                color: #4CAF50;
-                font-weight: bold;
-            }
-        </style>
-    </head>
-    <body>
-        <div class="email-container">
-            <div class="email-header">
-                Reminder Notification
-            </div>
-            <div class="email-body">
-                <p>Hello <span class="highlight">%s</span>,</p>
-                <p>This is a reminder for you:</p>
-                <div class="message-text">
-                    <p><strong>Workspace:</strong> %s</p>
-                    <p><strong>Workspace Description:</strong> %s</p>
-                    <p><strong>Schedule:</strong> %s</p>
-                    <p><strong>Schedule Description:</strong> %s</p>
-                    <p><strong>Start Time:</strong> %s</p>
-                    <p><strong>End Time:</strong> %s</p>
-                </div>
-            </div>
-            <div class="email-footer">
-                <p>Thank you for using our service!</p>
-            </div>
-        </div>
-    </body>
-    </html>
-    `, reminder.WorkspaceUser.UserEmail.Email,
-		reminder.WorkspaceUser.Workspace.Title,
-		reminder.WorkspaceUser.Workspace.Description,
-		reminder.Schedule.Title,
-		reminder.Schedule.Description,
-		startTime,
-		endTime)
+func notificationEmail(notification models.TwNotifications, locale string) (mail.Rendered, error) {
+	name, ok := notificationTemplates[notification.Type]
+	if !ok {
+		name = mail.TemplateNotification
+	}
+	return mail.Render(name, locale, mail.NotificationData{
+		Recipient:   notification.UserEmail.Email,
+		Type:        notification.Type,
+		Title:       notification.Title,
+		Description: notification.Description,
+		Message:     notification.Message,
+		Link:        notification.Link,
+	})
 }
 
-func checkReminder() {
-	fmt.Println("Starting cron job: checkReminder at", time.Now())
+// SendNotification emails the queued notifications that are due, following
+// the recipients' notification settings. A failed delivery is retried with
+// exponential backoff until the notification gets dead-lettered.
+func SendNotification(store Store, sender mail.Sender) RunReport {
+	var report RunReport
+	log.Println("Starting cron job: sendNotification")
 
-	reminders, err := GetReminders()
+	dueNotifications, err := store.DueNotifications()
 	if err != nil {
-		fmt.Println("Error getting reminders:", err)
-		return
+		report.Fail("Error getting due notifications:", err)
+		return report
 	}
-	now := time.Now()
-
-	nowFormatted := now.Format("2006-01-02 15:04:05")
-	now, _ = time.Parse("2006-01-02 15:04:05", nowFormatted)
-	fmt.Printf("Now: %s", now)
-	// Lấy thời gian trước đó 2 phút không có múi giờ
-	twoMinutesAgo := now.Add(-2 * time.Minute)
-	fmt.Printf("Two minutes ago: %s", twoMinutesAgo)
-	for _, reminder := range reminders {
-		reminderTimeFormatted := reminder.ReminderTime.Format("2006-01-02 15:04:05")
-		reminderTime, _ := time.Parse("2006-01-02 15:04:05", reminderTimeFormatted)
-		fmt.Println("Reminder time: %s", reminderTime)
-		fmt.Println("Reminder time before now: %t", reminderTime.Before(now))
-		fmt.Println("Reminder time after two minutes ago: %t", reminderTime.After(twoMinutesAgo))
-		fmt.Println("Reminder is sent: %t", reminder.IsSent)
-		fmt.Println(reminder.ReminderTime)
-		if reminderTime.After(twoMinutesAgo) && reminderTime.Before(now) && !reminder.IsSent {
-			if reminder.Type == "only me" {
-				// Send reminder
-				fmt.Printf("Sending reminder ID %d to email %d", reminder.ID, reminder.WorkspaceUser.UserEmail.Email)
-				// Update reminder to sent
-				err := updateReminderToSent(reminder.ID)
-				if err != nil {
-					fmt.Println("Error updating reminder to sent:", err)
-					continue
-				}
-				// Create message
-				message := createMessage(reminder)
-				// Send email
-				err = SendEmail(reminder.WorkspaceUser.UserEmail.Email, "Reminder", message)
-				if err != nil {
-					fmt.Println("Error sending email:", err)
-					continue
-				}
-				notificationMessage := fmt.Sprintf("Schedule %s is about to start at %s on %s", reminder.Schedule.Title, reminder.Schedule.StartTime.Format("15:04"), reminder.Schedule.StartTime.Format("02/01/2006"))
-				notification := models.TwNotifications{
-					UserEmailId:     reminder.WorkspaceUser.UserEmail.ID,
-					Type:            "reminder",
-					Message:         notificationMessage,
-					IsRead:          false,
-					RelatedItemId:   reminder.Schedule.ID,
-					RelatedItemType: "schedule",
-					ExtraData:       "",
-					IsSent:          false,
-					NotifiedAt:      &now,
-				}
-				PushNotification(notification)
 
-			} else {
-				// Send reminder
-				fmt.Printf("Sending reminder ID %d to all participants of schedule ID %d", reminder.ID, reminder.Schedule.ID)
-				// Update reminder to sent
-				err := updateReminderToSent(reminder.ID)
-				if err != nil {
-					fmt.Println("Error updating reminder to sent:", err)
-					continue
-				}
-				// Create message
-				message := createMessage(reminder)
-				participants, err := GetParticipantsByScheduleId(reminder.Schedule.ID)
-				if err != nil {
-					fmt.Println("Error getting participants:", err)
-					continue
-				}
-				fmt.Println("Participants:", participants)
-				// Send email
-				for _, participant := range participants {
-					err = SendEmail(participant.Email, "Reminder", message)
-					if err != nil {
-						fmt.Println("Error sending email:", err)
-						continue
-					}
-					notificationMessage := fmt.Sprintf("Schedule %s is about to start at %s on %s", reminder.Schedule.Title, reminder.Schedule.StartTime.Format("15:04"), reminder.Schedule.StartTime.Format("02/01/2006"))
-					notification := models.TwNotifications{
-						UserEmailId:     participant.UserId,
-						Type:            "reminder",
-						Message:         notificationMessage,
-						IsRead:          false,
-						RelatedItemId:   reminder.Schedule.ID,
-						RelatedItemType: "schedule",
-						ExtraData:       "",
-						IsSent:          false,
-						NotifiedAt:      &now,
-					}
-					PushNotification(notification)
-				}
+	now := time.Now()
+	for _, notification := range dueNotifications {
+		report.Processed++
+
+		// Apply the recipient's settings as they are now
+		plan, err := store.NotificationPlan(notification.UserEmailId, notification.Type, now)
+		if err != nil {
+			report.Fail("Error getting notification settings:", err)
+			continue
+		}
+		if plan.Deferred {
+			if err := store.DeferNotification(notification.ID, plan.DeliverAt); err != nil {
+				report.Fail("Error deferring notification:", err)
 			}
-
+			continue
+		}
+		if !plan.Deliver || !plan.Email {
+			// Kept in-app only, nothing to email
+			if err := store.MarkNotificationSent(notification.ID); err != nil {
+				report.Fail("Error updating notification to sent:", err)
+			}
+			continue
 		}
-	}
-}
-
-func GetParticipantsByScheduleId(id int) ([]schedule_participant_dtos.ScheduleParticipantInfo, error) {
-	resp, err := http.Get(fmt.Sprintf("https://dms.timewise.space/dbms/v1/schedule_participant/schedule/%d", id))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var participants []schedule_participant_dtos.ScheduleParticipantInfo
-	if err := json.Unmarshal(body, &participants); err != nil {
-		return nil, err
-	}
-
-	return participants, nil
-
-}
-
-func PushNotification(notifications models.TwNotifications) {
-	url := "https://dms.timewise.space/dbms/v1/notification"
-	jsonData, err := json.Marshal(notifications)
-	if err != nil {
-		fmt.Println("Error marshaling JSON:", err)
-		return
-	}
-
-	// Create a new request with the JSON payload
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return
-	}
-	req.Header.Set("accept", "application/json")
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error sending request:", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Failed to push notification:", resp.StatusCode)
-	}
-
-	fmt.Println("Notification pushed successfully")
-}
-func updateReminderToSent(reminderID int) error {
-	url := fmt.Sprintf("https://dms.timewise.space/dbms/v1/reminder/%d/is_sent", reminderID)
-	req, err := http.NewRequest(http.MethodPut, url, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("accept", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update reminder: status code %d", resp.StatusCode)
-	}
-
-	return nil
-}
-
-func GetReminders() ([]models.TwReminder, error) {
-	//resp, err := http.Get("https://dms.timewise.space/dbms/v1/reminder")
-	resp, err := http.Get("https://dms.timewise.space/dbms/v1/reminder")
-
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+		// Send email
+		email, err := notificationEmail(notification, plan.Locale)
+		if err == nil {
+			err = sender.Send(email.Message(notification.UserEmail.Email))
+		}
+		if err != nil {
+			report.Fail("Error sending email:", err)
+			deadLettered, recordErr := store.RecordNotificationFailure(notification.ID, err.Error())
+			if recordErr != nil {
+				report.Fail("Error recording notification failure:", recordErr)
+			} else if deadLettered {
+				log.Printf("Notification ID %d dead-lettered", notification.ID)
+			}
+			continue
+		}
 
-	var reminders []models.TwReminder
-	if err := json.Unmarshal(body, &reminders); err != nil {
-		return nil, err
+		// Update notification to sent
+		err = store.MarkNotificationSent(notification.ID)
+		if err != nil {
+			report.Fail("Error updating notification to sent:", err)
+		}
 	}
-
-	return reminders, nil
-
+	return report
 }
 
-func sendNotification() {
-	fmt.Println("Starting cron job: sendNotification at", time.Now())
+// SendDigests emails the notification digests that are due. A digest that
+// cannot be emailed is released, so that its notifications go into the
+// user's next digest until they get dead-lettered.
+func SendDigests(store Store, sender mail.Sender) RunReport {
+	var report RunReport
+	log.Println("Starting cron job: sendDigests")
 
-	unsentNotifications, err := GetUnsentNotifications()
+	userIds, err := store.DueDigestUsers()
 	if err != nil {
-		fmt.Println("Error getting unsent notifications:", err)
-		return
+		report.Fail("Error getting due digests:", err)
+		return report
 	}
-
-	for _, notification := range unsentNotifications {
-		if notification.NotifiedAt != nil && notification.NotifiedAt.Before(time.Now()) {
-			// Send notification
-			fmt.Printf("Sending notification ID %d to email %d", notification.ID, notification.UserEmail.Email)
-
-			// Send email
-			err := SendEmail(notification.UserEmail.Email, "Notification", notification.Message)
-			if err != nil {
-				fmt.Println("Error sending email:", err)
+	for _, userId := range userIds {
+		digests, err := store.ClaimDigests(userId)
+		if err != nil {
+			report.Fail("Error claiming digests:", err)
+			continue
+		}
+		for _, digest := range digests {
+			report.Processed++
+			email := mail.Rendered{Subject: digest.Subject, HTML: digest.HTML, Text: digest.Text}
+			if err := sender.Send(email.Message(digest.Email)); err != nil {
+				report.Fail("Error sending digest email:", err)
+				if err := store.ReleaseDigest(digest.ID, err.Error()); err != nil {
+					report.Fail("Error releasing digest:", err)
+				}
 				continue
 			}
-
-			// Update notification to sent
-			err = updateNotificationToSent(notification.ID)
-			if err != nil {
-				fmt.Println("Error updating notification to sent:", err)
+			if err := store.MarkDigestSent(digest.ID); err != nil {
+				report.Fail("Error updating digest to sent:", err)
 			}
 		}
 	}
+	return report
 }
 
-func GetUnsentNotifications() ([]models.TwNotifications, error) {
-	resp, err := http.Get("https://dms.timewise.space/dbms/v1/notification")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// ClearExpiredLinkEmailRequests resets the email link requests that have
+// expired.
+func ClearExpiredLinkEmailRequests(store Store) RunReport {
+	var report RunReport
+	log.Println("Starting cron job: clearExpiredLinkEmailRequests")
 
-	body, err := ioutil.ReadAll(resp.Body)
+	cleared, err := store.ClearExpiredLinkEmailRequests()
 	if err != nil {
-		return nil, err
+		report.Fail("Error clearing expired link email requests:", err)
+		return report
 	}
-
-	var notifications []models.TwNotifications
-	if err := json.Unmarshal(body, &notifications); err != nil {
-		return nil, err
+	if cleared > 0 {
+		report.Processed = int(cleared)
 	}
-
-	return notifications, nil
+	return report
 }
 
-func updateNotificationToSent(notificationID int) error {
-	url := fmt.Sprintf("https://dms.timewise.space/dbms/v1/notification/%d", notificationID)
-	req, err := http.NewRequest(http.MethodPut, url, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("accept", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// SyncReminderRules creates the relative reminders of the occurrences of
+// recurring schedules that came into range.
+func SyncReminderRules(store Store) RunReport {
+	var report RunReport
+	synced, err := store.SyncReminderRules()
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update notification: status code %d", resp.StatusCode)
-	}
-
-	return nil
-}
-
-func SendEmail(to string, subject string, body string) error {
-	dialer := ConfigSMTP()
-	if dialer == nil {
-		return errors.New("failed to configure SMTP dialer")
-	}
-	m := gomail.NewMessage()
-	m.SetHeader("From", dialer.Username)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body)
-
-	if err := dialer.DialAndSend(m); err != nil {
-		return err
+		report.Fail("Error syncing relative reminders:", err)
 	}
-
-	return nil
+	report.Processed = synced
+	return report
 }
-func ConfigSMTP() *gomail.Dialer {
-	SmtpHost := "smtp.gmail.com"
-	SmtpPort := 587
-	SmtpEmail := "timewise.space@gmail.com"
-	SmtpPassword := "dczt wlvd eisn cixf"
-	return gomail.NewDialer(SmtpHost, SmtpPort, SmtpEmail, SmtpPassword)
-}
-
-func clearExpiredLinkEmailRequests() {
-	fmt.Println("Starting cron job: clearExpiredLinkEmailRequests at", time.Now())
 
-	err := DeleteLinkEmailRequest()
+// EscalateReminders notifies the schedule creators of the participants who
+// did not acknowledge a reminder of a high priority schedule in time.
+func EscalateReminders(store Store) RunReport {
+	var report RunReport
+	escalated, err := store.EscalateReminders()
 	if err != nil {
-		fmt.Println("Error getting expired link email requests:", err)
-		return
+		report.Fail("Error escalating reminders:", err)
 	}
+	report.Processed = escalated
+	return report
 }
 
-func DeleteLinkEmailRequest() error {
-	resp, err := http.Get("https://dms.timewise.space/dbms/v1/user_email/clear-expired")
+// PruneJobRuns deletes the job runs older than retention.
+func PruneJobRuns(store Store, retention time.Duration) RunReport {
+	var report RunReport
+	deleted, err := store.PruneJobRuns(time.Now().Add(-retention))
 	if err != nil {
-		return err
+		report.Fail("Error pruning job runs:", err)
+		return report
 	}
-	defer resp.Body.Close()
-
-	return nil
+	report.Processed = int(deleted)
+	return report
 }
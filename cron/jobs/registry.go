@@ -0,0 +1,230 @@
+package jobs
+
+import (
+	"dbms/dms_models"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pollInterval is how often the leader picks up paused jobs and
+	// requested runs.
+	pollInterval = 10 * time.Second
+	// maxRecordedErrors bounds the error messages stored with a run.
+	maxRecordedErrors = 20
+)
+
+// RunReport collects what one run of a job did.
+type RunReport struct {
+	Processed int
+	Errors    []string
+}
+
+// Fail logs an error and records it in the report.
+func (r *RunReport) Fail(message string, err error) {
+	log.Println(message, err)
+	r.Errors = append(r.Errors, fmt.Sprint(message, " ", err))
+}
+
+type job struct {
+	name string
+	spec string
+	run  func() RunReport
+}
+
+// Registry runs the jobs on the leading worker and records each run. Jobs
+// can be paused, resumed and triggered through the admin API, which the
+// registry polls.
+type Registry struct {
+	store   Store
+	elector *Elector
+	cron    *cron.Cron
+	jobs    map[string]*job
+	order   []string
+	stop    chan struct{}
+	polled  chan struct{}
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	paused   map[string]bool
+	running  map[string]bool
+	stopping bool
+}
+
+func NewRegistry(store Store, elector *Elector) *Registry {
+	return &Registry{
+		store:   store,
+		elector: elector,
+		cron:    cron.New(),
+		jobs:    make(map[string]*job),
+		stop:    make(chan struct{}),
+		polled:  make(chan struct{}),
+		paused:  make(map[string]bool),
+		running: make(map[string]bool),
+	}
+}
+
+// Add schedules a job under a unique name with a robfig/cron spec.
+func (r *Registry) Add(name, spec string, run func() RunReport) error {
+	if _, ok := r.jobs[name]; ok {
+		return fmt.Errorf("job %q is already registered", name)
+	}
+	j := &job{name: name, spec: spec, run: run}
+	if _, err := r.cron.AddFunc(spec, func() {
+		r.runJob(j, dms_models.CronJobTriggerSchedule)
+	}); err != nil {
+		return err
+	}
+	r.jobs[name] = j
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Start records the jobs so that they show up in the admin API and starts
+// running them.
+func (r *Registry) Start() error {
+	jobs := make([]dms_models.TwCronJob, 0, len(r.order))
+	for _, name := range r.order {
+		jobs = append(jobs, dms_models.TwCronJob{Name: name, Spec: r.jobs[name].spec})
+	}
+	if err := r.store.RegisterCronJobs(jobs); err != nil {
+		return err
+	}
+	r.pollControls()
+	go r.pollLoop()
+	r.cron.Start()
+	log.Println("Cron jobs started")
+	return nil
+}
+
+// Stop stops scheduling runs and waits for the runs in progress to finish.
+func (r *Registry) Stop() {
+	r.mu.Lock()
+	r.stopping = true
+	r.mu.Unlock()
+
+	close(r.stop)
+	<-r.polled
+	<-r.cron.Stop().Done()
+	r.wg.Wait()
+	log.Println("Cron jobs stopped")
+}
+
+func (r *Registry) pollLoop() {
+	defer close(r.polled)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.pollControls()
+		}
+	}
+}
+
+// pollControls refreshes the paused jobs and runs the requested ones.
+func (r *Registry) pollControls() {
+	if !r.elector.IsLeader() {
+		return
+	}
+	controls, err := r.store.JobControls()
+	if err != nil {
+		log.Println("Error getting job controls:", err)
+		return
+	}
+
+	r.mu.Lock()
+	for _, control := range controls {
+		r.paused[control.Name] = control.Paused
+	}
+	r.mu.Unlock()
+
+	for _, control := range controls {
+		j, ok := r.jobs[control.Name]
+		if !ok || control.TriggerRequestedAt == nil {
+			continue
+		}
+		claimed, err := r.store.ClaimJobTrigger(control.Name)
+		if err != nil {
+			log.Println("Error claiming job trigger:", err)
+			continue
+		}
+		if claimed {
+			go r.runJob(j, dms_models.CronJobTriggerManual)
+		}
+	}
+}
+
+// runJob runs a job on the leader unless it is paused (scheduled runs only)
+// or still running, and records the run.
+func (r *Registry) runJob(j *job, trigger string) {
+	if !r.elector.IsLeader() {
+		return
+	}
+	r.mu.Lock()
+	if r.stopping || r.running[j.name] || (trigger == dms_models.CronJobTriggerSchedule && r.paused[j.name]) {
+		r.mu.Unlock()
+		return
+	}
+	r.running[j.name] = true
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, j.name)
+		r.mu.Unlock()
+		r.wg.Done()
+	}()
+
+	run := dms_models.TwCronJobRun{
+		JobName:   j.name,
+		Trigger:   trigger,
+		Status:    dms_models.CronJobRunRunning,
+		Worker:    workerName,
+		StartedAt: time.Now(),
+	}
+	recorded := true
+	if err := r.store.StartJobRun(&run); err != nil {
+		log.Println("Error recording job run:", err)
+		recorded = false
+	}
+
+	report := safeRun(j)
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.ItemsProcessed = report.Processed
+	run.ErrorCount = len(report.Errors)
+	run.Status = dms_models.CronJobRunSucceeded
+	if run.ErrorCount > 0 {
+		run.Status = dms_models.CronJobRunFailed
+		errs := report.Errors
+		if len(errs) > maxRecordedErrors {
+			errs = append(errs[:maxRecordedErrors:maxRecordedErrors], fmt.Sprintf("... and %d more", len(report.Errors)-maxRecordedErrors))
+		}
+		run.Errors = strings.Join(errs, "\n")
+	}
+	log.Printf("Job %s finished in %s: %d processed, %d error(s)", j.name, finishedAt.Sub(run.StartedAt).Round(time.Millisecond), run.ItemsProcessed, run.ErrorCount)
+	if recorded {
+		if err := r.store.FinishJobRun(run); err != nil {
+			log.Println("Error recording job run:", err)
+		}
+	}
+}
+
+// safeRun runs a job, turning a panic into an error of the run.
+func safeRun(j *job) (report RunReport) {
+	defer func() {
+		if p := recover(); p != nil {
+			report.Fail("Job panicked:", fmt.Errorf("%v", p))
+		}
+	}()
+	return j.run()
+}
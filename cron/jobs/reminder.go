@@ -0,0 +1,260 @@
+package jobs
+
+import (
+	"dbms/config"
+	"dbms/dms_models"
+	"dbms/mail"
+	"dbms/services/notification"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"log"
+	"time"
+)
+
+// reminderType is the notification type of reminders, governed by the
+// notification_on_due_date setting.
+const reminderType = "reminder"
+
+// onTimeGrace is how late a reminder may be and still count as on time,
+// i.e. due since the previous run.
+const onTimeGrace = 2 * time.Minute
+
+// CatchUp decides what happens to reminders that became due while no worker
+// was running, see the config.CatchUp* policies.
+type CatchUp struct {
+	Policy string
+	Window time.Duration
+}
+
+func NewCatchUp(cfg config.CronConfig) (CatchUp, error) {
+	switch cfg.ReminderCatchUp {
+	case config.CatchUpAll, config.CatchUpWindow, config.CatchUpNone:
+		return CatchUp{Policy: cfg.ReminderCatchUp, Window: cfg.ReminderCatchUpWindow}, nil
+	default:
+		return CatchUp{}, fmt.Errorf("unknown CRON.REMINDER_CATCH_UP policy %q", cfg.ReminderCatchUp)
+	}
+}
+
+// allows reports whether a reminder that is late by the given duration must
+// still be sent.
+func (c CatchUp) allows(late time.Duration) bool {
+	if late <= onTimeGrace {
+		return true
+	}
+	switch c.Policy {
+	case config.CatchUpAll:
+		return true
+	case config.CatchUpWindow:
+		return late <= c.Window
+	default:
+		return false
+	}
+}
+
+type reminderRecipient struct {
+	Email       string
+	UserEmailId int
+}
+
+// CheckReminder delivers the due reminders. Each reminder is claimed
+// atomically before it is sent, so that it is delivered once even when
+// several workers or overlapping runs see it. Missed reminders are sent or
+// skipped according to catchUp, and every attempt is recorded.
+//
+// When some recipients could not be reached the reminder is released, and
+// the next runs retry only those recipients, within catchUp: a recipient
+// with a recorded delivery other than a failure is not reminded again. A
+// crash between sending an email and recording it can still remind that
+// recipient twice.
+func CheckReminder(store Store, sender mail.Sender, catchUp CatchUp) RunReport {
+	var report RunReport
+	log.Println("Starting cron job: checkReminder")
+
+	now := notification.WallClockNow()
+	reminders, err := store.DueReminders(now)
+	if err != nil {
+		report.Fail("Error getting due reminders:", err)
+		return report
+	}
+
+	for _, reminder := range reminders {
+		late := now.Sub(reminder.ReminderTime)
+		claimed, err := store.ClaimReminder(reminder.ID)
+		if err != nil {
+			report.Fail("Error claiming reminder:", err)
+			continue
+		}
+		if !claimed {
+			// Another worker is delivering it
+			continue
+		}
+		report.Processed++
+
+		if !catchUp.allows(late) {
+			recordDelivery(store, reminder, "", dms_models.ReminderDeliverySkipped, fmt.Sprintf("missed by %s", late.Round(time.Second)), late)
+			continue
+		}
+
+		recipients, err := reminderRecipients(store, reminder)
+		if err != nil {
+			report.Fail("Error getting participants:", err)
+			releaseReminder(store, reminder.ID)
+			continue
+		}
+		reached, err := reachedRecipients(store, reminder.ID)
+		if err != nil {
+			report.Fail("Error getting reminder deliveries:", err)
+			releaseReminder(store, reminder.ID)
+			continue
+		}
+
+		failed := 0
+		for _, recipient := range recipients {
+			if reached[recipient.Email] {
+				// Reminded by an earlier run that failed for someone else
+				continue
+			}
+			plan, err := store.NotificationPlan(recipient.UserEmailId, reminderType, now)
+			if err != nil {
+				report.Fail("Error getting notification settings:", err)
+				failed++
+				continue
+			}
+			if !plan.Deliver {
+				recordDelivery(store, reminder, recipient.Email, dms_models.ReminderDeliverySkipped, plan.Reason, late)
+				continue
+			}
+			if plan.Deferred || !plan.Email {
+				// The queued notification carries the reminder: in-app only,
+				// or emailed by sendNotification once quiet hours are over.
+				status := dms_models.ReminderDeliveryInApp
+				if plan.Email {
+					status = dms_models.ReminderDeliveryDeferred
+				}
+				if err := store.PushNotification(reminderNotification(reminder, recipient, now)); err != nil {
+					report.Fail("Error pushing notification:", err)
+					failed++
+					continue
+				}
+				recordDelivery(store, reminder, recipient.Email, status, plan.Reason, late)
+				continue
+			}
+
+			email, err := reminderEmail(reminder, recipient, plan.Locale)
+			if err != nil {
+				report.Fail("Error rendering email:", err)
+				failed++
+				continue
+			}
+			if err := sender.Send(email.Message(recipient.Email)); err != nil {
+				report.Fail("Error sending email:", err)
+				recordDelivery(store, reminder, recipient.Email, dms_models.ReminderDeliveryFailed, err.Error(), late)
+				failed++
+				continue
+			}
+			recordDelivery(store, reminder, recipient.Email, dms_models.ReminderDeliverySent, "", late)
+			if err := store.PushNotification(reminderNotification(reminder, recipient, now)); err != nil {
+				report.Fail("Error pushing notification:", err)
+			}
+		}
+
+		// Someone did not get it, e.g. the mail server is down: give the
+		// reminder back so that the next run retries them within the
+		// catch-up policy.
+		if failed > 0 {
+			releaseReminder(store, reminder.ID)
+		}
+	}
+	return report
+}
+
+// reminderRecipients returns the creator of an "only me" reminder, or else
+// every participant of the schedule.
+func reminderRecipients(store Store, reminder models.TwReminder) ([]reminderRecipient, error) {
+	if reminder.Type == "only me" {
+		return []reminderRecipient{{
+			Email:       reminder.WorkspaceUser.UserEmail.Email,
+			UserEmailId: reminder.WorkspaceUser.UserEmail.ID,
+		}}, nil
+	}
+	participants, err := store.GetParticipantsByScheduleId(reminder.Schedule.ID)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]reminderRecipient, 0, len(participants))
+	for _, participant := range participants {
+		recipients = append(recipients, reminderRecipient{Email: participant.Email, UserEmailId: participant.UserId})
+	}
+	return recipients, nil
+}
+
+// reachedRecipients returns the recipients an earlier run already
+// delivered a reminder to, or deliberately did not.
+func reachedRecipients(store Store, reminderId int) (map[string]bool, error) {
+	deliveries, err := store.ReminderDeliveries(reminderId)
+	if err != nil {
+		return nil, err
+	}
+	reached := make(map[string]bool, len(deliveries))
+	for _, delivery := range deliveries {
+		if delivery.Status != dms_models.ReminderDeliveryFailed {
+			reached[delivery.Recipient] = true
+		}
+	}
+	return reached, nil
+}
+
+func reminderEmail(reminder models.TwReminder, recipient reminderRecipient, locale string) (mail.Rendered, error) {
+	return mail.Render(mail.TemplateReminder, locale, mail.ReminderData{
+		Recipient:            recipient.Email,
+		Workspace:            reminder.WorkspaceUser.Workspace.Title,
+		WorkspaceDescription: reminder.WorkspaceUser.Workspace.Description,
+		Schedule:             reminder.Schedule.Title,
+		ScheduleDescription:  reminder.Schedule.Description,
+		StartTime:            reminder.Schedule.StartTime,
+		EndTime:              reminder.Schedule.EndTime,
+	})
+}
+
+func reminderNotification(reminder models.TwReminder, recipient reminderRecipient, now time.Time) models.TwNotifications {
+	startTime := "N/A"
+	startDate := ""
+	if reminder.Schedule.StartTime != nil {
+		startTime = reminder.Schedule.StartTime.Format("15:04")
+		startDate = reminder.Schedule.StartTime.Format("02/01/2006")
+	}
+	return models.TwNotifications{
+		UserEmailId:     recipient.UserEmailId,
+		Type:            reminderType,
+		Message:         fmt.Sprintf("Schedule %s is about to start at %s on %s", reminder.Schedule.Title, startTime, startDate),
+		IsRead:          false,
+		RelatedItemId:   reminder.Schedule.ID,
+		RelatedItemType: "schedule",
+		ExtraData:       "",
+		IsSent:          false,
+		NotifiedAt:      &now,
+	}
+}
+
+func recordDelivery(store Store, reminder models.TwReminder, recipient, status, reason string, late time.Duration) {
+	if late < 0 {
+		late = 0
+	}
+	err := store.RecordReminderDelivery(dms_models.TwReminderDelivery{
+		ReminderId:  reminder.ID,
+		Recipient:   recipient,
+		Status:      status,
+		Error:       reason,
+		LateSeconds: int(late / time.Second),
+		Worker:      workerName,
+	})
+	if err != nil {
+		log.Println("Error recording reminder delivery:", err)
+	}
+}
+
+func releaseReminder(store Store, reminderId int) {
+	if err := store.ReleaseReminder(reminderId); err != nil {
+		log.Println("Error releasing reminder:", err)
+	}
+}
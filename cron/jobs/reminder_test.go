@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"dbms/config"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"dbms/mail"
+	"dbms/services/notification"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var catchUpAll = CatchUp{Policy: config.CatchUpAll}
+
+// seedReminder creates a reminder of schedule 1 by the owner that became due
+// late ago, e.g. "only me" or "participants".
+func seedReminder(t *testing.T, db *gorm.DB, id int, reminderType string, late time.Duration) {
+	t.Helper()
+	seed(t, db, &models.TwReminder{
+		ID:              id,
+		ScheduleId:      1,
+		ReminderTime:    notification.WallClockNow().Add(-late),
+		Method:          "email",
+		Type:            reminderType,
+		WorkspaceUserID: 1,
+	})
+}
+
+// deliveries returns "recipient:status" for every delivery of a reminder,
+// in order.
+func deliveries(t *testing.T, db *gorm.DB, reminderId int) []string {
+	t.Helper()
+	records, err := notification.ReminderDeliveries(db, reminderId)
+	if err != nil {
+		t.Fatalf("load deliveries: %v", err)
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, record.Recipient+":"+record.Status)
+	}
+	return got
+}
+
+func TestCheckReminderDeliversDueReminders(t *testing.T) {
+	db := openJobsDB(t)
+	seedReminder(t, db, 1, "only me", time.Minute)
+	seedReminder(t, db, 2, "participants", time.Minute)
+	// Not due yet
+	seedReminder(t, db, 3, "only me", -time.Hour)
+	sender := mail.NewMemorySender("cron@example.com")
+
+	report := CheckReminder(NewDBStore(db), sender, catchUpAll)
+	if report.Processed != 2 || len(report.Errors) != 0 {
+		t.Errorf("report = %+v, want 2 processed", report)
+	}
+
+	messages := sender.Messages()
+	if to := strings.Join(recipients(messages), ","); to != ownerEmail+","+ownerEmail+","+memberEmail {
+		t.Fatalf("sent to %s, want the owner for both reminders and the member", to)
+	}
+	if messages[0].Subject != "Reminder: Standup" || !strings.Contains(messages[0].Text, "Workspace: Team") {
+		t.Errorf("message to the owner = %+v", messages[0])
+	}
+	// The member reads Vietnamese.
+	if messages[2].Subject != "Nhắc nhở: Standup" {
+		t.Errorf("subject to the member = %q, want it in Vietnamese", messages[2].Subject)
+	}
+
+	if count := dbtest.Count(t, db, "tw_reminders", "is_sent = ?", true); count != 2 {
+		t.Errorf("%d reminders sent, want 2", count)
+	}
+	if got := strings.Join(deliveries(t, db, 2), ","); got != ownerEmail+":"+dms_models.ReminderDeliverySent+","+memberEmail+":"+dms_models.ReminderDeliverySent {
+		t.Errorf("deliveries of the participant reminder = %s", got)
+	}
+	if count := dbtest.Count(t, db, "tw_notifications", "type = ? AND related_item_id = ?", reminderType, 1); count != 3 {
+		t.Errorf("%d in-app notifications, want 3", count)
+	}
+}
+
+func TestCheckReminderFollowsNotificationSettings(t *testing.T) {
+	db := openJobsDB(t)
+	seedReminder(t, db, 1, "participants", time.Minute)
+	seed(t, db,
+		// The owner turned reminders off, the member only wants them in-app.
+		&models.TwNotificationSettings{UserId: 1, NotificationOnDueDate: false, NotificationOnEmail: true},
+		&models.TwNotificationSettings{UserId: 2, NotificationOnDueDate: true, NotificationOnEmail: false},
+	)
+	sender := mail.NewMemorySender("cron@example.com")
+
+	CheckReminder(NewDBStore(db), sender, catchUpAll)
+	if messages := sender.Messages(); len(messages) != 0 {
+		t.Errorf("sent %d emails, want none", len(messages))
+	}
+	want := ownerEmail + ":" + dms_models.ReminderDeliverySkipped + "," + memberEmail + ":" + dms_models.ReminderDeliveryInApp
+	if got := strings.Join(deliveries(t, db, 1), ","); got != want {
+		t.Errorf("deliveries = %s, want %s", got, want)
+	}
+	if count := dbtest.Count(t, db, "tw_notifications", "user_email_id = ?", 2); count != 1 {
+		t.Errorf("the member got %d in-app notifications, want 1", count)
+	}
+}
+
+func TestCheckReminderDeliversOnceAcrossRuns(t *testing.T) {
+	db := openJobsDB(t)
+	seedReminder(t, db, 1, "participants", time.Minute)
+	store := NewDBStore(db)
+	sender := mail.NewMemorySender("cron@example.com")
+
+	// Two workers, or a run overlapping the next one, see the same reminder.
+	var wg sync.WaitGroup
+	reports := make([]RunReport, 2)
+	for i := range reports {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i] = CheckReminder(store, sender, catchUpAll)
+		}(i)
+	}
+	wg.Wait()
+	CheckReminder(store, sender, catchUpAll)
+
+	if processed := reports[0].Processed + reports[1].Processed; processed != 1 {
+		t.Errorf("the reminder was processed %d times, want once", processed)
+	}
+	if to := strings.Join(recipients(sender.Messages()), ","); to != ownerEmail+","+memberEmail {
+		t.Errorf("sent to %s, want the owner and the member once", to)
+	}
+	if got := deliveries(t, db, 1); len(got) != 2 {
+		t.Errorf("deliveries = %v, want one per recipient", got)
+	}
+}
+
+func TestCheckReminderCatchUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		catchUp  CatchUp
+		late     time.Duration
+		wantSent bool
+	}{
+		{name: "on time", catchUp: CatchUp{Policy: config.CatchUpNone}, late: time.Minute, wantSent: true},
+		{name: "within the on-time grace", catchUp: CatchUp{Policy: config.CatchUpNone}, late: onTimeGrace - time.Second, wantSent: true},
+		{name: "none", catchUp: CatchUp{Policy: config.CatchUpNone}, late: onTimeGrace + time.Minute},
+		{name: "all", catchUp: CatchUp{Policy: config.CatchUpAll}, late: 48 * time.Hour, wantSent: true},
+		{name: "within the window", catchUp: CatchUp{Policy: config.CatchUpWindow, Window: time.Hour}, late: 50 * time.Minute, wantSent: true},
+		{name: "past the window", catchUp: CatchUp{Policy: config.CatchUpWindow, Window: time.Hour}, late: 70 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openJobsDB(t)
+			seedReminder(t, db, 1, "only me", tt.late)
+			sender := mail.NewMemorySender("cron@example.com")
+
+			report := CheckReminder(NewDBStore(db), sender, tt.catchUp)
+			if report.Processed != 1 || len(report.Errors) != 0 {
+				t.Errorf("report = %+v, want 1 processed", report)
+			}
+			if sent := len(sender.Messages()) == 1; sent != tt.wantSent {
+				t.Errorf("sent %d emails, want sent = %v", len(sender.Messages()), tt.wantSent)
+			}
+			// Skipped reminders are not retried either.
+			if count := dbtest.Count(t, db, "tw_reminders", "is_sent = ?", true); count != 1 {
+				t.Errorf("the reminder is not marked sent")
+			}
+			records, err := notification.ReminderDeliveries(db, 1)
+			if err != nil {
+				t.Fatalf("load deliveries: %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("deliveries = %+v, want 1", records)
+			}
+			want := dms_models.TwReminderDelivery{Recipient: ownerEmail, Status: dms_models.ReminderDeliverySent}
+			if !tt.wantSent {
+				want = dms_models.TwReminderDelivery{Status: dms_models.ReminderDeliverySkipped, Error: "missed by"}
+			}
+			got := records[0]
+			if got.Recipient != want.Recipient || got.Status != want.Status || !strings.HasPrefix(got.Error, want.Error) {
+				t.Errorf("delivery = %+v, want %+v", got, want)
+			}
+			if wantLate := int(tt.late / time.Second); got.LateSeconds < wantLate || got.LateSeconds > wantLate+1 {
+				t.Errorf("LateSeconds = %d, want %d", got.LateSeconds, wantLate)
+			}
+		})
+	}
+}
+
+func TestCheckReminderRetriesOnlyFailedRecipients(t *testing.T) {
+	db := openJobsDB(t)
+	seedReminder(t, db, 1, "participants", time.Minute)
+	store := NewDBStore(db)
+	memory := mail.NewMemorySender("cron@example.com")
+
+	// The member's mailbox is unavailable on the first run.
+	CheckReminder(store, failingSender{MemorySender: memory, failing: map[string]bool{memberEmail: true}}, catchUpAll)
+	if to := strings.Join(recipients(memory.Messages()), ","); to != ownerEmail {
+		t.Fatalf("first run sent to %s, want the owner", to)
+	}
+	if count := dbtest.Count(t, db, "tw_reminders", "is_sent = ?", false); count != 1 {
+		t.Fatalf("the reminder was not released for the member")
+	}
+
+	memory.Reset()
+	CheckReminder(store, memory, catchUpAll)
+	if to := strings.Join(recipients(memory.Messages()), ","); to != memberEmail {
+		t.Errorf("second run sent to %s, want only the member", to)
+	}
+	if count := dbtest.Count(t, db, "tw_reminders", "is_sent = ?", true); count != 1 {
+		t.Errorf("the reminder is not marked sent once everybody got it")
+	}
+	want := []string{
+		ownerEmail + ":" + dms_models.ReminderDeliverySent,
+		memberEmail + ":" + dms_models.ReminderDeliveryFailed,
+		memberEmail + ":" + dms_models.ReminderDeliverySent,
+	}
+	if got := deliveries(t, db, 1); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("deliveries = %v, want %v", got, want)
+	}
+
+	memory.Reset()
+	if report := CheckReminder(store, memory, catchUpAll); report.Processed != 0 || len(memory.Messages()) != 0 {
+		t.Errorf("third run = %+v and %d emails, want nothing", report, len(memory.Messages()))
+	}
+}
@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"dbms/config"
+	"dbms/dms_models"
+	"dbms/services/account"
+	"dbms/services/cronjob"
+	"dbms/services/leader"
+	"dbms/services/notification"
+	"dbms/services/webhook"
+	"github.com/timewise-team/timewise-models/dtos/core_dtos/schedule_participant_dtos"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Store is the data the jobs read and change. DBStore works on the database
+// directly, HTTPStore goes through the dbms API.
+type Store interface {
+	// DueReminders returns the unsent reminders due at or before the given
+	// time, oldest first.
+	DueReminders(before time.Time) ([]models.TwReminder, error)
+	// ClaimReminder atomically marks a reminder as sent and reports whether
+	// this caller got it.
+	ClaimReminder(reminderId int) (bool, error)
+	ReleaseReminder(reminderId int) error
+	RecordReminderDelivery(delivery dms_models.TwReminderDelivery) error
+	// ReminderDeliveries returns the delivery attempts of a reminder, oldest
+	// first.
+	ReminderDeliveries(reminderId int) ([]dms_models.TwReminderDelivery, error)
+	GetParticipantsByScheduleId(scheduleId int) ([]schedule_participant_dtos.ScheduleParticipantInfo, error)
+	// PushNotification queues a notification, subject to the recipient's
+	// settings.
+	PushNotification(notification models.TwNotifications) error
+	// NotificationPlan applies the settings of the user owning a user email
+	// to a notification of the given type due at the given time.
+	NotificationPlan(userEmailId int, notificationType string, at time.Time) (notification.Plan, error)
+	DeferNotification(notificationId int, until time.Time) error
+	// DueNotifications returns the unsent notifications to deliver now,
+	// leaving out those waiting for a retry and dead-lettered ones.
+	DueNotifications() ([]models.TwNotifications, error)
+	// RecordNotificationFailure counts a failed delivery and reports whether
+	// the notification got dead-lettered.
+	RecordNotificationFailure(notificationId int, reason string) (bool, error)
+	MarkNotificationSent(notificationId int) error
+	// DueDigestUsers returns the users whose notification digest is due.
+	DueDigestUsers() ([]int, error)
+	// ClaimDigests collects the unsent notifications of a user into pending
+	// digests and marks them sent; nothing is claimed when the digest is no
+	// longer due.
+	ClaimDigests(userId int) ([]dms_models.TwNotificationDigest, error)
+	MarkDigestSent(digestId int) error
+	// ReleaseDigest makes the notifications of a digest that could not be
+	// emailed unsent again, dead-lettering those that failed too often.
+	ReleaseDigest(digestId int, reason string) error
+	// ClearExpiredLinkEmailRequests returns how many requests were cleared,
+	// or -1 when the store cannot tell.
+	ClearExpiredLinkEmailRequests() (int64, error)
+	// SyncReminderRules extends the relative reminders of recurring
+	// schedules and returns how many schedules were synced.
+	SyncReminderRules() (int, error)
+	// EscalateReminders notifies the schedule creators of the unacknowledged
+	// reminders of high priority schedules and returns how many were
+	// notified.
+	EscalateReminders() (int, error)
+	// DispatchWebhookEvents fans the events of the webhook outbox out to the
+	// subscriptions and returns how many events were dispatched.
+	DispatchWebhookEvents() (int, error)
+	// ClaimWebhookDeliveries returns the webhook deliveries to send now,
+	// hiding them from the other workers for a while.
+	ClaimWebhookDeliveries() ([]webhook.Dispatch, error)
+	// RecordWebhookAttempt logs a request of a webhook delivery and
+	// schedules its retry when it failed.
+	RecordWebhookAttempt(deliveryId int, attempt webhook.Attempt) error
+	// AcquireLeadership takes or renews the cron lease for holder and
+	// reports whether holder is the leader.
+	AcquireLeadership(holder string, ttl time.Duration) (bool, error)
+	ReleaseLeadership(holder string) error
+	// RegisterCronJobs records the scheduled jobs for the admin API.
+	RegisterCronJobs(jobs []dms_models.TwCronJob) error
+	// JobControls returns the pause state and requested runs of the jobs.
+	JobControls() ([]dms_models.TwCronJob, error)
+	// ClaimJobTrigger clears a requested run and reports whether this caller
+	// got it.
+	ClaimJobTrigger(name string) (bool, error)
+	StartJobRun(run *dms_models.TwCronJobRun) error
+	FinishJobRun(run dms_models.TwCronJobRun) error
+	PruneJobRuns(before time.Time) (int64, error)
+}
+
+// The batches bound the reminders and notifications handled by one run; the
+// rest are picked up by the next runs.
+const (
+	dueRemindersBatch     = 500
+	dueNotificationsBatch = 500
+	dueDigestsBatch       = 100
+	webhookEventsBatch    = 500
+	webhookDeliveryBatch  = 100
+)
+
+type DBStore struct {
+	DB *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+func (s *DBStore) DueReminders(before time.Time) ([]models.TwReminder, error) {
+	return notification.DueReminders(s.DB, before, dueRemindersBatch)
+}
+
+func (s *DBStore) ClaimReminder(reminderId int) (bool, error) {
+	return notification.ClaimReminder(s.DB, reminderId)
+}
+
+func (s *DBStore) ReleaseReminder(reminderId int) error {
+	return notification.ReleaseReminder(s.DB, reminderId)
+}
+
+func (s *DBStore) RecordReminderDelivery(delivery dms_models.TwReminderDelivery) error {
+	return notification.RecordReminderDelivery(s.DB, &delivery)
+}
+
+func (s *DBStore) ReminderDeliveries(reminderId int) ([]dms_models.TwReminderDelivery, error) {
+	return notification.ReminderDeliveries(s.DB, reminderId)
+}
+
+func (s *DBStore) GetParticipantsByScheduleId(scheduleId int) ([]schedule_participant_dtos.ScheduleParticipantInfo, error) {
+	return notification.ScheduleParticipants(s.DB, scheduleId)
+}
+
+func (s *DBStore) PushNotification(n models.TwNotifications) error {
+	_, err := notification.Create(s.DB, &n)
+	return err
+}
+
+func (s *DBStore) NotificationPlan(userEmailId int, notificationType string, at time.Time) (notification.Plan, error) {
+	return notification.PlanForUserEmail(s.DB, userEmailId, notificationType, at)
+}
+
+func (s *DBStore) DeferNotification(notificationId int, until time.Time) error {
+	return notification.Defer(s.DB, notificationId, until)
+}
+
+func (s *DBStore) DueNotifications() ([]models.TwNotifications, error) {
+	return notification.Due(s.DB, time.Now(), dueNotificationsBatch)
+}
+
+func (s *DBStore) RecordNotificationFailure(notificationId int, reason string) (bool, error) {
+	delivery, err := notification.RecordFailure(s.DB, notificationId, reason, config.LoadNotificationConfig())
+	return delivery.DeadLetteredAt != nil, err
+}
+
+func (s *DBStore) MarkNotificationSent(notificationId int) error {
+	return notification.MarkSent(s.DB, notificationId)
+}
+
+func (s *DBStore) DueDigestUsers() ([]int, error) {
+	return notification.DueDigestUsers(s.DB, time.Now(), dueDigestsBatch)
+}
+
+func (s *DBStore) ClaimDigests(userId int) ([]dms_models.TwNotificationDigest, error) {
+	return notification.ClaimDigests(s.DB, userId, time.Now())
+}
+
+func (s *DBStore) MarkDigestSent(digestId int) error {
+	return notification.MarkDigestSent(s.DB, digestId)
+}
+
+func (s *DBStore) ReleaseDigest(digestId int, reason string) error {
+	return notification.ReleaseDigest(s.DB, digestId, reason, config.LoadNotificationConfig())
+}
+
+func (s *DBStore) ClearExpiredLinkEmailRequests() (int64, error) {
+	return account.ClearExpiredEmailLinks(s.DB)
+}
+
+func (s *DBStore) SyncReminderRules() (int, error) {
+	return notification.SyncRecurringReminderRules(s.DB, notification.WallClockNow())
+}
+
+func (s *DBStore) EscalateReminders() (int, error) {
+	return notification.EscalateReminders(s.DB, config.LoadReminderConfig(), time.Now())
+}
+
+func (s *DBStore) DispatchWebhookEvents() (int, error) {
+	return webhook.DispatchEvents(s.DB, time.Now(), webhookEventsBatch)
+}
+
+func (s *DBStore) ClaimWebhookDeliveries() ([]webhook.Dispatch, error) {
+	return webhook.ClaimDeliveries(s.DB, time.Now(), webhookDeliveryBatch)
+}
+
+func (s *DBStore) RecordWebhookAttempt(deliveryId int, attempt webhook.Attempt) error {
+	_, err := webhook.RecordAttempt(s.DB, deliveryId, attempt, time.Now(), config.LoadWebhookConfig())
+	return err
+}
+
+func (s *DBStore) AcquireLeadership(holder string, ttl time.Duration) (bool, error) {
+	_, isLeader, err := leader.Acquire(s.DB, leader.CronLease, holder, ttl)
+	return isLeader, err
+}
+
+func (s *DBStore) ReleaseLeadership(holder string) error {
+	return leader.Release(s.DB, leader.CronLease, holder)
+}
+
+func (s *DBStore) RegisterCronJobs(jobs []dms_models.TwCronJob) error {
+	return cronjob.Register(s.DB, jobs)
+}
+
+func (s *DBStore) JobControls() ([]dms_models.TwCronJob, error) {
+	return cronjob.Controls(s.DB)
+}
+
+func (s *DBStore) ClaimJobTrigger(name string) (bool, error) {
+	return cronjob.ClaimTrigger(s.DB, name)
+}
+
+func (s *DBStore) StartJobRun(run *dms_models.TwCronJobRun) error {
+	return cronjob.StartRun(s.DB, run)
+}
+
+func (s *DBStore) FinishJobRun(run dms_models.TwCronJobRun) error {
+	return cronjob.FinishRun(s.DB, &run)
+}
+
+func (s *DBStore) PruneJobRuns(before time.Time) (int64, error) {
+	return cronjob.PruneRuns(s.DB, before)
+}
@@ -0,0 +1,270 @@
+package jobs
+
+import (
+	"bytes"
+	"dbms/dms_models"
+	"dbms/services/notification"
+	"dbms/services/webhook"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/dtos/core_dtos/schedule_participant_dtos"
+	"github.com/timewise-team/timewise-models/models"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// HTTPStore reads and changes the data through the dbms API at BaseURL,
+// e.g. when the worker runs without access to the database.
+type HTTPStore struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+func NewHTTPStore(baseURL, apiKey string) (*HTTPStore, error) {
+	if baseURL == "" {
+		return nil, errors.New("CRON.DMS_BASE_URL is required in http mode")
+	}
+	return &HTTPStore{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+type statusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s %s: status code %d", e.Method, e.Path, e.StatusCode)
+}
+
+// do sends a request to the dbms API and decodes the JSON response into out
+// when it is not nil.
+func (s *HTTPStore) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+	req, err := http.NewRequest(method, s.BaseURL+"/dbms/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.APIKey != "" {
+		req.Header.Set("X-Api-Key", s.APIKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{Method: method, Path: path, StatusCode: resp.StatusCode}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (s *HTTPStore) DueReminders(before time.Time) ([]models.TwReminder, error) {
+	var reminders []models.TwReminder
+	path := "/reminder/due?before=" + url.QueryEscape(before.Format(time.RFC3339))
+	err := s.do(http.MethodGet, path, nil, &reminders)
+	return reminders, err
+}
+
+func (s *HTTPStore) ClaimReminder(reminderId int) (bool, error) {
+	err := s.do(http.MethodPut, fmt.Sprintf("/reminder/%d/claim", reminderId), nil, nil)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *HTTPStore) ReleaseReminder(reminderId int) error {
+	return s.do(http.MethodPut, fmt.Sprintf("/reminder/%d/release", reminderId), nil, nil)
+}
+
+func (s *HTTPStore) RecordReminderDelivery(delivery dms_models.TwReminderDelivery) error {
+	return s.do(http.MethodPost, fmt.Sprintf("/reminder/%d/deliveries", delivery.ReminderId), delivery, nil)
+}
+
+func (s *HTTPStore) ReminderDeliveries(reminderId int) ([]dms_models.TwReminderDelivery, error) {
+	var deliveries []dms_models.TwReminderDelivery
+	err := s.do(http.MethodGet, fmt.Sprintf("/reminder/%d/deliveries", reminderId), nil, &deliveries)
+	return deliveries, err
+}
+
+func (s *HTTPStore) GetParticipantsByScheduleId(scheduleId int) ([]schedule_participant_dtos.ScheduleParticipantInfo, error) {
+	var participants []schedule_participant_dtos.ScheduleParticipantInfo
+	err := s.do(http.MethodGet, fmt.Sprintf("/schedule_participant/schedule/%d", scheduleId), nil, &participants)
+	return participants, err
+}
+
+func (s *HTTPStore) PushNotification(notification models.TwNotifications) error {
+	return s.do(http.MethodPost, "/notification", notification, nil)
+}
+
+func (s *HTTPStore) DueNotifications() ([]models.TwNotifications, error) {
+	var notifications []models.TwNotifications
+	err := s.do(http.MethodGet, "/notification/due", nil, &notifications)
+	return notifications, err
+}
+
+func (s *HTTPStore) RecordNotificationFailure(notificationId int, reason string) (bool, error) {
+	var delivery dms_models.TwNotificationDelivery
+	body := map[string]string{"error": reason}
+	err := s.do(http.MethodPost, fmt.Sprintf("/notification/%d/failure", notificationId), body, &delivery)
+	return delivery.DeadLetteredAt != nil, err
+}
+
+func (s *HTTPStore) MarkNotificationSent(notificationId int) error {
+	return s.do(http.MethodPut, fmt.Sprintf("/notification/%d", notificationId), nil, nil)
+}
+
+func (s *HTTPStore) DueDigestUsers() ([]int, error) {
+	var userIds []int
+	err := s.do(http.MethodGet, "/notification/digest/due", nil, &userIds)
+	return userIds, err
+}
+
+func (s *HTTPStore) ClaimDigests(userId int) ([]dms_models.TwNotificationDigest, error) {
+	var digests []dms_models.TwNotificationDigest
+	body := map[string]int{"user_id": userId}
+	err := s.do(http.MethodPost, "/notification/digest/claim", body, &digests)
+	return digests, err
+}
+
+func (s *HTTPStore) MarkDigestSent(digestId int) error {
+	return s.do(http.MethodPut, fmt.Sprintf("/notification/digest/%d/sent", digestId), nil, nil)
+}
+
+func (s *HTTPStore) ReleaseDigest(digestId int, reason string) error {
+	body := map[string]string{"error": reason}
+	return s.do(http.MethodPut, fmt.Sprintf("/notification/digest/%d/release", digestId), body, nil)
+}
+
+// ClearExpiredLinkEmailRequests cannot tell how many requests were cleared,
+// the endpoint does not report it.
+func (s *HTTPStore) ClearExpiredLinkEmailRequests() (int64, error) {
+	return -1, s.do(http.MethodGet, "/user_email/clear-expired", nil, nil)
+}
+
+func (s *HTTPStore) SyncReminderRules() (int, error) {
+	var response struct {
+		Synced int `json:"synced"`
+	}
+	err := s.do(http.MethodPost, "/reminder/rule/sync", nil, &response)
+	return response.Synced, err
+}
+
+func (s *HTTPStore) EscalateReminders() (int, error) {
+	var response struct {
+		Escalated int `json:"escalated"`
+	}
+	err := s.do(http.MethodPost, "/reminder/escalate", nil, &response)
+	return response.Escalated, err
+}
+
+func (s *HTTPStore) DispatchWebhookEvents() (int, error) {
+	var response struct {
+		Dispatched int `json:"dispatched"`
+	}
+	err := s.do(http.MethodPost, "/webhook/event/dispatch", nil, &response)
+	return response.Dispatched, err
+}
+
+func (s *HTTPStore) ClaimWebhookDeliveries() ([]webhook.Dispatch, error) {
+	var dispatches []webhook.Dispatch
+	err := s.do(http.MethodPost, "/webhook/delivery/claim", nil, &dispatches)
+	return dispatches, err
+}
+
+func (s *HTTPStore) RecordWebhookAttempt(deliveryId int, attempt webhook.Attempt) error {
+	return s.do(http.MethodPost, fmt.Sprintf("/webhook/delivery/%d/attempt", deliveryId), attempt, nil)
+}
+
+func (s *HTTPStore) AcquireLeadership(holder string, ttl time.Duration) (bool, error) {
+	var response struct {
+		Leader bool `json:"leader"`
+	}
+	body := map[string]interface{}{
+		"holder":      holder,
+		"ttl_seconds": int(ttl / time.Second),
+	}
+	err := s.do(http.MethodPut, "/cron/leader", body, &response)
+	return response.Leader, err
+}
+
+func (s *HTTPStore) ReleaseLeadership(holder string) error {
+	return s.do(http.MethodDelete, "/cron/leader?holder="+url.QueryEscape(holder), nil, nil)
+}
+
+func (s *HTTPStore) RegisterCronJobs(jobs []dms_models.TwCronJob) error {
+	return s.do(http.MethodPut, "/cron/jobs", jobs, nil)
+}
+
+func (s *HTTPStore) JobControls() ([]dms_models.TwCronJob, error) {
+	var jobs []dms_models.TwCronJob
+	err := s.do(http.MethodGet, "/cron/jobs", nil, &jobs)
+	return jobs, err
+}
+
+func (s *HTTPStore) ClaimJobTrigger(name string) (bool, error) {
+	err := s.do(http.MethodPut, "/cron/jobs/"+url.PathEscape(name)+"/trigger/claim", nil, nil)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *HTTPStore) StartJobRun(run *dms_models.TwCronJobRun) error {
+	return s.do(http.MethodPost, "/cron/jobs/runs", run, run)
+}
+
+func (s *HTTPStore) FinishJobRun(run dms_models.TwCronJobRun) error {
+	return s.do(http.MethodPut, fmt.Sprintf("/cron/jobs/runs/%d", run.ID), run, nil)
+}
+
+func (s *HTTPStore) PruneJobRuns(before time.Time) (int64, error) {
+	var response struct {
+		Deleted int64 `json:"deleted"`
+	}
+	err := s.do(http.MethodDelete, "/cron/jobs/runs?before="+url.QueryEscape(before.Format(time.RFC3339)), nil, &response)
+	return response.Deleted, err
+}
+
+func (s *HTTPStore) NotificationPlan(userEmailId int, notificationType string, at time.Time) (notification.Plan, error) {
+	var plan notification.Plan
+	query := url.Values{}
+	query.Set("user_email_id", strconv.Itoa(userEmailId))
+	query.Set("type", notificationType)
+	query.Set("at", at.Format(time.RFC3339))
+	err := s.do(http.MethodGet, "/notification/plan?"+query.Encode(), nil, &plan)
+	return plan, err
+}
+
+func (s *HTTPStore) DeferNotification(notificationId int, until time.Time) error {
+	body := map[string]time.Time{"until": until}
+	return s.do(http.MethodPut, fmt.Sprintf("/notification/%d/defer", notificationId), body, nil)
+}
@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"dbms/mail"
+	"errors"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"testing"
+	"time"
+)
+
+const (
+	ownerEmail  = "owner@example.com"
+	memberEmail = "member@example.com"
+)
+
+// openJobsDB returns a database holding workspace 1 with its owner, user
+// email 1, and a member, user email 2, both participating in schedule 1.
+func openJobsDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwUser{},
+		&models.TwUserEmail{},
+		&models.TwWorkspace{},
+		&models.TwWorkspaceUser{},
+		&models.TwSchedule{},
+		&models.TwScheduleParticipant{},
+		&models.TwReminder{},
+		&models.TwNotifications{},
+		&models.TwNotificationSettings{},
+		&dms_models.TwReminderDelivery{},
+		&dms_models.TwNotificationDelivery{},
+		&dms_models.TwNotificationDigestSetting{},
+		&dms_models.TwNotificationQuietHours{},
+	)
+	start := time.Now().Add(time.Hour)
+	seed(t, db,
+		&models.TwUser{ID: 1, FirstName: "Owner", Locale: "en"},
+		&models.TwUser{ID: 2, FirstName: "Member", Locale: "vi"},
+		&models.TwUserEmail{ID: 1, UserId: 1, Email: ownerEmail},
+		&models.TwUserEmail{ID: 2, UserId: 2, Email: memberEmail},
+		&models.TwWorkspace{ID: 1, Title: "Team"},
+		&models.TwWorkspaceUser{ID: 1, UserEmailId: 1, WorkspaceId: 1, Role: "owner", Status: "joined", IsActive: true, IsVerified: true},
+		&models.TwWorkspaceUser{ID: 2, UserEmailId: 2, WorkspaceId: 1, Role: "member", Status: "joined", IsActive: true, IsVerified: true},
+		&models.TwSchedule{ID: 1, WorkspaceId: 1, Title: "Standup", StartTime: &start, CreatedBy: 1, Status: "not yet"},
+		&models.TwScheduleParticipant{ScheduleId: 1, WorkspaceUserId: 1, Status: "creator", InvitationStatus: "joined"},
+		&models.TwScheduleParticipant{ScheduleId: 1, WorkspaceUserId: 2, Status: "participant", InvitationStatus: "joined"},
+	)
+	return db
+}
+
+func seed(t *testing.T, db *gorm.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+}
+
+// recipients returns the recipient of every message, in order.
+func recipients(messages []mail.Message) []string {
+	var to []string
+	for _, message := range messages {
+		to = append(to, message.To...)
+	}
+	return to
+}
+
+// failingSender fails the messages to the given addresses and hands the
+// others to a MemorySender.
+type failingSender struct {
+	*mail.MemorySender
+	failing map[string]bool
+}
+
+func (s failingSender) Send(message mail.Message) error {
+	for _, to := range message.To {
+		if s.failing[to] {
+			return errors.New("mailbox unavailable")
+		}
+	}
+	return s.MemorySender.Send(message)
+}
+
+func TestSendNotificationEmailsDueNotifications(t *testing.T) {
+	db := openJobsDB(t)
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	seed(t, db,
+		&models.TwNotifications{ID: 1, UserEmailId: 1, Type: "mention", Title: "You were mentioned", NotifiedAt: &past},
+		&models.TwNotifications{ID: 2, UserEmailId: 2, Type: "schedule", Title: "Standup moved", NotifiedAt: &past},
+		// Not due yet
+		&models.TwNotifications{ID: 3, UserEmailId: 1, Type: "schedule", Title: "Later", NotifiedAt: &future},
+		&models.TwNotifications{ID: 4, UserEmailId: 2, Type: "comment", Title: "New comment", NotifiedAt: &past},
+		// The member only wants in-app notifications.
+		&models.TwNotificationSettings{UserId: 2, NotificationOnComment: true, NotificationOnScheduleChange: true, NotificationOnEmail: false},
+	)
+	sender := mail.NewMemorySender("cron@example.com")
+
+	report := SendNotification(NewDBStore(db), sender)
+	if report.Processed != 3 || len(report.Errors) != 0 {
+		t.Errorf("report = %+v, want 3 processed", report)
+	}
+	messages := sender.Messages()
+	if len(messages) != 1 || messages[0].To[0] != ownerEmail || messages[0].Subject != "You were mentioned" {
+		t.Fatalf("sent %+v, want the mention to the owner", messages)
+	}
+	if messages[0].From != "cron@example.com" || messages[0].HTML == "" || messages[0].Text == "" {
+		t.Errorf("message = %+v, want both parts from the sender address", messages[0])
+	}
+	var sent []int
+	if err := db.Model(&models.TwNotifications{}).Where("is_sent = ?", true).Order("id").Pluck("id", &sent).Error; err != nil {
+		t.Fatalf("load sent notifications: %v", err)
+	}
+	if len(sent) != 3 || sent[0] != 1 || sent[1] != 2 || sent[2] != 4 {
+		t.Errorf("notifications %v sent, want 1, 2 and 4", sent)
+	}
+}
+
+func TestSendNotificationRecordsFailures(t *testing.T) {
+	db := openJobsDB(t)
+	past := time.Now().Add(-time.Minute)
+	seed(t, db,
+		&models.TwNotifications{ID: 1, UserEmailId: 1, Type: "mention", Title: "You were mentioned", NotifiedAt: &past},
+		&models.TwNotifications{ID: 2, UserEmailId: 2, Type: "mention", Title: "You were mentioned", NotifiedAt: &past},
+	)
+	sender := failingSender{MemorySender: mail.NewMemorySender("cron@example.com"), failing: map[string]bool{memberEmail: true}}
+	store := NewDBStore(db)
+
+	report := SendNotification(store, sender)
+	if report.Processed != 2 || len(report.Errors) != 1 {
+		t.Errorf("report = %+v, want 2 processed and 1 failure", report)
+	}
+	if to := recipients(sender.Messages()); len(to) != 1 || to[0] != ownerEmail {
+		t.Errorf("sent to %v, want the owner", to)
+	}
+	var delivery dms_models.TwNotificationDelivery
+	if err := db.Where("notification_id = ?", 2).First(&delivery).Error; err != nil {
+		t.Fatalf("load delivery: %v", err)
+	}
+	if delivery.Attempts != 1 || delivery.LastError != "mailbox unavailable" || delivery.NextAttemptAt == nil {
+		t.Errorf("delivery = %+v, want one attempt scheduled for a retry", delivery)
+	}
+	if count := dbtest.Count(t, db, "tw_notifications", "id = ? AND is_sent = ?", 2, false); count != 1 {
+		t.Errorf("the failed notification was marked sent")
+	}
+
+	// The failed notification waits for its retry.
+	sender.Reset()
+	if report := SendNotification(store, sender); report.Processed != 0 {
+		t.Errorf("second run report = %+v, want nothing processed", report)
+	}
+}
+
+func TestClearExpiredLinkEmailRequests(t *testing.T) {
+	db := openJobsDB(t)
+	pending := "pending"
+	linkedTo := 1
+	expired := time.Now().UTC().Add(-time.Hour)
+	valid := time.Now().UTC().Add(time.Hour)
+	seed(t, db,
+		&models.TwUserEmail{ID: 3, UserId: 2, Email: "expired@example.com", Status: &pending, IsLinkedTo: &linkedTo, ExpiresAt: &expired},
+		&models.TwUserEmail{ID: 4, UserId: 2, Email: "valid@example.com", Status: &pending, IsLinkedTo: &linkedTo, ExpiresAt: &valid},
+	)
+
+	report := ClearExpiredLinkEmailRequests(NewDBStore(db))
+	if report.Processed != 1 || len(report.Errors) != 0 {
+		t.Errorf("report = %+v, want 1 processed", report)
+	}
+	var userEmails []models.TwUserEmail
+	if err := db.Where("id IN ?", []int{3, 4}).Order("id").Find(&userEmails).Error; err != nil {
+		t.Fatalf("load user emails: %v", err)
+	}
+	if userEmails[0].Status != nil || userEmails[0].IsLinkedTo != nil || userEmails[0].ExpiresAt != nil {
+		t.Errorf("expired request = %+v, want it cleared", userEmails[0])
+	}
+	if userEmails[1].Status == nil || userEmails[1].IsLinkedTo == nil || userEmails[1].ExpiresAt == nil {
+		t.Errorf("valid request = %+v, want it kept", userEmails[1])
+	}
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"bytes"
+	"dbms/services/webhook"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// maxWebhookResponse bounds the response body read from a webhook receiver.
+const maxWebhookResponse = 1024
+
+// NewWebhookClient returns the client delivering webhooks. Redirects are not
+// followed, a receiver has to answer 2xx at its subscribed URL. It connects
+// directly, never through a proxy, and refuses the addresses forbidden by
+// webhook.ForbiddenAddress once the receiver's host name is resolved.
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: refuseForbiddenAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func refuseForbiddenAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || webhook.ForbiddenAddress(ip) {
+		return fmt.Errorf("refusing to deliver a webhook to %s", host)
+	}
+	return nil
+}
+
+// DeliverWebhooks fans the new events of the webhook outbox out to the
+// subscriptions, then sends the deliveries that are due, signed with the
+// subscription's secret. Every request is logged; failed ones are retried
+// with exponential backoff by later runs.
+func DeliverWebhooks(store Store, client *http.Client) RunReport {
+	var report RunReport
+	log.Println("Starting cron job: deliverWebhooks")
+
+	if _, err := store.DispatchWebhookEvents(); err != nil {
+		// The deliveries already dispatched can still be sent
+		report.Fail("Error dispatching webhook events:", err)
+	}
+
+	dispatches, err := store.ClaimWebhookDeliveries()
+	if err != nil {
+		report.Fail("Error claiming webhook deliveries:", err)
+		return report
+	}
+	for _, dispatch := range dispatches {
+		report.Processed++
+		attempt := sendWebhook(client, dispatch)
+		if !attempt.Succeeded() {
+			report.Fail(fmt.Sprintf("Error delivering webhook delivery ID %d (attempt %d):", dispatch.DeliveryId, dispatch.Attempt), errors.New(webhookFailure(attempt)))
+		}
+		if err := store.RecordWebhookAttempt(dispatch.DeliveryId, attempt); err != nil {
+			report.Fail("Error recording webhook attempt:", err)
+		}
+	}
+	return report
+}
+
+func sendWebhook(client *http.Client, dispatch webhook.Dispatch) webhook.Attempt {
+	attempt := webhook.Attempt{Worker: workerName}
+	req, err := http.NewRequest(http.MethodPost, dispatch.URL, bytes.NewBufferString(dispatch.Body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	for name, value := range dispatch.Headers(time.Now()) {
+		req.Header.Set(name, value)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	attempt.DurationMs = int(time.Since(start) / time.Millisecond)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	attempt.StatusCode = resp.StatusCode
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	attempt.Response = string(body)
+	return attempt
+}
+
+func webhookFailure(attempt webhook.Attempt) string {
+	if attempt.Error != "" {
+		return attempt.Error
+	}
+	return fmt.Sprintf("status code %d", attempt.StatusCode)
+}
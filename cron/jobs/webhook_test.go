@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"dbms/services/webhook"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookClientRefusesForbiddenAddresses(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	// The receiver listens on the loopback address, where a subscribed host
+	// name could resolve to after validation.
+	attempt := sendWebhook(NewWebhookClient(time.Second), webhook.Dispatch{URL: server.URL, Body: "{}"})
+	if attempt.Succeeded() || !strings.Contains(attempt.Error, "refusing to deliver a webhook") {
+		t.Errorf("attempt = %+v, want the connection refused", attempt)
+	}
+	if requests != 0 {
+		t.Errorf("the receiver got %d request(s)", requests)
+	}
+}
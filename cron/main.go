@@ -1,9 +1,60 @@
 package main
 
 import (
+	"context"
+	"dbms/config"
 	"dbms/cron/jobs"
+	"dbms/database"
+	"dbms/mail"
+	"log"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	jobs.RegisterJobs()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Could not load config: %v", err)
+	}
+
+	var store jobs.Store
+	cronCfg := config.LoadCronConfig()
+	switch cronCfg.Mode {
+	case config.CronModeDB:
+		db, err := database.InitDB(cfg)
+		if err != nil {
+			log.Fatalf("Could not initialize database: %v", err)
+		}
+		store = jobs.NewDBStore(db)
+	case config.CronModeHTTP:
+		store, err = jobs.NewHTTPStore(cronCfg.DMSBaseURL, cronCfg.APIKey)
+		if err != nil {
+			log.Fatalf("Could not initialize dbms client: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown CRON.MODE %q, expected %q or %q", cronCfg.Mode, config.CronModeDB, config.CronModeHTTP)
+	}
+	log.Printf("Running cron jobs in %s mode", cronCfg.Mode)
+
+	mailCfg := config.LoadMailConfig()
+	sender, err := mail.New(mailCfg)
+	if err != nil {
+		log.Fatalf("Could not initialize mail sender: %v", err)
+	}
+	log.Printf("Sending email through the %s backend", mailCfg.Backend)
+
+	catchUp, err := jobs.NewCatchUp(cronCfg)
+	if err != nil {
+		log.Fatalf("Invalid reminder catch-up policy: %v", err)
+	}
+
+	// Stop on SIGTERM or Ctrl+C once the running jobs have finished
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	elector := jobs.NewElector(store, cronCfg.LeaderTTL)
+	if err := jobs.RegisterJobs(ctx, store, sender, catchUp, elector, cronCfg.JobRunRetention); err != nil {
+		log.Fatalf("Could not run cron jobs: %v", err)
+	}
+	log.Println("Cron worker stopped")
 }
@@ -0,0 +1,109 @@
+// Package dbtest opens throwaway in-memory SQLite databases for tests and
+// injects write failures into them.
+package dbtest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const driverName = "sqlite3_dbtest"
+
+// ErrInjected is the error returned by the writes that FailWrites breaks.
+var ErrInjected = errors.New("dbtest: injected failure")
+
+var registerDriver sync.Once
+
+// Open returns a fresh in-memory database with the given models migrated.
+// It has a single connection, so a query issued outside a running
+// transaction blocks instead of silently reading another snapshot. NOW() is
+// provided for the MySQL expressions used by the handlers, and column
+// defaults of CURRENT_TIMESTAMP(n) are migrated as CURRENT_TIMESTAMP.
+func Open(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	registerDriver.Do(func() {
+		sql.Register(driverName, &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return conn.RegisterFunc("now", func(precision ...int64) string {
+					return time.Now().UTC().Format("2006-01-02 15:04:05.000")
+				}, false)
+			},
+		})
+	})
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.New(sqlite.Config{DriverName: driverName, DSN: dsn}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, table := range tables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(table); err != nil {
+			t.Fatalf("parse %T: %v", table, err)
+		}
+		// The parsed schema is cached, so AutoMigrate sees the change.
+		for _, field := range stmt.Schema.Fields {
+			if strings.HasPrefix(field.DefaultValue, "CURRENT_TIMESTAMP(") {
+				field.DefaultValue = "CURRENT_TIMESTAMP"
+			}
+		}
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// FailWrites makes every create, update and delete on table fail with
+// ErrInjected from now on.
+func FailWrites(t *testing.T, db *gorm.DB, table string) {
+	t.Helper()
+	fail := func(tx *gorm.DB) {
+		if tx.Statement.Table == table {
+			tx.AddError(ErrInjected)
+		}
+	}
+	name := "dbtest:fail_" + table
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register(name, fail),
+		callbacks.Update().Before("gorm:update").Register(name, fail),
+		callbacks.Delete().Before("gorm:delete").Register(name, fail),
+	} {
+		if err != nil {
+			t.Fatalf("register failure on %s: %v", table, err)
+		}
+	}
+}
+
+// Count returns the number of rows of table matching the optional condition.
+func Count(t *testing.T, db *gorm.DB, table string, query ...interface{}) int64 {
+	t.Helper()
+	var count int64
+	tx := db.Table(table)
+	if len(query) > 0 {
+		tx = tx.Where(query[0], query[1:]...)
+	}
+	if err := tx.Count(&count).Error; err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return count
+}
@@ -0,0 +1,13 @@
+package dms_models
+
+import "time"
+
+// TwBoardColumnLimit holds the optional work-in-progress limit of a board
+// column, i.e. the maximum number of cards it may hold.
+type TwBoardColumnLimit struct {
+	ID            int       `gorm:"primary_key"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	BoardColumnId int       `json:"board_column_id" gorm:"uniqueIndex"`
+	WipLimit      int       `json:"wip_limit"`
+}
@@ -0,0 +1,38 @@
+package dms_models
+
+import "time"
+
+const (
+	CronJobRunRunning   = "running"
+	CronJobRunSucceeded = "succeeded"
+	CronJobRunFailed    = "failed"
+
+	CronJobTriggerSchedule = "schedule"
+	CronJobTriggerManual   = "manual"
+)
+
+// TwCronJob is a job known to the cron workers together with the admin
+// controls the workers poll: whether it is paused and whether a run was
+// requested.
+type TwCronJob struct {
+	Name               string     `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	Spec               string     `json:"spec"`
+	Paused             bool       `json:"paused"`
+	TriggerRequestedAt *time.Time `json:"trigger_requested_at" gorm:"default:null"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TwCronJobRun records one run of a cron job.
+type TwCronJobRun struct {
+	ID             int        `gorm:"primary_key"`
+	JobName        string     `json:"job_name" gorm:"index;type:varchar(64)"`
+	Trigger        string     `json:"trigger" gorm:"type:varchar(16)"`
+	Status         string     `json:"status" gorm:"type:varchar(16)"`
+	Worker         string     `json:"worker"`
+	StartedAt      time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt     *time.Time `json:"finished_at" gorm:"default:null"`
+	ItemsProcessed int        `json:"items_processed"`
+	ErrorCount     int        `json:"error_count"`
+	Errors         string     `json:"errors" gorm:"type:text"`
+}
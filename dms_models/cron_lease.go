@@ -0,0 +1,14 @@
+package dms_models
+
+import "time"
+
+// TwCronLease is the leadership lease of the cron workers. The worker named
+// in Holder runs the jobs until ExpiresAt unless it renews the lease; after
+// that any other worker may take it over.
+type TwCronLease struct {
+	Name       string    `json:"name" gorm:"primaryKey;type:varchar(64)"`
+	Holder     string    `json:"holder" gorm:"type:varchar(255)"`
+	AcquiredAt time.Time `json:"acquired_at" gorm:"type:datetime(3)"`
+	RenewedAt  time.Time `json:"renewed_at" gorm:"type:datetime(3)"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"type:datetime(3)"`
+}
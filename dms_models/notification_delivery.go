@@ -0,0 +1,19 @@
+package dms_models
+
+import "time"
+
+// TwNotificationDelivery tracks the failed email deliveries of a
+// notification. A notification without a row has never failed. After too
+// many failures DeadLetteredAt is set and the notification is no longer
+// retried until it is requeued.
+type TwNotificationDelivery struct {
+	ID             int        `gorm:"primary_key"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	NotificationId int        `json:"notification_id" gorm:"uniqueIndex"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error" gorm:"type:text"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at" gorm:"default:null"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" gorm:"default:null;index"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at" gorm:"default:null;index"`
+}
@@ -0,0 +1,60 @@
+package dms_models
+
+import "time"
+
+const (
+	DigestCadenceOff    = "off"
+	DigestCadenceHourly = "hourly"
+	DigestCadenceDaily  = "daily"
+)
+
+const (
+	// DigestPending is a digest claimed by a worker and being emailed.
+	DigestPending = "pending"
+	DigestSent    = "sent"
+	// DigestFailed is a digest whose email failed; its notifications were
+	// released for the next digest.
+	DigestFailed = "failed"
+)
+
+// TwNotificationDigestSetting is the digest preference of a user. While
+// Cadence is not "off", the user's notifications are not emailed one by one
+// but collected into a digest sent every hour, or every day at DailyAt in
+// Timezone. NextAt is when the next digest is due.
+type TwNotificationDigestSetting struct {
+	ID         int        `gorm:"primary_key"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserId     int        `json:"user_id" gorm:"uniqueIndex"`
+	Cadence    string     `json:"cadence" gorm:"type:varchar(16);default:off"`
+	DailyAt    string     `json:"daily_at" gorm:"type:varchar(5)"`
+	Timezone   string     `json:"timezone" gorm:"type:varchar(64)"`
+	NextAt     *time.Time `json:"next_at" gorm:"index"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+}
+
+// TwNotificationDigest is one digest email, carrying the notifications
+// listed in TwNotificationDigestItem.
+type TwNotificationDigest struct {
+	ID                int        `gorm:"primary_key"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	UserEmailId       int        `json:"user_email_id" gorm:"index"`
+	Email             string     `json:"email"`
+	Cadence           string     `json:"cadence" gorm:"type:varchar(16)"`
+	Status            string     `json:"status" gorm:"type:varchar(16);index"`
+	NotificationCount int        `json:"notification_count"`
+	Subject           string     `json:"subject"`
+	HTML              string     `json:"html" gorm:"type:longtext"`
+	Text              string     `json:"text" gorm:"type:longtext"`
+	Error             string     `json:"error" gorm:"type:text"`
+	SentAt            *time.Time `json:"sent_at"`
+}
+
+// TwNotificationDigestItem links a notification to the digest that carried
+// it.
+type TwNotificationDigestItem struct {
+	ID             int `gorm:"primary_key"`
+	DigestId       int `json:"digest_id" gorm:"index"`
+	NotificationId int `json:"notification_id" gorm:"uniqueIndex"`
+}
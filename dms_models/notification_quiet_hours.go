@@ -0,0 +1,16 @@
+package dms_models
+
+import "time"
+
+// TwNotificationQuietHours is the daily time range, in the user's timezone,
+// during which the notifications of a user are held back. Start after End
+// spans midnight, e.g. 22:00-07:00.
+type TwNotificationQuietHours struct {
+	ID        int       `gorm:"primary_key"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserId    int       `json:"user_id" gorm:"uniqueIndex"`
+	Start     string    `json:"start" gorm:"type:varchar(5)"`
+	End       string    `json:"end" gorm:"type:varchar(5)"`
+	Timezone  string    `json:"timezone" gorm:"type:varchar(64)"`
+}
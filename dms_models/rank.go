@@ -0,0 +1,25 @@
+package dms_models
+
+import "time"
+
+// TwScheduleRank holds the lexicographic sort key of a schedule inside its
+// board column. Moving a card rewrites only its own rank row.
+type TwScheduleRank struct {
+	ID            int       `gorm:"primary_key"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	ScheduleId    int       `json:"schedule_id" gorm:"uniqueIndex"`
+	BoardColumnId int       `json:"board_column_id" gorm:"index:idx_schedule_rank_column_key"`
+	RankKey       string    `json:"rank_key" gorm:"type:varchar(64);index:idx_schedule_rank_column_key"`
+}
+
+// TwBoardColumnRank holds the lexicographic sort key of a board column inside
+// its workspace.
+type TwBoardColumnRank struct {
+	ID            int       `gorm:"primary_key"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	BoardColumnId int       `json:"board_column_id" gorm:"uniqueIndex"`
+	WorkspaceId   int       `json:"workspace_id" gorm:"index:idx_board_column_rank_workspace_key"`
+	RankKey       string    `json:"rank_key" gorm:"type:varchar(64);index:idx_board_column_rank_workspace_key"`
+}
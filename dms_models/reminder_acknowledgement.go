@@ -0,0 +1,46 @@
+package dms_models
+
+import "time"
+
+const (
+	// The schedule creator was notified of the participants who did not
+	// acknowledge the reminder.
+	ReminderEscalationNotified = "notified"
+	// Nothing to escalate, e.g. every participant acknowledged the reminder.
+	ReminderEscalationSkipped = "skipped"
+)
+
+// TwReminderAcknowledgement records that a workspace user acknowledged a
+// reminder. Acknowledging a snoozed copy acknowledges the reminder it was
+// snoozed from.
+type TwReminderAcknowledgement struct {
+	ID              int       `gorm:"primary_key"`
+	CreatedAt       time.Time `json:"created_at"`
+	ReminderId      int       `json:"reminder_id" gorm:"uniqueIndex:idx_reminder_acknowledgement"`
+	WorkspaceUserID int       `json:"workspace_user_id" gorm:"uniqueIndex:idx_reminder_acknowledgement"`
+}
+
+// TwReminderSnooze links the "only me" copy a workspace user snoozed a
+// reminder into to the reminder it was snoozed from.
+type TwReminderSnooze struct {
+	ID               int       `gorm:"primary_key"`
+	CreatedAt        time.Time `json:"created_at"`
+	ReminderId       int       `json:"reminder_id" gorm:"index"`
+	SnoozeReminderId int       `json:"snooze_reminder_id" gorm:"uniqueIndex"`
+	WorkspaceUserID  int       `json:"workspace_user_id" gorm:"index"`
+	Until            time.Time `json:"until"`
+}
+
+// TwReminderEscalation records that a participant reminder of a high
+// priority schedule was checked for acknowledgements, so that the schedule
+// creator is notified at most once per reminder.
+type TwReminderEscalation struct {
+	ID             int       `gorm:"primary_key"`
+	CreatedAt      time.Time `json:"created_at"`
+	ReminderId     int       `json:"reminder_id" gorm:"uniqueIndex"`
+	Status         string    `json:"status" gorm:"type:varchar(16)"`
+	NotificationId int       `json:"notification_id"`
+	// Unacknowledged lists the emails of the participants who did not
+	// acknowledge the reminder, comma separated.
+	Unacknowledged string `json:"unacknowledged" gorm:"type:text"`
+}
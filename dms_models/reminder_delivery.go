@@ -0,0 +1,27 @@
+package dms_models
+
+import "time"
+
+const (
+	ReminderDeliverySent    = "sent"
+	ReminderDeliveryFailed  = "failed"
+	ReminderDeliverySkipped = "skipped"
+	// The recipient's settings moved the email past quiet hours or turned it
+	// into an in-app notification only.
+	ReminderDeliveryDeferred = "deferred"
+	ReminderDeliveryInApp    = "in_app"
+)
+
+// TwReminderDelivery records one attempt of the cron worker to deliver a
+// reminder to one recipient. Reminders skipped by the catch-up policy get a
+// single record without recipient.
+type TwReminderDelivery struct {
+	ID          int       `gorm:"primary_key"`
+	CreatedAt   time.Time `json:"created_at"`
+	ReminderId  int       `json:"reminder_id" gorm:"index"`
+	Recipient   string    `json:"recipient"`
+	Status      string    `json:"status" gorm:"type:varchar(16)"`
+	Error       string    `json:"error" gorm:"type:text"`
+	LateSeconds int       `json:"late_seconds"`
+	Worker      string    `json:"worker"`
+}
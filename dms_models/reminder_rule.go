@@ -0,0 +1,35 @@
+package dms_models
+
+import "time"
+
+const (
+	ReminderAnchorStart = "start"
+	ReminderAnchorEnd   = "end"
+)
+
+// TwReminderRule is a reminder relative to the start or end of a schedule,
+// e.g. 15 minutes before start. It produces one TwReminder per occurrence
+// of the schedule, recalculated whenever the schedule's times change.
+type TwReminderRule struct {
+	ID         int       `gorm:"primary_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ScheduleId int       `json:"schedule_id" gorm:"index"`
+	Anchor     string    `json:"anchor" gorm:"type:varchar(8)"`
+	// OffsetMinutes is how long before the anchor the reminder is due; a
+	// negative offset is after the anchor.
+	OffsetMinutes   int    `json:"offset_minutes"`
+	Method          string `json:"method"`
+	Type            string `json:"type"`
+	WorkspaceUserID int    `json:"workspace_user_id" gorm:"index"`
+}
+
+// TwReminderRuleOccurrence links a reminder produced by a rule to the
+// occurrence of the schedule it is for, identified by the occurrence's
+// original start.
+type TwReminderRuleOccurrence struct {
+	ID              int       `gorm:"primary_key"`
+	RuleId          int       `json:"rule_id" gorm:"uniqueIndex:idx_rule_occurrence"`
+	OccurrenceStart time.Time `json:"occurrence_start" gorm:"uniqueIndex:idx_rule_occurrence"`
+	ReminderId      int       `json:"reminder_id" gorm:"uniqueIndex"`
+}
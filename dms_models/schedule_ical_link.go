@@ -0,0 +1,15 @@
+package dms_models
+
+import "time"
+
+// TwScheduleICalLink remembers the iCalendar UID a schedule was imported from,
+// so that re-importing the same file updates the schedule instead of
+// duplicating it.
+type TwScheduleICalLink struct {
+	ID          int       `gorm:"primary_key"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	WorkspaceId int       `json:"workspace_id" gorm:"uniqueIndex:idx_ical_link_workspace_uid"`
+	UID         string    `json:"uid" gorm:"type:varchar(255);uniqueIndex:idx_ical_link_workspace_uid"`
+	ScheduleId  int       `json:"schedule_id" gorm:"index"`
+}
@@ -0,0 +1,70 @@
+package dms_models
+
+import "time"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// Given up after too many failed attempts, until it is redelivered.
+	WebhookDeliveryFailed = "failed"
+)
+
+// TwWebhookSubscription sends the events of a workspace of the listed types
+// to URL, signed with Secret.
+type TwWebhookSubscription struct {
+	ID          int        `gorm:"primary_key"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at" gorm:"default:null"`
+	WorkspaceId int        `json:"workspace_id" gorm:"index"`
+	URL         string     `json:"url" gorm:"type:varchar(2048)"`
+	// Secret is only returned when the subscription is created.
+	Secret string `json:"-" gorm:"type:varchar(128)"`
+	// EventTypes lists the event types sent, comma separated.
+	EventTypes string `json:"event_types" gorm:"type:text"`
+	IsActive   bool   `json:"is_active"`
+	CreatedBy  int    `json:"created_by"`
+}
+
+// TwWebhookEvent is the transactional outbox of the webhooks: the handlers
+// insert an event in the transaction of the change it describes, and the
+// cron worker fans it out to the subscriptions, setting DispatchedAt.
+type TwWebhookEvent struct {
+	ID           int        `gorm:"primary_key"`
+	CreatedAt    time.Time  `json:"created_at"`
+	WorkspaceId  int        `json:"workspace_id" gorm:"index"`
+	Type         string     `json:"type" gorm:"type:varchar(64)"`
+	Payload      string     `json:"payload" gorm:"type:mediumtext"`
+	DispatchedAt *time.Time `json:"dispatched_at" gorm:"default:null;index"`
+}
+
+// TwWebhookDelivery is the delivery of an event to a subscription, retried
+// with exponential backoff until it succeeds or fails MaxAttempts times.
+type TwWebhookDelivery struct {
+	ID             int        `gorm:"primary_key"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	EventId        int        `json:"event_id" gorm:"uniqueIndex:idx_webhook_delivery"`
+	SubscriptionId int        `json:"subscription_id" gorm:"uniqueIndex:idx_webhook_delivery;index"`
+	EventType      string     `json:"event_type" gorm:"type:varchar(64)"`
+	Status         string     `json:"status" gorm:"type:varchar(16);index"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error" gorm:"type:text"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" gorm:"default:null;index"`
+	DeliveredAt    *time.Time `json:"delivered_at" gorm:"default:null"`
+}
+
+// TwWebhookDeliveryAttempt logs one request of a webhook delivery.
+type TwWebhookDeliveryAttempt struct {
+	ID         int       `gorm:"primary_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	DeliveryId int       `json:"delivery_id" gorm:"index"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error" gorm:"type:text"`
+	// Response is the beginning of the response body.
+	Response   string `json:"response" gorm:"type:text"`
+	DurationMs int    `json:"duration_ms"`
+	Worker     string `json:"worker"`
+}
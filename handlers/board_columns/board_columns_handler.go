@@ -1,13 +1,32 @@
 package board_columns
 
 import (
+	"dbms/common"
+	"dbms/services/board"
+	"dbms/services/notification"
+	"dbms/services/policy"
+	"dbms/services/webhook"
 	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/dtos/core_dtos/board_columns_dtos"
 	"github.com/timewise-team/timewise-models/models"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// deletionLogAction marks the workspace log entries written when a board
+// column is deleted; they list the schedules archived with it.
+const deletionLogAction = "delete board column"
+
+// BoardColumnResponse is a board column with its card count and WIP limit.
+type BoardColumnResponse struct {
+	models.TwBoardColumn
+	board.WipStatus
+}
+
 // getBoardColumnsByWorkspace godoc
 // @Summary Get board columns by workspace
 // @Description Get board columns by workspace
@@ -15,7 +34,7 @@ import (
 // @Accept json
 // @Produce json
 // @Param workspace_id path int true "Workspace ID"
-// @Success 200 {array} models.TwBoardColumn
+// @Success 200 {array} BoardColumnResponse
 // @Router /dbms/v1/workspace/{workspace_id}/board_columns [get]
 func (h *BoardColumnsHandler) getBoardColumnsByWorkspace(c *fiber.Ctx) error {
 	// Parse the request
@@ -27,9 +46,9 @@ func (h *BoardColumnsHandler) getBoardColumnsByWorkspace(c *fiber.Ctx) error {
 	}
 	var boardColumns []models.TwBoardColumn
 	// Get the board columns
-	if result := h.DB.Where("workspace_id = ?", workspaceID).
-		Where("deleted_at IS NULL").
-		Order("position").
+	if result := h.DB.Where("tw_board_columns.workspace_id = ?", workspaceID).
+		Where("tw_board_columns.deleted_at IS NULL").
+		Scopes(board.ColumnOrder).
 		Find(&boardColumns); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": result.Error.Error(),
@@ -40,8 +59,23 @@ func (h *BoardColumnsHandler) getBoardColumnsByWorkspace(c *fiber.Ctx) error {
 			"message": "Failed to get board columns",
 		})
 	}
+	ids := make([]int, 0, len(boardColumns))
+	for _, boardColumn := range boardColumns {
+		ids = append(ids, boardColumn.ID)
+	}
+	wip, err := board.ColumnsWip(h.DB, ids)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	response := make([]BoardColumnResponse, 0, len(boardColumns))
+	for i, boardColumn := range boardColumns {
+		boardColumn.Position = i + 1
+		response = append(response, BoardColumnResponse{TwBoardColumn: boardColumn, WipStatus: wip[boardColumn.ID]})
+	}
 	// Return the response
-	return c.JSON(boardColumns)
+	return c.JSON(response)
 }
 
 // getBoardColumnById godoc
@@ -63,36 +97,538 @@ func (h *BoardColumnsHandler) getBoardColumnById(c *fiber.Ctx) error {
 		}
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
+	if err := setRankPosition(h.DB, &boardColumn); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
 	return c.JSON(boardColumn)
 }
 
+type DeleteBoardColumnResponse struct {
+	OnDelete    string `json:"on_delete"`
+	MovedTo     int    `json:"moved_to,omitempty"`
+	ScheduleIds []int  `json:"schedule_ids"`
+}
+
 // deleteBoardColumn godoc
 // @Summary Delete board column
-// @Description Delete board column
+// @Description Delete board column. on_delete decides what happens to its schedules: "move_to=<column_id>" appends them to another column of the same workspace, "archive" soft-deletes them and cancels their unsent reminders; they come back when the column is restored.
 // @Tags board_columns
 // @Accept json
 // @Produce json
 // @Param id path int true "Board column ID"
-// @Success 204
+// @Param on_delete query string true "move_to=<column_id> or archive"
+// @Param workspace_user_id query int true "Workspace user performing the deletion, recorded in the schedule logs"
+// @Param override_wip_limit query bool false "Move the schedules even if the move_to column reaches its WIP limit; each override is logged"
+// @Success 200 {object} DeleteBoardColumnResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 409 {object} fiber.Map "The move_to column has reached its WIP limit"
 // @Router /dbms/v1/board_columns/{id} [delete]
 func (h *BoardColumnsHandler) deleteBoardColumn(c *fiber.Ctx) error {
 	boardColumnId := c.Params("board_column_id")
+	onDelete := c.Query("on_delete")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
+	response := DeleteBoardColumnResponse{ScheduleIds: []int{}}
+	if onDelete == "archive" {
+		response.OnDelete = onDelete
+	} else if target, ok := strings.CutPrefix(onDelete, "move_to="); ok {
+		targetId, err := strconv.Atoi(target)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid move_to column ID",
+			})
+		}
+		response.OnDelete = "move_to"
+		response.MovedTo = targetId
+	} else {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "on_delete must be move_to=<column_id> or archive",
+		})
+	}
+
 	var boardColumn models.TwBoardColumn
 
 	// Retrieve the board column
-	if err := h.DB.Where("id = ?", boardColumnId).First(&boardColumn).Error; err != nil {
+	if err := h.DB.Where("id = ? AND deleted_at IS NULL", boardColumnId).First(&boardColumn).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).SendString("BoardColumn not found")
 		}
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Delete, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+	if response.MovedTo != 0 {
+		var target models.TwBoardColumn
+		if err := h.DB.Where("id = ? AND workspace_id = ? AND deleted_at IS NULL", response.MovedTo, boardColumn.WorkspaceId).First(&target).Error; err != nil || target.ID == boardColumn.ID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "move_to must be another board column of the same workspace",
+			})
+		}
+	}
+
+	// Dropping the column rank closes the gap: the remaining columns keep
+	// their order and positions are derived from it. The IDs of the cards
+	// archived with the column are recorded in the workspace log, which is how
+	// restore finds them.
+	now := time.Now().Truncate(time.Second)
+	overrideWip := c.QueryBool("override_wip_limit")
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockWorkspaceColumns(tx, boardColumn.WorkspaceId); err != nil {
+			return err
+		}
+		var schedules []models.TwSchedule
+		if err := tx.Where("tw_schedules.board_column_id = ? AND tw_schedules.is_deleted = false", boardColumn.ID).
+			Scopes(board.ScheduleOrder).
+			Find(&schedules).Error; err != nil {
+			return err
+		}
+
+		var logs []models.TwScheduleLog
+		for _, schedule := range schedules {
+			response.ScheduleIds = append(response.ScheduleIds, schedule.ID)
+			if response.MovedTo != 0 {
+				wip, overridden, err := board.EnforceWipLimit(tx, response.MovedTo, overrideWip)
+				if err != nil {
+					return err
+				}
+				if overridden {
+					logs = append(logs, *board.WipOverrideLog(schedule.ID, workspaceUserId, response.MovedTo, wip))
+				}
+				if err := tx.Model(&models.TwSchedule{}).Where("id = ?", schedule.ID).
+					UpdateColumns(map[string]interface{}{"board_column_id": response.MovedTo, "updated_at": now}).Error; err != nil {
+					return err
+				}
+				if _, err := board.AppendSchedule(tx, schedule.ID, response.MovedTo); err != nil {
+					return err
+				}
+				moveLog := models.TwScheduleLog{
+					ScheduleId:      schedule.ID,
+					WorkspaceUserId: workspaceUserId,
+					Action:          "update schedule",
+					FieldChanged:    "board_column_id",
+					OldValue:        strconv.Itoa(boardColumn.ID),
+					NewValue:        strconv.Itoa(response.MovedTo),
+					Description:     "Board column deleted",
+				}
+				logs = append(logs, moveLog)
+				schedule.BoardColumnId = response.MovedTo
+				schedule.UpdatedAt = &now
+				if err := webhook.Emit(tx, schedule.WorkspaceId, webhook.EventScheduleMoved, webhook.NewScheduleChange(schedule, workspaceUserId, []models.TwScheduleLog{moveLog})); err != nil {
+					return err
+				}
+			} else {
+				if err := tx.Model(&models.TwSchedule{}).Where("id = ?", schedule.ID).
+					UpdateColumns(map[string]interface{}{"is_deleted": true, "deleted_at": now, "updated_at": now}).Error; err != nil {
+					return err
+				}
+				if err := notification.SyncScheduleReminders(tx, schedule.ID, notification.WallClockNow()); err != nil {
+					return err
+				}
+				logs = append(logs, models.TwScheduleLog{
+					ScheduleId:      schedule.ID,
+					WorkspaceUserId: workspaceUserId,
+					Action:          "archive schedule",
+					Description:     "Board column deleted",
+				})
+				schedule.IsDeleted = true
+				schedule.DeletedAt = &now
+				schedule.UpdatedAt = &now
+				if err := webhook.Emit(tx, schedule.WorkspaceId, webhook.EventScheduleDeleted, webhook.NewScheduleChange(schedule, workspaceUserId, nil)); err != nil {
+					return err
+				}
+			}
+		}
+		if len(logs) > 0 {
+			if err := tx.Create(&logs).Error; err != nil {
+				return err
+			}
+		}
+		var archived, cancelled []int
+		if response.MovedTo == 0 {
+			archived = response.ScheduleIds
+			var err error
+			if cancelled, err = notification.CancelScheduleReminders(tx, archived); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(deletionLog(boardColumn, workspaceUserId, archived, cancelled)).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&boardColumn).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return board.RemoveColumnRank(tx, boardColumn.ID)
+	})
+	if err != nil {
+		return sendTransactionError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// restoreBoardColumn godoc
+// @Summary Restore board column
+// @Description Restore a deleted board column at the end of its workspace, together with the schedules archived when it was deleted and their cancelled reminders
+// @Tags board_columns
+// @Accept json
+// @Produce json
+// @Param id path int true "Board column ID"
+// @Param workspace_user_id query int true "Workspace user performing the restore, recorded in the schedule logs"
+// @Success 200 {object} models.TwBoardColumn
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/board_columns/{id}/restore [put]
+func (h *BoardColumnsHandler) restoreBoardColumn(c *fiber.Ctx) error {
+	boardColumnId := c.Params("board_column_id")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
+
+	var boardColumn models.TwBoardColumn
+	if err := h.DB.Where("id = ? AND deleted_at IS NOT NULL", boardColumnId).First(&boardColumn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString("Deleted BoardColumn not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	// Restoring takes the same grant as deleting.
+	if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Delete, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockWorkspaceColumns(tx, boardColumn.WorkspaceId); err != nil {
+			return err
+		}
+		scheduleIds, reminderIds, err := archivedItems(tx, boardColumn)
+		if err != nil {
+			return err
+		}
+		if len(scheduleIds) > 0 {
+			if err := tx.Model(&models.TwSchedule{}).Where("id IN (?)", scheduleIds).
+				UpdateColumns(map[string]interface{}{"is_deleted": false, "deleted_at": nil, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+			logs := make([]models.TwScheduleLog, 0, len(scheduleIds))
+			for _, scheduleId := range scheduleIds {
+				logs = append(logs, models.TwScheduleLog{
+					ScheduleId:      scheduleId,
+					WorkspaceUserId: workspaceUserId,
+					Action:          "restore schedule",
+					Description:     "Board column restored",
+				})
+			}
+			if err := tx.Create(&logs).Error; err != nil {
+				return err
+			}
+			if err := notification.RestoreReminders(tx, scheduleIds, reminderIds); err != nil {
+				return err
+			}
+			for _, scheduleId := range scheduleIds {
+				if err := notification.SyncScheduleReminders(tx, scheduleId, notification.WallClockNow()); err != nil {
+					return err
+				}
+			}
+			var restored []models.TwSchedule
+			if err := tx.Where("id IN (?)", scheduleIds).Order("id").Find(&restored).Error; err != nil {
+				return err
+			}
+			for _, schedule := range restored {
+				change := webhook.NewScheduleChange(schedule, workspaceUserId, nil)
+				change.Changes = []webhook.Change{{Field: "is_deleted", OldValue: "true", NewValue: "false"}}
+				if err := webhook.Emit(tx, schedule.WorkspaceId, webhook.EventScheduleUpdated, change); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Model(&boardColumn).UpdateColumns(map[string]interface{}{
+			"deleted_at": nil,
+			"updated_at": gorm.Expr("NOW()"),
+		}).Error; err != nil {
+			return err
+		}
+		_, err = board.AppendColumn(tx, boardColumn.ID, boardColumn.WorkspaceId)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	boardColumn.DeletedAt = time.Time{}
+	return c.JSON(boardColumn)
+}
+
+// setRankPosition replaces the legacy position of a board column with its
+// 1-based position in rank order. Deleted columns keep the stored one.
+func setRankPosition(db *gorm.DB, boardColumn *models.TwBoardColumn) error {
+	positions, err := board.ColumnPositions(db, boardColumn.WorkspaceId)
+	if err != nil {
+		return err
+	}
+	if position, ok := positions[boardColumn.ID]; ok {
+		boardColumn.Position = position
+	}
+	return nil
+}
+
+// deletionLog records the deletion of a board column in the workspace log,
+// with the IDs of the schedules archived together with it and, after a
+// semicolon, of their cancelled reminders.
+func deletionLog(boardColumn models.TwBoardColumn, workspaceUserId int, scheduleIds, reminderIds []int) *models.TwWorkspaceLog {
+	newValue := joinIds(scheduleIds)
+	if len(reminderIds) > 0 {
+		newValue += ";" + joinIds(reminderIds)
+	}
+	return &models.TwWorkspaceLog{
+		WorkspaceId:     boardColumn.WorkspaceId,
+		WorkspaceUserId: workspaceUserId,
+		Action:          deletionLogAction,
+		FieldChanged:    "board_column_id",
+		OldValue:        strconv.Itoa(boardColumn.ID),
+		NewValue:        newValue,
+		Description:     "Board column deleted; new_value lists the archived schedules, then their cancelled reminders after a semicolon",
+	}
+}
+
+func joinIds(ids []int) string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, strconv.Itoa(id))
+	}
+	return strings.Join(values, ",")
+}
+
+func splitIds(value string, logId int) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, id := range strings.Split(value, ",") {
+		parsed, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q in workspace log %d", id, logId)
+		}
+		ids = append(ids, parsed)
+	}
+	return ids, nil
+}
+
+// archivedItems returns the schedules archived by the last deletion of a
+// board column that are still archived in it, and the reminders cancelled
+// with them. Schedules deleted on their own are not part of the archive and
+// stay deleted.
+func archivedItems(tx *gorm.DB, boardColumn models.TwBoardColumn) ([]int, []int, error) {
+	var archive models.TwWorkspaceLog
+	err := tx.Where("workspace_id = ? AND action = ? AND field_changed = ? AND old_value = ? AND deleted_at IS NULL",
+		boardColumn.WorkspaceId, deletionLogAction, "board_column_id", strconv.Itoa(boardColumn.ID)).
+		Order("id DESC").
+		First(&archive).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && archive.NewValue == "") {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	scheduleValue, reminderValue, _ := strings.Cut(archive.NewValue, ";")
+	archived, err := splitIds(scheduleValue, archive.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	reminderIds, err := splitIds(reminderValue, archive.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var scheduleIds []int
+	err = tx.Model(&models.TwSchedule{}).
+		Where("id IN (?) AND board_column_id = ? AND is_deleted = true", archived, boardColumn.ID).
+		Order("id").
+		Pluck("id", &scheduleIds).Error
+	return scheduleIds, reminderIds, err
+}
+
+// sendTransactionError writes the response for an error returned from a
+// board column transaction; a full target column answers 409.
+func sendTransactionError(c *fiber.Ctx, err error) error {
+	var wipErr *board.WipLimitError
+	if errors.As(err, &wipErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":         "Board column has reached its WIP limit",
+			"board_column_id": wipErr.BoardColumnId,
+			"schedule_count":  wipErr.ScheduleCount,
+			"wip_limit":       wipErr.WipLimit,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+}
+
+type WipLimitRequest struct {
+	WipLimit *int `json:"wip_limit"`
+}
+
+// updateWipLimit godoc
+// @Summary Update board column WIP limit
+// @Description Set the maximum number of cards of a board column; a null or zero wip_limit removes the limit
+// @Tags board_columns
+// @Accept json
+// @Produce json
+// @Param id path int true "Board column ID"
+// @Param workspace_user_id query int true "Workspace user changing the limit"
+// @Param body body WipLimitRequest true "WIP limit request"
+// @Success 200 {object} board.WipStatus
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/board_columns/{id}/wip_limit [put]
+func (h *BoardColumnsHandler) updateWipLimit(c *fiber.Ctx) error {
+	boardColumnId := c.Params("board_column_id")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
+	var requestBody WipLimitRequest
+	if err := c.BodyParser(&requestBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	if requestBody.WipLimit != nil && *requestBody.WipLimit < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid wip_limit",
+		})
+	}
+	if requestBody.WipLimit != nil && *requestBody.WipLimit == 0 {
+		requestBody.WipLimit = nil
+	}
 
-	// Update the deleted_at field using gorm.Expr("NOW()")
-	if err := h.DB.Model(&boardColumn).Update("deleted_at", gorm.Expr("NOW()")).Error; err != nil {
+	var boardColumn models.TwBoardColumn
+	if err := h.DB.Where("id = ? AND deleted_at IS NULL", boardColumnId).First(&boardColumn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString("BoardColumn not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Update, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+	if err := board.SetWipLimit(h.DB, boardColumn.ID, requestBody.WipLimit); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	wip, err := board.ColumnWip(h.DB, boardColumn.ID)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
+	return c.JSON(wip)
+}
 
-	return c.SendStatus(fiber.StatusNoContent)
+type MoveBoardColumnRequest struct {
+	Position    int `json:"position"`
+	WorkspaceId int `json:"workspace_id"`
+}
+
+// moveBoardColumn godoc
+// @Summary Move board column
+// @Description Move a board column to a 1-based position of its workspace in a single transaction. Positions past the end append the column.
+// @Tags board_columns
+// @Accept json
+// @Produce json
+// @Param id path int true "Board column ID"
+// @Param workspace_user_id query int true "Workspace user moving the column"
+// @Param body body MoveBoardColumnRequest true "Move board column request"
+// @Success 200 {array} models.TwBoardColumn
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/board_columns/{id}/move [put]
+func (h *BoardColumnsHandler) moveBoardColumn(c *fiber.Ctx) error {
+	boardColumnId := c.Params("board_column_id")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
+	var requestBody MoveBoardColumnRequest
+	if err := c.BodyParser(&requestBody); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request body",
+		})
+	}
+	if requestBody.Position <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid position",
+		})
+	}
+
+	var boardColumn models.TwBoardColumn
+	err := h.DB.Where("id = ? AND deleted_at IS NULL", boardColumnId).First(&boardColumn).Error
+	if err == nil && requestBody.WorkspaceId != 0 && requestBody.WorkspaceId != boardColumn.WorkspaceId {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "BoardColumn not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Update, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+
+	var boardColumns []models.TwBoardColumn
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		locked, err := board.LockWorkspaceColumns(tx, boardColumn.WorkspaceId)
+		if err != nil {
+			return err
+		}
+		// The column may have been deleted since it was loaded.
+		found := false
+		for _, column := range locked {
+			found = found || column.ID == boardColumn.ID
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		if _, err := board.MoveColumn(tx, boardColumn.ID, boardColumn.WorkspaceId, requestBody.Position); err != nil {
+			return err
+		}
+		return tx.Where("tw_board_columns.workspace_id = ?", boardColumn.WorkspaceId).
+			Where("tw_board_columns.deleted_at IS NULL").
+			Scopes(board.ColumnOrder).
+			Find(&boardColumns).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "BoardColumn not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	for i := range boardColumns {
+		boardColumns[i].Position = i + 1
+	}
+	return c.JSON(boardColumns)
 }
 
 // updateBoardColumn godoc
@@ -102,11 +638,20 @@ func (h *BoardColumnsHandler) deleteBoardColumn(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param id path int true "Board column ID"
+// @Param workspace_user_id query int true "Workspace user renaming the column"
 // @Param body body board_columns_dtos.BoardColumnsRequest true "Update board column request"
 // @Success 200 {object} models.TwBoardColumn
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
 // @Router /dbms/v1/board_columns/{id} [put]
 func (h *BoardColumnsHandler) updateBoardColumn(c *fiber.Ctx) error {
 	boardColumnId := c.Params("board_column_id")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
 	var boardColumn models.TwBoardColumn
 	if err := h.DB.Where("id = ?", boardColumnId).First(&boardColumn).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -114,6 +659,9 @@ func (h *BoardColumnsHandler) updateBoardColumn(c *fiber.Ctx) error {
 		}
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Update, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
 	// Parse the request
 	var updatedBoardColumn models.TwBoardColumn
 	if err := c.BodyParser(&updatedBoardColumn); err != nil {
@@ -180,6 +728,9 @@ func (h *BoardColumnsHandler) getBoardColumnField(c *fiber.Ctx) error {
 			}
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
+		if err := setRankPosition(h.DB, &boardColumn); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
 		return c.JSON(fiber.Map{
 			"position": boardColumn.Position,
 		})
@@ -189,14 +740,17 @@ func (h *BoardColumnsHandler) getBoardColumnField(c *fiber.Ctx) error {
 
 // updateBoardColumnField godoc
 // @Summary Update board column field
-// @Description Update board column field
+// @Description Update the name of a board column, or move it to a 1-based position of its workspace
 // @Tags board_columns
 // @Accept json
 // @Produce json
 // @Param id path int true "Board column ID"
 // @Param field path string true "Field"
+// @Param workspace_user_id query int true "Workspace user changing the column"
 // @Param body body models.TwBoardColumn true "Update board column request"
 // @Success 200 {object} models.TwBoardColumn
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
 // @Router /dbms/v1/board_columns/{id}/{field} [put]
 func (h *BoardColumnsHandler) updateBoardColumnField(c *fiber.Ctx) error {
 	field := c.Params("field")
@@ -216,6 +770,12 @@ func (h *BoardColumnsHandler) updateBoardColumnField(c *fiber.Ctx) error {
 			"message": "Invalid field",
 		})
 	}
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
 	if field == "name" {
 		var boardColumn models.TwBoardColumn
 		if err := h.DB.Where("id = ?", boardColumnId).First(&boardColumn).Error; err != nil {
@@ -224,6 +784,9 @@ func (h *BoardColumnsHandler) updateBoardColumnField(c *fiber.Ctx) error {
 			}
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
+		if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Update, 0); err != nil {
+			return common.SendPolicyError(c, err)
+		}
 		var updateBoardColumnRequest models.TwBoardColumn
 		if err := c.BodyParser(&updateBoardColumnRequest); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -244,14 +807,32 @@ func (h *BoardColumnsHandler) updateBoardColumnField(c *fiber.Ctx) error {
 			}
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
+		if _, err := policy.Authorize(h.DB, workspaceUserId, boardColumn.WorkspaceId, policy.BoardColumn, policy.Update, 0); err != nil {
+			return common.SendPolicyError(c, err)
+		}
 		var updateBoardColumnRequest models.TwBoardColumn
 		if err := c.BodyParser(&updateBoardColumnRequest); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
-		if result := h.DB.Model(&boardColumn).Update("position", updateBoardColumnRequest.Position); result.Error != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+		if updateBoardColumnRequest.Position <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid position",
+			})
+		}
+		// Same as updatePosition: only the rank row of the column is written.
+		err := h.DB.Transaction(func(tx *gorm.DB) error {
+			if _, err := board.LockWorkspaceColumns(tx, boardColumn.WorkspaceId); err != nil {
+				return err
+			}
+			if _, err := board.MoveColumn(tx, boardColumn.ID, boardColumn.WorkspaceId, updateBoardColumnRequest.Position); err != nil {
+				return err
+			}
+			return setRankPosition(tx, &boardColumn)
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
 		return c.JSON(fiber.Map{
 			"position": boardColumn.Position,
@@ -266,10 +847,19 @@ func (h *BoardColumnsHandler) updateBoardColumnField(c *fiber.Ctx) error {
 // @Tags board_columns
 // @Accept json
 // @Produce json
+// @Param workspace_user_id query int true "Workspace user creating the column"
 // @Param body body board_columns_dtos.BoardColumnsRequest true "Create board column request"
 // @Success 200 {object} models.TwBoardColumn
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
 // @Router /dbms/v1/board_columns [post]
 func (h *BoardColumnsHandler) createBoardColumn(c *fiber.Ctx) error {
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
 	// Parse the request
 	var createBoardColumnRequest board_columns_dtos.BoardColumnsRequest
 	if err := c.BodyParser(&createBoardColumnRequest); err != nil {
@@ -277,14 +867,31 @@ func (h *BoardColumnsHandler) createBoardColumn(c *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, createBoardColumnRequest.WorkspaceId, policy.BoardColumn, policy.Create, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
 	var boardColumn = models.TwBoardColumn{
 		Name:        createBoardColumnRequest.Name,
 		Position:    createBoardColumnRequest.Position,
 		WorkspaceId: createBoardColumnRequest.WorkspaceId,
 	}
-	// Create the board column
-	if result := h.DB.Create(&boardColumn); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	// Create the board column; a zero position appends it
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockWorkspaceColumns(tx, boardColumn.WorkspaceId); err != nil {
+			return err
+		}
+		if err := tx.Create(&boardColumn).Error; err != nil {
+			return err
+		}
+		if boardColumn.Position <= 0 {
+			_, err := board.AppendColumn(tx, boardColumn.ID, boardColumn.WorkspaceId)
+			return err
+		}
+		_, err := board.MoveColumn(tx, boardColumn.ID, boardColumn.WorkspaceId, boardColumn.Position)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return c.JSON(boardColumn)
 }
@@ -318,7 +925,7 @@ type RequestBody struct {
 
 // updatePositionAfterDeletion godoc
 // @Summary Update position after deletion
-// @Description Update position after deletion
+// @Description Deprecated: board column order is kept by rank keys, so deleting a column leaves no gap to close. Kept for older clients; validates the request and changes nothing.
 // @Tags board_columns
 // @Accept json
 // @Produce json
@@ -333,34 +940,16 @@ func (h *BoardColumnsHandler) updatePositionAfterDeletion(c *fiber.Ctx) error {
 			"message": "Invalid request body",
 		})
 	}
-
-	// Lấy các tham số từ body
-	position := requestBody.Position
-	workspaceId := requestBody.WorkspaceId
-	if position == 0 {
+	if requestBody.Position == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid position",
 		})
 	}
-	if workspaceId == 0 {
+	if requestBody.WorkspaceId == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"message": "Invalid workspace ID",
 		})
 	}
-	// Update query to decrement the position of all columns with position greater than the specified position in the same workspace
-	err := h.DB.Model(&models.TwBoardColumn{}).
-		Where("position > ? AND workspace_id = ?", position, workspaceId).
-		Where("deleted_at IS NULL").
-		UpdateColumns(map[string]interface{}{
-			"position":   gorm.Expr("position - 1"),
-			"updated_at": gorm.Expr("NOW()"), // Cập nhật trường updated_at
-		}).Error
-
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": err.Error(),
-		})
-	}
 
 	return c.SendStatus(fiber.StatusOK)
 }
@@ -412,18 +1001,21 @@ func (h *BoardColumnsHandler) getRage(c *fiber.Ctx) error {
 	}
 
 	// Lấy các cột trong phạm vi vị trí và workspaceId
-	var columns []models.TwBoardColumn
-	if result := h.DB.Where("position >= ? AND position <= ? AND workspace_id = ?", position1, position2, workspaceId).
-		Where("deleted_at IS NULL").
-		Find(&columns); result.Error != nil {
+	var boardColumns []models.TwBoardColumn
+	if result := h.DB.Where("tw_board_columns.workspace_id = ?", workspaceId).
+		Where("tw_board_columns.deleted_at IS NULL").
+		Scopes(board.ColumnOrder).
+		Find(&boardColumns); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": result.Error.Error(),
 		})
 	}
-	if columns == nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"message": "Failed to get columns",
-		})
+	columns := []models.TwBoardColumn{}
+	for i := range boardColumns {
+		boardColumns[i].Position = i + 1
+		if boardColumns[i].Position >= position1 && boardColumns[i].Position <= position2 {
+			columns = append(columns, boardColumns[i])
+		}
 	}
 
 	// Trả về danh sách cột
@@ -436,10 +1028,19 @@ func (h *BoardColumnsHandler) getRage(c *fiber.Ctx) error {
 // @Tags board_columns
 // @Accept json
 // @Produce json
+// @Param workspace_user_id query int true "Workspace user moving the column"
 // @Param body body models.TwBoardColumn true "Update position request"
 // @Success 200
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
 // @Router /dbms/v1/board_columns/update_position/position [put]
 func (h *BoardColumnsHandler) updatePosition(c *fiber.Ctx) error {
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
 
 	var boardColumn models.TwBoardColumn
 	if err := c.BodyParser(&boardColumn); err != nil {
@@ -453,10 +1054,17 @@ func (h *BoardColumnsHandler) updatePosition(c *fiber.Ctx) error {
 			"message": "Failed to find the board column",
 		})
 	}
-	err := h.DB.Model(&oldBoardColumn).UpdateColumns(map[string]interface{}{
-		"position":   boardColumn.Position,
-		"updated_at": gorm.Expr("NOW()"),
-	}).Error
+	if _, err := policy.Authorize(h.DB, workspaceUserId, oldBoardColumn.WorkspaceId, policy.BoardColumn, policy.Update, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+	// Only the rank row of the moved column is written.
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockWorkspaceColumns(tx, oldBoardColumn.WorkspaceId); err != nil {
+			return err
+		}
+		_, err := board.MoveColumn(tx, oldBoardColumn.ID, oldBoardColumn.WorkspaceId, boardColumn.Position)
+		return err
+	})
 	if err != nil {
 
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
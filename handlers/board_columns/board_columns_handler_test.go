@@ -0,0 +1,416 @@
+package board_columns
+
+import (
+	"bytes"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"dbms/services/board"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testWorkspaceId = 1
+	testOwnerId     = 10
+	testMemberId    = 11
+)
+
+// newTestApp serves the board column routes, and the field routes under
+// /fields, over a fresh database holding a workspace owner and member, three
+// ranked board columns 1, 2 and 3, and two cards in column 1.
+func newTestApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwWorkspaceUser{},
+		&models.TwBoardColumn{},
+		&models.TwSchedule{},
+		&models.TwScheduleLog{},
+		&models.TwWorkspaceLog{},
+		&models.TwRecurrenceException{},
+		&models.TwReminder{},
+		&dms_models.TwScheduleRank{},
+		&dms_models.TwBoardColumnRank{},
+		&dms_models.TwBoardColumnLimit{},
+		&dms_models.TwReminderRule{},
+		&dms_models.TwReminderRuleOccurrence{},
+		&dms_models.TwWebhookSubscription{},
+		&dms_models.TwWebhookEvent{},
+	)
+	now := time.Now()
+	rows := []interface{}{
+		&models.TwWorkspaceUser{ID: testOwnerId, WorkspaceId: testWorkspaceId, Role: "owner", Status: "joined", IsActive: true},
+		&models.TwWorkspaceUser{ID: testMemberId, WorkspaceId: testWorkspaceId, Role: "member", Status: "joined", IsActive: true},
+	}
+	for id := 1; id <= 3; id++ {
+		rows = append(rows, &models.TwBoardColumn{ID: id, WorkspaceId: testWorkspaceId, Name: fmt.Sprintf("Column %d", id), Position: id})
+	}
+	for id := 1; id <= 2; id++ {
+		rows = append(rows, &models.TwSchedule{ID: id, WorkspaceId: testWorkspaceId, BoardColumnId: 1, Title: "Card",
+			StartTime: &now, EndTime: &now, CreatedBy: testOwnerId, CreatedAt: &now, UpdatedAt: &now, Position: id})
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for id := 1; id <= 3; id++ {
+			if _, err := board.AppendColumn(tx, id, testWorkspaceId); err != nil {
+				return err
+			}
+		}
+		for id := 1; id <= 2; id++ {
+			if _, err := board.AppendSchedule(tx, id, 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("seed ranks: %v", err)
+	}
+
+	app := fiber.New()
+	RegisterBoardColumnsHandler(app.Group("/board_columns"), db)
+	// The field routes are not registered by RegisterBoardColumnsHandler.
+	h := BoardColumnsHandler{DB: db}
+	app.Get("/fields/:board_column_id/:field", h.getBoardColumnField)
+	app.Put("/fields/:board_column_id/:field", h.updateBoardColumnField)
+	return app, db
+}
+
+func request(t *testing.T, app *fiber.App, method, target string, body interface{}) int {
+	t.Helper()
+	return requestJSON(t, app, method, target, body, nil)
+}
+
+// requestJSON sends a request and decodes a successful response into out,
+// unless it is nil.
+func requestJSON(t *testing.T, app *fiber.App, method, target string, body, out interface{}) int {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	if out != nil && resp.StatusCode == fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode %s %s: %v", method, target, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// snapshot captures the rows a board column change may touch.
+type snapshot struct {
+	Columns       []models.TwBoardColumn
+	Schedules     []models.TwSchedule
+	ScheduleRanks []dms_models.TwScheduleRank
+	ColumnRanks   []dms_models.TwBoardColumnRank
+	Logs          int64
+}
+
+func takeSnapshot(t *testing.T, db *gorm.DB) snapshot {
+	t.Helper()
+	var s snapshot
+	for _, dest := range []interface{}{&s.Columns, &s.Schedules, &s.ScheduleRanks, &s.ColumnRanks} {
+		if err := db.Order("id").Find(dest).Error; err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+	}
+	s.Logs = dbtest.Count(t, db, "tw_schedule_logs")
+	return s
+}
+
+func (s snapshot) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func TestBoardColumnChangesRollBackWhenRankWriteFails(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  string
+		method string
+		target string
+		body   interface{}
+	}{
+		{
+			name:   "delete moving cards to another column",
+			table:  "tw_schedule_ranks",
+			method: http.MethodDelete,
+			target: fmt.Sprintf("/board_columns/1?on_delete=move_to=2&workspace_user_id=%d", testOwnerId),
+		},
+		{
+			name:   "delete archiving cards",
+			table:  "tw_board_column_ranks",
+			method: http.MethodDelete,
+			target: fmt.Sprintf("/board_columns/1?on_delete=archive&workspace_user_id=%d", testOwnerId),
+		},
+		{
+			name:   "move",
+			table:  "tw_board_column_ranks",
+			method: http.MethodPut,
+			target: fmt.Sprintf("/board_columns/3/move?workspace_user_id=%d", testOwnerId),
+			body:   MoveBoardColumnRequest{Position: 1, WorkspaceId: testWorkspaceId},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			before := takeSnapshot(t, db)
+			dbtest.FailWrites(t, db, tt.table)
+
+			if status := request(t, app, tt.method, tt.target, tt.body); status != fiber.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d", status, fiber.StatusInternalServerError)
+			}
+			if after := takeSnapshot(t, db); after.String() != before.String() {
+				t.Errorf("the failed request changed the database\nbefore: %s\nafter:  %s", before, after)
+			}
+		})
+	}
+}
+
+func TestDeleteBoardColumnArchiveCancelsRemindersAndRestoreBringsCardsBack(t *testing.T) {
+	app, db := newTestApp(t)
+	now := time.Now()
+	// Card 2 was deleted on its own before the column; it must stay deleted.
+	if err := db.Model(&models.TwSchedule{}).Where("id = ?", 2).
+		UpdateColumns(map[string]interface{}{"is_deleted": true, "deleted_at": now}).Error; err != nil {
+		t.Fatalf("delete card: %v", err)
+	}
+	reminders := []interface{}{
+		&models.TwReminder{ID: 1, ScheduleId: 1, ReminderTime: now.Add(time.Hour), WorkspaceUserID: testOwnerId},
+		&models.TwReminder{ID: 2, ScheduleId: 1, ReminderTime: now.Add(-time.Hour), WorkspaceUserID: testOwnerId, IsSent: true},
+	}
+	for _, reminder := range reminders {
+		if err := db.Omit(clause.Associations).Create(reminder).Error; err != nil {
+			t.Fatalf("seed reminder: %v", err)
+		}
+	}
+
+	status := request(t, app, http.MethodDelete, fmt.Sprintf("/board_columns/1?on_delete=archive&workspace_user_id=%d", testOwnerId), nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("delete status = %d, want %d", status, fiber.StatusOK)
+	}
+	if count := dbtest.Count(t, db, "tw_reminders", "deleted_at IS NULL"); count != 1 {
+		t.Errorf("%d reminders left after archiving, want only the sent one", count)
+	}
+	if count := dbtest.Count(t, db, "tw_schedules", "is_deleted = true"); count != 2 {
+		t.Errorf("%d deleted cards after archiving, want 2", count)
+	}
+
+	status = request(t, app, http.MethodPut, fmt.Sprintf("/board_columns/1/restore?workspace_user_id=%d", testOwnerId), nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("restore status = %d, want %d", status, fiber.StatusOK)
+	}
+	var schedules []models.TwSchedule
+	if err := db.Order("id").Find(&schedules).Error; err != nil {
+		t.Fatalf("load cards: %v", err)
+	}
+	if schedules[0].IsDeleted || schedules[0].DeletedAt != nil {
+		t.Errorf("archived card 1 was not restored")
+	}
+	if !schedules[1].IsDeleted {
+		t.Errorf("card 2, deleted before the column, was restored")
+	}
+	var restored []models.TwReminder
+	if err := db.Where("deleted_at IS NULL").Order("id").Find(&restored).Error; err != nil {
+		t.Fatalf("load reminders: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("%d reminders after restoring, want the cancelled one back next to the sent one", len(restored))
+	}
+}
+
+func TestDeleteBoardColumnMoveToEnforcesWipLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		override   bool
+		wantStatus int
+		wantColumn int
+		wantLogs   int64
+	}{
+		{name: "rejected", wantStatus: fiber.StatusConflict, wantColumn: 1},
+		{name: "overridden", override: true, wantStatus: fiber.StatusOK, wantColumn: 2, wantLogs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			// Column 2 has room for exactly one more card.
+			wipLimit := 1
+			if err := board.SetWipLimit(db, 2, &wipLimit); err != nil {
+				t.Fatalf("set WIP limit: %v", err)
+			}
+
+			target := fmt.Sprintf("/board_columns/1?on_delete=move_to=2&workspace_user_id=%d&override_wip_limit=%v", testOwnerId, tt.override)
+			if status := request(t, app, http.MethodDelete, target, nil); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if count := dbtest.Count(t, db, "tw_schedules", "board_column_id = ?", tt.wantColumn); count != 2 {
+				t.Errorf("column %d has %d cards, want both", tt.wantColumn, count)
+			}
+			if count := dbtest.Count(t, db, "tw_schedule_logs", "action = ?", "override wip limit"); count != tt.wantLogs {
+				t.Errorf("%d WIP overrides logged, want %d", count, tt.wantLogs)
+			}
+		})
+	}
+}
+
+func TestDeleteAndRestoreBoardColumnEmitScheduleEvents(t *testing.T) {
+	app, db := newTestApp(t)
+	subscription := &dms_models.TwWebhookSubscription{WorkspaceId: testWorkspaceId, URL: "https://hooks.example.com", Secret: "0123456789abcdef",
+		EventTypes: "schedule.deleted,schedule.moved,schedule.updated", IsActive: true, CreatedBy: testOwnerId}
+	if err := db.Create(subscription).Error; err != nil {
+		t.Fatalf("seed subscription: %v", err)
+	}
+
+	steps := []struct {
+		name   string
+		method string
+		target string
+		want   map[string]int64
+	}{
+		{name: "delete moving the cards", method: http.MethodDelete, target: "/board_columns/1?on_delete=move_to=2&workspace_user_id=%d",
+			want: map[string]int64{"schedule.moved": 2}},
+		{name: "delete archiving the cards", method: http.MethodDelete, target: "/board_columns/2?on_delete=archive&workspace_user_id=%d",
+			want: map[string]int64{"schedule.moved": 2, "schedule.deleted": 2}},
+		{name: "restore", method: http.MethodPut, target: "/board_columns/2/restore?workspace_user_id=%d",
+			want: map[string]int64{"schedule.moved": 2, "schedule.deleted": 2, "schedule.updated": 2}},
+	}
+	for _, step := range steps {
+		if status := request(t, app, step.method, fmt.Sprintf(step.target, testOwnerId), nil); status != fiber.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", step.name, status, fiber.StatusOK)
+		}
+		for eventType, want := range step.want {
+			if count := dbtest.Count(t, db, "tw_webhook_events", "type = ?", eventType); count != want {
+				t.Errorf("%s: %d %s events, want %d", step.name, count, eventType, want)
+			}
+		}
+	}
+
+	var restored dms_models.TwWebhookEvent
+	if err := db.Where("type = ?", "schedule.updated").First(&restored).Error; err != nil {
+		t.Fatalf("load restore event: %v", err)
+	}
+	if !strings.Contains(restored.Payload, `"changes":[{"field":"is_deleted","old_value":"true","new_value":"false"}]`) {
+		t.Errorf("restore event payload %s does not record the is_deleted change", restored.Payload)
+	}
+}
+
+func TestBoardColumnPositionFollowsRankOrder(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	var moved map[string]int
+	target := fmt.Sprintf("/fields/3/position?workspace_user_id=%d", testOwnerId)
+	if status := requestJSON(t, app, http.MethodPut, target, map[string]int{"position": 1}, &moved); status != fiber.StatusOK {
+		t.Fatalf("update position status = %d, want %d", status, fiber.StatusOK)
+	}
+	if moved["position"] != 1 {
+		t.Errorf("update position answered %v, want position 1", moved)
+	}
+
+	var columns []BoardColumnResponse
+	if status := requestJSON(t, app, http.MethodGet, fmt.Sprintf("/board_columns/workspace/%d", testWorkspaceId), nil, &columns); status != fiber.StatusOK {
+		t.Fatalf("list status = %d, want %d", status, fiber.StatusOK)
+	}
+	var order []int
+	for _, column := range columns {
+		order = append(order, column.ID)
+	}
+	if fmt.Sprint(order) != "[3 1 2]" {
+		t.Fatalf("columns in order %v, want [3 1 2]", order)
+	}
+
+	for id, want := range map[int]int{3: 1, 1: 2, 2: 3} {
+		var column models.TwBoardColumn
+		if status := requestJSON(t, app, http.MethodGet, fmt.Sprintf("/board_columns/%d", id), nil, &column); status != fiber.StatusOK {
+			t.Fatalf("get column %d status = %d, want %d", id, status, fiber.StatusOK)
+		}
+		var field map[string]int
+		if status := requestJSON(t, app, http.MethodGet, fmt.Sprintf("/fields/%d/position", id), nil, &field); status != fiber.StatusOK {
+			t.Fatalf("get position of column %d status = %d, want %d", id, status, fiber.StatusOK)
+		}
+		if column.Position != want || field["position"] != want {
+			t.Errorf("column %d at position %d, position field %d, want %d", id, column.Position, field["position"], want)
+		}
+	}
+}
+
+func TestBoardColumnChangesRequireColumnGrant(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   interface{}
+	}{
+		{name: "delete", method: http.MethodDelete, target: "/board_columns/1?on_delete=archive&workspace_user_id=%d"},
+		{name: "move", method: http.MethodPut, target: "/board_columns/3/move?workspace_user_id=%d",
+			body: MoveBoardColumnRequest{Position: 1, WorkspaceId: testWorkspaceId}},
+		{name: "WIP limit", method: http.MethodPut, target: "/board_columns/1/wip_limit?workspace_user_id=%d",
+			body: map[string]interface{}{"wip_limit": 1}},
+		{name: "create", method: http.MethodPost, target: "/board_columns?workspace_user_id=%d",
+			body: map[string]interface{}{"name": "Review", "workspace_id": testWorkspaceId}},
+		{name: "rename", method: http.MethodPut, target: "/board_columns/1?workspace_user_id=%d",
+			body: map[string]interface{}{"name": "Backlog"}},
+		{name: "rename field", method: http.MethodPut, target: "/fields/1/name?workspace_user_id=%d",
+			body: map[string]interface{}{"name": "Backlog"}},
+		{name: "position field", method: http.MethodPut, target: "/fields/3/position?workspace_user_id=%d",
+			body: map[string]interface{}{"position": 1}},
+		{name: "update position", method: http.MethodPut, target: "/board_columns/update_position/position?workspace_user_id=%d",
+			body: map[string]interface{}{"id": 3, "position": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, db := newTestApp(t)
+			before := takeSnapshot(t, db)
+
+			if status := request(t, app, tt.method, fmt.Sprintf(tt.target, testMemberId), tt.body); status != fiber.StatusForbidden {
+				t.Fatalf("member status = %d, want %d", status, fiber.StatusForbidden)
+			}
+			if after := takeSnapshot(t, db); after.String() != before.String() {
+				t.Errorf("the denied request changed the database\nbefore: %s\nafter:  %s", before, after)
+			}
+			if count := dbtest.Count(t, db, "tw_board_column_limits"); count != 0 {
+				t.Errorf("%d WIP limits set by the denied request", count)
+			}
+			if status := request(t, app, tt.method, fmt.Sprintf(tt.target, testOwnerId), tt.body); status != fiber.StatusOK {
+				t.Errorf("owner status = %d, want %d", status, fiber.StatusOK)
+			}
+		})
+	}
+}
+
+func TestRestoreBoardColumnRequiresColumnGrant(t *testing.T) {
+	app, db := newTestApp(t)
+	if status := request(t, app, http.MethodDelete, fmt.Sprintf("/board_columns/1?on_delete=archive&workspace_user_id=%d", testOwnerId), nil); status != fiber.StatusOK {
+		t.Fatalf("delete status = %d, want %d", status, fiber.StatusOK)
+	}
+
+	if status := request(t, app, http.MethodPut, fmt.Sprintf("/board_columns/1/restore?workspace_user_id=%d", testMemberId), nil); status != fiber.StatusForbidden {
+		t.Fatalf("member restore status = %d, want %d", status, fiber.StatusForbidden)
+	}
+	if count := dbtest.Count(t, db, "tw_board_columns", "id = ? AND deleted_at IS NULL", 1); count != 0 {
+		t.Errorf("the denied restore brought the column back")
+	}
+}
@@ -21,6 +21,9 @@ func RegisterBoardColumnsHandler(router fiber.Router, db *gorm.DB) {
 	router.Get("/:board_column_id/workspace/:workspace_id", boardColumnsHandler.getBoardColumnById)
 	router.Post("", boardColumnsHandler.createBoardColumn)
 	router.Put("/:board_column_id", boardColumnsHandler.updateBoardColumn)
+	router.Put("/:board_column_id/move", boardColumnsHandler.moveBoardColumn)
+	router.Put("/:board_column_id/restore", boardColumnsHandler.restoreBoardColumn)
+	router.Put("/:board_column_id/wip_limit", boardColumnsHandler.updateWipLimit)
 	router.Delete("/:board_column_id", boardColumnsHandler.deleteBoardColumn)
 	//router.Get("/:board_column_id/:field", boardColumnsHandler.getBoardColumnField)
 	//router.Put("/:board_column_id/:field", boardColumnsHandler.updateBoardColumnField)
@@ -1,9 +1,13 @@
 package document
 
 import (
+	"dbms/common"
+	"dbms/services/policy"
+	"dbms/services/webhook"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/dtos/core_dtos/comment_dtos"
 	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
 	"log"
 )
 
@@ -119,8 +123,17 @@ func (h *CommentHandler) createComment(c *fiber.Ctx) error {
 	if err := c.BodyParser(&comment); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
-	if result := h.DB.Create(&comment); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	if _, err := policy.AuthorizeForSchedule(h.DB, comment.WorkspaceUserId, comment.ScheduleId, policy.Comment, policy.Create, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return webhook.EmitForSchedule(tx, comment.ScheduleId, webhook.EventCommentCreated, webhook.NewComment(comment))
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return c.JSON(comment)
 }
@@ -128,6 +141,12 @@ func (h *CommentHandler) createComment(c *fiber.Ctx) error {
 func (h *CommentHandler) updateComment(c *fiber.Ctx) error {
 	var comment models.TwComment
 	commentId := c.Params("id")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
 	result := h.DB.Where("id = ?", commentId).Find(&comment)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -140,10 +159,16 @@ func (h *CommentHandler) updateComment(c *fiber.Ctx) error {
 			"message": "record not found",
 		})
 	}
+	if _, err := policy.AuthorizeForSchedule(h.DB, workspaceUserId, comment.ScheduleId, policy.Comment, policy.Update, comment.WorkspaceUserId); err != nil {
+		return common.SendPolicyError(c, err)
+	}
 
+	// The body may not move the comment or change its author.
+	id, scheduleId, author := comment.ID, comment.ScheduleId, comment.WorkspaceUserId
 	if err := c.BodyParser(&comment); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
+	comment.ID, comment.ScheduleId, comment.WorkspaceUserId = id, scheduleId, author
 
 	if result := h.DB.Omit("deleted_at").Save(&comment); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
@@ -165,6 +190,12 @@ func (h *CommentHandler) updateComment(c *fiber.Ctx) error {
 func (h *CommentHandler) deleteComment(c *fiber.Ctx) error {
 	var comment models.TwComment
 	commentId := c.Params("id")
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
 	result := h.DB.Where("id = ?", commentId).Find(&comment)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -177,10 +208,16 @@ func (h *CommentHandler) deleteComment(c *fiber.Ctx) error {
 			"message": "record not found",
 		})
 	}
+	if _, err := policy.AuthorizeForSchedule(h.DB, workspaceUserId, comment.ScheduleId, policy.Comment, policy.Delete, comment.WorkspaceUserId); err != nil {
+		return common.SendPolicyError(c, err)
+	}
 
+	// The body may not move the comment or change its author.
+	id, scheduleId, author := comment.ID, comment.ScheduleId, comment.WorkspaceUserId
 	if err := c.BodyParser(&comment); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
+	comment.ID, comment.ScheduleId, comment.WorkspaceUserId = id, scheduleId, author
 
 	if result := h.DB.Save(&comment); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
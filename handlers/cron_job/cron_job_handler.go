@@ -0,0 +1,112 @@
+package cron_job
+
+import (
+	"dbms/services/leader"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	minLeaseTTL = 5 * time.Second
+	maxLeaseTTL = 10 * time.Minute
+)
+
+type CronJobHandler struct {
+	DB *gorm.DB
+}
+
+type AcquireLeaderRequest struct {
+	Holder     string `json:"holder"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+type AcquireLeaderResponse struct {
+	Leader bool          `json:"leader"`
+	Lease  leader.Status `json:"lease"`
+}
+
+// getLeader godoc
+// @Summary Get the current cron leader
+// @Description Get the cron worker that currently runs the jobs. active is false when the lease expired and no worker holds it
+// @Tags cron
+// @Produce json
+// @Success 200 {object} leader.Status
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/cron/leader [get]
+func (h *CronJobHandler) getLeader(c *fiber.Ctx) error {
+	status, err := leader.Current(h.DB, leader.CronLease)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "No cron worker has been elected yet",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(status)
+}
+
+// acquireLeader godoc
+// @Summary Acquire or renew the cron leadership
+// @Description Take the cron lease for a worker, or renew it when the worker already holds it. Used by the cron workers in http mode
+// @Tags cron
+// @Accept json
+// @Produce json
+// @Param body body AcquireLeaderRequest true "Worker name and lease duration"
+// @Success 200 {object} AcquireLeaderResponse
+// @Failure 400 {object} fiber.Map
+// @Router /dbms/v1/cron/leader [put]
+func (h *CronJobHandler) acquireLeader(c *fiber.Ctx) error {
+	var request AcquireLeaderRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if request.Holder == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "holder is required",
+		})
+	}
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+	if ttl < minLeaseTTL || ttl > maxLeaseTTL {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "ttl_seconds must be between 5 and 600",
+		})
+	}
+
+	status, isLeader, err := leader.Acquire(h.DB, leader.CronLease, request.Holder, ttl)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(AcquireLeaderResponse{Leader: isLeader, Lease: status})
+}
+
+// releaseLeader godoc
+// @Summary Release the cron leadership
+// @Description Give up the cron lease if the given worker holds it
+// @Tags cron
+// @Produce json
+// @Param holder query string true "Worker name"
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/cron/leader [delete]
+func (h *CronJobHandler) releaseLeader(c *fiber.Ctx) error {
+	holder := c.Query("holder")
+	if holder == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "holder is required",
+		})
+	}
+	if err := leader.Release(h.DB, leader.CronLease, holder); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"message": "Lease released"})
+}
@@ -0,0 +1,249 @@
+package cron_job
+
+import (
+	"dbms/dms_models"
+	"dbms/services/cronjob"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	defaultRunsLimit = 50
+	maxRunsLimit     = 500
+)
+
+// getJobs godoc
+// @Summary List cron jobs
+// @Description List the cron jobs with their schedule, pause state, pending trigger and latest run
+// @Tags cron
+// @Produce json
+// @Success 200 {array} cronjob.Job
+// @Router /dbms/v1/cron/jobs [get]
+func (h *CronJobHandler) getJobs(c *fiber.Ctx) error {
+	jobs, err := cronjob.List(h.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(jobs)
+}
+
+// registerJobs godoc
+// @Summary Register cron jobs
+// @Description Record the jobs a cron worker schedules, keeping their pause state. Used by the cron workers in http mode
+// @Tags cron
+// @Accept json
+// @Produce json
+// @Param body body []dms_models.TwCronJob true "Jobs (name and spec)"
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/cron/jobs [put]
+func (h *CronJobHandler) registerJobs(c *fiber.Ctx) error {
+	var jobs []dms_models.TwCronJob
+	if err := c.BodyParser(&jobs); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	for i := range jobs {
+		if jobs[i].Name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "name is required",
+			})
+		}
+		jobs[i].Paused = false
+		jobs[i].TriggerRequestedAt = nil
+	}
+	if err := cronjob.Register(h.DB, jobs); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"message": "Jobs registered"})
+}
+
+// getJobRuns godoc
+// @Summary List cron job runs
+// @Description List the latest runs of the cron jobs, newest first
+// @Tags cron
+// @Produce json
+// @Param job query string false "Job name"
+// @Param limit query int false "Maximum number of runs (default 50, at most 500)"
+// @Success 200 {array} dms_models.TwCronJobRun
+// @Router /dbms/v1/cron/jobs/runs [get]
+func (h *CronJobHandler) getJobRuns(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultRunsLimit)
+	if limit <= 0 || limit > maxRunsLimit {
+		limit = maxRunsLimit
+	}
+	runs, err := cronjob.Runs(h.DB, c.Query("job"), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(runs)
+}
+
+// createJobRun godoc
+// @Summary Record the start of a cron job run
+// @Description Used by the cron workers in http mode
+// @Tags cron
+// @Accept json
+// @Produce json
+// @Param body body dms_models.TwCronJobRun true "Run"
+// @Success 200 {object} dms_models.TwCronJobRun
+// @Router /dbms/v1/cron/jobs/runs [post]
+func (h *CronJobHandler) createJobRun(c *fiber.Ctx) error {
+	var run dms_models.TwCronJobRun
+	if err := c.BodyParser(&run); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	run.ID = 0
+	if err := cronjob.StartRun(h.DB, &run); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(run)
+}
+
+// finishJobRun godoc
+// @Summary Record the outcome of a cron job run
+// @Description Used by the cron workers in http mode
+// @Tags cron
+// @Accept json
+// @Produce json
+// @Param run_id path int true "Run ID"
+// @Param body body dms_models.TwCronJobRun true "Run"
+// @Success 200 {object} dms_models.TwCronJobRun
+// @Router /dbms/v1/cron/jobs/runs/{run_id} [put]
+func (h *CronJobHandler) finishJobRun(c *fiber.Ctx) error {
+	runId, err := c.ParamsInt("run_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid run_id",
+		})
+	}
+	var run dms_models.TwCronJobRun
+	if err := c.BodyParser(&run); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	run.ID = runId
+	if err := cronjob.FinishRun(h.DB, &run); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(run)
+}
+
+// pruneJobRuns godoc
+// @Summary Delete old cron job runs
+// @Description Delete the finished runs started before the given time
+// @Tags cron
+// @Produce json
+// @Param before query string true "RFC3339 time"
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/cron/jobs/runs [delete]
+func (h *CronJobHandler) pruneJobRuns(c *fiber.Ctx) error {
+	before, err := time.Parse(time.RFC3339, c.Query("before"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid before, expected an RFC3339 time",
+		})
+	}
+	deleted, err := cronjob.PruneRuns(h.DB, before)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"deleted": deleted})
+}
+
+// triggerJob godoc
+// @Summary Run a cron job now
+// @Description Ask the leading cron worker to run the job on its next poll, even when the job is paused
+// @Tags cron
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} dms_models.TwCronJob
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/cron/jobs/{name}/trigger [post]
+func (h *CronJobHandler) triggerJob(c *fiber.Ctx) error {
+	job, err := cronjob.RequestTrigger(h.DB, c.Params("name"))
+	return sendJob(c, job, err)
+}
+
+// claimJobTrigger godoc
+// @Summary Claim a requested cron job run
+// @Description Clear a requested run so that only one worker performs it; 409 when there is none. Used by the cron workers in http mode
+// @Tags cron
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} fiber.Map
+// @Failure 409 {object} fiber.Map
+// @Router /dbms/v1/cron/jobs/{name}/trigger/claim [put]
+func (h *CronJobHandler) claimJobTrigger(c *fiber.Ctx) error {
+	claimed, err := cronjob.ClaimTrigger(h.DB, c.Params("name"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if !claimed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "No run requested",
+		})
+	}
+	return c.JSON(fiber.Map{"claimed": true})
+}
+
+// pauseJob godoc
+// @Summary Pause a cron job
+// @Description Stop the scheduled runs of a job until it is resumed. Runs in progress finish
+// @Tags cron
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} dms_models.TwCronJob
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/cron/jobs/{name}/pause [put]
+func (h *CronJobHandler) pauseJob(c *fiber.Ctx) error {
+	job, err := cronjob.SetPaused(h.DB, c.Params("name"), true)
+	return sendJob(c, job, err)
+}
+
+// resumeJob godoc
+// @Summary Resume a cron job
+// @Description Resume the scheduled runs of a paused job
+// @Tags cron
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} dms_models.TwCronJob
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/cron/jobs/{name}/resume [put]
+func (h *CronJobHandler) resumeJob(c *fiber.Ctx) error {
+	job, err := cronjob.SetPaused(h.DB, c.Params("name"), false)
+	return sendJob(c, job, err)
+}
+
+func sendJob(c *fiber.Ctx, job dms_models.TwCronJob, err error) error {
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Cron job not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(job)
+}
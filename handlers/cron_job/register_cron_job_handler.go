@@ -0,0 +1,25 @@
+package cron_job
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterCronJobHandler(router fiber.Router, db *gorm.DB) {
+	cronJobHandler := CronJobHandler{
+		DB: db,
+	}
+	router.Get("/leader", cronJobHandler.getLeader)
+	router.Put("/leader", cronJobHandler.acquireLeader)
+	router.Delete("/leader", cronJobHandler.releaseLeader)
+	router.Get("/jobs", cronJobHandler.getJobs)
+	router.Put("/jobs", cronJobHandler.registerJobs)
+	router.Get("/jobs/runs", cronJobHandler.getJobRuns)
+	router.Post("/jobs/runs", cronJobHandler.createJobRun)
+	router.Delete("/jobs/runs", cronJobHandler.pruneJobRuns)
+	router.Put("/jobs/runs/:run_id", cronJobHandler.finishJobRun)
+	router.Post("/jobs/:name/trigger", cronJobHandler.triggerJob)
+	router.Put("/jobs/:name/trigger/claim", cronJobHandler.claimJobTrigger)
+	router.Put("/jobs/:name/pause", cronJobHandler.pauseJob)
+	router.Put("/jobs/:name/resume", cronJobHandler.resumeJob)
+}
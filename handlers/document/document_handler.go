@@ -1,6 +1,9 @@
 package document
 
 import (
+	"dbms/common"
+	"dbms/services/policy"
+	"dbms/services/webhook"
 	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
@@ -97,13 +100,23 @@ func (h *DocumentHandler) getDocumentsByScheduleID(c *fiber.Ctx) error {
 // @Produce json
 // @Param document body models.TwDocument true "Document object"
 // @Success 200 {object} models.TwDocument
+// @Failure 403 {object} fiber.Map
 // @Router /dbms/v1/document/upload [post]
 func (h *DocumentHandler) createDocument(c *fiber.Ctx) error {
 	var document models.TwDocument
 	if err := c.BodyParser(&document); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
-	if err := h.DB.Create(&document).Error; err != nil {
+	if _, err := policy.AuthorizeForSchedule(h.DB, document.UploadedBy, document.ScheduleId, policy.Document, policy.Create, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&document).Error; err != nil {
+			return err
+		}
+		return webhook.EmitForSchedule(tx, document.ScheduleId, webhook.EventDocumentUploaded, webhook.NewDocument(document))
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return c.JSON(document)
@@ -117,17 +130,32 @@ func (h *DocumentHandler) createDocument(c *fiber.Ctx) error {
 // @Produce json
 // @Param scheduleId query string true "Schedule ID associated with the file"
 // @Param fileName query string true "Name of the file to delete"
+// @Param workspace_user_id query int true "Workspace user deleting the file"
 // @Success 204 "No Content"
+// @Failure 403 {object} fiber.Map
 // @Router /dbms/v1/document [delete]
 func (h *DocumentHandler) deleteDocument(c *fiber.Ctx) error {
-	scheduleID := c.Query("scheduleId")
-	if scheduleID == "" {
+	scheduleID := c.QueryInt("scheduleId")
+	if scheduleID == 0 {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 	fileName := c.Query("fileName")
 	if fileName == "" {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+	var documents []models.TwDocument
+	if err := h.DB.Where("schedule_id = ? AND file_name = ?", scheduleID, fileName).Find(&documents).Error; err != nil {
+		return fmt.Errorf("failed to load document from database: %v", err)
+	}
+	for _, document := range documents {
+		if _, err := policy.AuthorizeForSchedule(h.DB, workspaceUserId, scheduleID, policy.Document, policy.Delete, document.UploadedBy); err != nil {
+			return common.SendPolicyError(c, err)
+		}
+	}
 	if err := h.DB.Where("schedule_id = ? AND file_name = ?", scheduleID, fileName).Delete(&models.TwDocument{}).Error; err != nil {
 		return fmt.Errorf("failed to delete document from database: %v", err)
 	}
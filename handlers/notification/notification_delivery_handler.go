@@ -0,0 +1,210 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/services/notification"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	maxDueNotifications = 500
+	defaultDeadLetters  = 100
+	maxDeadLetters      = 500
+)
+
+type DeliveryFailureRequest struct {
+	Error string `json:"error"`
+}
+
+type RequeueRequest struct {
+	NotificationIds []int `json:"notification_ids"`
+}
+
+// GetDueNotifications godoc
+// @Summary Get notifications due for delivery
+// @Description Get the unsent notifications whose time has come, leaving out those waiting for a retry and dead-lettered ones (used by the cron worker)
+// @Tags notification
+// @Produce json
+// @Param limit query int false "Maximum number of notifications (default 500)"
+// @Success 200 {array} models.TwNotifications
+// @Router /dbms/v1/notification/due [get]
+func (h *NotificationHandler) GetDueNotifications(ctx *fiber.Ctx) error {
+	limit := ctx.QueryInt("limit", maxDueNotifications)
+	if limit <= 0 || limit > maxDueNotifications {
+		limit = maxDueNotifications
+	}
+	notifications, err := notification.Due(h.DB, time.Now(), limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(notifications)
+}
+
+// RecordDeliveryFailure godoc
+// @Summary Record a failed notification delivery
+// @Description Count a failed delivery and schedule the next attempt with exponential backoff, or dead-letter the notification after too many failures
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param notification_id path int true "Notification ID"
+// @Param body body DeliveryFailureRequest true "Failure reason"
+// @Success 200 {object} dms_models.TwNotificationDelivery
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/{notification_id}/failure [post]
+func (h *NotificationHandler) RecordDeliveryFailure(ctx *fiber.Ctx) error {
+	notificationID, err := ctx.ParamsInt("notification_id")
+	if err != nil || notificationID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Notification ID is required",
+		})
+	}
+	var request DeliveryFailureRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	delivery, err := notification.RecordFailure(h.DB, notificationID, request.Error, config.LoadNotificationConfig())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Notification not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(delivery)
+}
+
+// GetDeadLetters godoc
+// @Summary Get dead-lettered notifications
+// @Description Get the notifications that failed too many times and are no longer retried, most recent first
+// @Tags notification
+// @Produce json
+// @Param limit query int false "Maximum number of notifications (default 100)"
+// @Success 200 {array} notification.DeadLetter
+// @Router /dbms/v1/notification/dead_letter [get]
+func (h *NotificationHandler) GetDeadLetters(ctx *fiber.Ctx) error {
+	limit := ctx.QueryInt("limit", defaultDeadLetters)
+	if limit <= 0 || limit > maxDeadLetters {
+		limit = maxDeadLetters
+	}
+	deadLetters, err := notification.DeadLetters(h.DB, limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(deadLetters)
+}
+
+// RequeueDeadLetters godoc
+// @Summary Requeue dead-lettered notifications
+// @Description Give dead-lettered notifications a fresh set of delivery attempts. An empty list requeues all of them
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param body body RequeueRequest true "Notification IDs"
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/notification/dead_letter/requeue [post]
+func (h *NotificationHandler) RequeueDeadLetters(ctx *fiber.Ctx) error {
+	var request RequeueRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+	requeued, err := notification.Requeue(h.DB, request.NotificationIds)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(fiber.Map{"requeued": requeued})
+}
+
+// GetDeliveryPlan godoc
+// @Summary Get the delivery plan of a notification
+// @Description Apply the recipient's notification settings and quiet hours to a notification of the given type (used by the cron worker)
+// @Tags notification
+// @Produce json
+// @Param user_email_id query int true "Recipient user email ID"
+// @Param type query string true "Notification type"
+// @Param at query string false "RFC3339 time the notification is due (default now)"
+// @Success 200 {object} notification.Plan
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/plan [get]
+func (h *NotificationHandler) GetDeliveryPlan(ctx *fiber.Ctx) error {
+	userEmailId := ctx.QueryInt("user_email_id")
+	if userEmailId <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_email_id is required",
+		})
+	}
+	at := time.Now()
+	if value := ctx.Query("at"); value != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid at, expected an RFC3339 time",
+			})
+		}
+	}
+	plan, err := notification.PlanForUserEmail(h.DB, userEmailId, ctx.Query("type"), at)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "User email not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(plan)
+}
+
+type DeferRequest struct {
+	Until time.Time `json:"until"`
+}
+
+// DeferNotification godoc
+// @Summary Defer a notification
+// @Description Move the delivery of an unsent notification to a later time, e.g. past the recipient's quiet hours (used by the cron worker)
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param notification_id path int true "Notification ID"
+// @Param body body DeferRequest true "New delivery time"
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/notification/{notification_id}/defer [put]
+func (h *NotificationHandler) DeferNotification(ctx *fiber.Ctx) error {
+	notificationID, err := ctx.ParamsInt("notification_id")
+	if err != nil || notificationID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Notification ID is required",
+		})
+	}
+	var request DeferRequest
+	if err := ctx.BodyParser(&request); err != nil || request.Until.IsZero() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "until is required",
+		})
+	}
+	if err := notification.Defer(h.DB, notificationID, request.Until); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(fiber.Map{"notified_at": request.Until})
+}
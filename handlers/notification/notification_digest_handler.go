@@ -0,0 +1,200 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/services/notification"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"time"
+)
+
+const maxDueDigests = 100
+
+type ClaimDigestsRequest struct {
+	UserId int `json:"user_id"`
+}
+
+// GetDueDigests godoc
+// @Summary Get users whose digest is due
+// @Description Get the IDs of the users whose notification digest is due (used by the cron worker)
+// @Tags notification
+// @Produce json
+// @Param limit query int false "Maximum number of users (default 100)"
+// @Success 200 {array} int
+// @Router /dbms/v1/notification/digest/due [get]
+func (h *NotificationHandler) GetDueDigests(ctx *fiber.Ctx) error {
+	limit := ctx.QueryInt("limit", maxDueDigests)
+	if limit <= 0 || limit > maxDueDigests {
+		limit = maxDueDigests
+	}
+	userIds, err := notification.DueDigestUsers(h.DB, time.Now(), limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(userIds)
+}
+
+// ClaimDigests godoc
+// @Summary Claim the digests of a user
+// @Description Collect the unsent notifications of a user whose digest is due into one pending digest per user email, mark them sent and schedule the next digest. Returns an empty list when the digest is not due (used by the cron worker)
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param body body ClaimDigestsRequest true "User ID"
+// @Success 200 {array} dms_models.TwNotificationDigest
+// @Router /dbms/v1/notification/digest/claim [post]
+func (h *NotificationHandler) ClaimDigests(ctx *fiber.Ctx) error {
+	var request ClaimDigestsRequest
+	if err := ctx.BodyParser(&request); err != nil || request.UserId <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_id is required",
+		})
+	}
+	digests, err := notification.ClaimDigests(h.DB, request.UserId, time.Now())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if digests == nil {
+		return ctx.JSON([]interface{}{})
+	}
+	return ctx.JSON(digests)
+}
+
+// MarkDigestSent godoc
+// @Summary Mark a digest as sent
+// @Description Record that a pending digest was emailed (used by the cron worker)
+// @Tags notification
+// @Produce json
+// @Param digest_id path int true "Digest ID"
+// @Success 200 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/digest/{digest_id}/sent [put]
+func (h *NotificationHandler) MarkDigestSent(ctx *fiber.Ctx) error {
+	digestId, err := ctx.ParamsInt("digest_id")
+	if err != nil || digestId == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Digest ID is required",
+		})
+	}
+	if err := notification.MarkDigestSent(h.DB, digestId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Pending digest not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(fiber.Map{"message": "Digest marked as sent"})
+}
+
+// ReleaseDigest godoc
+// @Summary Release a digest that could not be sent
+// @Description Record that a pending digest could not be emailed: each of its notifications counts a failed attempt and becomes unsent again, or is dead-lettered after NOTIFICATION.MAX_ATTEMPTS failures, and the user's digest is retried later (used by the cron worker)
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param digest_id path int true "Digest ID"
+// @Param body body DeliveryFailureRequest true "Failure reason"
+// @Success 200 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/digest/{digest_id}/release [put]
+func (h *NotificationHandler) ReleaseDigest(ctx *fiber.Ctx) error {
+	digestId, err := ctx.ParamsInt("digest_id")
+	if err != nil || digestId == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Digest ID is required",
+		})
+	}
+	var request DeliveryFailureRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+	err = notification.ReleaseDigest(h.DB, digestId, request.Error, config.LoadNotificationConfig())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Pending digest not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(fiber.Map{"message": "Digest released"})
+}
+
+// GetDigest godoc
+// @Summary Get a digest
+// @Description Get a notification digest; with format=html the digest email itself is returned
+// @Tags notification
+// @Produce json,html
+// @Param digest_id path int true "Digest ID"
+// @Param format query string false "json (default) or html"
+// @Success 200 {object} dms_models.TwNotificationDigest
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/digest/{digest_id} [get]
+func (h *NotificationHandler) GetDigest(ctx *fiber.Ctx) error {
+	digestId, err := ctx.ParamsInt("digest_id")
+	if err != nil || digestId == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Digest ID is required",
+		})
+	}
+	digest, err := notification.GetDigest(h.DB, digestId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Digest not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if ctx.Query("format") == "html" {
+		ctx.Type("html", "utf-8")
+		return ctx.SendString(digest.HTML)
+	}
+	return ctx.JSON(digest)
+}
+
+// GetNotificationDigest godoc
+// @Summary Get the digest of a notification
+// @Description Get the digest that carried a notification
+// @Tags notification
+// @Produce json
+// @Param notification_id path int true "Notification ID"
+// @Success 200 {object} dms_models.TwNotificationDigest
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/{notification_id}/digest [get]
+func (h *NotificationHandler) GetNotificationDigest(ctx *fiber.Ctx) error {
+	notificationID, err := ctx.ParamsInt("notification_id")
+	if err != nil || notificationID == 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Notification ID is required",
+		})
+	}
+	digest, err := notification.DigestOf(h.DB, notificationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Notification was not sent in a digest",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return ctx.JSON(digest)
+}
@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"dbms/services/notification"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/models"
@@ -13,7 +14,7 @@ type NotificationHandler struct {
 
 // CreateNotification godoc
 // @Summary Create a new notification
-// @Description Create a new notification
+// @Description Create a new notification. The recipient's notification settings apply: when they opted out of the type nothing is stored and {"skipped": true, "reason": ...} is returned, and delivery is deferred past their quiet hours
 // @Tags notification
 // @Accept json
 // @Produce json
@@ -26,11 +27,18 @@ func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	if result := h.DB.Create(&request); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": result.Error.Error()})
+	// Insert data into database, unless the recipient opted out of the type
+	plan, err := notification.Create(h.DB, &request)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "User email not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !plan.Deliver {
+		return c.JSON(fiber.Map{"skipped": true, "reason": plan.Reason})
 	}
-	// Insert data into database
-	return nil
+	return c.JSON(request)
 }
 
 // GetUnsentNotifications godoc
@@ -42,8 +50,8 @@ func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 // @Success 200 {array} models.TwNotifications
 // @Router /dbms/v1/notification [get]
 func (h *NotificationHandler) GetUnsentNotifications(ctx *fiber.Ctx) error {
-	var notifications []models.TwNotifications
-	if err := h.DB.Where("is_sent = ?", false).Preload("UserEmail").Find(&notifications).Error; err != nil {
+	notifications, err := notification.Unsent(h.DB)
+	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -129,15 +137,14 @@ func (h *NotificationHandler) UpdateNotiStatus(c *fiber.Ctx) error {
 // @Success 200 {object} fiber.Map
 // @Router /dbms/v1/notification/{notification_id} [put]
 func (h *NotificationHandler) updateNotificationToSent(ctx *fiber.Ctx) error {
-	notificationID := ctx.Params("notification_id")
-	if notificationID == "" {
+	notificationID, err := ctx.ParamsInt("notification_id")
+	if err != nil || notificationID == 0 {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Notification ID is required",
 		})
 	}
 
-	var notification models.TwNotifications
-	if err := h.DB.First(&notification, notificationID).Error; err != nil {
+	if err := notification.MarkSent(h.DB, notificationID); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Notification not found",
@@ -148,13 +155,6 @@ func (h *NotificationHandler) updateNotificationToSent(ctx *fiber.Ctx) error {
 		})
 	}
 
-	notification.IsSent = true
-	if err := h.DB.Save(&notification).Error; err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
-	}
-
 	return ctx.JSON(fiber.Map{
 		"message": "Notification updated to sent",
 	})
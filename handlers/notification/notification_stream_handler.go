@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"bufio"
+	"dbms/services/notification"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	streamRetry     = 3 * time.Second
+	maxStreamResume = 500
+	maxStreamSent   = 5000
+)
+
+// StreamNotifications godoc
+// @Summary Stream new notifications
+// @Description Server-Sent Events stream of the notifications created for the given user emails. Each event has the notification id as its id, "notification" as its type and the notification as JSON data. Reconnect with the Last-Event-ID header (or last_event_id query) to receive the notifications missed in between. A comment is sent as heartbeat every 15 seconds
+// @Tags notification
+// @Produce text/event-stream
+// @Param user_email_ids query string true "Comma separated user email IDs"
+// @Param last_event_id query int false "Resume after this notification id (the Last-Event-ID header takes precedence)"
+// @Success 200 {string} string
+// @Failure 400 {object} fiber.Map
+// @Router /dbms/v1/notification/stream [get]
+func (h *NotificationHandler) StreamNotifications(c *fiber.Ctx) error {
+	var userEmailIds []int
+	for _, value := range strings.Split(c.Query("user_email_ids"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user_email_ids",
+			})
+		}
+		userEmailIds = append(userEmailIds, id)
+	}
+	if len(userEmailIds) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "user_email_ids is required",
+		})
+	}
+	lastEventId := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	afterId := 0
+	if lastEventId != "" {
+		var err error
+		if afterId, err = strconv.Atoi(lastEventId); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid Last-Event-ID",
+			})
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	db := h.DB
+	broker := notification.DefaultBroker()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Subscribe before reading the backlog, so that nothing created in
+		// between is lost; duplicates are skipped below.
+		events, cancel := broker.Subscribe(userEmailIds)
+		defer cancel()
+
+		sent := make(map[int]bool)
+		send := func(n models.TwNotifications) error {
+			if sent[n.ID] {
+				return nil
+			}
+			if len(sent) >= maxStreamSent {
+				sent = make(map[int]bool)
+			}
+			sent[n.ID] = true
+			data, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: notification\ndata: %s\n\n", n.ID, data); err != nil {
+				return err
+			}
+			return w.Flush()
+		}
+
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		if afterId > 0 {
+			missed, err := notification.Since(db, userEmailIds, afterId, maxStreamResume)
+			if err != nil {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", strconv.Quote(err.Error()))
+				w.Flush()
+				return
+			}
+			for _, n := range missed {
+				if err := send(n); err != nil {
+					return
+				}
+			}
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case n, ok := <-events:
+				if !ok {
+					// Dropped by the broker, the client resumes from its last id
+					return
+				}
+				if err := send(n); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"bufio"
+	"dbms/database/dbtest"
+	"dbms/services/notification"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// droppingBroker hands every subscriber the given notifications and then
+// drops it, the way MemoryBroker drops a subscriber that falls behind.
+type droppingBroker struct {
+	notifications []models.TwNotifications
+}
+
+func (b droppingBroker) Publish(models.TwNotifications) {}
+
+func (b droppingBroker) Subscribe([]int) (<-chan models.TwNotifications, func()) {
+	ch := make(chan models.TwNotifications, len(b.notifications))
+	for _, n := range b.notifications {
+		ch <- n
+	}
+	close(ch)
+	return ch, func() {}
+}
+
+// streamedIds returns the event ids of a stream.
+func streamedIds(t *testing.T, app *fiber.App, target, lastEventId string) (int, []string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return resp.StatusCode, ids
+}
+
+func TestStreamNotificationsResumesFromLastEventID(t *testing.T) {
+	db := dbtest.Open(t, &models.TwNotifications{})
+	deleted := time.Now()
+	for _, n := range []models.TwNotifications{
+		{ID: 1, UserEmailId: 1},
+		{ID: 2, UserEmailId: 1},
+		{ID: 3, UserEmailId: 1},
+		{ID: 4, UserEmailId: 2},
+		{ID: 5, UserEmailId: 1, DeletedAt: &deleted},
+		{ID: 6, UserEmailId: 1},
+	} {
+		if err := db.Create(&n).Error; err != nil {
+			t.Fatalf("seed notification: %v", err)
+		}
+	}
+	// Notification 6 was published while the backlog was read, then the
+	// subscriber fell behind after notification 7.
+	previous := notification.DefaultBroker()
+	notification.SetBroker(droppingBroker{notifications: []models.TwNotifications{
+		{ID: 6, UserEmailId: 1},
+		{ID: 7, UserEmailId: 1},
+	}})
+	defer notification.SetBroker(previous)
+
+	handler := NotificationHandler{DB: db}
+	app := fiber.New()
+	app.Get("/notification/stream", handler.StreamNotifications)
+
+	tests := []struct {
+		name        string
+		target      string
+		lastEventId string
+		wantStatus  int
+		wantIds     string
+	}{
+		{name: "Last-Event-ID header", target: "/notification/stream?user_email_ids=1", lastEventId: "1", wantStatus: fiber.StatusOK, wantIds: "2,3,6,7"},
+		{name: "header over query", target: "/notification/stream?user_email_ids=1&last_event_id=1", lastEventId: "3", wantStatus: fiber.StatusOK, wantIds: "6,7"},
+		{name: "query", target: "/notification/stream?user_email_ids=1,2&last_event_id=2", wantStatus: fiber.StatusOK, wantIds: "3,4,6,7"},
+		{name: "without last event id", target: "/notification/stream?user_email_ids=1", wantStatus: fiber.StatusOK, wantIds: "6,7"},
+		{name: "invalid last event id", target: "/notification/stream?user_email_ids=1", lastEventId: "abc", wantStatus: fiber.StatusBadRequest},
+		{name: "missing user emails", target: "/notification/stream", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, ids := streamedIds(t, app, tt.target, tt.lastEventId)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if got := strings.Join(ids, ","); got != tt.wantIds {
+				t.Errorf("streamed %s, want %s", got, tt.wantIds)
+			}
+		})
+	}
+}
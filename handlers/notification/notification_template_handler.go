@@ -0,0 +1,58 @@
+package notification
+
+import (
+	"dbms/mail"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetEmailTemplates godoc
+// @Summary List email templates
+// @Description List the email templates and the locales they are available in
+// @Tags notification
+// @Produce json
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/notification/template [get]
+func (h *NotificationHandler) GetEmailTemplates(ctx *fiber.Ctx) error {
+	return ctx.JSON(fiber.Map{
+		"templates":      mail.Templates(),
+		"locales":        mail.Locales(),
+		"default_locale": mail.DefaultLocale,
+	})
+}
+
+// PreviewEmailTemplate godoc
+// @Summary Preview an email template
+// @Description Render an email template against sample data: the subject, HTML and plain-text parts as JSON, or only the HTML or text part with format=html or format=text
+// @Tags notification
+// @Produce json,html,plain
+// @Param name path string true "Template name, e.g. reminder, invitation, mention, assignment, notification, digest"
+// @Param locale query string false "Locale, en (default) or vi"
+// @Param format query string false "json (default), html or text"
+// @Success 200 {object} mail.Rendered
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/notification/template/{name}/preview [get]
+func (h *NotificationHandler) PreviewEmailTemplate(ctx *fiber.Ctx) error {
+	name := ctx.Params("name")
+	data, ok := mail.Sample(name)
+	if !ok {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found",
+		})
+	}
+	email, err := mail.Render(name, ctx.Query("locale", mail.DefaultLocale), data)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	switch ctx.Query("format") {
+	case "html":
+		ctx.Type("html", "utf-8")
+		return ctx.SendString(email.HTML)
+	case "text":
+		ctx.Type("txt", "utf-8")
+		return ctx.SendString(email.Text)
+	default:
+		return ctx.JSON(email)
+	}
+}
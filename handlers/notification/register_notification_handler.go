@@ -14,6 +14,21 @@ func RegisterNotificationHandler(router fiber.Router, db *gorm.DB) {
 		handler.Router.Post("/", notification.CreateNotification)
 		handler.Router.Post("/user-email-ids", notification.GetNotiByUserEmailIds)
 		handler.Router.Get("/", notification.GetUnsentNotifications)
+		handler.Router.Get("/due", notification.GetDueNotifications)
+		handler.Router.Get("/plan", notification.GetDeliveryPlan)
+		handler.Router.Get("/stream", notification.StreamNotifications)
+		handler.Router.Get("/template", notification.GetEmailTemplates)
+		handler.Router.Get("/template/:name/preview", notification.PreviewEmailTemplate)
+		handler.Router.Get("/dead_letter", notification.GetDeadLetters)
+		handler.Router.Post("/dead_letter/requeue", notification.RequeueDeadLetters)
+		handler.Router.Get("/digest/due", notification.GetDueDigests)
+		handler.Router.Post("/digest/claim", notification.ClaimDigests)
+		handler.Router.Put("/digest/:digest_id/sent", notification.MarkDigestSent)
+		handler.Router.Put("/digest/:digest_id/release", notification.ReleaseDigest)
+		handler.Router.Get("/digest/:digest_id", notification.GetDigest)
+		handler.Router.Get("/:notification_id/digest", notification.GetNotificationDigest)
+		handler.Router.Post("/:notification_id/failure", notification.RecordDeliveryFailure)
+		handler.Router.Put("/:notification_id/defer", notification.DeferNotification)
 		handler.Router.Put("/:notification_id", notification.updateNotificationToSent)
 		handler.Router.Put("/update-status/read", notification.UpdateNotiStatus)
 	})
@@ -1,9 +1,13 @@
 package notification_setting
 
 import (
+	"dbms/dms_models"
+	"dbms/services/notification"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/models"
 	"gorm.io/gorm"
+	"time"
 )
 
 type NotificationSettingHandler struct {
@@ -75,3 +79,140 @@ func (h NotificationSettingHandler) UpdateNotificationSetting(ctx *fiber.Ctx) er
 	return ctx.JSON(notificationSetting)
 
 }
+
+// GetQuietHours godoc
+// @Summary Get quiet hours
+// @Description Get the daily time range during which the notifications of a user are held back
+// @Tags notification_setting
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dms_models.TwNotificationQuietHours
+// @Router /dbms/v1/notification_setting/{user_id}/quiet_hours [get]
+func (h NotificationSettingHandler) GetQuietHours(ctx *fiber.Ctx) error {
+	userId, err := ctx.ParamsInt("user_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid user_id")
+	}
+	quiet, err := notification.QuietHours(h.DB, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).SendString("No quiet hours")
+		}
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(quiet)
+}
+
+// UpdateQuietHours godoc
+// @Summary Update quiet hours
+// @Description Set the daily time range (HH:MM, in the given timezone) during which the notifications of a user are held back; start after end spans midnight. Empty start and end remove the quiet hours
+// @Tags notification_setting
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param quiet_hours body dms_models.TwNotificationQuietHours true "Quiet hours"
+// @Success 200 {object} dms_models.TwNotificationQuietHours
+// @Router /dbms/v1/notification_setting/{user_id}/quiet_hours [put]
+func (h NotificationSettingHandler) UpdateQuietHours(ctx *fiber.Ctx) error {
+	userId, err := ctx.ParamsInt("user_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid user_id")
+	}
+	var quiet dms_models.TwNotificationQuietHours
+	if err := ctx.BodyParser(&quiet); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	quiet.UserId = userId
+	quiet, err = notification.SetQuietHours(h.DB, quiet)
+	if err != nil {
+		var invalid *notification.InvalidQuietHours
+		if errors.As(err, &invalid) {
+			return ctx.Status(fiber.StatusBadRequest).SendString(invalid.Reason)
+		}
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(quiet)
+}
+
+// PreviewNotification godoc
+// @Summary Preview notification delivery
+// @Description Show whether a notification of the given type would be delivered to the user, by email or in-app only, and when, given their notification settings and quiet hours
+// @Tags notification_setting
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param type query string true "Notification type, e.g. comment, reminder, tag, schedule_change"
+// @Param at query string false "RFC3339 time the notification would be created (default now)"
+// @Success 200 {object} notification.Plan
+// @Router /dbms/v1/notification_setting/{user_id}/preview [get]
+func (h NotificationSettingHandler) PreviewNotification(ctx *fiber.Ctx) error {
+	userId, err := ctx.ParamsInt("user_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid user_id")
+	}
+	notificationType := ctx.Query("type")
+	if notificationType == "" {
+		return ctx.Status(fiber.StatusBadRequest).SendString("type is required")
+	}
+	at := time.Now()
+	if value := ctx.Query("at"); value != "" {
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).SendString("Invalid at, expected an RFC3339 time")
+		}
+	}
+	plan, err := notification.PlanForUser(h.DB, userId, notificationType, at)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(plan)
+}
+
+// GetDigestSetting godoc
+// @Summary Get digest setting
+// @Description Get whether the notifications of a user are collected into an hourly or daily digest email instead of being emailed one by one
+// @Tags notification_setting
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} dms_models.TwNotificationDigestSetting
+// @Router /dbms/v1/notification_setting/{user_id}/digest [get]
+func (h NotificationSettingHandler) GetDigestSetting(ctx *fiber.Ctx) error {
+	userId, err := ctx.ParamsInt("user_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid user_id")
+	}
+	setting, err := notification.DigestSetting(h.DB, userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(setting)
+}
+
+// UpdateDigestSetting godoc
+// @Summary Update digest setting
+// @Description Set the digest cadence of a user: off, hourly or daily at daily_at (HH:MM, default 08:00) in the given timezone
+// @Tags notification_setting
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param digest body dms_models.TwNotificationDigestSetting true "Digest setting"
+// @Success 200 {object} dms_models.TwNotificationDigestSetting
+// @Router /dbms/v1/notification_setting/{user_id}/digest [put]
+func (h NotificationSettingHandler) UpdateDigestSetting(ctx *fiber.Ctx) error {
+	userId, err := ctx.ParamsInt("user_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid user_id")
+	}
+	var setting dms_models.TwNotificationDigestSetting
+	if err := ctx.BodyParser(&setting); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	setting.UserId = userId
+	setting, err = notification.SetDigestSetting(h.DB, setting, time.Now())
+	if err != nil {
+		var invalid *notification.InvalidDigestSetting
+		if errors.As(err, &invalid) {
+			return ctx.Status(fiber.StatusBadRequest).SendString(invalid.Reason)
+		}
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(setting)
+}
@@ -12,5 +12,10 @@ func RegisterNotificationSettingHandler(router fiber.Router, db *gorm.DB) {
 	router.Get("/:user_id", notificationSettingHandler.GetNotificationSettingByUserId)
 	router.Post("/", notificationSettingHandler.CreateNotificationSetting)
 	router.Put("/:user_id", notificationSettingHandler.UpdateNotificationSetting)
+	router.Get("/:user_id/quiet_hours", notificationSettingHandler.GetQuietHours)
+	router.Put("/:user_id/quiet_hours", notificationSettingHandler.UpdateQuietHours)
+	router.Get("/:user_id/preview", notificationSettingHandler.PreviewNotification)
+	router.Get("/:user_id/digest", notificationSettingHandler.GetDigestSetting)
+	router.Put("/:user_id/digest", notificationSettingHandler.UpdateDigestSetting)
 
 }
@@ -1,6 +1,7 @@
 package recurrence_exception
 
 import (
+	"dbms/services/notification"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/dtos/core_dtos"
 	"github.com/timewise-team/timewise-models/models"
@@ -90,6 +91,9 @@ func (h *RecurrenceExceptionHandler) CreateRecurrenceException(c *fiber.Ctx) err
 	if result := h.DB.Create(&recurrenceException); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
 	}
+	if err := notification.SyncScheduleReminders(h.DB, recurrenceException.ScheduleId, notification.WallClockNow()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
 	return c.JSON(core_dtos.TwRecurrenceExceptionResponseDTO{
 		ID:            recurrenceException.ID,
 		ScheduleId:    recurrenceException.ScheduleId,
@@ -139,6 +143,9 @@ func (h *RecurrenceExceptionHandler) UpdateRecurrenceException(c *fiber.Ctx) err
 	if result := h.DB.Save(&recurrenceException); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
 	}
+	if err := notification.SyncScheduleReminders(h.DB, recurrenceException.ScheduleId, notification.WallClockNow()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
 	return c.JSON(core_dtos.TwRecurrenceExceptionResponseDTO{
 		ID:            recurrenceException.ID,
 		ScheduleId:    recurrenceException.ScheduleId,
@@ -168,5 +175,8 @@ func (h *RecurrenceExceptionHandler) DeleteRecurrenceException(c *fiber.Ctx) err
 	if result := h.DB.Delete(&recurrenceException, id); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
 	}
+	if err := notification.SyncScheduleReminders(h.DB, recurrenceException.ScheduleId, notification.WallClockNow()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -10,10 +10,24 @@ func RegisterReminderHandler(router fiber.Router, db *gorm.DB) {
 		DB: db,
 	}
 	router.Post("/", reminderHandler.CreateReminder)
+	router.Get("/due", reminderHandler.GetDueReminders)
+	router.Post("/escalate", reminderHandler.EscalateReminders)
+	router.Post("/rule", reminderHandler.CreateReminderRule)
+	router.Post("/rule/sync", reminderHandler.SyncReminderRules)
+	router.Get("/rule/schedule/:schedule_id", reminderHandler.GetReminderRules)
+	router.Put("/rule/:rule_id", reminderHandler.UpdateReminderRule)
+	router.Delete("/rule/:rule_id", reminderHandler.DeleteReminderRule)
 	router.Get("/:reminder_id", reminderHandler.GetReminderById)
 	router.Get("/schedule/:schedule_id", reminderHandler.GetRemindersByScheduleId)
 	router.Put("/:reminder_id", reminderHandler.UpdateReminder)
 	router.Delete("/:reminder_id", reminderHandler.DeleteReminder)
 	router.Get("", reminderHandler.GetReminders)
 	router.Put("/:reminder_id/is_sent", reminderHandler.CompleteReminder)
+	router.Put("/:reminder_id/claim", reminderHandler.ClaimReminder)
+	router.Put("/:reminder_id/release", reminderHandler.ReleaseReminder)
+	router.Post("/:reminder_id/deliveries", reminderHandler.CreateReminderDelivery)
+	router.Get("/:reminder_id/deliveries", reminderHandler.GetReminderDeliveries)
+	router.Put("/:reminder_id/snooze", reminderHandler.SnoozeReminder)
+	router.Put("/:reminder_id/acknowledge", reminderHandler.AcknowledgeReminder)
+	router.Get("/:reminder_id/acknowledgements", reminderHandler.GetReminderAcknowledgements)
 }
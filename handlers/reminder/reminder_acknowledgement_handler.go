@@ -0,0 +1,158 @@
+package reminder
+
+import (
+	"dbms/config"
+	"dbms/services/notification"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SnoozeReminderRequest struct {
+	WorkspaceUserID int `json:"workspace_user_id"`
+	// Minutes snoozes the reminder for that long from now.
+	Minutes int `json:"minutes"`
+	// Until snoozes the reminder until that RFC3339 time instead.
+	Until *time.Time `json:"until"`
+}
+
+type AcknowledgeReminderRequest struct {
+	WorkspaceUserID int `json:"workspace_user_id"`
+}
+
+// snoozeReminder godoc
+// @Summary Snooze a reminder
+// @Description Remind the workspace user again in the given minutes, or at the given time. The other participants of the schedule are not reminded again
+// @Tags reminder
+// @Accept json
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Param body body SnoozeReminderRequest true "Snooze"
+// @Success 200 {object} models.TwReminder
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/reminder/{reminder_id}/snooze [put]
+func (h ReminderHandler) SnoozeReminder(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	var request SnoozeReminderRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	// Reminder times are wall-clock times
+	now := notification.WallClockNow()
+	var until time.Time
+	switch {
+	case request.Until != nil && request.Minutes != 0:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Either minutes or until is required, not both",
+		})
+	case request.Until != nil:
+		until = notification.WallClock(*request.Until)
+	case request.Minutes > 0:
+		until = now.Add(time.Duration(request.Minutes) * time.Minute)
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Either a positive minutes or until is required",
+		})
+	}
+
+	snoozed, err := notification.SnoozeReminder(h.DB, id, request.WorkspaceUserID, until, now, config.LoadReminderConfig().MaxSnooze)
+	if err != nil {
+		return sendReminderActionError(ctx, err)
+	}
+	return ctx.JSON(snoozed)
+}
+
+// acknowledgeReminder godoc
+// @Summary Acknowledge a reminder
+// @Description Record that the workspace user saw a reminder, dropping their pending snoozes of it. Unacknowledged reminders of high priority schedules are escalated to the schedule creator
+// @Tags reminder
+// @Accept json
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Param body body AcknowledgeReminderRequest true "Acknowledgement"
+// @Success 200 {object} dms_models.TwReminderAcknowledgement
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/reminder/{reminder_id}/acknowledge [put]
+func (h ReminderHandler) AcknowledgeReminder(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	var request AcknowledgeReminderRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	acknowledgement, err := notification.AcknowledgeReminder(h.DB, id, request.WorkspaceUserID)
+	if err != nil {
+		return sendReminderActionError(ctx, err)
+	}
+	return ctx.JSON(acknowledgement)
+}
+
+// getReminderAcknowledgements godoc
+// @Summary Get acknowledgements of a reminder
+// @Description Get the workspace users who acknowledged a reminder, oldest first
+// @Tags reminder
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Success 200 {array} dms_models.TwReminderAcknowledgement
+// @Router /dbms/v1/reminder/{reminder_id}/acknowledgements [get]
+func (h ReminderHandler) GetReminderAcknowledgements(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	acknowledgements, err := notification.ReminderAcknowledgements(h.DB, id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(acknowledgements)
+}
+
+// escalateReminders godoc
+// @Summary Escalate unacknowledged reminders
+// @Description Notify the creators of high priority schedules of the participants who did not acknowledge a reminder within the escalation window (used by the cron worker)
+// @Tags reminder
+// @Produce json
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/reminder/escalate [post]
+func (h ReminderHandler) EscalateReminders(ctx *fiber.Ctx) error {
+	escalated, err := notification.EscalateReminders(h.DB, config.LoadReminderConfig(), time.Now())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(fiber.Map{"escalated": escalated})
+}
+
+func sendReminderActionError(ctx *fiber.Ctx, err error) error {
+	var invalid *notification.InvalidReminderAction
+	switch {
+	case errors.As(err, &invalid):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": invalid.Reason,
+		})
+	case errors.Is(err, notification.ErrNotReminderRecipient):
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Reminder not found",
+		})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+}
@@ -0,0 +1,129 @@
+package reminder
+
+import (
+	"dbms/dms_models"
+	"dbms/services/notification"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+const maxDueReminders = 500
+
+// getDueReminders godoc
+// @Summary Get due reminders
+// @Description Get the unsent reminders due at or before the given time, oldest first (used by the cron worker)
+// @Tags reminder
+// @Produce json
+// @Param before query string true "RFC3339 time"
+// @Param limit query int false "Maximum number of reminders (default 500)"
+// @Success 200 {array} models.TwReminder
+// @Router /dbms/v1/reminder/due [get]
+func (h ReminderHandler) GetDueReminders(ctx *fiber.Ctx) error {
+	before, err := time.Parse(time.RFC3339, ctx.Query("before"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid before, expected an RFC3339 time")
+	}
+	limit := ctx.QueryInt("limit", maxDueReminders)
+	if limit <= 0 || limit > maxDueReminders {
+		limit = maxDueReminders
+	}
+	reminders, err := notification.DueReminders(h.DB, before, limit)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(reminders)
+}
+
+// claimReminder godoc
+// @Summary Claim a reminder
+// @Description Atomically mark an unsent reminder as sent. Only one caller succeeds, the others get 409
+// @Tags reminder
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Success 200 {object} fiber.Map
+// @Failure 409 {object} fiber.Map
+// @Router /dbms/v1/reminder/{reminder_id}/claim [put]
+func (h ReminderHandler) ClaimReminder(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	claimed, err := notification.ClaimReminder(h.DB, id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	if !claimed {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message": "Reminder is already sent or claimed",
+		})
+	}
+	return ctx.JSON(fiber.Map{"claimed": true})
+}
+
+// releaseReminder godoc
+// @Summary Release a claimed reminder
+// @Description Mark a claimed reminder as unsent again so that it is retried
+// @Tags reminder
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/reminder/{reminder_id}/release [put]
+func (h ReminderHandler) ReleaseReminder(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	if err := notification.ReleaseReminder(h.DB, id); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(fiber.Map{"released": true})
+}
+
+// createReminderDelivery godoc
+// @Summary Record a reminder delivery attempt
+// @Description Record one attempt to deliver a reminder to a recipient
+// @Tags reminder
+// @Accept json
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Param delivery body dms_models.TwReminderDelivery true "Delivery attempt"
+// @Success 200 {object} dms_models.TwReminderDelivery
+// @Router /dbms/v1/reminder/{reminder_id}/deliveries [post]
+func (h ReminderHandler) CreateReminderDelivery(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	var delivery dms_models.TwReminderDelivery
+	if err := ctx.BodyParser(&delivery); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	delivery.ID = 0
+	delivery.ReminderId = id
+	if err := notification.RecordReminderDelivery(h.DB, &delivery); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(delivery)
+}
+
+// getReminderDeliveries godoc
+// @Summary Get the delivery attempts of a reminder
+// @Description Get the delivery attempts of a reminder, oldest first
+// @Tags reminder
+// @Produce json
+// @Param reminder_id path string true "Reminder ID"
+// @Success 200 {array} dms_models.TwReminderDelivery
+// @Router /dbms/v1/reminder/{reminder_id}/deliveries [get]
+func (h ReminderHandler) GetReminderDeliveries(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	deliveries, err := notification.ReminderDeliveries(h.DB, id)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(deliveries)
+}
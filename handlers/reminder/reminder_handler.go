@@ -1,6 +1,8 @@
 package reminder
 
 import (
+	"dbms/services/notification"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/models"
 	"gorm.io/gorm"
@@ -157,16 +159,9 @@ func (h ReminderHandler) DeleteReminder(ctx *fiber.Ctx) error {
 // @Success 200 {array} models.TwReminder
 // @Router /dbms/v1/reminder [get]
 func (h ReminderHandler) GetReminders(ctx *fiber.Ctx) error {
-	var reminders []models.TwReminder
-	if result := h.DB.
-		Where("deleted_at IS NULL").
-		Preload("WorkspaceUser").
-		Preload("WorkspaceUser.Workspace").
-		Preload("WorkspaceUser.UserEmail").
-		Preload("WorkspaceUser.UserEmail.User").
-		Preload("Schedule").
-		Find(&reminders); result.Error != nil {
-		return ctx.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	reminders, err := notification.Reminders(h.DB)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return ctx.JSON(reminders)
 }
@@ -181,23 +176,16 @@ func (h ReminderHandler) GetReminders(ctx *fiber.Ctx) error {
 // @Success 200 {object} models.TwReminder
 // @Router /dbms/v1/reminder/{reminder_id}/is_sent [put]
 func (h ReminderHandler) CompleteReminder(ctx *fiber.Ctx) error {
-	id := ctx.Params("reminder_id")
-	var reminder models.TwReminder
-	if result := h.DB.
-		Where("id = ?", id).
-		Where("deleted_at IS NULL").
-		First(&reminder); result.Error != nil {
-		return ctx.Status(fiber.StatusNotFound).SendString(result.Error.Error())
-	}
-
-	// Chỉ cập nhật các thuộc tính cần thiết
-	updateFields := map[string]interface{}{
-		"updated_at": gorm.Expr("NOW()"),
-		"is_sent":    true,
-	}
-
-	if result := h.DB.Model(&reminder).Updates(updateFields); result.Error != nil {
-		return ctx.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	id, err := ctx.ParamsInt("reminder_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid reminder_id")
+	}
+	reminder, err := notification.MarkReminderSent(h.DB, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
 	return ctx.JSON(reminder)
@@ -0,0 +1,137 @@
+package reminder
+
+import (
+	"dbms/dms_models"
+	"dbms/services/notification"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// getReminderRules godoc
+// @Summary Get relative reminders of a schedule
+// @Description Get the reminders of a schedule that are relative to its start or end time
+// @Tags reminder
+// @Produce json
+// @Param schedule_id path string true "Schedule ID"
+// @Success 200 {array} dms_models.TwReminderRule
+// @Router /dbms/v1/reminder/rule/schedule/{schedule_id} [get]
+func (h ReminderHandler) GetReminderRules(ctx *fiber.Ctx) error {
+	scheduleId, err := ctx.ParamsInt("schedule_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid schedule_id")
+	}
+	rules, err := notification.ReminderRules(h.DB, scheduleId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(rules)
+}
+
+// createReminderRule godoc
+// @Summary Create a relative reminder
+// @Description Create a reminder offset_minutes before the start or end of a schedule (negative for after). It produces one reminder per occurrence of the schedule, recalculated whenever the schedule's times change
+// @Tags reminder
+// @Accept json
+// @Produce json
+// @Param rule body dms_models.TwReminderRule true "Relative reminder"
+// @Success 200 {object} dms_models.TwReminderRule
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/reminder/rule [post]
+func (h ReminderHandler) CreateReminderRule(ctx *fiber.Ctx) error {
+	var rule dms_models.TwReminderRule
+	if err := ctx.BodyParser(&rule); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	rule.ID = 0
+	if err := notification.CreateReminderRule(h.DB, &rule, notification.WallClockNow()); err != nil {
+		return sendReminderRuleError(ctx, err)
+	}
+	return ctx.JSON(rule)
+}
+
+// updateReminderRule godoc
+// @Summary Update a relative reminder
+// @Description Change the anchor, offset, method and type of a relative reminder and recalculate its unsent reminders
+// @Tags reminder
+// @Accept json
+// @Produce json
+// @Param rule_id path string true "Relative reminder ID"
+// @Param rule body dms_models.TwReminderRule true "Relative reminder"
+// @Success 200 {object} dms_models.TwReminderRule
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/reminder/rule/{rule_id} [put]
+func (h ReminderHandler) UpdateReminderRule(ctx *fiber.Ctx) error {
+	ruleId, err := ctx.ParamsInt("rule_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid rule_id")
+	}
+	var rule dms_models.TwReminderRule
+	if err := ctx.BodyParser(&rule); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	rule.ID = ruleId
+	if err := notification.UpdateReminderRule(h.DB, &rule, notification.WallClockNow()); err != nil {
+		return sendReminderRuleError(ctx, err)
+	}
+	return ctx.JSON(rule)
+}
+
+// deleteReminderRule godoc
+// @Summary Delete a relative reminder
+// @Description Delete a relative reminder with its unsent reminders
+// @Tags reminder
+// @Produce json
+// @Param rule_id path string true "Relative reminder ID"
+// @Success 200 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/reminder/rule/{rule_id} [delete]
+func (h ReminderHandler) DeleteReminderRule(ctx *fiber.Ctx) error {
+	ruleId, err := ctx.ParamsInt("rule_id")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("Invalid rule_id")
+	}
+	if err := notification.DeleteReminderRule(h.DB, ruleId); err != nil {
+		return sendReminderRuleError(ctx, err)
+	}
+	return ctx.JSON(fiber.Map{
+		"message": "Relative reminder deleted successfully",
+	})
+}
+
+// syncReminderRules godoc
+// @Summary Extend relative reminders of recurring schedules
+// @Description Recalculate the relative reminders of every recurring schedule, creating the reminders of the occurrences that came into range (used by the cron worker)
+// @Tags reminder
+// @Produce json
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/reminder/rule/sync [post]
+func (h ReminderHandler) SyncReminderRules(ctx *fiber.Ctx) error {
+	synced, err := notification.SyncRecurringReminderRules(h.DB, notification.WallClockNow())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	return ctx.JSON(fiber.Map{"synced": synced})
+}
+
+func sendReminderRuleError(ctx *fiber.Ctx, err error) error {
+	var invalid *notification.InvalidReminderRule
+	switch {
+	case errors.As(err, &invalid):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": invalid.Reason,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Schedule or relative reminder not found",
+		})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+}
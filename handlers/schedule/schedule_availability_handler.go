@@ -0,0 +1,174 @@
+package schedule
+
+import (
+	"dbms/services/calendar"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+const (
+	maxAvailabilityWindow = 62 * 24 * time.Hour
+	defaultMaxSlots       = 20
+	maxSlotsLimit         = 200
+)
+
+type AvailabilityRequest struct {
+	WorkspaceUserIDs  []int    `json:"workspace_user_ids"`
+	Emails            []string `json:"emails"`
+	From              string   `json:"from"`
+	To                string   `json:"to"`
+	SlotMinutes       int      `json:"slot_minutes"`
+	WorkingHoursStart string   `json:"working_hours_start"`
+	WorkingHoursEnd   string   `json:"working_hours_end"`
+	Timezone          string   `json:"timezone"`
+	IncludeWeekends   bool     `json:"include_weekends"`
+	MaxSlots          int      `json:"max_slots"`
+}
+
+type ParticipantAvailability struct {
+	WorkspaceUserID int                     `json:"workspace_user_id,omitempty"`
+	Email           string                  `json:"email,omitempty"`
+	Busy            []calendar.BusyInterval `json:"busy"`
+}
+
+type AvailabilityResponse struct {
+	From          time.Time                 `json:"from"`
+	To            time.Time                 `json:"to"`
+	Participants  []ParticipantAvailability `json:"participants"`
+	FreeIntervals []calendar.Interval       `json:"free_intervals"`
+	FreeSlots     []calendar.Interval       `json:"free_slots"`
+}
+
+// GetAvailability godoc
+// @Summary Get free/busy and common free slots
+// @Description Compute busy intervals of each person (workspace users or emails) from their joined schedules, recurrence exceptions included, and the common free slots inside working hours
+// @Tags schedule
+// @Accept json
+// @Produce json
+// @Param body body AvailabilityRequest true "Availability request (working hours as HH:MM, default 09:00-17:00 in the given timezone)"
+// @Success 200 {object} AvailabilityResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /dbms/v1/schedule/availability [post]
+func (h *ScheduleHandler) GetAvailability(c *fiber.Ctx) error {
+	var request AvailabilityRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if len(request.WorkspaceUserIDs) == 0 && len(request.Emails) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_ids or emails is required",
+		})
+	}
+
+	from, to, err := parseTimeWindow(request.From, request.To)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if to.Sub(from) > maxAvailabilityWindow {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "The requested window must not exceed 62 days",
+		})
+	}
+	if request.SlotMinutes <= 0 {
+		request.SlotMinutes = 30
+	}
+	if request.MaxSlots <= 0 {
+		request.MaxSlots = defaultMaxSlots
+	}
+	if request.MaxSlots > maxSlotsLimit {
+		request.MaxSlots = maxSlotsLimit
+	}
+	loc := time.UTC
+	if request.Timezone != "" {
+		if loc, err = time.LoadLocation(request.Timezone); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid timezone",
+			})
+		}
+	}
+	startMinute, err := calendar.ParseClock(request.WorkingHoursStart, 9*60)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid working_hours_start",
+		})
+	}
+	endMinute, err := calendar.ParseClock(request.WorkingHoursEnd, 17*60)
+	if err != nil || endMinute <= startMinute {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid working_hours_end",
+		})
+	}
+
+	// Each requested person becomes one group of workspace users.
+	groups := make(map[string][]int)
+	linked, err := calendar.LinkedWorkspaceUsers(h.DB, request.WorkspaceUserIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	for id, ids := range linked {
+		groups["id:"+strconv.Itoa(id)] = ids
+	}
+	byEmail, err := calendar.WorkspaceUsersByEmail(h.DB, request.Emails)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	for _, email := range request.Emails {
+		groups["email:"+email] = byEmail[email]
+	}
+
+	busy, err := calendar.BusyIntervals(h.DB, groups, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	response := AvailabilityResponse{
+		From:          from,
+		To:            to,
+		Participants:  []ParticipantAvailability{},
+		FreeIntervals: []calendar.Interval{},
+		FreeSlots:     []calendar.Interval{},
+	}
+	var allBusy []calendar.Interval
+	for _, id := range request.WorkspaceUserIDs {
+		items := busy["id:"+strconv.Itoa(id)]
+		response.Participants = append(response.Participants, ParticipantAvailability{WorkspaceUserID: id, Busy: items})
+		for _, item := range items {
+			allBusy = append(allBusy, item.Interval)
+		}
+	}
+	for _, email := range request.Emails {
+		items := busy["email:"+email]
+		response.Participants = append(response.Participants, ParticipantAvailability{Email: email, Busy: items})
+		for _, item := range items {
+			allBusy = append(allBusy, item.Interval)
+		}
+	}
+
+	slot := time.Duration(request.SlotMinutes) * time.Minute
+	merged := calendar.MergeIntervals(allBusy)
+	for _, working := range calendar.WorkingIntervals(from, to, loc, startMinute, endMinute, request.IncludeWeekends) {
+		for _, free := range calendar.SubtractIntervals(working, merged) {
+			if free.End.Sub(free.Start) < slot {
+				continue
+			}
+			response.FreeIntervals = append(response.FreeIntervals, free)
+			for start := free.Start; !start.Add(slot).After(free.End) && len(response.FreeSlots) < request.MaxSlots; start = start.Add(slot) {
+				response.FreeSlots = append(response.FreeSlots, calendar.Interval{Start: start, End: start.Add(slot)})
+			}
+		}
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,97 @@
+package schedule
+
+import (
+	"dbms/services/calendar"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+type ParticipantConflicts struct {
+	WorkspaceUserID int                 `json:"workspace_user_id"`
+	Conflicts       []calendar.Conflict `json:"conflicts"`
+}
+
+type ScheduleConflictsResponse struct {
+	ScheduleID   int                    `json:"schedule_id"`
+	From         time.Time              `json:"from"`
+	To           time.Time              `json:"to"`
+	Participants []ParticipantConflicts `json:"participants"`
+}
+
+// GetScheduleConflicts godoc
+// @Summary Get schedule conflicts
+// @Description List, for every joined participant of a schedule, their other schedules overlapping it. Recurring schedules are expanded; without from/to a recurring schedule is checked over the next 90 days.
+// @Tags schedule
+// @Accept json
+// @Produce json
+// @Param schedule_id path int true "Schedule ID"
+// @Param from query string false "Window start (RFC3339 or 2006-01-02 15:04:05.000)"
+// @Param to query string false "Window end (RFC3339 or 2006-01-02 15:04:05.000)"
+// @Success 200 {object} ScheduleConflictsResponse
+// @Failure 400 {string} string "Invalid window"
+// @Failure 404 {string} string "Schedule not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /dbms/v1/schedule/{schedule_id}/conflicts [get]
+func (h *ScheduleHandler) GetScheduleConflicts(c *fiber.Ctx) error {
+	var schedule models.TwSchedule
+	if err := h.DB.Where("id = ? AND is_deleted = false", c.Params("schedule_id")).First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString("Schedule not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	from, to := calendar.Window(schedule)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		var err error
+		if from, to, err = parseTimeWindow(c.Query("from"), c.Query("to")); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+	}
+
+	workspaceUserIds, err := calendar.JoinedParticipants(h.DB, schedule.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	conflicts, err := calendar.FindConflicts(h.DB, schedule, workspaceUserIds, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	response := ScheduleConflictsResponse{
+		ScheduleID:   schedule.ID,
+		From:         from,
+		To:           to,
+		Participants: []ParticipantConflicts{},
+	}
+	sort.Ints(workspaceUserIds)
+	for _, workspaceUserId := range workspaceUserIds {
+		items := conflicts[workspaceUserId]
+		if items == nil {
+			items = []calendar.Conflict{}
+		}
+		response.Participants = append(response.Participants, ParticipantConflicts{
+			WorkspaceUserID: workspaceUserId,
+			Conflicts:       items,
+		})
+	}
+	return c.JSON(response)
+}
+
+// checkConflicts reports the conflicts of schedule for the given participants
+// when the request asks for reject_conflicts=true, and nil otherwise.
+func checkConflicts(c *fiber.Ctx, db *gorm.DB, schedule models.TwSchedule, workspaceUserIds []int) ([]calendar.Conflict, error) {
+	if !c.QueryBool("reject_conflicts") {
+		return nil, nil
+	}
+	from, to := calendar.Window(schedule)
+	conflicts, err := calendar.FindConflicts(db, schedule, workspaceUserIds, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return calendar.FlattenConflicts(conflicts), nil
+}
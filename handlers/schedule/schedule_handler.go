@@ -1,6 +1,12 @@
 package schedule
 
 import (
+	"dbms/common"
+	"dbms/services/board"
+	"dbms/services/calendar"
+	"dbms/services/notification"
+	"dbms/services/policy"
+	"dbms/services/webhook"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +14,7 @@ import (
 	"github.com/timewise-team/timewise-models/dtos/core_dtos"
 	"github.com/timewise-team/timewise-models/models"
 	"gorm.io/gorm"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -277,7 +284,10 @@ func (h *ScheduleHandler) GetScheduleById(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param schedule body core_dtos.TwCreateScheduleRequest true "Schedule"
+// @Param reject_conflicts query bool false "Return 409 with the conflict list when the creator has overlapping schedules"
+// @Param override_wip_limit query bool false "Create the schedule even if the board column has reached its WIP limit; the override is logged"
 // @Success 201 {object} core_dtos.TwCreateShecduleResponse
+// @Failure 409 {object} fiber.Map "Conflicting schedules or full board column"
 // @Router /dbms/v1/schedule [post]
 func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 
@@ -286,11 +296,6 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
 
-	var existingSchedules []models.TwSchedule
-	if err := h.DB.Where("board_column_id = ? and is_deleted = false", *scheduleDTO.BoardColumnID).Find(&existingSchedules).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-	}
-
 	now := time.Now()
 	endTime := now.Add(1 * time.Hour)
 	schedule := models.TwSchedule{
@@ -303,7 +308,6 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 		CreatedBy:     *scheduleDTO.WorkspaceUserID,
 		CreatedAt:     &now,
 		UpdatedAt:     &now,
-		Position:      len(existingSchedules) + 1,
 		Status:        "not yet",
 		Visibility:    "public",
 	}
@@ -325,48 +329,97 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 		}
 	}
 
-	if result := h.DB.Create(&schedule); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	conflicts, err := checkConflicts(c, h.DB, schedule, []int{*scheduleDTO.WorkspaceUserID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	if len(conflicts) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":   "Schedule conflicts with existing schedules",
+			"conflicts": conflicts,
+		})
+	}
+
+	// The column row lock serializes concurrent inserts so that two schedules
+	// never get the same position.
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockColumn(tx, schedule.BoardColumnId); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "BoardColumn not found")
+			}
+			return err
+		}
+		wip, overridden, err := board.EnforceWipLimit(tx, schedule.BoardColumnId, c.QueryBool("override_wip_limit"))
+		if err != nil {
+			return err
+		}
+		schedule.Position = int(wip.ScheduleCount) + 1
+		if err := createScheduleWithCreator(tx, &schedule, *scheduleDTO.WorkspaceUserID); err != nil {
+			return err
+		}
+		if overridden {
+			return tx.Create(board.WipOverrideLog(schedule.ID, *scheduleDTO.WorkspaceUserID, schedule.BoardColumnId, wip)).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return sendTransactionError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(core_dtos.TwCreateShecduleResponse{
+		ID:            schedule.ID,
+		WorkspaceID:   schedule.WorkspaceId,
+		BoardColumnID: schedule.BoardColumnId,
+		Title:         schedule.Title,
+		Description:   schedule.Description,
+		Position:      schedule.Position,
+		StartTime:     *schedule.StartTime,
+		EndTime:       *schedule.EndTime,
+	})
+}
+
+// createScheduleWithCreator inserts a schedule together with its "create
+// schedule" log entry, the creator's participant row, a rank key placing it
+// last in its board column and the schedule.created webhook event. Callers
+// run it inside a transaction so that a failure leaves none of these rows
+// behind.
+func createScheduleWithCreator(db *gorm.DB, schedule *models.TwSchedule, workspaceUserId int) error {
+	if result := db.Create(schedule); result.Error != nil {
+		return result.Error
 	}
 
 	newScheduleLog := models.TwScheduleLog{
 		ScheduleId:      schedule.ID,
-		WorkspaceUserId: *scheduleDTO.WorkspaceUserID,
+		WorkspaceUserId: workspaceUserId,
 		Action:          "create schedule",
 	}
 
-	if result := h.DB.Create(&newScheduleLog); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	if result := db.Create(&newScheduleLog); result.Error != nil {
+		return result.Error
 	}
 
-	now = time.Now()
+	now := time.Now()
 	newScheduleParticipant := models.TwScheduleParticipant{
 		CreatedAt:        now,
 		UpdatedAt:        now,
 		ScheduleId:       schedule.ID,
-		WorkspaceUserId:  *scheduleDTO.WorkspaceUserID,
+		WorkspaceUserId:  workspaceUserId,
 		AssignAt:         &now,
-		AssignBy:         *scheduleDTO.WorkspaceUserID,
+		AssignBy:         workspaceUserId,
 		Status:           "creator",
 		ResponseTime:     &now,
 		InvitationSentAt: &now,
 		InvitationStatus: "joined",
 	}
 
-	if result := h.DB.Create(&newScheduleParticipant); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	if result := db.Create(&newScheduleParticipant); result.Error != nil {
+		return result.Error
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(core_dtos.TwCreateShecduleResponse{
-		ID:            schedule.ID,
-		WorkspaceID:   schedule.WorkspaceId,
-		BoardColumnID: schedule.BoardColumnId,
-		Title:         schedule.Title,
-		Description:   schedule.Description,
-		Position:      schedule.Position,
-		StartTime:     *schedule.StartTime,
-		EndTime:       *schedule.EndTime,
-	})
+	if _, err := board.AppendSchedule(db, schedule.ID, schedule.BoardColumnId); err != nil {
+		return err
+	}
+	return webhook.Emit(db, schedule.WorkspaceId, webhook.EventScheduleCreated, webhook.NewSchedule(*schedule))
 }
 
 func convertToISOFormat(input string) string {
@@ -404,7 +457,10 @@ func convertDateFormat(dateStr *string) *time.Time {
 // @Produce json
 // @Param schedule_id path int true "Schedule ID"
 // @Param schedule body core_dtos.TwUpdateScheduleRequest true "Schedule"
+// @Param reject_conflicts query bool false "Return 409 with the conflict list when a participant has overlapping schedules"
 // @Success 200 {object} core_dtos.TwUpdateScheduleResponse
+// @Failure 403 {object} fiber.Map "The workspace user's role does not allow the update"
+// @Failure 409 {object} fiber.Map
 // @Router /dbms/v1/schedule/{schedule_id} [put]
 func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
 	var scheduleDTO core_dtos.TwUpdateScheduleRequest
@@ -428,6 +484,10 @@ func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
+	if _, err := policy.Authorize(h.DB, workspaceUserId, schedule.WorkspaceId, policy.Schedule, policy.Update, schedule.CreatedBy); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+
 	// Tạo danh sách các log khi trường được cập nhật
 	var logs []models.TwScheduleLog
 
@@ -510,22 +570,49 @@ func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
 		checkAndLog("video_transcript", schedule.VideoTranscript, *scheduleDTO.VideoTranscript)
 		schedule.VideoTranscript = *scheduleDTO.VideoTranscript
 	}
-	schedule.CreatedBy = workspaceUserId
+
+	if c.QueryBool("reject_conflicts") {
+		participantIds, err := calendar.JoinedParticipants(h.DB, schedule.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+		conflicts, err := checkConflicts(c, h.DB, schedule, participantIds)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+		if len(conflicts) > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message":   "Schedule conflicts with existing schedules",
+				"conflicts": conflicts,
+			})
+		}
+	}
 
 	// Update timestamp
 	now := time.Now()
 	schedule.UpdatedAt = &now
 
-	// Lưu schedule đã cập nhật
-	if result := h.DB.Omit("deleted_at").Save(&schedule); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
-	}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Lưu schedule đã cập nhật
+		if result := tx.Omit("deleted_at").Save(&schedule); result.Error != nil {
+			return result.Error
+		}
 
-	// Thêm các log vào cơ sở dữ liệu
-	if len(logs) > 0 {
-		if result := h.DB.Create(&logs); result.Error != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+		// Thêm các log vào cơ sở dữ liệu
+		if len(logs) > 0 {
+			if result := tx.Create(&logs); result.Error != nil {
+				return result.Error
+			}
 		}
+
+		// Move the relative reminders along with the schedule
+		if err := notification.SyncScheduleReminders(tx, schedule.ID, notification.WallClockNow()); err != nil {
+			return err
+		}
+		return webhook.Emit(tx, schedule.WorkspaceId, webhook.EventScheduleUpdated, webhook.NewScheduleChange(schedule, workspaceUserId, logs))
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
 	// Trả về kết quả cập nhật thành công
@@ -553,6 +640,20 @@ func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
 	})
 }
 
+// UpdateSchedulePosition godoc
+// @Summary Move a schedule
+// @Description Move a schedule to a 1-based position of the same or another board column
+// @Tags schedule
+// @Accept json
+// @Produce json
+// @Param schedule_id path int true "Schedule ID"
+// @Param workspace_user_id path int true "Workspace user ID"
+// @Param schedule body core_dtos.TwUpdateSchedulePosition true "Target board column and position"
+// @Param override_wip_limit query bool false "Move the schedule even if the target board column has reached its WIP limit; the override is logged"
+// @Success 200 {object} core_dtos.TwUpdateScheduleResponse
+// @Failure 403 {object} fiber.Map "The workspace user's role does not allow the update"
+// @Failure 409 {object} fiber.Map
+// @Router /dbms/v1/schedule/position/{schedule_id}/workspace_user/{workspace_user_id} [put]
 func (h *ScheduleHandler) UpdateSchedulePosition(c *fiber.Ctx) error {
 	var scheduleDTO core_dtos.TwUpdateSchedulePosition
 	if err := c.BodyParser(&scheduleDTO); err != nil {
@@ -575,108 +676,101 @@ func (h *ScheduleHandler) UpdateSchedulePosition(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
-	var logs []models.TwScheduleLog
-
-	checkAndLog := func(field, oldValue, newValue string) {
-		if oldValue != newValue {
-			logs = append(logs, models.TwScheduleLog{
-				ScheduleId:      schedule.ID,
-				WorkspaceUserId: workspaceUserId,
-				Action:          "update schedule",
-				FieldChanged:    field,
-				OldValue:        oldValue,
-				NewValue:        newValue,
-			})
-		}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, schedule.WorkspaceId, policy.Schedule, policy.Update, schedule.CreatedBy); err != nil {
+		return common.SendPolicyError(c, err)
 	}
 
-	if scheduleDTO.BoardColumnID != nil {
-		if *scheduleDTO.BoardColumnID == schedule.BoardColumnId {
-			if *scheduleDTO.Position < schedule.Position {
-				var schedulesToUpdate []models.TwSchedule
-				if err := h.DB.Where("board_column_id = ? AND position < ? AND position >= ? AND is_deleted != 1", schedule.BoardColumnId, schedule.Position, scheduleDTO.Position).
-					Order("position ASC").Find(&schedulesToUpdate).Error; err != nil {
-					return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-				}
-
-				for i := range schedulesToUpdate {
-					schedulesToUpdate[i].Position += 1
-					if err := h.DB.Omit("deleted_at,start_time,end_time").Save(&schedulesToUpdate[i]).Error; err != nil {
-						return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-					}
-				}
-			} else if *scheduleDTO.Position > schedule.Position {
-				var schedulesToUpdate []models.TwSchedule
-				if err := h.DB.Where("board_column_id = ? AND position > ? AND position <= ? AND is_deleted != 1", schedule.BoardColumnId, schedule.Position, scheduleDTO.Position).
-					Order("position ASC").Find(&schedulesToUpdate).Error; err != nil {
-					return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-				}
-
-				for i := range schedulesToUpdate {
-					schedulesToUpdate[i].Position -= 1
-					if err := h.DB.Omit("deleted_at,start_time,end_time").Save(&schedulesToUpdate[i]).Error; err != nil {
-						return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-					}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the source and target columns in ID order, then reload the
+		// schedule so that its position reflects any concurrent move.
+		columnIds := []int{schedule.BoardColumnId}
+		if scheduleDTO.BoardColumnID != nil && *scheduleDTO.BoardColumnID != schedule.BoardColumnId {
+			columnIds = append(columnIds, *scheduleDTO.BoardColumnID)
+			sort.Ints(columnIds)
+		}
+		for _, columnId := range columnIds {
+			if _, err := board.LockColumn(tx, columnId); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fiber.NewError(fiber.StatusNotFound, "BoardColumn not found")
 				}
+				return err
 			}
-			checkAndLog("position", strconv.Itoa(schedule.Position), strconv.Itoa(*scheduleDTO.Position))
-			schedule.Position = *scheduleDTO.Position
-		} else if *scheduleDTO.BoardColumnID != schedule.BoardColumnId {
-			var schedulesToUpdate []models.TwSchedule
-			if err := h.DB.Where("board_column_id = ? AND position > ? AND is_deleted != 1", schedule.BoardColumnId, schedule.Position).
-				Order("position ASC").Find(&schedulesToUpdate).Error; err != nil {
-				return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-			}
+		}
+		if err := tx.Where("id = ?", schedule.ID).First(&schedule).Error; err != nil {
+			return err
+		}
 
-			for i := range schedulesToUpdate {
-				schedulesToUpdate[i].Position -= 1
-				if err := h.DB.Omit("deleted_at,start_time,end_time").Save(&schedulesToUpdate[i]).Error; err != nil {
-					return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-				}
+		var logs []models.TwScheduleLog
+
+		checkAndLog := func(field, oldValue, newValue string) {
+			if oldValue != newValue {
+				logs = append(logs, models.TwScheduleLog{
+					ScheduleId:      schedule.ID,
+					WorkspaceUserId: workspaceUserId,
+					Action:          "update schedule",
+					FieldChanged:    field,
+					OldValue:        oldValue,
+					NewValue:        newValue,
+				})
 			}
-			var schedulesInBoardColumnToUpdate []models.TwSchedule
-			if err := h.DB.Where("board_column_id = ? AND position >= ? AND is_deleted != 1", scheduleDTO.BoardColumnID, scheduleDTO.Position).
-				Order("position ASC").Find(&schedulesInBoardColumnToUpdate).Error; err != nil {
-				return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		oldColumnId := schedule.BoardColumnId
+		oldPosition := 0
+		if scheduleDTO.BoardColumnID != nil {
+			positions, err := board.SchedulePositions(tx, oldColumnId)
+			if err != nil {
+				return err
 			}
-			if len(schedulesInBoardColumnToUpdate) == 0 {
-				var maxPosition int
-				if err := h.DB.Model(&models.TwSchedule{}).
-					Where("board_column_id = ? AND is_deleted != 1", scheduleDTO.BoardColumnID).
-					Select("COALESCE(MAX(position), 0)").Scan(&maxPosition).Error; err != nil {
-					return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+			oldPosition = positions[schedule.ID]
+			if *scheduleDTO.BoardColumnID != schedule.BoardColumnId {
+				wip, overridden, err := board.EnforceWipLimit(tx, *scheduleDTO.BoardColumnID, c.QueryBool("override_wip_limit"))
+				if err != nil {
+					return err
 				}
-				checkAndLog("position", strconv.Itoa(schedule.Position), strconv.Itoa(*scheduleDTO.Position))
-				schedule.Position = maxPosition + 1
-			} else {
-				for i := range schedulesInBoardColumnToUpdate {
-					schedulesInBoardColumnToUpdate[i].Position += 1
-					if err := h.DB.Omit("deleted_at,start_time,end_time").Save(&schedulesInBoardColumnToUpdate[i]).Error; err != nil {
-						return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-					}
+				if overridden {
+					logs = append(logs, *board.WipOverrideLog(schedule.ID, workspaceUserId, *scheduleDTO.BoardColumnID, wip))
 				}
-				checkAndLog("position", strconv.Itoa(schedule.Position), strconv.Itoa(*scheduleDTO.Position))
-				schedule.Position = *scheduleDTO.Position
 			}
+			// Only the rank row of the moved card is rewritten; the positions
+			// of its siblings are derived from the rank order on read.
+			if _, err := board.MoveSchedule(tx, schedule.ID, *scheduleDTO.BoardColumnID, *scheduleDTO.Position); err != nil {
+				return err
+			}
+			schedule.BoardColumnId = *scheduleDTO.BoardColumnID
 		}
-		checkAndLog("board_column_id", strconv.Itoa(schedule.BoardColumnId), strconv.Itoa(*scheduleDTO.BoardColumnID))
-		schedule.BoardColumnId = *scheduleDTO.BoardColumnID
-	}
 
-	// Update timestamp
-	now := time.Now()
-	schedule.UpdatedAt = &now
+		// Update timestamp
+		now := time.Now()
+		schedule.UpdatedAt = &now
 
-	// Lưu schedule đã cập nhật
-	if result := h.DB.Omit("deleted_at").Save(&schedule); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
-	}
+		// Lưu schedule đã cập nhật. The legacy position column is left alone:
+		// it would go stale for the siblings, so the position is read back
+		// from the rank order instead.
+		if result := tx.Omit("deleted_at", "position").Save(&schedule); result.Error != nil {
+			return result.Error
+		}
 
-	// Thêm các log vào cơ sở dữ liệu
-	if len(logs) > 0 {
-		if result := h.DB.Create(&logs); result.Error != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+		if scheduleDTO.BoardColumnID != nil {
+			positions, err := board.SchedulePositions(tx, schedule.BoardColumnId)
+			if err != nil {
+				return err
+			}
+			schedule.Position = positions[schedule.ID]
+			checkAndLog("position", strconv.Itoa(oldPosition), strconv.Itoa(schedule.Position))
+			checkAndLog("board_column_id", strconv.Itoa(oldColumnId), strconv.Itoa(schedule.BoardColumnId))
+		}
+
+		// Thêm các log vào cơ sở dữ liệu
+		if len(logs) > 0 {
+			if result := tx.Create(&logs); result.Error != nil {
+				return result.Error
+			}
 		}
+		return webhook.Emit(tx, schedule.WorkspaceId, webhook.EventScheduleMoved, webhook.NewScheduleChange(schedule, workspaceUserId, logs))
+	})
+	if err != nil {
+		return sendTransactionError(c, err)
 	}
 
 	// Trả về kết quả cập nhật thành công
@@ -711,6 +805,7 @@ func (h *ScheduleHandler) UpdateSchedulePosition(c *fiber.Ctx) error {
 // @Produce json
 // @Param schedule_id path int true "Schedule ID"
 // @Success 204 "No Content"
+// @Failure 403 {object} fiber.Map "The workspace user's role does not allow the deletion"
 // @Router /dbms/v1/schedule/{schedule_id} [delete]
 func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
 	scheduleId := c.Params("schedule_id")
@@ -728,42 +823,72 @@ func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
-	now := time.Now()
+	if _, err := policy.Authorize(h.DB, workspaceUserId, schedule.WorkspaceId, policy.Schedule, policy.Delete, schedule.CreatedBy); err != nil {
+		return common.SendPolicyError(c, err)
+	}
 
-	schedule.IsDeleted = true
-	schedule.UpdatedAt = &now
-	schedule.DeletedAt = &now
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockColumn(tx, schedule.BoardColumnId); err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", schedule.ID).First(&schedule).Error; err != nil {
+			return err
+		}
 
-	if result := h.DB.Omit("start_time,end_time").Save(&schedule); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
-	}
+		now := time.Now()
 
-	var schedulesToUpdate []models.TwSchedule
-	if err := h.DB.Where("board_column_id = ? AND position > ? AND is_deleted != 1", schedule.BoardColumnId, schedule.Position).
-		Order("position ASC").Find(&schedulesToUpdate).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
-	}
+		schedule.IsDeleted = true
+		schedule.UpdatedAt = &now
+		schedule.DeletedAt = &now
 
-	for i := range schedulesToUpdate {
-		schedulesToUpdate[i].Position -= 1
-		if err := h.DB.Omit("deleted_at,start_time,end_time").Save(&schedulesToUpdate[i]).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		if result := tx.Omit("start_time,end_time").Save(&schedule); result.Error != nil {
+			return result.Error
 		}
-	}
 
-	newScheduleLog := models.TwScheduleLog{
-		ScheduleId:      schedule.ID,
-		WorkspaceUserId: workspaceUserId,
-		Action:          "delete schedule",
-	}
+		if err := board.RemoveScheduleRank(tx, schedule.ID); err != nil {
+			return err
+		}
+		if err := notification.SyncScheduleReminders(tx, schedule.ID, notification.WallClockNow()); err != nil {
+			return err
+		}
 
-	if result := h.DB.Create(&newScheduleLog); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+		newScheduleLog := models.TwScheduleLog{
+			ScheduleId:      schedule.ID,
+			WorkspaceUserId: workspaceUserId,
+			Action:          "delete schedule",
+		}
+
+		if err := tx.Create(&newScheduleLog).Error; err != nil {
+			return err
+		}
+		return webhook.Emit(tx, schedule.WorkspaceId, webhook.EventScheduleDeleted, webhook.NewScheduleChange(schedule, workspaceUserId, nil))
+	})
+	if err != nil {
+		return sendTransactionError(c, err)
 	}
 
 	return c.SendStatus(fiber.StatusOK)
 }
 
+// sendTransactionError writes the response for an error returned from a
+// transaction callback; *fiber.Error keeps its status code.
+func sendTransactionError(c *fiber.Ctx, err error) error {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).SendString(fiberErr.Message)
+	}
+	var wipErr *board.WipLimitError
+	if errors.As(err, &wipErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"message":         "Board column has reached its WIP limit",
+			"board_column_id": wipErr.BoardColumnId,
+			"schedule_count":  wipErr.ScheduleCount,
+			"wip_limit":       wipErr.WipLimit,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+}
+
 func (h *ScheduleHandler) GetSchedulesByBoardColumn(c *fiber.Ctx) error {
 	boardColumnID := c.Params("board_column_id")
 	if boardColumnID == "" {
@@ -815,8 +940,8 @@ func (h *ScheduleHandler) getSchedulesByBoardColumn(c *fiber.Ctx) error {
 		})
 	}
 	var schedules []models.TwSchedule
-	if result := h.DB.Where("board_column_id = ? and workspace_id = ? and is_deleted = false", boardColumnID, workspaceID).
-		Order("position").
+	if result := h.DB.Where("tw_schedules.board_column_id = ? and tw_schedules.workspace_id = ? and tw_schedules.is_deleted = false", boardColumnID, workspaceID).
+		Scopes(board.ScheduleOrder).
 		Find(&schedules); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": result.Error.Error(),
@@ -827,6 +952,9 @@ func (h *ScheduleHandler) getSchedulesByBoardColumn(c *fiber.Ctx) error {
 			"message": "Failed to get schedules",
 		})
 	}
+	for i := range schedules {
+		schedules[i].Position = i + 1
+	}
 	return c.JSON(schedules)
 }
 
@@ -836,19 +964,14 @@ func (h *ScheduleHandler) getSchedulesByBoardColumn(c *fiber.Ctx) error {
 // @Tags schedule
 // @Accept json
 // @Produce json
-// @Param x_api_key header string true "API Key"
+// @Param x_api_key header string true "API key with the schedule scope"
 // @Param schedule_id path string true "Schedule ID"
 // @Param video_transcript formData string true "Video transcript"
 // @Success 200 "Updated successfully"
 // @Router /dbms/v1/schedule/{schedule_id}/transcript [put]
 func (h *ScheduleHandler) UpdateTranscriptBySchedule(ctx *fiber.Ctx) error {
-	// get an api_key from params
-	//apiKey := ctx.Get("x_api_key")
-	//if apiKey != "667qwsrUlyVa" {
-	//	return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-	//		"error": "Unauthorized",
-	//	})
-	//}
+	// The x_api_key header is checked by the authentication middleware
+	// against the AUTH.API_KEYS credentials scoped to "schedule".
 
 	// Parse schedule_id from params
 	scheduleId := ctx.Params("schedule_id")
@@ -930,7 +1053,7 @@ func (h *ScheduleHandler) getSchedulesByBoardColumnFilter(c *fiber.Ctx) error {
 	var schedules []models.TwSchedule
 	query := h.DB.
 		Table("tw_schedules").
-		Select("DISTINCT tw_schedules.*").
+		Select("DISTINCT tw_schedules.*, tw_schedule_ranks.rank_key").
 		Joins("JOIN tw_schedule_participants ON tw_schedule_participants.schedule_id = tw_schedules.id").
 		Joins("JOIN tw_workspaces ON tw_workspaces.id = tw_schedules.workspace_id")
 
@@ -974,7 +1097,7 @@ func (h *ScheduleHandler) getSchedulesByBoardColumnFilter(c *fiber.Ctx) error {
 		Where("tw_schedules.board_column_id = ? AND tw_schedules.workspace_id = ? AND tw_schedules.is_deleted = false AND tw_workspaces.deleted_at IS NULL", boardColumnID, workspaceID)
 
 	if result := query.
-		Order("tw_schedules.position").
+		Scopes(board.ScheduleOrder).
 		Find(&schedules); result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"message": result.Error.Error(),
@@ -985,6 +1108,22 @@ func (h *ScheduleHandler) getSchedulesByBoardColumnFilter(c *fiber.Ctx) error {
 			"message": "Failed to get schedules",
 		})
 	}
+	// Positions are reported within the whole column, not the filtered list.
+	columnId, err := strconv.Atoi(boardColumnID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid board column ID",
+		})
+	}
+	positions, err := board.SchedulePositions(h.DB, columnId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	for i := range schedules {
+		schedules[i].Position = positions[schedules[i].ID]
+	}
 
 	return c.JSON(schedules)
 }
@@ -0,0 +1,238 @@
+package schedule
+
+import (
+	"bytes"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"dbms/services/board"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/dtos/core_dtos"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testWorkspaceId = 1
+	testOwnerId     = 10
+)
+
+// newTestApp serves the schedule routes over a fresh database holding one
+// workspace owner and two board columns, 1 and 2.
+func newTestApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwWorkspaceUser{},
+		&models.TwBoardColumn{},
+		&models.TwSchedule{},
+		&models.TwScheduleLog{},
+		&models.TwScheduleParticipant{},
+		&models.TwRecurrenceException{},
+		&models.TwReminder{},
+		&dms_models.TwScheduleRank{},
+		&dms_models.TwBoardColumnLimit{},
+		&dms_models.TwReminderRule{},
+		&dms_models.TwReminderRuleOccurrence{},
+		&dms_models.TwScheduleICalLink{},
+		&dms_models.TwWebhookSubscription{},
+		&dms_models.TwWebhookEvent{},
+	)
+	seed(t, db,
+		&models.TwWorkspaceUser{ID: testOwnerId, WorkspaceId: testWorkspaceId, Role: "owner", Status: "joined", IsActive: true},
+		&models.TwBoardColumn{ID: 1, WorkspaceId: testWorkspaceId, Name: "To do", Position: 1},
+		&models.TwBoardColumn{ID: 2, WorkspaceId: testWorkspaceId, Name: "Done", Position: 2},
+	)
+
+	app := fiber.New()
+	RegisterScheduleHandler(app.Group("/schedule"), db)
+	return app, db
+}
+
+func seed(t *testing.T, db *gorm.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+}
+
+// seedSchedule creates a schedule in a board column the way CreateSchedule
+// does.
+func seedSchedule(t *testing.T, db *gorm.DB, boardColumnId int) models.TwSchedule {
+	t.Helper()
+	now := time.Now()
+	schedule := models.TwSchedule{
+		WorkspaceId:   testWorkspaceId,
+		BoardColumnId: boardColumnId,
+		Title:         "Card",
+		StartTime:     &now,
+		EndTime:       &now,
+		CreatedBy:     testOwnerId,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+		Status:        "not yet",
+		Visibility:    "public",
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return createScheduleWithCreator(tx, &schedule, testOwnerId)
+	})
+	if err != nil {
+		t.Fatalf("seed schedule: %v", err)
+	}
+	return schedule
+}
+
+// request sends body as JSON and decodes a successful response into out when
+// out is not nil.
+func request(t *testing.T, app *fiber.App, method, target string, body, out interface{}) int {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
+
+func TestCreateScheduleRollsBackWhenParticipantInsertFails(t *testing.T) {
+	app, db := newTestApp(t)
+	dbtest.FailWrites(t, db, "tw_schedule_participants")
+
+	status := request(t, app, http.MethodPost, "/schedule", map[string]interface{}{
+		"workspace_id":      testWorkspaceId,
+		"workspace_user_id": testOwnerId,
+		"board_column_id":   1,
+		"title":             stringPtr("Card"),
+		"description":       stringPtr(""),
+	}, nil)
+	if status != fiber.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, fiber.StatusInternalServerError)
+	}
+	for _, table := range []string{"tw_schedules", "tw_schedule_logs", "tw_schedule_participants", "tw_schedule_ranks"} {
+		if count := dbtest.Count(t, db, table); count != 0 {
+			t.Errorf("%s has %d rows after the failed create, want 0", table, count)
+		}
+	}
+}
+
+func TestUpdateSchedulePositionRollsBackWhenRankUpdateFails(t *testing.T) {
+	app, db := newTestApp(t)
+	schedule := seedSchedule(t, db, 1)
+	var rankBefore dms_models.TwScheduleRank
+	if err := db.Where("schedule_id = ?", schedule.ID).First(&rankBefore).Error; err != nil {
+		t.Fatalf("load rank: %v", err)
+	}
+	logsBefore := dbtest.Count(t, db, "tw_schedule_logs")
+	dbtest.FailWrites(t, db, "tw_schedule_ranks")
+
+	status := request(t, app, http.MethodPut, fmt.Sprintf("/schedule/position/%d/workspace_user/%d", schedule.ID, testOwnerId),
+		map[string]interface{}{"board_column_id": intPtr(2), "position": intPtr(1)}, nil)
+	if status != fiber.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, fiber.StatusInternalServerError)
+	}
+
+	var reloaded models.TwSchedule
+	if err := db.First(&reloaded, schedule.ID).Error; err != nil {
+		t.Fatalf("reload schedule: %v", err)
+	}
+	if reloaded.BoardColumnId != 1 || reloaded.Position != schedule.Position {
+		t.Errorf("schedule moved to column %d position %d, want column 1 position %d", reloaded.BoardColumnId, reloaded.Position, schedule.Position)
+	}
+	var rankAfter dms_models.TwScheduleRank
+	if err := db.Where("schedule_id = ?", schedule.ID).First(&rankAfter).Error; err != nil {
+		t.Fatalf("load rank: %v", err)
+	}
+	if rankAfter.BoardColumnId != rankBefore.BoardColumnId || rankAfter.RankKey != rankBefore.RankKey {
+		t.Errorf("rank = %+v, want %+v", rankAfter, rankBefore)
+	}
+	if count := dbtest.Count(t, db, "tw_schedule_logs"); count != logsBefore {
+		t.Errorf("tw_schedule_logs has %d rows after the failed move, want %d", count, logsBefore)
+	}
+}
+
+func TestDeleteScheduleRollsBackWhenLogInsertFails(t *testing.T) {
+	app, db := newTestApp(t)
+	schedule := seedSchedule(t, db, 1)
+	dbtest.FailWrites(t, db, "tw_schedule_logs")
+
+	status := request(t, app, http.MethodDelete, fmt.Sprintf("/schedule/%d/workspace_user/%d", schedule.ID, testOwnerId), nil, nil)
+	if status != fiber.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, fiber.StatusInternalServerError)
+	}
+
+	var reloaded models.TwSchedule
+	if err := db.First(&reloaded, schedule.ID).Error; err != nil {
+		t.Fatalf("reload schedule: %v", err)
+	}
+	if reloaded.IsDeleted || reloaded.DeletedAt != nil {
+		t.Errorf("schedule was deleted by the failed request: is_deleted=%v deleted_at=%v", reloaded.IsDeleted, reloaded.DeletedAt)
+	}
+	if count := dbtest.Count(t, db, "tw_schedule_ranks", "schedule_id = ?", schedule.ID); count != 1 {
+		t.Errorf("schedule has %d rank rows after the failed delete, want 1", count)
+	}
+}
+
+func TestUpdateSchedulePositionReportsRankPosition(t *testing.T) {
+	app, db := newTestApp(t)
+	moved := seedSchedule(t, db, 1)
+	first := seedSchedule(t, db, 2)
+	last := seedSchedule(t, db, 2)
+
+	var response core_dtos.TwUpdateScheduleResponse
+	status := request(t, app, http.MethodPut, fmt.Sprintf("/schedule/position/%d/workspace_user/%d", moved.ID, testOwnerId),
+		map[string]interface{}{"board_column_id": intPtr(2), "position": intPtr(2)}, &response)
+	if status != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", status, fiber.StatusOK)
+	}
+	if response.BoardColumnID != 2 || response.Position != 2 {
+		t.Errorf("response column %d position %d, want column 2 position 2", response.BoardColumnID, response.Position)
+	}
+
+	positions, err := board.SchedulePositions(db, 2)
+	if err != nil {
+		t.Fatalf("SchedulePositions: %v", err)
+	}
+	want := map[int]int{first.ID: 1, moved.ID: 2, last.ID: 3}
+	for id, position := range want {
+		if positions[id] != position {
+			t.Errorf("position of %d = %d, want %d", id, positions[id], position)
+		}
+	}
+
+	var logs []models.TwScheduleLog
+	if err := db.Where("schedule_id = ? AND field_changed = ?", moved.ID, "position").Find(&logs).Error; err != nil {
+		t.Fatalf("load logs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].OldValue != "1" || logs[0].NewValue != "2" {
+		t.Errorf("position logs = %+v, want one change from 1 to 2", logs)
+	}
+}
@@ -0,0 +1,485 @@
+package schedule
+
+import (
+	"dbms/dms_models"
+	"dbms/ical"
+	"dbms/recurrence"
+	"dbms/services/board"
+	"dbms/services/calendar"
+	"dbms/services/notification"
+	"dbms/services/webhook"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+const scheduleUIDFormat = "schedule-%d@timewise.space"
+
+// ExportWorkspaceICal godoc
+// @Summary Export workspace schedules as iCalendar
+// @Description Render the non-deleted schedules of a workspace as an iCalendar (.ics) feed
+// @Tags schedule
+// @Produce text/calendar
+// @Param workspace_id path int true "Workspace ID"
+// @Success 200 {string} string "iCalendar document"
+// @Failure 404 {string} string "Workspace not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /dbms/v1/schedule/workspace/{workspace_id}/ics [get]
+func (h *ScheduleHandler) ExportWorkspaceICal(c *fiber.Ctx) error {
+	workspaceID := c.Params("workspace_id")
+
+	var workspace models.TwWorkspace
+	if err := h.DB.Where("id = ? AND deleted_at IS NULL AND is_deleted = false", workspaceID).First(&workspace).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString("Workspace not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	var schedules []models.TwSchedule
+	if err := h.DB.Where("workspace_id = ? AND is_deleted = false AND deleted_at IS NULL AND start_time IS NOT NULL", workspace.ID).
+		Order("start_time").
+		Find(&schedules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	return h.sendCalendar(c, workspace.Title, fmt.Sprintf("workspace-%d.ics", workspace.ID), schedules)
+}
+
+// ExportUserICal godoc
+// @Summary Export user schedules as iCalendar
+// @Description Render the schedules of every workspace joined by the user's linked emails as an iCalendar (.ics) feed
+// @Tags schedule
+// @Produce text/calendar
+// @Param user_id path int true "User ID"
+// @Success 200 {string} string "iCalendar document"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /dbms/v1/schedule/user/{user_id}/ics [get]
+func (h *ScheduleHandler) ExportUserICal(c *fiber.Ctx) error {
+	userId := c.Params("user_id")
+
+	// Same join chain as getWorkspacesByUserId in the workspace handler.
+	workspaceIds := h.DB.
+		Table("tw_workspaces").
+		Select("tw_workspaces.id").
+		Joins("JOIN tw_workspace_users ON tw_workspaces.id = tw_workspace_users.workspace_id").
+		Joins("JOIN tw_user_emails ON tw_workspace_users.user_email_id= tw_user_emails.id").
+		Joins("JOIN tw_users ON tw_user_emails.user_id = tw_users.id").
+		Where("tw_users.id = ? and tw_workspace_users.is_active = true and tw_workspace_users.is_verified = true and tw_workspace_users.role != 'guest' and tw_workspace_users.status ='joined'", userId).
+		Where("tw_workspaces.deleted_at IS NULL").
+		Where("tw_workspace_users.deleted_at IS NULL").
+		Where("tw_user_emails.deleted_at IS NULL").
+		Where("tw_users.deleted_at IS NULL").
+		Where("tw_workspaces.is_deleted = false")
+
+	var schedules []models.TwSchedule
+	if err := h.DB.Where("workspace_id IN (?) AND is_deleted = false AND deleted_at IS NULL AND start_time IS NOT NULL", workspaceIds).
+		Order("start_time").
+		Find(&schedules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	return h.sendCalendar(c, "Timewise", fmt.Sprintf("user-%s.ics", userId), schedules)
+}
+
+func (h *ScheduleHandler) sendCalendar(c *fiber.Ctx, name string, fileName string, schedules []models.TwSchedule) error {
+	exceptions, err := calendar.RecurrenceExceptions(h.DB, schedules)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	uids, err := getScheduleUIDs(h.DB, schedules)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	calendar := ical.Calendar{Name: name}
+	for _, schedule := range schedules {
+		calendar.Events = append(calendar.Events, scheduleToEvents(schedule, uids[schedule.ID], exceptions[schedule.ID])...)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	return c.Send(calendar.Encode())
+}
+
+// scheduleToEvents maps a schedule to its VEVENT. Cancelled recurrence
+// exceptions become EXDATEs; moved instances become overriding VEVENTs
+// carrying a RECURRENCE-ID.
+func scheduleToEvents(schedule models.TwSchedule, uid string, exceptions []models.TwRecurrenceException) []ical.Event {
+	now := time.Now()
+	event := ical.Event{
+		UID:          uid,
+		Summary:      schedule.Title,
+		Description:  schedule.Description,
+		Location:     schedule.Location,
+		Start:        *schedule.StartTime,
+		AllDay:       schedule.AllDay,
+		Created:      schedule.CreatedAt,
+		LastModified: schedule.UpdatedAt,
+		Stamp:        now,
+	}
+	if schedule.EndTime != nil {
+		event.End = *schedule.EndTime
+	}
+	events := []ical.Event{event}
+	if schedule.RecurrencePattern == "" {
+		return events
+	}
+
+	rule, err := recurrence.Parse(schedule.RecurrencePattern)
+	if err != nil {
+		return events
+	}
+	events[0].RRule = rule.String()
+
+	duration := event.End.Sub(event.Start)
+	for _, exception := range exceptions {
+		day := exception.ExceptionDate.UTC()
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		originals := rule.Between(schedule.StartTime.UTC(), dayStart, dayStart.AddDate(0, 0, 1))
+		if len(originals) == 0 {
+			continue
+		}
+		original := originals[0]
+		if exception.IsCancelled || exception.NewStartTime.IsZero() {
+			events[0].ExDates = append(events[0].ExDates, original)
+			continue
+		}
+		override := event
+		override.RecurrenceID = &original
+		override.Start = exception.NewStartTime
+		override.End = exception.NewStartTime.Add(duration)
+		if exception.NewEndTime.After(exception.NewStartTime) {
+			override.End = exception.NewEndTime
+		}
+		events = append(events, override)
+	}
+	return events
+}
+
+// getScheduleUIDs returns the iCalendar UID of each schedule: the UID it was
+// imported from when there is one, otherwise one derived from its ID.
+func getScheduleUIDs(db *gorm.DB, schedules []models.TwSchedule) (map[int]string, error) {
+	uids := make(map[int]string, len(schedules))
+	scheduleIds := make([]int, 0, len(schedules))
+	for _, schedule := range schedules {
+		uids[schedule.ID] = fmt.Sprintf(scheduleUIDFormat, schedule.ID)
+		scheduleIds = append(scheduleIds, schedule.ID)
+	}
+	if len(scheduleIds) == 0 {
+		return uids, nil
+	}
+	var links []dms_models.TwScheduleICalLink
+	if err := db.Where("schedule_id IN (?)", scheduleIds).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		uids[link.ScheduleId] = link.UID
+	}
+	return uids, nil
+}
+
+type ICalImportSkipped struct {
+	UID    string `json:"uid"`
+	Reason string `json:"reason"`
+}
+
+type ICalImportResponse struct {
+	Created []int               `json:"created"`
+	Updated []int               `json:"updated"`
+	Skipped []ICalImportSkipped `json:"skipped"`
+}
+
+// ImportICal godoc
+// @Summary Import schedules from an iCalendar file
+// @Description Create schedules in a board column from the VEVENTs of an .ics file. Events already imported into the workspace (matched on UID) are updated instead of duplicated, and their recurrence exceptions replaced by the file's. Recurring events in a time zone observing daylight saving time are skipped, as recurrences are expanded in UTC.
+// @Tags schedule
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "iCalendar file"
+// @Param workspace_id formData int true "Workspace ID"
+// @Param board_column_id formData int true "Board Column ID"
+// @Param workspace_user_id formData int true "Workspace User ID of the importer"
+// @Success 200 {object} ICalImportResponse
+// @Failure 400 {string} string "Invalid request"
+// @Failure 404 {string} string "BoardColumn not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /dbms/v1/schedule/import/ics [post]
+func (h *ScheduleHandler) ImportICal(c *fiber.Ctx) error {
+	workspaceId, err := strconv.Atoi(c.FormValue("workspace_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid workspace_id")
+	}
+	boardColumnId, err := strconv.Atoi(c.FormValue("board_column_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid board_column_id")
+	}
+	workspaceUserId, err := strconv.Atoi(c.FormValue("workspace_user_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid workspace_user_id")
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("File is required")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	defer file.Close()
+	events, err := ical.Parse(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid iCalendar file: " + err.Error())
+	}
+
+	var boardColumn models.TwBoardColumn
+	if err := h.DB.Where("id = ? AND workspace_id = ? AND deleted_at IS NULL", boardColumnId, workspaceId).First(&boardColumn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).SendString("BoardColumn not found")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+	var workspaceUser models.TwWorkspaceUser
+	if err := h.DB.Where("id = ? AND workspace_id = ? AND deleted_at IS NULL", workspaceUserId, workspaceId).First(&workspaceUser).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusBadRequest).SendString("Workspace user does not belong to the workspace")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// Overriding instances (RECURRENCE-ID) are applied to their master event.
+	var masters []ical.Event
+	overrides := make(map[string][]ical.Event)
+	for _, event := range events {
+		if event.RecurrenceID != nil {
+			overrides[event.UID] = append(overrides[event.UID], event)
+		} else {
+			masters = append(masters, event)
+		}
+	}
+
+	result := ICalImportResponse{Created: []int{}, Updated: []int{}, Skipped: []ICalImportSkipped{}}
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := board.LockColumn(tx, boardColumn.ID); err != nil {
+			return err
+		}
+		var position int64
+		if err := tx.Model(&models.TwSchedule{}).Where("board_column_id = ? and is_deleted = false", boardColumnId).Count(&position).Error; err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool)
+		for _, event := range masters {
+			if event.UID == "" {
+				result.Skipped = append(result.Skipped, ICalImportSkipped{Reason: "Event has no UID"})
+				continue
+			}
+			if seen[event.UID] {
+				result.Skipped = append(result.Skipped, ICalImportSkipped{UID: event.UID, Reason: "Duplicate UID in file"})
+				continue
+			}
+			seen[event.UID] = true
+			if event.Status == "CANCELLED" {
+				result.Skipped = append(result.Skipped, ICalImportSkipped{UID: event.UID, Reason: "Event is cancelled"})
+				continue
+			}
+			pattern := ""
+			if event.RRule != "" {
+				rule, err := recurrence.Parse(event.RRule)
+				if err != nil {
+					result.Skipped = append(result.Skipped, ICalImportSkipped{UID: event.UID, Reason: err.Error()})
+					continue
+				}
+				pattern = rule.String()
+				if event.ObservesDST() {
+					result.Skipped = append(result.Skipped, ICalImportSkipped{UID: event.UID,
+						Reason: fmt.Sprintf("Recurring events in time zone %s are not supported, it observes daylight saving time", event.TZID)})
+					continue
+				}
+			}
+
+			schedule, found, err := findImportedSchedule(tx, workspaceId, event.UID)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			start, end := event.Start.UTC(), event.End.UTC()
+			if found {
+				schedule.Title = event.Summary
+				schedule.Description = event.Description
+				schedule.Location = event.Location
+				schedule.StartTime = &start
+				schedule.EndTime = &end
+				schedule.AllDay = event.AllDay
+				schedule.RecurrencePattern = pattern
+				schedule.UpdatedAt = &now
+				if err := tx.Omit("deleted_at").Save(&schedule).Error; err != nil {
+					return err
+				}
+				if err := tx.Create(&models.TwScheduleLog{
+					ScheduleId:      schedule.ID,
+					WorkspaceUserId: workspaceUserId,
+					Action:          "update schedule",
+					Description:     "Updated from iCalendar import",
+				}).Error; err != nil {
+					return err
+				}
+				if err := webhook.Emit(tx, workspaceId, webhook.EventScheduleUpdated, webhook.NewScheduleChange(schedule, workspaceUserId, nil)); err != nil {
+					return err
+				}
+				result.Updated = append(result.Updated, schedule.ID)
+			} else {
+				position++
+				schedule = models.TwSchedule{
+					WorkspaceId:       workspaceId,
+					BoardColumnId:     boardColumnId,
+					Title:             event.Summary,
+					Description:       event.Description,
+					Location:          event.Location,
+					StartTime:         &start,
+					EndTime:           &end,
+					AllDay:            event.AllDay,
+					RecurrencePattern: pattern,
+					CreatedBy:         workspaceUserId,
+					CreatedAt:         &now,
+					UpdatedAt:         &now,
+					Position:          int(position),
+					Status:            "not yet",
+					Visibility:        "public",
+				}
+				if err := createScheduleWithCreator(tx, &schedule, workspaceUserId); err != nil {
+					return err
+				}
+				result.Created = append(result.Created, schedule.ID)
+			}
+
+			if err := saveICalLink(tx, workspaceId, event.UID, schedule.ID); err != nil {
+				return err
+			}
+			if pattern != "" {
+				if err := syncICalExceptions(tx, schedule.ID, event, overrides[event.UID]); err != nil {
+					return err
+				}
+			}
+			if found {
+				// Move the relative reminders along with the schedule
+				if err := notification.SyncScheduleReminders(tx, schedule.ID, notification.WallClockNow()); err != nil {
+					return err
+				}
+			}
+		}
+
+		for uid, instances := range overrides {
+			if !seen[uid] {
+				result.Skipped = append(result.Skipped, ICalImportSkipped{UID: uid, Reason: fmt.Sprintf("%d overriding instance(s) without a recurring event", len(instances))})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	return c.JSON(result)
+}
+
+// findImportedSchedule looks a UID up among the schedules previously imported
+// into the workspace, then among the UIDs this service exports itself.
+func findImportedSchedule(tx *gorm.DB, workspaceId int, uid string) (models.TwSchedule, bool, error) {
+	var schedule models.TwSchedule
+	scheduleId := 0
+	var link dms_models.TwScheduleICalLink
+	err := tx.Where("workspace_id = ? AND uid = ?", workspaceId, uid).First(&link).Error
+	switch {
+	case err == nil:
+		scheduleId = link.ScheduleId
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if _, scanErr := fmt.Sscanf(uid, scheduleUIDFormat, &scheduleId); scanErr != nil || fmt.Sprintf(scheduleUIDFormat, scheduleId) != uid {
+			return schedule, false, nil
+		}
+	default:
+		return schedule, false, err
+	}
+
+	err = tx.Where("id = ? AND workspace_id = ? AND is_deleted = false", scheduleId, workspaceId).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.TwSchedule{}, false, nil
+	}
+	return schedule, err == nil, err
+}
+
+func saveICalLink(tx *gorm.DB, workspaceId int, uid string, scheduleId int) error {
+	var link dms_models.TwScheduleICalLink
+	err := tx.Where("workspace_id = ? AND uid = ?", workspaceId, uid).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&dms_models.TwScheduleICalLink{WorkspaceId: workspaceId, UID: uid, ScheduleId: scheduleId}).Error
+	}
+	if err != nil {
+		return err
+	}
+	if link.ScheduleId == scheduleId {
+		return nil
+	}
+	return tx.Model(&link).Update("schedule_id", scheduleId).Error
+}
+
+// syncICalExceptions records EXDATEs as cancelled recurrence exceptions and
+// RECURRENCE-ID instances as moved (or cancelled) ones, updating the existing
+// exception for the same day so that re-imports stay idempotent. The file is
+// the source of truth: the other exceptions of the schedule are deleted.
+func syncICalExceptions(tx *gorm.DB, scheduleId int, master ical.Event, overrides []ical.Event) error {
+	var exceptions []models.TwRecurrenceException
+	for _, exDate := range master.ExDates {
+		exceptions = append(exceptions, models.TwRecurrenceException{
+			ScheduleId:    scheduleId,
+			ExceptionDate: exDate.UTC(),
+			IsCancelled:   true,
+		})
+	}
+	for _, override := range overrides {
+		exceptions = append(exceptions, models.TwRecurrenceException{
+			ScheduleId:    scheduleId,
+			ExceptionDate: override.RecurrenceID.UTC(),
+			NewStartTime:  override.Start.UTC(),
+			NewEndTime:    override.End.UTC(),
+			IsCancelled:   override.Status == "CANCELLED",
+		})
+	}
+
+	keptIds := make([]int, 0, len(exceptions))
+	for _, exception := range exceptions {
+		var existing models.TwRecurrenceException
+		err := tx.Where("schedule_id = ? AND DATE(exception_date) = ? AND deleted_at IS NULL", scheduleId, exception.ExceptionDate.Format("2006-01-02")).
+			First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.Create(&exception).Error; err != nil {
+				return err
+			}
+			keptIds = append(keptIds, exception.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		existing.ExceptionDate = exception.ExceptionDate
+		existing.NewStartTime = exception.NewStartTime
+		existing.NewEndTime = exception.NewEndTime
+		existing.IsCancelled = exception.IsCancelled
+		if err := tx.Omit("deleted_at").Save(&existing).Error; err != nil {
+			return err
+		}
+		keptIds = append(keptIds, existing.ID)
+	}
+
+	stale := tx.Model(&models.TwRecurrenceException{}).Where("schedule_id = ? AND deleted_at IS NULL", scheduleId)
+	if len(keptIds) > 0 {
+		stale = stale.Where("id NOT IN (?)", keptIds)
+	}
+	return stale.Update("deleted_at", gorm.Expr("NOW()")).Error
+}
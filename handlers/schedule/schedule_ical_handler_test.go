@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"bytes"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"dbms/services/notification"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// importICal imports a calendar into board column 1 as the workspace owner.
+func importICal(t *testing.T, app *fiber.App, calendar string) ICalImportResponse {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range map[string]int{"workspace_id": testWorkspaceId, "board_column_id": 1, "workspace_user_id": testOwnerId} {
+		if err := form.WriteField(name, strconv.Itoa(value)); err != nil {
+			t.Fatalf("write field: %v", err)
+		}
+	}
+	file, err := form.CreateFormFile("file", "calendar.ics")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	file.Write([]byte(calendar))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/schedule/import/ics", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	var result ICalImportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return result
+}
+
+func TestImportICalMovesRemindersOfUpdatedSchedules(t *testing.T) {
+	app, db := newTestApp(t)
+	schedule := seedSchedule(t, db, 1)
+	start := notification.WallClockNow().Add(48 * time.Hour).Truncate(time.Minute)
+	if err := db.Model(&schedule).Updates(map[string]interface{}{"start_time": start, "end_time": start.Add(time.Hour)}).Error; err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	rule := dms_models.TwReminderRule{ScheduleId: schedule.ID, OffsetMinutes: 15, WorkspaceUserID: testOwnerId}
+	if err := notification.CreateReminderRule(db, &rule, notification.WallClockNow()); err != nil {
+		t.Fatalf("create reminder rule: %v", err)
+	}
+
+	// Re-importing the exported event a day later moves it.
+	moved := start.Add(24 * time.Hour)
+	calendar := fmt.Sprintf("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VEVENT\r\n"+
+		"UID:"+scheduleUIDFormat+"\r\nDTSTAMP:20240101T000000Z\r\nDTSTART:%s\r\nDTEND:%s\r\nSUMMARY:Card\r\n"+
+		"END:VEVENT\r\nEND:VCALENDAR\r\n", schedule.ID, moved.Format("20060102T150405Z"), moved.Add(time.Hour).Format("20060102T150405Z"))
+	importICal(t, app, calendar)
+
+	var reminders []models.TwReminder
+	if err := db.Where("schedule_id = ? AND deleted_at IS NULL", schedule.ID).Find(&reminders).Error; err != nil {
+		t.Fatalf("load reminders: %v", err)
+	}
+	want := moved.Add(-15 * time.Minute)
+	if len(reminders) != 1 {
+		t.Fatalf("%d reminders, want 1", len(reminders))
+	}
+	if !reminders[0].ReminderTime.Equal(want) {
+		t.Errorf("reminder at %s, want %s", reminders[0].ReminderTime, want)
+	}
+}
+
+func TestImportICalDeletesExceptionsRemovedFromTheFile(t *testing.T) {
+	app, db := newTestApp(t)
+	series := func(lines ...string) string {
+		return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VEVENT\r\nUID:series@example.com\r\n" +
+			"DTSTART:20240101T090000Z\r\nDTEND:20240101T100000Z\r\nSUMMARY:Standup\r\nRRULE:FREQ=DAILY;COUNT=10\r\n" +
+			strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n"
+	}
+	moved := "BEGIN:VEVENT\r\nUID:series@example.com\r\nRECURRENCE-ID:20240105T090000Z\r\n" +
+		"DTSTART:20240105T140000Z\r\nDTEND:20240105T150000Z\r\nSUMMARY:Standup\r\nEND:VEVENT"
+
+	first := importICal(t, app, series("EXDATE:20240102T090000Z,20240103T090000Z", "END:VEVENT", moved))
+	if len(first.Created) != 1 {
+		t.Fatalf("created %v, want one schedule", first.Created)
+	}
+	if count := dbtest.Count(t, db, "tw_recurrence_exceptions", "deleted_at IS NULL"); count != 3 {
+		t.Fatalf("%d exceptions after the first import, want 3", count)
+	}
+
+	// The second version of the file only cancels January 3rd.
+	second := importICal(t, app, series("EXDATE:20240103T090000Z", "END:VEVENT"))
+	if len(second.Updated) != 1 {
+		t.Fatalf("updated %v, want the imported schedule", second.Updated)
+	}
+	var exceptions []models.TwRecurrenceException
+	if err := db.Where("schedule_id = ? AND deleted_at IS NULL", first.Created[0]).Find(&exceptions).Error; err != nil {
+		t.Fatalf("load exceptions: %v", err)
+	}
+	var days []string
+	for _, exception := range exceptions {
+		days = append(days, fmt.Sprintf("%s cancelled=%v", exception.ExceptionDate.UTC().Format("2006-01-02"), exception.IsCancelled))
+	}
+	if len(days) != 1 || days[0] != "2024-01-03 cancelled=true" {
+		t.Errorf("exceptions after the second import = %v, want only the cancellation of January 3rd", days)
+	}
+}
+
+func TestImportICalSkipsRecurringEventsInDSTZones(t *testing.T) {
+	app, db := newTestApp(t)
+	event := func(uid, tzid string) string {
+		return "BEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTART;TZID=" + tzid + ":20240101T090000\r\nSUMMARY:Standup\r\n" +
+			"RRULE:FREQ=WEEKLY\r\nEND:VEVENT\r\n"
+	}
+	calendar := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + event("paris", "Europe/Paris") + event("hanoi", "Asia/Ho_Chi_Minh") + "END:VCALENDAR\r\n"
+
+	result := importICal(t, app, calendar)
+	if len(result.Created) != 1 || len(result.Skipped) != 1 || result.Skipped[0].UID != "paris" {
+		t.Errorf("import = %+v, want the Hanoi series created and the Paris one skipped", result)
+	}
+	if count := dbtest.Count(t, db, "tw_schedules"); count != 1 {
+		t.Errorf("%d schedules, want 1", count)
+	}
+}
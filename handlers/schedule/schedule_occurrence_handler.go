@@ -0,0 +1,156 @@
+package schedule
+
+import (
+	"dbms/recurrence"
+	"dbms/services/calendar"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxOccurrenceWindow caps the range a single occurrences request may expand.
+const maxOccurrenceWindow = 366 * 24 * time.Hour
+
+type ScheduleOccurrenceResponse struct {
+	ScheduleID        int       `json:"schedule_id"`
+	WorkspaceID       int       `json:"workspace_id"`
+	BoardColumnID     int       `json:"board_column_id"`
+	Title             string    `json:"title"`
+	Description       string    `json:"description"`
+	Location          string    `json:"location"`
+	Status            string    `json:"status"`
+	Priority          string    `json:"priority"`
+	AllDay            bool      `json:"all_day"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	OriginalStartTime time.Time `json:"original_start_time"`
+	RecurrencePattern string    `json:"recurrence_pattern"`
+	IsRecurring       bool      `json:"is_recurring"`
+	IsException       bool      `json:"is_exception"`
+}
+
+// GetScheduleOccurrences godoc
+// @Summary Get schedule occurrences
+// @Description Expand the schedules of one or more workspaces into concrete occurrences within [from, to), applying recurrence exceptions
+// @Tags schedule
+// @Accept json
+// @Produce json
+// @Param workspace_id query string true "Workspace ID (comma separated for several workspaces)"
+// @Param from query string true "Window start (RFC3339 or 2006-01-02 15:04:05.000)"
+// @Param to query string true "Window end (RFC3339 or 2006-01-02 15:04:05.000)"
+// @Success 200 {array} ScheduleOccurrenceResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /dbms/v1/schedule/occurrences [get]
+func (h *ScheduleHandler) GetScheduleOccurrences(c *fiber.Ctx) error {
+	workspaceID := c.Query("workspace_id")
+	if workspaceID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_id is required",
+		})
+	}
+	from, to, err := parseTimeWindow(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	var schedules []models.TwSchedule
+	if err := h.DB.Table("tw_schedules").
+		Select("tw_schedules.*").
+		Joins("JOIN tw_workspaces ON tw_schedules.workspace_id = tw_workspaces.id AND tw_workspaces.deleted_at IS NULL").
+		Joins("JOIN tw_board_columns ON tw_schedules.board_column_id = tw_board_columns.id AND tw_board_columns.deleted_at IS NULL").
+		Where("tw_schedules.workspace_id IN (?)", strings.Split(workspaceID, ",")).
+		Scopes(calendar.ActiveInWindow(from, to)).
+		Find(&schedules).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+
+	occurrences, err := expandSchedules(h.DB, schedules, from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(occurrences)
+}
+
+// expandSchedules loads the recurrence exceptions of the given schedules and
+// expands each of them into the occurrences that overlap [from, to).
+// Schedules with an unparsable recurrence pattern are skipped.
+func expandSchedules(db *gorm.DB, schedules []models.TwSchedule, from, to time.Time) ([]ScheduleOccurrenceResponse, error) {
+	exceptions, err := calendar.RecurrenceExceptions(db, schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []ScheduleOccurrenceResponse{}
+	for _, schedule := range schedules {
+		expanded, err := recurrence.Expand(schedule, exceptions[schedule.ID], from, to)
+		if err != nil {
+			continue
+		}
+		for _, occurrence := range expanded {
+			occurrences = append(occurrences, ScheduleOccurrenceResponse{
+				ScheduleID:        schedule.ID,
+				WorkspaceID:       schedule.WorkspaceId,
+				BoardColumnID:     schedule.BoardColumnId,
+				Title:             schedule.Title,
+				Description:       schedule.Description,
+				Location:          schedule.Location,
+				Status:            schedule.Status,
+				Priority:          schedule.Priority,
+				AllDay:            schedule.AllDay,
+				StartTime:         occurrence.Start,
+				EndTime:           occurrence.End,
+				OriginalStartTime: occurrence.OriginalStart,
+				RecurrencePattern: schedule.RecurrencePattern,
+				IsRecurring:       occurrence.IsRecurring,
+				IsException:       occurrence.IsException,
+			})
+		}
+	}
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartTime.Before(occurrences[j].StartTime)
+	})
+	return occurrences, nil
+}
+
+func parseTimeWindow(fromStr, toStr string) (time.Time, time.Time, error) {
+	if fromStr == "" || toStr == "" {
+		return time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "from and to are required")
+	}
+	from, err := parseQueryTime(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "Invalid from")
+	}
+	to, err := parseQueryTime(toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "Invalid to")
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "to must be after from")
+	}
+	if to.Sub(from) > maxOccurrenceWindow {
+		return time.Time{}, time.Time{}, fiber.NewError(fiber.StatusBadRequest, "The requested window must not exceed 366 days")
+	}
+	return from, to, nil
+}
+
+// parseQueryTime accepts RFC3339, the "2006-01-02 15:04:05.000" layout used by
+// FilterSchedules, and plain dates.
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := parseTime(value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.UTC)
+}
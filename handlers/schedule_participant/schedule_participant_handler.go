@@ -1,6 +1,9 @@
 package schedule_participant
 
 import (
+	"dbms/services/calendar"
+	"dbms/services/notification"
+	"dbms/services/webhook"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/timewise-team/timewise-models/dtos/core_dtos/schedule_participant_dtos"
@@ -319,48 +322,14 @@ func (h *ScheduleParticipantHandler) getScheduleParticipantsByScheduleId(c *fibe
 // @Success 200 {array} schedule_participant_dtos.ScheduleParticipantInfo
 // @Router /dbms/v1/schedule_participant/schedule/{scheduleId} [get]
 func (h *ScheduleParticipantHandler) getScheduleParticipantsBySchedule(c *fiber.Ctx) error {
-	var scheduleParticipants []schedule_participant_dtos.ScheduleParticipantInfo
-	scheduleId := c.Params("scheduleId")
-
-	if scheduleId == "" {
+	scheduleId, err := c.ParamsInt("scheduleId")
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Schedule ID không hợp lệ",
 		})
 	}
 
-	// Perform the SQL query with multiple joins
-	err := h.DB.Table("tw_schedule_participants AS sp").
-		Select(`
-            sp.id AS id,
-            sp.schedule_id,
-            sp.workspace_user_id,
-			sp.status,
-			sp.assign_at,
-			sp.assign_by,
-			sp.response_time,
-			sp.invitation_sent_at,
-			sp.invitation_status,
-			wu.role,
-			wu.status AS status_workspace_user,
-			wu.is_verified,
-			ue.id as user_id,
-			ue.email,
-			u.first_name,
-			u.last_name,
-			u.profile_picture
-            
-        `).
-		Joins("JOIN tw_workspace_users AS wu ON wu.id =sp.workspace_user_id").
-		Joins("JOIN tw_user_emails AS ue ON wu.user_email_id = ue.id").
-		Joins("JOIN tw_users AS u ON ue.user_id = u.id").
-		Where("sp.schedule_id = ?", scheduleId).
-		Where("sp.deleted_at IS NULL AND sp.invitation_status != 'removed'").
-		Where("wu.deleted_at IS NULL").
-		Where("ue.deleted_at IS NULL").
-		Where("u.deleted_at IS NULL").
-		Where("wu.is_active = true AND wu.is_verified = true AND wu.status = 'joined'").
-		Scan(&scheduleParticipants).Error
-
+	scheduleParticipants, err := notification.ScheduleParticipants(h.DB, scheduleId)
 	if err != nil {
 		log.Println("Error querying schedule participants:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -368,23 +337,53 @@ func (h *ScheduleParticipantHandler) getScheduleParticipantsBySchedule(c *fiber.
 		})
 	}
 
-	if err != nil {
-		log.Println(err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Không thể lấy workspace",
-		})
-	}
-
 	return c.JSON(scheduleParticipants)
 }
 
+// inviteToSchedule godoc
+// @Summary Invite to schedule
+// @Description Invite a workspace user to a schedule
+// @Tags schedule_participant
+// @Accept json
+// @Produce json
+// @Param reject_conflicts query bool false "Return 409 with the conflict list when the invitee has overlapping schedules"
+// @Param body body models.TwScheduleParticipant true "Schedule participant"
+// @Success 200 {object} models.TwScheduleParticipant
+// @Failure 409 {object} fiber.Map
+// @Router /dbms/v1/schedule_participant/invite [post]
 func (h *ScheduleParticipantHandler) inviteToSchedule(c *fiber.Ctx) error {
 	var scheduleParticipants models.TwScheduleParticipant
 	if err := c.BodyParser(&scheduleParticipants); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
-	if result := h.DB.Create(&scheduleParticipants); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	if c.QueryBool("reject_conflicts") {
+		var schedule models.TwSchedule
+		if err := h.DB.Where("id = ? AND is_deleted = false", scheduleParticipants.ScheduleId).First(&schedule).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusNotFound).SendString("Schedule not found")
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+		from, to := calendar.Window(schedule)
+		conflicts, err := calendar.FindConflicts(h.DB, schedule, []int{scheduleParticipants.WorkspaceUserId}, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+		if len(conflicts) > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message":   "Schedule conflicts with the invitee's existing schedules",
+				"conflicts": calendar.FlattenConflicts(conflicts),
+			})
+		}
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&scheduleParticipants).Error; err != nil {
+			return err
+		}
+		return webhook.EmitForSchedule(tx, scheduleParticipants.ScheduleId, webhook.EventParticipantInvited, webhook.NewParticipant(scheduleParticipants))
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return c.JSON(scheduleParticipants)
 }
@@ -1,6 +1,7 @@
 package user_email
 
 import (
+	"dbms/services/account"
 	"errors"
 	"github.com/go-sql-driver/mysql"
 	"github.com/gofiber/fiber/v2"
@@ -380,14 +381,8 @@ func (h *UserEmailHandler) getEmailInProgress(c *fiber.Ctx) error {
 // @Success 200 {string} string
 // @Router /dbms/v1/user_email/clear-expired [get]
 func (h *UserEmailHandler) clearExpiredUserEmails(c *fiber.Ctx) error {
-	if result := h.DB.Model(&models.TwUserEmail{}).
-		Where("expires_at <= NOW()").
-		Updates(map[string]interface{}{
-			"status":       nil,
-			"is_linked_to": nil,
-			"expires_at":   nil,
-		}); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	if _, err := account.ClearExpiredEmailLinks(h.DB); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return c.Status(fiber.StatusOK).SendString("Expired user emails cleared successfully")
 }
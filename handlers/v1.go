@@ -1,10 +1,12 @@
 package feature
 
 import (
+	"dbms/config"
 	_ "dbms/docs"
 	"dbms/handlers/auth"
 	"dbms/handlers/board_columns"
 	comments "dbms/handlers/comments"
+	"dbms/handlers/cron_job"
 	"dbms/handlers/document"
 	"dbms/handlers/notification"
 	"dbms/handlers/notification_setting"
@@ -15,9 +17,11 @@ import (
 	"dbms/handlers/schedule_participant"
 	"dbms/handlers/user"
 	"dbms/handlers/user_email"
+	"dbms/handlers/webhook"
 	"dbms/handlers/workspace"
 	"dbms/handlers/workspace_log"
 	"dbms/handlers/workspace_user"
+	"dbms/middleware"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/swagger"
 	"gorm.io/gorm"
@@ -29,6 +33,13 @@ func RegisterHandlerV1(db *gorm.DB) *fiber.App {
 	router := fiber.New()
 	v1 := router.Group("/dbms/v1")
 	v1.Get("/swagger/*", swagger.HandlerDefault)
+
+	// Every route group below requires a service credential scoped to it
+	authenticator := middleware.NewAuthenticator(config.LoadAuthConfig())
+	config.Watch(func() {
+		authenticator.Reload(config.LoadAuthConfig())
+	})
+	v1.Use(authenticator.Guard("/dbms/v1"))
 	user.RegisterUserHandler(v1.Group("/user"), db)
 	schedule_log.RegisterScheduleLogHandler(v1.Group("/schedule_log"), db)
 	schedule_participant.RegisterScheduleParticipantHandler(v1.Group("/schedule_participant"), db)
@@ -45,5 +56,7 @@ func RegisterHandlerV1(db *gorm.DB) *fiber.App {
 	notification.RegisterNotificationHandler(v1.Group("/notification"), db)
 	reminder.RegisterReminderHandler(v1.Group("/reminder"), db)
 	notification_setting.RegisterNotificationSettingHandler(v1.Group("/notification_setting"), db)
+	cron_job.RegisterCronJobHandler(v1.Group("/cron"), db)
+	webhook.RegisterWebhookHandler(v1.Group("/webhook"), db)
 	return router
 }
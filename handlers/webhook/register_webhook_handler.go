@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterWebhookHandler(router fiber.Router, db *gorm.DB) {
+	webhookHandler := WebhookHandler{
+		DB: db,
+	}
+	router.Get("/event_types", webhookHandler.getEventTypes)
+	router.Post("/", webhookHandler.createSubscription)
+	router.Get("/workspace/:workspace_id", webhookHandler.getSubscriptions)
+	router.Post("/event/dispatch", webhookHandler.dispatchEvents)
+	router.Post("/delivery/claim", webhookHandler.claimDeliveries)
+	router.Get("/delivery/:delivery_id", webhookHandler.getDelivery)
+	router.Post("/delivery/:delivery_id/attempt", webhookHandler.recordAttempt)
+	router.Put("/delivery/:delivery_id/redeliver", webhookHandler.redeliver)
+	router.Get("/:webhook_id", webhookHandler.getSubscription)
+	router.Put("/:webhook_id", webhookHandler.updateSubscription)
+	router.Delete("/:webhook_id", webhookHandler.deleteSubscription)
+	router.Put("/:webhook_id/secret", webhookHandler.rotateSecret)
+	router.Get("/:webhook_id/deliveries", webhookHandler.getDeliveries)
+}
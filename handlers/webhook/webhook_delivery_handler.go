@@ -0,0 +1,183 @@
+package webhook
+
+import (
+	"dbms/config"
+	"dbms/dms_models"
+	"dbms/services/webhook"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	maxDispatchEvents     = 500
+	maxClaimDeliveries    = 100
+	defaultDeliveriesList = 50
+	maxDeliveriesList     = 500
+)
+
+// getDeliveries godoc
+// @Summary List the deliveries of a webhook subscription
+// @Description List the deliveries of a webhook subscription, most recent first
+// @Tags webhook
+// @Produce json
+// @Param webhook_id path int true "Subscription ID"
+// @Param status query string false "pending, delivered or failed"
+// @Param limit query int false "Maximum number of deliveries (default 50)"
+// @Success 200 {array} dms_models.TwWebhookDelivery
+// @Router /dbms/v1/webhook/{webhook_id}/deliveries [get]
+func (h *WebhookHandler) getDeliveries(c *fiber.Ctx) error {
+	subscriptionId, err := c.ParamsInt("webhook_id")
+	if err != nil || subscriptionId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid webhook_id",
+		})
+	}
+	status := c.Query("status")
+	switch status {
+	case "", dms_models.WebhookDeliveryPending, dms_models.WebhookDeliveryDelivered, dms_models.WebhookDeliveryFailed:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid status, expected pending, delivered or failed",
+		})
+	}
+	limit := c.QueryInt("limit", defaultDeliveriesList)
+	if limit <= 0 || limit > maxDeliveriesList {
+		limit = maxDeliveriesList
+	}
+	deliveries, err := webhook.Deliveries(h.DB, subscriptionId, status, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(deliveries)
+}
+
+// getDelivery godoc
+// @Summary Get a webhook delivery
+// @Description Get a webhook delivery with its event and the log of its attempts
+// @Tags webhook
+// @Produce json
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} webhook.DeliveryLog
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/delivery/{delivery_id} [get]
+func (h *WebhookHandler) getDelivery(c *fiber.Ctx) error {
+	deliveryId, err := c.ParamsInt("delivery_id")
+	if err != nil || deliveryId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid delivery_id",
+		})
+	}
+	log, err := webhook.GetDeliveryLog(h.DB, deliveryId)
+	if err != nil {
+		return sendDeliveryError(c, err)
+	}
+	return c.JSON(log)
+}
+
+// redeliver godoc
+// @Summary Redeliver a webhook delivery
+// @Description Queue a webhook delivery again with a fresh set of attempts, e.g. a failed one once its receiver is fixed
+// @Tags webhook
+// @Produce json
+// @Param delivery_id path int true "Delivery ID"
+// @Success 200 {object} dms_models.TwWebhookDelivery
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/delivery/{delivery_id}/redeliver [put]
+func (h *WebhookHandler) redeliver(c *fiber.Ctx) error {
+	deliveryId, err := c.ParamsInt("delivery_id")
+	if err != nil || deliveryId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid delivery_id",
+		})
+	}
+	delivery, err := webhook.Redeliver(h.DB, deliveryId, time.Now())
+	if err != nil {
+		return sendDeliveryError(c, err)
+	}
+	return c.JSON(delivery)
+}
+
+// dispatchEvents godoc
+// @Summary Dispatch webhook events
+// @Description Fan the events of the outbox out to the subscriptions that want them (used by the cron worker)
+// @Tags webhook
+// @Produce json
+// @Success 200 {object} fiber.Map
+// @Router /dbms/v1/webhook/event/dispatch [post]
+func (h *WebhookHandler) dispatchEvents(c *fiber.Ctx) error {
+	dispatched, err := webhook.DispatchEvents(h.DB, time.Now(), maxDispatchEvents)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{"dispatched": dispatched})
+}
+
+// claimDeliveries godoc
+// @Summary Claim due webhook deliveries
+// @Description Claim the pending webhook deliveries that are due, with the URL, secret and body to send (used by the cron worker)
+// @Tags webhook
+// @Produce json
+// @Param limit query int false "Maximum number of deliveries (default 100)"
+// @Success 200 {array} webhook.Dispatch
+// @Router /dbms/v1/webhook/delivery/claim [post]
+func (h *WebhookHandler) claimDeliveries(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", maxClaimDeliveries)
+	if limit <= 0 || limit > maxClaimDeliveries {
+		limit = maxClaimDeliveries
+	}
+	dispatches, err := webhook.ClaimDeliveries(h.DB, time.Now(), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(dispatches)
+}
+
+// recordAttempt godoc
+// @Summary Record a webhook delivery attempt
+// @Description Log a request of a webhook delivery, then mark the delivery delivered, schedule a retry with exponential backoff, or give it up after too many failures (used by the cron worker)
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param delivery_id path int true "Delivery ID"
+// @Param body body webhook.Attempt true "Attempt"
+// @Success 200 {object} dms_models.TwWebhookDelivery
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/delivery/{delivery_id}/attempt [post]
+func (h *WebhookHandler) recordAttempt(c *fiber.Ctx) error {
+	deliveryId, err := c.ParamsInt("delivery_id")
+	if err != nil || deliveryId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid delivery_id",
+		})
+	}
+	var attempt webhook.Attempt
+	if err := c.BodyParser(&attempt); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	delivery, err := webhook.RecordAttempt(h.DB, deliveryId, attempt, time.Now(), config.LoadWebhookConfig())
+	if err != nil {
+		return sendDeliveryError(c, err)
+	}
+	return c.JSON(delivery)
+}
+
+func sendDeliveryError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Webhook delivery not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"dbms/common"
+	"dbms/services/policy"
+	"dbms/services/webhook"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	DB *gorm.DB
+}
+
+type CreateSubscriptionRequest struct {
+	WorkspaceId int    `json:"workspace_id"`
+	URL         string `json:"url"`
+	// Secret signs the deliveries; one is generated when empty.
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+	// CreatedBy is the workspace user creating the subscription, an admin
+	// or owner of the workspace.
+	CreatedBy int `json:"workspace_user_id"`
+}
+
+// getEventTypes godoc
+// @Summary List webhook event types
+// @Description List the event types a webhook subscription can list
+// @Tags webhook
+// @Produce json
+// @Success 200 {array} string
+// @Router /dbms/v1/webhook/event_types [get]
+func (h *WebhookHandler) getEventTypes(c *fiber.Ctx) error {
+	return c.JSON(webhook.EventTypes)
+}
+
+// createSubscription godoc
+// @Summary Create a webhook subscription
+// @Description Send the events of the listed types of a workspace to a URL. The deliveries are signed with the secret, which is only returned here
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param body body CreateSubscriptionRequest true "Subscription"
+// @Success 200 {object} webhook.SubscriptionWithSecret
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Router /dbms/v1/webhook [post]
+func (h *WebhookHandler) createSubscription(c *fiber.Ctx) error {
+	var request CreateSubscriptionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if request.WorkspaceId == 0 || request.CreatedBy == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_id and workspace_user_id are required",
+		})
+	}
+	if _, err := policy.Authorize(h.DB, request.CreatedBy, request.WorkspaceId, policy.Webhook, policy.Create, 0); err != nil {
+		return common.SendPolicyError(c, err)
+	}
+	subscription, err := webhook.CreateSubscription(h.DB, request.WorkspaceId, request.URL, request.Secret, request.EventTypes, request.CreatedBy)
+	if err != nil {
+		return sendSubscriptionError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// getSubscriptions godoc
+// @Summary List the webhook subscriptions of a workspace
+// @Description List the webhook subscriptions of a workspace, without their secrets
+// @Tags webhook
+// @Produce json
+// @Param workspace_id path int true "Workspace ID"
+// @Success 200 {array} dms_models.TwWebhookSubscription
+// @Router /dbms/v1/webhook/workspace/{workspace_id} [get]
+func (h *WebhookHandler) getSubscriptions(c *fiber.Ctx) error {
+	workspaceId, err := c.ParamsInt("workspace_id")
+	if err != nil || workspaceId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid workspace_id",
+		})
+	}
+	subscriptions, err := webhook.Subscriptions(h.DB, workspaceId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	return c.JSON(subscriptions)
+}
+
+// getSubscription godoc
+// @Summary Get a webhook subscription
+// @Description Get a webhook subscription, without its secret
+// @Tags webhook
+// @Produce json
+// @Param webhook_id path int true "Subscription ID"
+// @Success 200 {object} dms_models.TwWebhookSubscription
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/{webhook_id} [get]
+func (h *WebhookHandler) getSubscription(c *fiber.Ctx) error {
+	subscriptionId, err := c.ParamsInt("webhook_id")
+	if err != nil || subscriptionId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid webhook_id",
+		})
+	}
+	subscription, err := webhook.GetSubscription(h.DB, subscriptionId)
+	if err != nil {
+		return sendSubscriptionError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// updateSubscription godoc
+// @Summary Update a webhook subscription
+// @Description Change the URL or event types of a webhook subscription, or pause and resume it with is_active. Omitted fields are kept
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path int true "Subscription ID"
+// @Param workspace_user_id query int true "Workspace user changing the subscription"
+// @Param body body webhook.SubscriptionChange true "Changes"
+// @Success 200 {object} dms_models.TwWebhookSubscription
+// @Failure 400 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/{webhook_id} [put]
+func (h *WebhookHandler) updateSubscription(c *fiber.Ctx) error {
+	subscriptionId, err := c.ParamsInt("webhook_id")
+	if err != nil || subscriptionId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid webhook_id",
+		})
+	}
+	var change webhook.SubscriptionChange
+	if err := c.BodyParser(&change); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+	if denied, err := h.authorize(c, subscriptionId, policy.Update); denied {
+		return err
+	}
+	subscription, err := webhook.UpdateSubscription(h.DB, subscriptionId, change)
+	if err != nil {
+		return sendSubscriptionError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// rotateSecret godoc
+// @Summary Rotate the secret of a webhook subscription
+// @Description Replace the secret of a webhook subscription with a new one, returned once
+// @Tags webhook
+// @Produce json
+// @Param webhook_id path int true "Subscription ID"
+// @Param workspace_user_id query int true "Workspace user rotating the secret"
+// @Success 200 {object} webhook.SubscriptionWithSecret
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/{webhook_id}/secret [put]
+func (h *WebhookHandler) rotateSecret(c *fiber.Ctx) error {
+	subscriptionId, err := c.ParamsInt("webhook_id")
+	if err != nil || subscriptionId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid webhook_id",
+		})
+	}
+	if denied, err := h.authorize(c, subscriptionId, policy.Update); denied {
+		return err
+	}
+	subscription, err := webhook.RotateSecret(h.DB, subscriptionId)
+	if err != nil {
+		return sendSubscriptionError(c, err)
+	}
+	return c.JSON(subscription)
+}
+
+// deleteSubscription godoc
+// @Summary Delete a webhook subscription
+// @Description Delete a webhook subscription; its pending deliveries are not sent
+// @Tags webhook
+// @Produce json
+// @Param webhook_id path int true "Subscription ID"
+// @Param workspace_user_id query int true "Workspace user deleting the subscription"
+// @Success 200 {object} fiber.Map
+// @Failure 403 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /dbms/v1/webhook/{webhook_id} [delete]
+func (h *WebhookHandler) deleteSubscription(c *fiber.Ctx) error {
+	subscriptionId, err := c.ParamsInt("webhook_id")
+	if err != nil || subscriptionId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid webhook_id",
+		})
+	}
+	if denied, err := h.authorize(c, subscriptionId, policy.Delete); denied {
+		return err
+	}
+	if err := webhook.DeleteSubscription(h.DB, subscriptionId); err != nil {
+		return sendSubscriptionError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message": "Webhook subscription deleted successfully",
+	})
+}
+
+// authorize checks that the workspace_user_id of the request may perform
+// action on the webhooks of the subscription's workspace. When it may not,
+// it answers the request and reports true with the error to return from the
+// handler.
+func (h *WebhookHandler) authorize(c *fiber.Ctx, subscriptionId int, action policy.Action) (bool, error) {
+	workspaceUserId := c.QueryInt("workspace_user_id")
+	if workspaceUserId == 0 {
+		return true, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": "workspace_user_id is required",
+		})
+	}
+	subscription, err := webhook.GetSubscription(h.DB, subscriptionId)
+	if err != nil {
+		return true, sendSubscriptionError(c, err)
+	}
+	if _, err := policy.Authorize(h.DB, workspaceUserId, subscription.WorkspaceId, policy.Webhook, action, 0); err != nil {
+		return true, common.SendPolicyError(c, err)
+	}
+	return false, nil
+}
+
+func sendSubscriptionError(c *fiber.Ctx, err error) error {
+	var invalid *webhook.InvalidSubscription
+	switch {
+	case errors.As(err, &invalid):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"message": invalid.Reason,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "Webhook subscription not found",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"message": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"bytes"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testWorkspaceId = 1
+	testOwnerId     = 10
+	testMemberId    = 11
+	// testOutsiderId is the owner of another workspace.
+	testOutsiderId = 12
+)
+
+// newTestApp serves the webhook routes over a fresh database holding the
+// owner and a member of workspace 1, the owner of workspace 2, and
+// subscription 1 of workspace 1.
+func newTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	db := dbtest.Open(t, &models.TwWorkspaceUser{}, &dms_models.TwWebhookSubscription{})
+	rows := []interface{}{
+		&models.TwWorkspaceUser{ID: testOwnerId, WorkspaceId: testWorkspaceId, Role: "owner", Status: "joined", IsActive: true},
+		&models.TwWorkspaceUser{ID: testMemberId, WorkspaceId: testWorkspaceId, Role: "member", Status: "joined", IsActive: true},
+		&models.TwWorkspaceUser{ID: testOutsiderId, WorkspaceId: 2, Role: "owner", Status: "joined", IsActive: true},
+		&dms_models.TwWebhookSubscription{ID: 1, WorkspaceId: testWorkspaceId, URL: "https://hooks.example.com", Secret: "0123456789abcdef",
+			EventTypes: "schedule.created", IsActive: true, CreatedBy: testOwnerId},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	app := fiber.New()
+	RegisterWebhookHandler(app.Group("/webhook"), db)
+	return app
+}
+
+func request(t *testing.T, app *fiber.App, method, target string, body interface{}) int {
+	t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			t.Fatalf("marshal body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	return resp.StatusCode
+}
+
+func TestSubscriptionChangesRequireWorkspaceAdmin(t *testing.T) {
+	create := func(workspaceUserId int) interface{} {
+		return CreateSubscriptionRequest{WorkspaceId: testWorkspaceId, URL: "https://hooks.example.com/new",
+			EventTypes: []string{"schedule.created"}, CreatedBy: workspaceUserId}
+	}
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   func(workspaceUserId int) interface{}
+	}{
+		{name: "create", method: http.MethodPost, target: "/webhook", body: create},
+		{name: "update", method: http.MethodPut, target: "/webhook/1?workspace_user_id=%d",
+			body: func(int) interface{} { return map[string]interface{}{"is_active": false} }},
+		{name: "rotate secret", method: http.MethodPut, target: "/webhook/1/secret?workspace_user_id=%d"},
+		{name: "delete", method: http.MethodDelete, target: "/webhook/1?workspace_user_id=%d"},
+	}
+	actors := []struct {
+		name            string
+		workspaceUserId int
+		want            int
+	}{
+		{name: "member", workspaceUserId: testMemberId, want: fiber.StatusForbidden},
+		{name: "owner of another workspace", workspaceUserId: testOutsiderId, want: fiber.StatusForbidden},
+		{name: "owner", workspaceUserId: testOwnerId, want: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		for _, actor := range actors {
+			t.Run(tt.name+" by "+actor.name, func(t *testing.T) {
+				app := newTestApp(t)
+				target := tt.target
+				if strings.Contains(target, "%d") {
+					target = fmt.Sprintf(target, actor.workspaceUserId)
+				}
+				var body interface{}
+				if tt.body != nil {
+					body = tt.body(actor.workspaceUserId)
+				}
+				if status := request(t, app, tt.method, target, body); status != actor.want {
+					t.Errorf("status = %d, want %d", status, actor.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSubscriptionChangesRequireWorkspaceUser(t *testing.T) {
+	app := newTestApp(t)
+	if status := request(t, app, http.MethodDelete, "/webhook/1", nil); status != fiber.StatusBadRequest {
+		t.Errorf("status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+	if status := request(t, app, http.MethodPost, "/webhook", CreateSubscriptionRequest{WorkspaceId: testWorkspaceId,
+		URL: "https://hooks.example.com/new", EventTypes: []string{"schedule.created"}}); status != fiber.StatusBadRequest {
+		t.Errorf("create status = %d, want %d", status, fiber.StatusBadRequest)
+	}
+}
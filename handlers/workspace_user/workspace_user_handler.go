@@ -2,6 +2,8 @@ package workspace_user
 
 //workspace_user_handler.go
 import (
+	"dbms/services/policy"
+	"dbms/services/webhook"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	workspaceUserDtos "github.com/timewise-team/timewise-models/dtos/core_dtos/workspace_user_dtos"
@@ -352,23 +354,31 @@ func (h *WorkspaceUserHandler) DeleteWorkspaceUser(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param workspace_id path string true "Workspace ID"
+// @Param workspace_user_id query int true "Workspace user performing the change"
 // @Param workspace_user body workspaceUserDtos.UpdateWorkspaceUserRoleRequest true "Update role request"
 // @Success 200 {object} fiber.Map
+// @Failure 403 {object} fiber.Map "The workspace user's role does not allow the change"
 // @Router /dbms/v1/workspace_user/role/workspace/{workspace_id} [put]
 func (h *WorkspaceUserHandler) UpdateRole(c *fiber.Ctx) error {
-	workspaceId := c.Params("workspace_id")
-	if workspaceId == "" {
+	workspaceId, err := c.ParamsInt("workspace_id")
+	if err != nil || workspaceId == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Workspace is required",
 		})
 	}
+	actingUserId := c.QueryInt("workspace_user_id")
+	if actingUserId == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "workspace_user_id is required",
+		})
+	}
 	var workspaceUserRequest workspaceUserDtos.UpdateWorkspaceUserRoleRequest
 	if err := c.BodyParser(&workspaceUserRequest); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
 	}
 
 	var workspaceUser models.TwWorkspaceUser
-	err := h.DB.
+	err = h.DB.
 		Joins("JOIN tw_user_emails ON tw_workspace_users.user_email_id = tw_user_emails.id").
 		Where("tw_user_emails.email = ? and tw_workspace_users.workspace_id = ?", workspaceUserRequest.Email, workspaceId).
 		Where("tw_workspace_users.deleted_at IS NULL").
@@ -383,12 +393,42 @@ func (h *WorkspaceUserHandler) UpdateRole(c *fiber.Ctx) error {
 		})
 	}
 
-	if result := h.DB.Model(&workspaceUser).
-		Updates(map[string]interface{}{
-			"role":       workspaceUserRequest.Role,
-			"updated_at": gorm.Expr("NOW()"),
-		}); result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).SendString(result.Error.Error())
+	actingUser, err := policy.ActingUser(h.DB, actingUserId, workspaceId)
+	if err == nil {
+		err = policy.CheckRoleChange(actingUser.Role, workspaceUser.Role, workspaceUserRequest.Role, actingUser.ID == workspaceUser.ID)
+	}
+	if err != nil {
+		var denied *policy.Denied
+		if errors.As(err, &denied) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": denied.Reason,
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	oldRole := workspaceUser.Role
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&workspaceUser).
+			Updates(map[string]interface{}{
+				"role":       workspaceUserRequest.Role,
+				"updated_at": gorm.Expr("NOW()"),
+			}).Error; err != nil {
+			return err
+		}
+		if oldRole == workspaceUserRequest.Role {
+			return nil
+		}
+		return webhook.Emit(tx, workspaceId, webhook.EventMemberRoleChanged, webhook.RoleChange{
+			WorkspaceUserId: workspaceUser.ID,
+			Email:           workspaceUserRequest.Email,
+			OldRole:         oldRole,
+			NewRole:         workspaceUserRequest.Role,
+			ChangedBy:       actingUser.ID,
+		})
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Role updated successfully",
@@ -0,0 +1,292 @@
+package ical
+
+import (
+	"bufio"
+	"dbms/recurrence"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Property is one unfolded content line, e.g. DTSTART;TZID=Europe/Paris:20240131T090000.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// Parse reads an iCalendar document and returns its VEVENTs. Events nested in
+// other components (e.g. VALARM inside VEVENT) are ignored.
+func Parse(r io.Reader) ([]Event, error) {
+	properties, err := readProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var current *Event
+	var stack []string
+	for _, property := range properties {
+		switch property.Name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(property.Value))
+			if len(stack) >= 2 && stack[len(stack)-1] == "VEVENT" && stack[len(stack)-2] == "VCALENDAR" {
+				current = &Event{}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(property.Value) {
+				return nil, fmt.Errorf("unexpected END:%s", property.Value)
+			}
+			if stack[len(stack)-1] == "VEVENT" && current != nil && len(stack) == 2 {
+				if err := finishEvent(current); err != nil {
+					return nil, err
+				}
+				events = append(events, *current)
+				current = nil
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if current == nil || len(stack) != 2 {
+			continue
+		}
+		if err := applyProperty(current, property); err != nil {
+			return nil, err
+		}
+	}
+	if len(stack) != 0 {
+		return nil, errors.New("unterminated calendar component")
+	}
+	if events == nil && len(properties) == 0 {
+		return nil, errors.New("empty calendar")
+	}
+	return events, nil
+}
+
+func applyProperty(event *Event, property Property) error {
+	switch property.Name {
+	case "UID":
+		event.UID = UnescapeText(property.Value)
+	case "SUMMARY":
+		event.Summary = UnescapeText(property.Value)
+	case "DESCRIPTION":
+		event.Description = UnescapeText(property.Value)
+	case "LOCATION":
+		event.Location = UnescapeText(property.Value)
+	case "STATUS":
+		event.Status = strings.ToUpper(property.Value)
+	case "RRULE":
+		event.RRule = property.Value
+	case "DTSTART":
+		t, allDay, err := parseDateValue(property)
+		if err != nil {
+			return fmt.Errorf("invalid DTSTART %q: %w", property.Value, err)
+		}
+		event.Start, event.AllDay = t, allDay
+		if tzid := property.Params["TZID"]; tzid != "" && !allDay && !strings.HasSuffix(property.Value, "Z") {
+			if _, err := time.LoadLocation(tzid); err == nil {
+				event.TZID = tzid
+			}
+		}
+	case "DTEND":
+		t, _, err := parseDateValue(property)
+		if err != nil {
+			return fmt.Errorf("invalid DTEND %q: %w", property.Value, err)
+		}
+		event.End = t
+	case "DURATION":
+		d, err := ParseDuration(property.Value)
+		if err != nil {
+			return err
+		}
+		event.duration = d
+	case "RECURRENCE-ID":
+		t, _, err := parseDateValue(property)
+		if err != nil {
+			return fmt.Errorf("invalid RECURRENCE-ID %q: %w", property.Value, err)
+		}
+		event.RecurrenceID = &t
+	case "EXDATE":
+		for _, value := range strings.Split(property.Value, ",") {
+			t, _, err := parseDateValue(Property{Name: property.Name, Params: property.Params, Value: value})
+			if err != nil {
+				return fmt.Errorf("invalid EXDATE %q: %w", value, err)
+			}
+			event.ExDates = append(event.ExDates, t)
+		}
+	}
+	return nil
+}
+
+func finishEvent(event *Event) error {
+	if event.Start.IsZero() {
+		return fmt.Errorf("event %q has no DTSTART", event.UID)
+	}
+	if event.End.IsZero() {
+		switch {
+		case event.duration != 0:
+			event.End = event.Start.Add(event.duration)
+		case event.AllDay:
+			event.End = event.Start.AddDate(0, 0, 1)
+		default:
+			event.End = event.Start
+		}
+	}
+	return nil
+}
+
+// ObservesDST reports whether the UTC offset of the event's TZID changes
+// within a year of its start. Recurrences are expanded in UTC, so the
+// instances of a series in such a zone would shift by the DST offset.
+func (e Event) ObservesDST() bool {
+	if e.TZID == "" {
+		return false
+	}
+	loc, err := time.LoadLocation(e.TZID)
+	if err != nil {
+		return false
+	}
+	_, offset := e.Start.In(loc).Zone()
+	for month := 1; month <= 12; month++ {
+		if _, other := e.Start.AddDate(0, month, 0).In(loc).Zone(); other != offset {
+			return true
+		}
+	}
+	return false
+}
+
+// readProperties splits the document into unfolded content lines.
+func readProperties(r io.Reader) ([]Property, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	properties := make([]Property, 0, len(lines))
+	for _, line := range lines {
+		property, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, property)
+	}
+	return properties, nil
+}
+
+func parseProperty(line string) (Property, error) {
+	// The value starts at the first colon that is not inside a quoted parameter.
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return Property{}, fmt.Errorf("invalid content line %q", line)
+	}
+
+	head := strings.Split(line[:colon], ";")
+	property := Property{
+		Name:   strings.ToUpper(head[0]),
+		Params: make(map[string]string),
+		Value:  line[colon+1:],
+	}
+	for _, param := range head[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		property.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+	return property, nil
+}
+
+// parseDateValue parses DATE and DATE-TIME values, honouring TZID and
+// treating floating times as UTC.
+func parseDateValue(property Property) (time.Time, bool, error) {
+	value := strings.TrimSpace(property.Value)
+	if property.Params["VALUE"] == "DATE" || len(value) == len(recurrence.DateLayout) {
+		t, err := time.ParseInLocation(recurrence.DateLayout, value, time.UTC)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(recurrence.DateTimeLayout, value)
+		return t, false, err
+	}
+	loc := time.UTC
+	if tzid := property.Params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t.UTC(), false, err
+}
+
+// ParseDuration parses an RFC 5545 DURATION value such as PT1H30M or P1W.
+func ParseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid DURATION %q", value)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid DURATION %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if match[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// UnescapeText reverses EscapeText.
+func UnescapeText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
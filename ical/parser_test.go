@@ -0,0 +1,243 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseOne(t *testing.T, lines ...string) Event {
+	t.Helper()
+	document := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	events, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("%d events, want 1", len(events))
+	}
+	return events[0]
+}
+
+func utc(value string) time.Time {
+	t, err := time.Parse("20060102T150405Z", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseUnfoldsLines(t *testing.T) {
+	event := parseOne(t,
+		"UID:1",
+		"DTSTART:20240131T090000Z",
+		"SUMMARY:A long",
+		"  title",
+		"DESCRIPTION:fol",
+		"\tded",
+	)
+	if event.Summary != "A long title" {
+		t.Errorf("Summary = %q", event.Summary)
+	}
+	if event.Description != "folded" {
+		t.Errorf("Description = %q", event.Description)
+	}
+}
+
+func TestParseUnescapesText(t *testing.T) {
+	event := parseOne(t,
+		"UID:1",
+		"DTSTART:20240131T090000Z",
+		`SUMMARY:Lunch\, then coffee\; maybe`,
+		`DESCRIPTION:Line one\nLine two\NBack\\slash`,
+		`LOCATION:Room "A":1`,
+	)
+	if event.Summary != "Lunch, then coffee; maybe" {
+		t.Errorf("Summary = %q", event.Summary)
+	}
+	if event.Description != "Line one\nLine two\nBack\\slash" {
+		t.Errorf("Description = %q", event.Description)
+	}
+	if event.Location != `Room "A":1` {
+		t.Errorf("Location = %q", event.Location)
+	}
+}
+
+func TestEscapeTextRoundTrips(t *testing.T) {
+	for _, value := range []string{"plain", "a, b; c", `back\slash`, "two\nlines", `trailing\`} {
+		if got := UnescapeText(EscapeText(value)); got != value {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", value, got)
+		}
+	}
+}
+
+func TestParseDateValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantAllDay bool
+		wantTZID   string
+	}{
+		{
+			name:      "UTC",
+			lines:     []string{"DTSTART:20240131T090000Z", "DTEND:20240131T100000Z"},
+			wantStart: utc("20240131T090000Z"),
+			wantEnd:   utc("20240131T100000Z"),
+		},
+		{
+			name:      "floating time is read as UTC",
+			lines:     []string{"DTSTART:20240131T090000", "DURATION:PT30M"},
+			wantStart: utc("20240131T090000Z"),
+			wantEnd:   utc("20240131T093000Z"),
+		},
+		{
+			name:      "TZID in winter",
+			lines:     []string{"DTSTART;TZID=Europe/Paris:20240131T090000", "DTEND;TZID=Europe/Paris:20240131T100000"},
+			wantStart: utc("20240131T080000Z"),
+			wantEnd:   utc("20240131T090000Z"),
+			wantTZID:  "Europe/Paris",
+		},
+		{
+			name:      "TZID in summer",
+			lines:     []string{`DTSTART;TZID="Europe/Paris":20240701T090000`},
+			wantStart: utc("20240701T070000Z"),
+			wantEnd:   utc("20240701T070000Z"),
+			wantTZID:  "Europe/Paris",
+		},
+		{
+			name:      "unknown TZID is read as UTC",
+			lines:     []string{"DTSTART;TZID=Mars/Olympus:20240131T090000"},
+			wantStart: utc("20240131T090000Z"),
+			wantEnd:   utc("20240131T090000Z"),
+		},
+		{
+			name:       "DATE lasts one day",
+			lines:      []string{"DTSTART;VALUE=DATE:20240131"},
+			wantStart:  utc("20240131T000000Z"),
+			wantEnd:    utc("20240201T000000Z"),
+			wantAllDay: true,
+		},
+		{
+			name:       "DATE without VALUE",
+			lines:      []string{"DTSTART:20240131", "DTEND:20240203"},
+			wantStart:  utc("20240131T000000Z"),
+			wantEnd:    utc("20240203T000000Z"),
+			wantAllDay: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := parseOne(t, append([]string{"UID:1"}, tt.lines...)...)
+			if !event.Start.Equal(tt.wantStart) || !event.End.Equal(tt.wantEnd) {
+				t.Errorf("event from %s to %s, want %s to %s", event.Start, event.End, tt.wantStart, tt.wantEnd)
+			}
+			if event.AllDay != tt.wantAllDay {
+				t.Errorf("AllDay = %v, want %v", event.AllDay, tt.wantAllDay)
+			}
+			if event.TZID != tt.wantTZID {
+				t.Errorf("TZID = %q, want %q", event.TZID, tt.wantTZID)
+			}
+		})
+	}
+}
+
+func TestParseExceptions(t *testing.T) {
+	document := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:series",
+		"DTSTART:20240101T090000Z",
+		"RRULE:FREQ=DAILY;COUNT=5",
+		"EXDATE:20240102T090000Z,20240103T090000Z",
+		"EXDATE;TZID=Europe/Paris:20240104T100000",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"DESCRIPTION:ignored",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:series",
+		"RECURRENCE-ID:20240105T090000Z",
+		"DTSTART:20240105T140000Z",
+		"DTEND:20240105T150000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	events, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d events, want 2", len(events))
+	}
+
+	master := events[0]
+	if master.RRule != "FREQ=DAILY;COUNT=5" || master.RecurrenceID != nil || master.Description != "" {
+		t.Errorf("master = %+v", master)
+	}
+	wantExDates := []time.Time{utc("20240102T090000Z"), utc("20240103T090000Z"), utc("20240104T090000Z")}
+	if len(master.ExDates) != len(wantExDates) {
+		t.Fatalf("ExDates = %v, want %v", master.ExDates, wantExDates)
+	}
+	for i, want := range wantExDates {
+		if !master.ExDates[i].Equal(want) {
+			t.Errorf("ExDates[%d] = %s, want %s", i, master.ExDates[i], want)
+		}
+	}
+
+	override := events[1]
+	if override.RecurrenceID == nil || !override.RecurrenceID.Equal(utc("20240105T090000Z")) {
+		t.Errorf("RecurrenceID = %v", override.RecurrenceID)
+	}
+	if !override.Start.Equal(utc("20240105T140000Z")) {
+		t.Errorf("override starts at %s", override.Start)
+	}
+}
+
+func TestParseRejectsInvalidDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+	}{
+		{name: "empty", document: ""},
+		{name: "unterminated", document: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\n"},
+		{name: "mismatched END", document: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "line without colon", document: "BEGIN:VCALENDAR\r\nnonsense\r\nEND:VCALENDAR\r\n"},
+		{name: "event without DTSTART", document: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{name: "invalid DTSTART", document: "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.document)); err == nil {
+				t.Errorf("Parse succeeded")
+			}
+		})
+	}
+}
+
+func TestEventObservesDST(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  bool
+	}{
+		{name: "UTC", lines: []string{"DTSTART:20240131T090000Z"}},
+		{name: "floating", lines: []string{"DTSTART:20240131T090000"}},
+		{name: "zone with DST", lines: []string{"DTSTART;TZID=Europe/Paris:20240131T090000"}, want: true},
+		{name: "zone with DST, starting in summer", lines: []string{"DTSTART;TZID=America/New_York:20240701T090000"}, want: true},
+		{name: "zone without DST", lines: []string{"DTSTART;TZID=Asia/Ho_Chi_Minh:20240131T090000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := parseOne(t, append([]string{"UID:1"}, tt.lines...)...)
+			if got := event.ObservesDST(); got != tt.want {
+				t.Errorf("ObservesDST = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
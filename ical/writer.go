@@ -0,0 +1,151 @@
+package ical
+
+import (
+	"bytes"
+	"dbms/recurrence"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ProductID = "-//Timewise//Timewise DMS//EN"
+	// maxLineOctets is the RFC 5545 content line limit, excluding CRLF.
+	maxLineOctets = 75
+)
+
+// Event is a single VEVENT. When RecurrenceID is set the event overrides one
+// instance of the recurring event sharing its UID.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	RRule        string
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+	Created      *time.Time
+	LastModified *time.Time
+	Stamp        time.Time
+	Status       string
+	// TZID is the time zone DTSTART was given in, empty for UTC, floating
+	// and DATE values. Start itself is always in UTC.
+	TZID string
+
+	// duration holds a parsed DURATION until DTEND is derived from it.
+	duration time.Duration
+}
+
+// Calendar is a VCALENDAR holding a list of events.
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Encode renders the calendar as an RFC 5545 document with folded CRLF lines.
+func (c Calendar) Encode() []byte {
+	var buf bytes.Buffer
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:"+ProductID)
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	writeLine(&buf, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(&buf, "X-WR-CALNAME:"+EscapeText(c.Name))
+	}
+	for _, event := range c.Events {
+		event.encode(&buf)
+	}
+	writeLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+func (e Event) encode(buf *bytes.Buffer) {
+	writeLine(buf, "BEGIN:VEVENT")
+	writeLine(buf, "UID:"+EscapeText(e.UID))
+	stamp := e.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	writeLine(buf, "DTSTAMP:"+formatDateTime(stamp))
+	if e.Created != nil {
+		writeLine(buf, "CREATED:"+formatDateTime(*e.Created))
+	}
+	if e.LastModified != nil {
+		writeLine(buf, "LAST-MODIFIED:"+formatDateTime(*e.LastModified))
+	}
+	if e.RecurrenceID != nil {
+		writeLine(buf, "RECURRENCE-ID"+formatValue(*e.RecurrenceID, e.AllDay))
+	}
+	writeLine(buf, "DTSTART"+formatValue(e.Start, e.AllDay))
+	if !e.End.IsZero() && e.End.After(e.Start) {
+		writeLine(buf, "DTEND"+formatValue(e.End, e.AllDay))
+	} else if e.AllDay {
+		writeLine(buf, "DTEND"+formatValue(e.Start.AddDate(0, 0, 1), true))
+	}
+	writeLine(buf, "SUMMARY:"+EscapeText(e.Summary))
+	if e.Description != "" {
+		writeLine(buf, "DESCRIPTION:"+EscapeText(e.Description))
+	}
+	if e.Location != "" {
+		writeLine(buf, "LOCATION:"+EscapeText(e.Location))
+	}
+	if e.RRule != "" {
+		writeLine(buf, "RRULE:"+e.RRule)
+	}
+	if e.Status != "" {
+		writeLine(buf, "STATUS:"+e.Status)
+	}
+	for _, exDate := range e.ExDates {
+		writeLine(buf, "EXDATE"+formatValue(exDate, e.AllDay))
+	}
+	writeLine(buf, "END:VEVENT")
+}
+
+// EscapeText escapes a TEXT property value as described in RFC 5545 3.3.11.
+func EscapeText(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(value)
+}
+
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format(recurrence.DateTimeLayout)
+}
+
+// formatValue returns the parameter and value part of a date-time property,
+// e.g. ":20240131T090000Z" or ";VALUE=DATE:20240131".
+func formatValue(t time.Time, allDay bool) string {
+	if allDay {
+		return ";VALUE=DATE:" + t.UTC().Format(recurrence.DateLayout)
+	}
+	return ":" + formatDateTime(t)
+}
+
+// writeLine writes a content line, folding it so that no physical line is
+// longer than 75 octets and no UTF-8 sequence is split.
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,107 @@
+package lexorank
+
+import (
+	"errors"
+	"strings"
+)
+
+// Alphabet lists the key digits in ascending order. Digits and lowercase
+// letters sort the same way byte-wise and under MySQL's case-insensitive
+// collations.
+const Alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// MaxLength is the key length past which a list should be rebalanced.
+const MaxLength = 12
+
+const base = len(Alphabet)
+
+var ErrInvalidRange = errors.New("lexorank: lower key must sort before upper key")
+
+// Between returns a key that sorts strictly between a and b. An empty a
+// means "before everything" and an empty b "after everything". Keys produced
+// here never end with the zero digit, so there is always room for another
+// key in between.
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) {
+		return "", errors.New("lexorank: invalid key")
+	}
+	if b != "" && a >= b {
+		return "", ErrInvalidRange
+	}
+
+	var key []byte
+	// The key is bounded below by a (resp. above by b) for as long as its
+	// prefix equals a's (resp. b's).
+	lowBound, highBound := true, b != ""
+	for i := 0; ; i++ {
+		low := 0
+		if lowBound && i < len(a) {
+			low = digit(a[i])
+		}
+		high := base
+		if highBound {
+			high = digit(b[i])
+		}
+		if high-low > 1 {
+			key = append(key, Alphabet[(low+high)/2])
+			return string(key), nil
+		}
+		key = append(key, Alphabet[low])
+		lowBound = lowBound && i < len(a)
+		if low < high {
+			highBound = false
+		}
+	}
+}
+
+// After returns a key sorting after a.
+func After(a string) (string, error) {
+	return Between(a, "")
+}
+
+// Spread returns n ascending keys of equal length spaced evenly over the key
+// space, used to rebalance a list or to assign keys to an existing ordering.
+func Spread(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	// Leave at least one full digit of room between neighbouring keys.
+	width, space := 2, base*base
+	for space/(n+1) < base {
+		width++
+		space *= base
+	}
+	step := space / (n + 1)
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strings.TrimRight(format((i+1)*step, width), "0")
+	}
+	return keys
+}
+
+// TooLong reports whether a key has grown long enough to warrant a rebalance.
+func TooLong(key string) bool {
+	return len(key) > MaxLength
+}
+
+func format(value, width int) string {
+	buf := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		buf[i] = Alphabet[value%base]
+		value /= base
+	}
+	return string(buf)
+}
+
+func digit(c byte) int {
+	return strings.IndexByte(Alphabet, c)
+}
+
+func valid(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if digit(key[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(key, "0")
+}
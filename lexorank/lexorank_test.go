@@ -0,0 +1,151 @@
+package lexorank
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	long := strings.Repeat("z", MaxLength)
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "empty list", a: "", b: "", want: "i"},
+		{name: "before the smallest key", a: "", b: "1", want: "0i"},
+		{name: "before the first digit", a: "", b: "01", want: "00i"},
+		{name: "after the largest digit", a: "z", b: "", want: "zi"},
+		{name: "after a long key of largest digits", a: long, b: "", want: long + "i"},
+		{name: "room between", a: "a", b: "c", want: "b"},
+		{name: "adjacent keys", a: "a", b: "b", want: "ai"},
+		{name: "key and its extension", a: "a", b: "a1", want: "a0i"},
+		{name: "long common prefix", a: "abcdefghijk1", b: "abcdefghijk2", want: "abcdefghijk1i"},
+		{name: "different lengths", a: "azz", b: "b", want: "azzi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Between(%q, %q): %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Errorf("Between(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+			assertBetween(t, tt.a, got, tt.b)
+		})
+	}
+}
+
+func TestBetweenRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		wantErr error
+	}{
+		{name: "equal keys", a: "i", b: "i", wantErr: ErrInvalidRange},
+		{name: "reversed keys", a: "j", b: "i", wantErr: ErrInvalidRange},
+		{name: "uppercase digit", a: "A", b: ""},
+		{name: "punctuation", a: "", b: "a-b"},
+		{name: "trailing zero", a: "a0", b: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Between(tt.a, tt.b)
+			if err == nil {
+				t.Fatalf("Between(%q, %q) succeeded, want error", tt.a, tt.b)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Between(%q, %q) error = %v, want %v", tt.a, tt.b, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Repeatedly inserting at the same spot is the worst case for key growth:
+// every key must still sort correctly and TooLong must eventually ask for a
+// rebalance.
+func TestBetweenRepeatedInsertion(t *testing.T) {
+	tests := []struct {
+		name      string
+		low, high string
+		keepLow   bool
+		keepHigh  bool
+	}{
+		{name: "always at the head", high: "i", keepLow: true},
+		{name: "always at the tail", low: "i", keepHigh: true},
+		{name: "always right after the same key", low: "i", high: "j", keepLow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			low, high := tt.low, tt.high
+			sawTooLong := false
+			for i := 0; i < 200; i++ {
+				key, err := Between(low, high)
+				if err != nil {
+					t.Fatalf("insertion %d between %q and %q: %v", i, low, high, err)
+				}
+				assertBetween(t, low, key, high)
+				sawTooLong = sawTooLong || TooLong(key)
+				if !tt.keepLow {
+					low = key
+				}
+				if !tt.keepHigh {
+					high = key
+				}
+			}
+			if !sawTooLong {
+				t.Errorf("keys never grew past MaxLength")
+			}
+		})
+	}
+}
+
+func TestSpread(t *testing.T) {
+	if keys := Spread(0); keys != nil {
+		t.Errorf("Spread(0) = %v, want nil", keys)
+	}
+	for _, n := range []int{1, 2, 35, 36, 1000, 50000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		if !sort.StringsAreSorted(keys) {
+			t.Errorf("Spread(%d) keys are not sorted", n)
+		}
+		for i, key := range keys {
+			if !valid(key) || key == "" {
+				t.Fatalf("Spread(%d)[%d] = %q is not a valid key", n, i, key)
+			}
+			if TooLong(key) {
+				t.Errorf("Spread(%d)[%d] = %q is already too long", n, i, key)
+			}
+			if i > 0 {
+				if keys[i-1] >= key {
+					t.Fatalf("Spread(%d) keys %q and %q are not strictly ascending", n, keys[i-1], key)
+				}
+				// A rebalanced list must leave room for an insertion anywhere.
+				between, err := Between(keys[i-1], key)
+				if err != nil {
+					t.Fatalf("Between(%q, %q): %v", keys[i-1], key, err)
+				}
+				assertBetween(t, keys[i-1], between, key)
+			}
+		}
+	}
+}
+
+func assertBetween(t *testing.T, a, key, b string) {
+	t.Helper()
+	if !valid(key) || key == "" {
+		t.Fatalf("%q is not a valid key", key)
+	}
+	if key <= a || (b != "" && key >= b) {
+		t.Fatalf("%q does not sort strictly between %q and %q", key, a, b)
+	}
+}
@@ -0,0 +1,57 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileSender writes every message to a maildir (Dir/new) instead of sending
+// it, so that development and staging runs never reach real inboxes. The
+// files can be opened with any mail client.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &FileSender{Dir: dir, From: from}, nil
+}
+
+func (s *FileSender) Send(message Message) error {
+	m, err := build(message, s.From)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.%s.eml", time.Now().UnixNano(), hex.EncodeToString(suffix))
+
+	// Write to tmp and move into new, so readers never see partial files.
+	tmp := filepath.Join(s.Dir, "tmp", name)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := m.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.Dir, "new", name))
+}
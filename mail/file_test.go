@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSenderWritesMaildirMessages(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := NewFileSender(dir, "cron@example.com")
+	if err != nil {
+		t.Fatalf("NewFileSender: %v", err)
+	}
+	message := Message{
+		To:      []string{"user@example.com", "other@example.com"},
+		Subject: "Reminder: Standup",
+		HTML:    "<p>Standup at 09:00</p>",
+		Text:    "Standup at 09:00\n",
+	}
+	if err := sender.Send(message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := sender.Send(Message{Subject: "Nobody"}); err == nil {
+		t.Errorf("Send without recipient succeeded")
+	}
+
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("%d files left in tmp", len(tmp))
+	}
+	files, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatalf("read new: %v", err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), ".eml") {
+		t.Fatalf("files in new = %v, want one .eml file", files)
+	}
+	f, err := os.Open(filepath.Join(dir, "new", files[0].Name()))
+	if err != nil {
+		t.Fatalf("open message: %v", err)
+	}
+	defer f.Close()
+
+	parsed, err := netmail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	for header, want := range map[string]string{
+		"From":    "cron@example.com",
+		"To":      "user@example.com, other@example.com",
+		"Subject": "Reminder: Standup",
+	} {
+		if got := parsed.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// The text part comes first, the HTML part is its alternative.
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", parsed.Header.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{contentType: "text/plain", body: message.Text},
+		{contentType: "text/html", body: message.HTML},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("read %s part: %v", want.contentType, err)
+		}
+		if got, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); got != want.contentType {
+			t.Errorf("part type = %q, want %q", got, want.contentType)
+		}
+		// Line breaks are CRLF on the wire.
+		body, _ := io.ReadAll(part)
+		if strings.ReplaceAll(string(body), "\r\n", "\n") != want.body {
+			t.Errorf("%s part = %q, want %q", want.contentType, body, want.body)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("more than two parts: %v", err)
+	}
+}
+
+func TestFileSenderWritesHTMLOnlyMessages(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := NewFileSender(dir, "cron@example.com")
+	if err != nil {
+		t.Fatalf("NewFileSender: %v", err)
+	}
+	if err := sender.Send(Message{To: []string{"user@example.com"}, Subject: "Hello", HTML: "<p>Hello</p>"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	files, _ := os.ReadDir(filepath.Join(dir, "new"))
+	if len(files) != 1 {
+		t.Fatalf("%d files in new, want 1", len(files))
+	}
+	f, err := os.Open(filepath.Join(dir, "new", files[0].Name()))
+	if err != nil {
+		t.Fatalf("open message: %v", err)
+	}
+	defer f.Close()
+	parsed, err := netmail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type")); mediaType != "text/html" {
+		t.Errorf("Content-Type = %q, want text/html", parsed.Header.Get("Content-Type"))
+	}
+}
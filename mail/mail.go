@@ -0,0 +1,65 @@
+// Package mail sends the emails of the cron worker through a configurable
+// backend: SMTP, a maildir on disk for development, or memory for tests.
+// The emails are rendered from the templates directory, see Render.
+package mail
+
+import (
+	"dbms/config"
+	"errors"
+	"fmt"
+	"gopkg.in/gomail.v2"
+)
+
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+	// Text is an optional plain-text alternative of HTML.
+	Text string
+}
+
+type Sender interface {
+	Send(message Message) error
+}
+
+// New returns the sender configured by MAIL.BACKEND.
+func New(cfg config.MailConfig) (Sender, error) {
+	switch cfg.Backend {
+	case config.MailBackendSMTP:
+		return NewSMTPSender(cfg)
+	case config.MailBackendFile:
+		return NewFileSender(cfg.Dir, cfg.From)
+	case config.MailBackendMemory:
+		return NewMemorySender(cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", cfg.Backend)
+	}
+}
+
+// build renders a message as MIME, falling back to from when the message
+// has no sender of its own.
+func build(message Message, from string) (*gomail.Message, error) {
+	if message.From == "" {
+		message.From = from
+	}
+	if message.From == "" {
+		return nil, errors.New("mail: missing sender address")
+	}
+	if len(message.To) == 0 {
+		return nil, errors.New("mail: missing recipient")
+	}
+	m := gomail.NewMessage()
+	m.SetHeader("From", message.From)
+	m.SetHeader("To", message.To...)
+	m.SetHeader("Subject", message.Subject)
+	if message.Text != "" {
+		m.SetBody("text/plain", message.Text)
+		if message.HTML != "" {
+			m.AddAlternative("text/html", message.HTML)
+		}
+	} else {
+		m.SetBody("text/html", message.HTML)
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"dbms/config"
+	"fmt"
+	"testing"
+)
+
+func TestNewPicksTheConfiguredBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.MailConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "smtp", cfg: config.MailConfig{Backend: config.MailBackendSMTP, SMTPHost: "smtp.example.com", SMTPPort: 587, SMTPTLS: "starttls"}, want: "*mail.SMTPSender"},
+		{name: "smtp without host", cfg: config.MailConfig{Backend: config.MailBackendSMTP, SMTPTLS: "starttls"}, wantErr: true},
+		{name: "smtp with unknown TLS mode", cfg: config.MailConfig{Backend: config.MailBackendSMTP, SMTPHost: "smtp.example.com", SMTPTLS: "ssl"}, wantErr: true},
+		{name: "file", cfg: config.MailConfig{Backend: config.MailBackendFile, Dir: t.TempDir()}, want: "*mail.FileSender"},
+		{name: "memory", cfg: config.MailConfig{Backend: config.MailBackendMemory}, want: "*mail.MemorySender"},
+		{name: "unknown", cfg: config.MailConfig{Backend: "pigeon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, err := New(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("New returned %T, want an error", sender)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if got := fmt.Sprintf("%T", sender); got != tt.want {
+				t.Errorf("New returned %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemorySender(t *testing.T) {
+	sender := NewMemorySender("cron@example.com")
+	if err := sender.Send(Message{To: []string{"user@example.com"}, Subject: "Hello", HTML: "<p>Hello</p>"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := sender.Send(Message{From: "other@example.com", To: []string{"user@example.com"}, Subject: "Again"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := sender.Send(Message{Subject: "Nobody"}); err == nil {
+		t.Errorf("Send without recipient succeeded")
+	}
+
+	messages := sender.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("%d messages, want 2", len(messages))
+	}
+	if messages[0].From != "cron@example.com" || messages[1].From != "other@example.com" {
+		t.Errorf("senders = %q and %q, want the default and the message's own", messages[0].From, messages[1].From)
+	}
+	// Messages returns a copy.
+	messages[0].Subject = "Changed"
+	if sender.Messages()[0].Subject != "Hello" {
+		t.Errorf("changing the returned messages changed the sender's")
+	}
+
+	sender.Reset()
+	if len(sender.Messages()) != 0 {
+		t.Errorf("Reset kept %d messages", len(sender.Messages()))
+	}
+	if err := NewMemorySender("").Send(Message{To: []string{"user@example.com"}}); err == nil {
+		t.Errorf("Send without sender address succeeded")
+	}
+}
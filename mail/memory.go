@@ -0,0 +1,42 @@
+package mail
+
+import "sync"
+
+// MemorySender keeps the messages in memory so that tests can inspect what
+// would have been sent.
+type MemorySender struct {
+	From string
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemorySender(from string) *MemorySender {
+	return &MemorySender{From: from}
+}
+
+func (s *MemorySender) Send(message Message) error {
+	if _, err := build(message, s.From); err != nil {
+		return err
+	}
+	if message.From == "" {
+		message.From = s.From
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the messages sent so far.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *MemorySender) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
@@ -0,0 +1,78 @@
+package mail
+
+import "time"
+
+// Sample returns sample data for the named template, used to preview it.
+func Sample(name string) (interface{}, bool) {
+	start := time.Date(2024, time.December, 20, 9, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	notification := NotificationData{
+		Recipient:   "jane.doe@example.com",
+		Title:       "Sprint planning",
+		Description: "Plan the work of the next sprint",
+		Link:        "https://timewise.space/workspace/1/schedule/42",
+		Actor:       "John Smith",
+		Workspace:   "Product team",
+	}
+	switch name {
+	case TemplateReminder:
+		return ReminderData{
+			Recipient:            "jane.doe@example.com",
+			Workspace:            "Product team",
+			WorkspaceDescription: "Roadmap, planning & releases",
+			Schedule:             "Sprint planning",
+			ScheduleDescription:  "Plan the work of the next sprint",
+			StartTime:            &start,
+			EndTime:              &end,
+			Link:                 "https://timewise.space/workspace/1/schedule/42",
+		}, true
+	case TemplateInvitation:
+		notification.Type = "invitation"
+		notification.Title = "Product team"
+		notification.Description = "Roadmap, planning & releases"
+		notification.Link = "https://timewise.space/workspace/1/invitation"
+		return notification, true
+	case TemplateMention:
+		notification.Type = "mention"
+		notification.Message = "@jane could you take a look at the <estimates> before Friday?"
+		return notification, true
+	case TemplateAssignment:
+		notification.Type = "assign"
+		return notification, true
+	case TemplateNotification:
+		notification.Type = "schedule_change"
+		notification.Message = "The schedule was moved to 10:00"
+		return notification, true
+	case TemplateDigest:
+		return DigestData{
+			Recipient: "jane.doe@example.com",
+			Cadence:   "daily",
+			Count:     3,
+			Workspaces: []DigestWorkspace{
+				{
+					Title: "Product team",
+					Items: []DigestItem{
+						{
+							Title: "Sprint planning",
+							Notifications: []DigestNotification{
+								{Title: "John Smith mentioned you", Description: "Could you take a look at the estimates?", Link: "https://timewise.space/workspace/1/schedule/42", CreatedAt: start.Add(-3 * time.Hour)},
+								{Title: "Schedule moved", Description: "The schedule was moved to 10:00", CreatedAt: start.Add(-2 * time.Hour)},
+							},
+						},
+					},
+				},
+				{
+					Items: []DigestItem{
+						{
+							Notifications: []DigestNotification{
+								{Title: "Your email was linked", CreatedAt: start.Add(-time.Hour)},
+							},
+						},
+					},
+				},
+			},
+		}, true
+	default:
+		return nil, false
+	}
+}
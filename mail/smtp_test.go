@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer serves one SMTP session without STARTTLS, accepting every
+// command, and sends the commands and the data it got on the returned
+// channel once the session is over.
+func fakeSMTPServer(t *testing.T) (string, int, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	session := make(chan []string, 1)
+	go func() {
+		var received []string
+		defer func() { session <- received }()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(lines ...string) { conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n")) }
+
+		reply("220 localhost ESMTP")
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			received = append(received, line)
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case inData:
+				if line == "." {
+					inData = false
+					reply("250 queued")
+				}
+			case command == "EHLO":
+				reply("250-localhost", "250 8BITMIME")
+			case command == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, session
+}
+
+func TestSMTPSenderDeliversMessages(t *testing.T) {
+	host, port, session := fakeSMTPServer(t)
+	sender := &SMTPSender{Host: host, Port: port, TLS: "none", From: "cron@example.com"}
+
+	err := sender.Send(Message{To: []string{"user@example.com", "other@example.com"}, Subject: "Reminder: Standup", Text: "Standup at 09:00\n"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	received := strings.Join(<-session, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<cron@example.com>",
+		"RCPT TO:<user@example.com>",
+		"RCPT TO:<other@example.com>",
+		"Subject: Reminder: Standup",
+		"Standup at 09:00",
+		"QUIT",
+	} {
+		if !strings.Contains(received, want) {
+			t.Errorf("the session lacks %q:\n%s", want, received)
+		}
+	}
+}
+
+func TestSMTPSenderRefusesToDowngradeStartTLS(t *testing.T) {
+	host, port, session := fakeSMTPServer(t)
+	sender := &SMTPSender{Host: host, Port: port, TLS: "starttls", Username: "cron", Password: "secret", From: "cron@example.com"}
+
+	err := sender.Send(Message{To: []string{"user@example.com"}, Subject: "Hello", Text: "Hello\n"})
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("Send error = %v, want STARTTLS required", err)
+	}
+	received := strings.Join(<-session, "\n")
+	if strings.Contains(received, "AUTH") || strings.Contains(received, "MAIL FROM") {
+		t.Errorf("the credentials or the message went out in plain text:\n%s", received)
+	}
+}
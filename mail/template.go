@@ -0,0 +1,201 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// The email templates. Every template has an HTML part, rendered inside
+// templates/layout.html, and a plain-text part that also holds the subject.
+const (
+	TemplateReminder     = "reminder"
+	TemplateInvitation   = "invitation"
+	TemplateMention      = "mention"
+	TemplateAssignment   = "assignment"
+	TemplateNotification = "notification"
+	TemplateDigest       = "digest"
+)
+
+const (
+	LocaleEnglish    = "en"
+	LocaleVietnamese = "vi"
+	// DefaultLocale is used for users without a supported locale.
+	DefaultLocale = LocaleEnglish
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	templateNames = []string{TemplateReminder, TemplateInvitation, TemplateMention, TemplateAssignment, TemplateNotification, TemplateDigest}
+	locales       = []string{LocaleEnglish, LocaleVietnamese}
+)
+
+// ReminderData is the data of the reminder template.
+type ReminderData struct {
+	Recipient            string     `json:"recipient"`
+	Workspace            string     `json:"workspace"`
+	WorkspaceDescription string     `json:"workspace_description"`
+	Schedule             string     `json:"schedule"`
+	ScheduleDescription  string     `json:"schedule_description"`
+	StartTime            *time.Time `json:"start_time"`
+	EndTime              *time.Time `json:"end_time"`
+	Link                 string     `json:"link"`
+}
+
+// NotificationData is the data of the notification, invitation, mention
+// and assignment templates. Actor is who invited, mentioned or assigned the
+// recipient.
+type NotificationData struct {
+	Recipient   string `json:"recipient"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Message     string `json:"message"`
+	Link        string `json:"link"`
+	Actor       string `json:"actor"`
+	Workspace   string `json:"workspace"`
+}
+
+// DigestData is the data of the digest template.
+type DigestData struct {
+	Recipient  string            `json:"recipient"`
+	Cadence    string            `json:"cadence"`
+	Count      int               `json:"count"`
+	Workspaces []DigestWorkspace `json:"workspaces"`
+}
+
+// DigestWorkspace is the part of a digest about one workspace; an empty
+// title stands for the notifications without a workspace.
+type DigestWorkspace struct {
+	Title string       `json:"title"`
+	Items []DigestItem `json:"items"`
+}
+
+// DigestItem is the part of a digest about one related item, e.g. a
+// schedule; an empty title stands for the notifications without one.
+type DigestItem struct {
+	Title         string               `json:"title"`
+	Notifications []DigestNotification `json:"notifications"`
+}
+
+type DigestNotification struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Link        string    `json:"link"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Rendered is a rendered email, ready to be sent with Message.
+type Rendered struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// Message returns the email as a message to the given recipients.
+func (r Rendered) Message(to ...string) Message {
+	return Message{To: to, Subject: r.Subject, HTML: r.HTML, Text: r.Text}
+}
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var templates = parseTemplates()
+
+var templateFuncs = map[string]interface{}{
+	"datetime": formatDateTime,
+}
+
+func parseTemplates() map[string]map[string]emailTemplate {
+	parsed := make(map[string]map[string]emailTemplate, len(locales))
+	for _, locale := range locales {
+		parsed[locale] = make(map[string]emailTemplate, len(templateNames))
+		for _, name := range templateNames {
+			html := htmltemplate.Must(htmltemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS,
+				"templates/layout.html",
+				"templates/"+locale+"/common.html",
+				"templates/"+locale+"/"+name+".html",
+			))
+			text := texttemplate.Must(texttemplate.New(name).Funcs(templateFuncs).ParseFS(templateFS,
+				"templates/"+locale+"/"+name+".txt",
+			))
+			parsed[locale][name] = emailTemplate{html: html, text: text}
+		}
+	}
+	return parsed
+}
+
+// Templates returns the names of the email templates.
+func Templates() []string {
+	names := append([]string(nil), templateNames...)
+	sort.Strings(names)
+	return names
+}
+
+// Locales returns the supported locales.
+func Locales() []string {
+	return append([]string(nil), locales...)
+}
+
+// Locale maps a user locale such as "vi-VN" to a supported locale, or to
+// DefaultLocale when it is not supported.
+func Locale(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if i := strings.IndexAny(value, "-_"); i >= 0 {
+		value = value[:i]
+	}
+	for _, locale := range locales {
+		if value == locale {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// Render renders the named template in the given locale, see Locale. The
+// data must match the template, e.g. ReminderData for TemplateReminder.
+func Render(name, locale string, data interface{}) (Rendered, error) {
+	tmpl, ok := templates[Locale(locale)][name]
+	if !ok {
+		return Rendered{}, fmt.Errorf("mail: unknown template %q", name)
+	}
+	var subject, html, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Rendered{}, fmt.Errorf("mail: rendering %s subject: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Rendered{}, fmt.Errorf("mail: rendering %s html: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Rendered{}, fmt.Errorf("mail: rendering %s text: %w", name, err)
+	}
+	return Rendered{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+// formatDateTime formats a time or time pointer as shown in the emails.
+func formatDateTime(value interface{}) string {
+	switch t := value.(type) {
+	case time.Time:
+		return t.Format("02/01/2006 15:04")
+	case *time.Time:
+		if t == nil {
+			return ""
+		}
+		return t.Format("02/01/2006 15:04")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderEscapesHTMLOnly(t *testing.T) {
+	const script = `<script>alert("x")</script>`
+	start := time.Date(2024, time.December, 20, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+	}{
+		{name: "reminder", template: TemplateReminder, data: ReminderData{Recipient: "user@example.com", Schedule: script, ScheduleDescription: script, StartTime: &start}},
+		{name: "notification", template: TemplateNotification, data: NotificationData{Recipient: "user@example.com", Title: script, Description: script}},
+		{name: "mention", template: TemplateMention, data: NotificationData{Recipient: "user@example.com", Title: script, Message: script, Actor: script}},
+	}
+
+	for _, tt := range tests {
+		for _, locale := range locales {
+			t.Run(tt.name+"/"+locale, func(t *testing.T) {
+				rendered, err := Render(tt.template, locale, tt.data)
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+				if strings.Contains(rendered.HTML, "<script") {
+					t.Errorf("the HTML part contains the script:\n%s", rendered.HTML)
+				}
+				if !strings.Contains(rendered.HTML, "&lt;script&gt;") {
+					t.Errorf("the HTML part lacks the escaped script:\n%s", rendered.HTML)
+				}
+				if !strings.Contains(rendered.Text, script) {
+					t.Errorf("the text part does not show the script as written:\n%s", rendered.Text)
+				}
+				if !strings.Contains(rendered.Subject, script) {
+					t.Errorf("subject = %q, want the script as written", rendered.Subject)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderEveryTemplateAndLocale(t *testing.T) {
+	for _, name := range templateNames {
+		sample, ok := Sample(name)
+		if !ok {
+			t.Errorf("no sample data for %s", name)
+			continue
+		}
+		// The zero data has every optional field left out.
+		empty := reflect.Zero(reflect.TypeOf(sample)).Interface()
+		for _, locale := range locales {
+			for dataName, data := range map[string]interface{}{"sample": sample, "empty": empty} {
+				t.Run(name+"/"+locale+"/"+dataName, func(t *testing.T) {
+					rendered, err := Render(name, locale, data)
+					if err != nil {
+						t.Fatalf("Render: %v", err)
+					}
+					if strings.TrimSpace(rendered.Subject) == "" {
+						t.Errorf("empty subject")
+					}
+					if strings.TrimSpace(rendered.Text) == "" || !strings.Contains(rendered.HTML, "</html>") {
+						t.Errorf("rendered = %+v, want a text part and a whole HTML document", rendered)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestRenderFallsBackToTheDefaultLocale(t *testing.T) {
+	data, _ := Sample(TemplateReminder)
+	english, err := Render(TemplateReminder, LocaleEnglish, data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, locale := range []string{"", "fr", "EN-us"} {
+		rendered, err := Render(TemplateReminder, locale, data)
+		if err != nil {
+			t.Fatalf("Render in %q: %v", locale, err)
+		}
+		if rendered != english {
+			t.Errorf("rendered in %q = %q, want the English email", locale, rendered.Subject)
+		}
+	}
+	vietnamese, err := Render(TemplateReminder, "vi-VN", data)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if vietnamese.Subject == english.Subject {
+		t.Errorf("vi-VN got the English subject %q", vietnamese.Subject)
+	}
+	if _, err := Render("newsletter", LocaleEnglish, data); err == nil {
+		t.Errorf("rendering an unknown template succeeded")
+	}
+}
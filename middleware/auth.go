@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"dbms/config"
+	"encoding/hex"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderAPIKey = "X-Api-Key"
+	// HeaderLegacyAPIKey is the header the transcript service already sends.
+	HeaderLegacyAPIKey = "x_api_key"
+	HeaderKeyID        = "X-Key-Id"
+	HeaderTimestamp    = "X-Timestamp"
+	HeaderSignature    = "X-Signature"
+
+	// LocalCredentialID is the c.Locals key holding the authenticated
+	// credential ID.
+	LocalCredentialID = "credential_id"
+)
+
+// Authenticator checks service credentials on every request of the route
+// groups it guards. Its credentials can be replaced at runtime with Reload.
+type Authenticator struct {
+	mu  sync.RWMutex
+	cfg config.AuthConfig
+
+	// seen remembers the signatures accepted within the clock skew window so
+	// that a captured signed request cannot be replayed.
+	seenMu    sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewAuthenticator(cfg config.AuthConfig) *Authenticator {
+	return &Authenticator{cfg: cfg, seen: make(map[string]time.Time)}
+}
+
+// Reload swaps in a new set of credentials; requests in flight finish with
+// the old ones.
+func (a *Authenticator) Reload(cfg config.AuthConfig) {
+	a.mu.Lock()
+	a.cfg = cfg
+	a.mu.Unlock()
+}
+
+func (a *Authenticator) config() config.AuthConfig {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.cfg
+}
+
+// Require returns a middleware accepting either a static API key or an HMAC
+// signed request whose credential is allowed to access scope.
+//
+// Signed requests send X-Key-Id, X-Timestamp (Unix seconds) and X-Signature,
+// the hex HMAC-SHA256 of
+//
+//	METHOD + "\n" + original URL + "\n" + timestamp + "\n" + hex(SHA256(body))
+//
+// with the credential's secret.
+func (a *Authenticator) Require(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return a.check(c, scope)
+	}
+}
+
+// Guard is Require for every route group mounted under prefix: the scope is
+// the first path segment after prefix, e.g. "schedule" for
+// /dbms/v1/schedule/occurrences.
+func (a *Authenticator) Guard(prefix string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		group := strings.TrimPrefix(strings.TrimPrefix(c.Path(), prefix), "/")
+		if i := strings.IndexByte(group, '/'); i >= 0 {
+			group = group[:i]
+		}
+		return a.check(c, group)
+	}
+}
+
+func (a *Authenticator) check(c *fiber.Ctx, scope string) error {
+	cfg := a.config()
+	if cfg.Disabled {
+		return c.Next()
+	}
+
+	if apiKey := apiKey(c); apiKey != "" {
+		credential, ok := matchAPIKey(cfg.APIKeys, apiKey)
+		if !ok {
+			return unauthorized(c, "Invalid API key")
+		}
+		return a.authorize(c, credential, scope)
+	}
+
+	if keyID := c.Get(HeaderKeyID); keyID != "" {
+		credential, message := a.verifySignature(c, cfg, keyID)
+		if message != "" {
+			return unauthorized(c, message)
+		}
+		return a.authorize(c, credential, scope)
+	}
+
+	return unauthorized(c, "Missing credentials")
+}
+
+func (a *Authenticator) authorize(c *fiber.Ctx, credential config.Credential, scope string) error {
+	for _, allowed := range credential.Scopes {
+		if allowed == "*" || allowed == scope {
+			c.Locals(LocalCredentialID, credential.ID)
+			return c.Next()
+		}
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"message": "Credential " + credential.ID + " is not allowed to access " + scope,
+	})
+}
+
+func apiKey(c *fiber.Ctx) string {
+	if key := c.Get(HeaderAPIKey); key != "" {
+		return key
+	}
+	return c.Get(HeaderLegacyAPIKey)
+}
+
+func matchAPIKey(credentials []config.Credential, key string) (config.Credential, bool) {
+	for _, credential := range credentials {
+		if subtle.ConstantTimeCompare([]byte(credential.Secret), []byte(key)) == 1 {
+			return credential, true
+		}
+	}
+	return config.Credential{}, false
+}
+
+// verifySignature returns the matching HMAC credential, or the reason the
+// request was rejected.
+func (a *Authenticator) verifySignature(c *fiber.Ctx, cfg config.AuthConfig, keyID string) (config.Credential, string) {
+	timestamp := c.Get(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return config.Credential{}, "Invalid " + HeaderTimestamp
+	}
+	signedAt := time.Unix(seconds, 0)
+	now := time.Now()
+	if signedAt.Before(now.Add(-cfg.MaxClockSkew)) || signedAt.After(now.Add(cfg.MaxClockSkew)) {
+		return config.Credential{}, "Request timestamp is outside the allowed window"
+	}
+	signature, err := hex.DecodeString(c.Get(HeaderSignature))
+	if err != nil || len(signature) == 0 {
+		return config.Credential{}, "Invalid " + HeaderSignature
+	}
+
+	bodyHash := sha256.Sum256(c.Body())
+	payload := c.Method() + "\n" + c.OriginalURL() + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])
+	for _, credential := range cfg.HMACKeys {
+		if credential.ID != keyID {
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(credential.Secret))
+		mac.Write([]byte(payload))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			continue
+		}
+		if !a.remember(keyID+":"+hex.EncodeToString(signature), signedAt.Add(cfg.MaxClockSkew), now) {
+			return config.Credential{}, "Request has already been used"
+		}
+		return credential, ""
+	}
+	return config.Credential{}, "Invalid signature"
+}
+
+// remember records a signature until expiry and reports whether it was new.
+func (a *Authenticator) remember(key string, expiry, now time.Time) bool {
+	a.seenMu.Lock()
+	defer a.seenMu.Unlock()
+	if now.Sub(a.lastPrune) > time.Minute {
+		for seenKey, seenExpiry := range a.seen {
+			if seenExpiry.Before(now) {
+				delete(a.seen, seenKey)
+			}
+		}
+		a.lastPrune = now
+	}
+	if _, ok := a.seen[key]; ok {
+		return false
+	}
+	a.seen[key] = expiry
+	return true
+}
+
+func unauthorized(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"message": message,
+	})
+}
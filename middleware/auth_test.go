@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"dbms/config"
+	"encoding/hex"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const prefix = "/dbms/v1"
+
+var testConfig = config.AuthConfig{
+	APIKeys: []config.Credential{
+		{ID: "core", Secret: "core-key", Scopes: []string{"*"}},
+		{ID: "transcriber", Secret: "transcriber-key", Scopes: []string{"schedule"}},
+		// Rotation: the old and new key of the transcriber are both valid.
+		{ID: "transcriber", Secret: "transcriber-key-2", Scopes: []string{"schedule"}},
+	},
+	HMACKeys: []config.Credential{
+		{ID: "core", Secret: "core-secret", Scopes: []string{"schedule", "user"}},
+		{ID: "core", Secret: "core-secret-2", Scopes: []string{"schedule", "user"}},
+	},
+	MaxClockSkew: 5 * time.Minute,
+}
+
+// newTestApp guards the routes under prefix with an authenticator, the
+// routes answering the ID of the authenticated credential.
+func newTestApp(cfg config.AuthConfig) (*fiber.App, *Authenticator) {
+	authenticator := NewAuthenticator(cfg)
+	app := fiber.New()
+	app.Use(prefix, authenticator.Guard(prefix))
+	app.All(prefix+"/*", func(c *fiber.Ctx) error {
+		id, _ := c.Locals(LocalCredentialID).(string)
+		return c.SendString(id)
+	})
+	return app, authenticator
+}
+
+func send(t *testing.T, app *fiber.App, req *http.Request) (int, string) {
+	t.Helper()
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// signedRequest signs a request the way the other services do.
+func signedRequest(method, target, body, keyID, secret string, signedAt time.Time) *http.Request {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	bodyHash := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + "\n" + target + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(HeaderKeyID, keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestGuardAPIKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		key        string
+		path       string
+		wantStatus int
+		wantID     string
+	}{
+		{name: "valid key", header: HeaderAPIKey, key: "transcriber-key", path: "/schedule/1", wantStatus: fiber.StatusOK, wantID: "transcriber"},
+		{name: "rotated key", header: HeaderAPIKey, key: "transcriber-key-2", path: "/schedule/1", wantStatus: fiber.StatusOK, wantID: "transcriber"},
+		{name: "legacy header", header: HeaderLegacyAPIKey, key: "transcriber-key", path: "/schedule", wantStatus: fiber.StatusOK, wantID: "transcriber"},
+		{name: "wildcard scope", header: HeaderAPIKey, key: "core-key", path: "/workspace/1/members", wantStatus: fiber.StatusOK, wantID: "core"},
+		{name: "wrong key", header: HeaderAPIKey, key: "transcriber-key-3", path: "/schedule/1", wantStatus: fiber.StatusUnauthorized},
+		{name: "scope denied", header: HeaderAPIKey, key: "transcriber-key", path: "/workspace/1", wantStatus: fiber.StatusForbidden},
+		{name: "scope is a whole path segment", header: HeaderAPIKey, key: "transcriber-key", path: "/schedules", wantStatus: fiber.StatusForbidden},
+		{name: "missing credentials", path: "/schedule/1", wantStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp(testConfig)
+			req := httptest.NewRequest(http.MethodGet, prefix+tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.key)
+			}
+			status, body := send(t, app, req)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", status, tt.wantStatus, body)
+			}
+			if tt.wantID != "" && body != tt.wantID {
+				t.Errorf("authenticated as %q, want %q", body, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestGuardSignatures(t *testing.T) {
+	const target = prefix + "/schedule/1?workspace_user_id=2"
+	const body = `{"title":"Card"}`
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		request    func() *http.Request
+		wantStatus int
+	}{
+		{
+			name:       "valid signature",
+			request:    func() *http.Request { return signedRequest(http.MethodPut, target, body, "core", "core-secret", now) },
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "rotated secret",
+			request:    func() *http.Request { return signedRequest(http.MethodPut, target, body, "core", "core-secret-2", now) },
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name: "within the clock skew",
+			request: func() *http.Request {
+				return signedRequest(http.MethodPut, target, body, "core", "core-secret", now.Add(-4*time.Minute))
+			},
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name: "tampered body",
+			request: func() *http.Request {
+				signed := signedRequest(http.MethodPut, target, body, "core", "core-secret", now)
+				req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(`{"title":"Other"}`))
+				req.Header = signed.Header
+				return req
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "tampered URL",
+			request: func() *http.Request {
+				signed := signedRequest(http.MethodPut, target, body, "core", "core-secret", now)
+				req := httptest.NewRequest(http.MethodPut, prefix+"/schedule/1?workspace_user_id=3", strings.NewReader(body))
+				req.Header = signed.Header
+				return req
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "tampered method",
+			request: func() *http.Request {
+				signed := signedRequest(http.MethodPut, target, body, "core", "core-secret", now)
+				req := httptest.NewRequest(http.MethodDelete, target, strings.NewReader(body))
+				req.Header = signed.Header
+				return req
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "wrong secret",
+			request:    func() *http.Request { return signedRequest(http.MethodPut, target, body, "core", "guess", now) },
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "unknown key ID",
+			request:    func() *http.Request { return signedRequest(http.MethodPut, target, body, "other", "core-secret", now) },
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "expired timestamp",
+			request: func() *http.Request {
+				return signedRequest(http.MethodPut, target, body, "core", "core-secret", now.Add(-6*time.Minute))
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "future timestamp",
+			request: func() *http.Request {
+				return signedRequest(http.MethodPut, target, body, "core", "core-secret", now.Add(6*time.Minute))
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "invalid timestamp",
+			request: func() *http.Request {
+				req := signedRequest(http.MethodPut, target, body, "core", "core-secret", now)
+				req.Header.Set(HeaderTimestamp, "yesterday")
+				return req
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "invalid signature",
+			request: func() *http.Request {
+				req := signedRequest(http.MethodPut, target, body, "core", "core-secret", now)
+				req.Header.Set(HeaderSignature, "not hex")
+				return req
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name: "scope denied",
+			request: func() *http.Request {
+				return signedRequest(http.MethodGet, prefix+"/workspace/1", "", "core", "core-secret", now)
+			},
+			wantStatus: fiber.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApp(testConfig)
+			status, body := send(t, app, tt.request())
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", status, tt.wantStatus, body)
+			}
+			if status == fiber.StatusOK && body != "core" {
+				t.Errorf("authenticated as %q, want core", body)
+			}
+		})
+	}
+}
+
+func TestGuardRejectsReplayedSignatures(t *testing.T) {
+	app, _ := newTestApp(testConfig)
+	signedAt := time.Now()
+	request := func() *http.Request {
+		return signedRequest(http.MethodPost, prefix+"/schedule", `{"title":"Card"}`, "core", "core-secret", signedAt)
+	}
+
+	if status, body := send(t, app, request()); status != fiber.StatusOK {
+		t.Fatalf("first request status = %d, want %d: %s", status, fiber.StatusOK, body)
+	}
+	if status, body := send(t, app, request()); status != fiber.StatusUnauthorized {
+		t.Errorf("replayed request status = %d, want %d: %s", status, fiber.StatusUnauthorized, body)
+	}
+}
+
+func TestGuardDisabled(t *testing.T) {
+	app, _ := newTestApp(config.AuthConfig{Disabled: true})
+	req := httptest.NewRequest(http.MethodGet, prefix+"/workspace/1", nil)
+	if status, body := send(t, app, req); status != fiber.StatusOK {
+		t.Errorf("status = %d, want %d: %s", status, fiber.StatusOK, body)
+	}
+}
+
+func TestReloadReplacesCredentials(t *testing.T) {
+	app, authenticator := newTestApp(testConfig)
+	authenticator.Reload(config.AuthConfig{
+		APIKeys:      []config.Credential{{ID: "core", Secret: "new-core-key", Scopes: []string{"*"}}},
+		MaxClockSkew: time.Minute,
+	})
+
+	for key, want := range map[string]int{"core-key": fiber.StatusUnauthorized, "new-core-key": fiber.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, prefix+"/schedule/1", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		if status, body := send(t, app, req); status != want {
+			t.Errorf("key %s: status = %d, want %d: %s", key, status, want, body)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	authenticator := NewAuthenticator(testConfig)
+	app := fiber.New()
+	app.Get("/transcript", authenticator.Require("schedule"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	for key, want := range map[string]int{"transcriber-key": fiber.StatusNoContent, "nope": fiber.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/transcript", nil)
+		req.Header.Set(HeaderAPIKey, key)
+		if status, body := send(t, app, req); status != want {
+			t.Errorf("key %s: status = %d, want %d: %s", key, status, want, body)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"dbms/config"
 	"dbms/database"
+	"dbms/dms_models"
+	"dbms/services/board"
 	"github.com/spf13/viper"
 	"github.com/timewise-team/timewise-models/models"
 	"log"
@@ -52,6 +54,28 @@ func main() {
 		//&models.TwNotificationSettings{},
 		//&models.TwNotifications{},
 		//&models.TwDocument{},
+		&dms_models.TwScheduleICalLink{},
+		&dms_models.TwScheduleRank{},
+		&dms_models.TwBoardColumnRank{},
+		&dms_models.TwBoardColumnLimit{},
+		&dms_models.TwReminderDelivery{},
+		&dms_models.TwCronLease{},
+		&dms_models.TwCronJob{},
+		&dms_models.TwCronJobRun{},
+		&dms_models.TwNotificationDelivery{},
+		&dms_models.TwNotificationQuietHours{},
+		&dms_models.TwNotificationDigestSetting{},
+		&dms_models.TwNotificationDigest{},
+		&dms_models.TwNotificationDigestItem{},
+		&dms_models.TwReminderRule{},
+		&dms_models.TwReminderRuleOccurrence{},
+		&dms_models.TwReminderAcknowledgement{},
+		&dms_models.TwReminderSnooze{},
+		&dms_models.TwReminderEscalation{},
+		&dms_models.TwWebhookSubscription{},
+		&dms_models.TwWebhookEvent{},
+		&dms_models.TwWebhookDelivery{},
+		&dms_models.TwWebhookDeliveryAttempt{},
 	)
 	if err != nil {
 		log.Fatalf("Could not migrate schema: %v", err)
@@ -59,4 +83,12 @@ func main() {
 	} else {
 		log.Println("Migration success")
 	}
+
+	// Convert the integer positions of existing schedules and board columns
+	// into rank keys
+	if err := board.BackfillRanks(db); err != nil {
+		log.Fatalf("Could not backfill rank keys: %v", err)
+		return
+	}
+	log.Println("Rank keys backfilled")
 }
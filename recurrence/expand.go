@@ -0,0 +1,136 @@
+package recurrence
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"sort"
+	"time"
+)
+
+const (
+	DateTimeLayout = "20060102T150405Z"
+	DateLayout     = "20060102"
+)
+
+// Occurrence is one concrete instance of a schedule inside a time window.
+type Occurrence struct {
+	ScheduleId    int
+	Start         time.Time
+	End           time.Time
+	OriginalStart time.Time
+	IsRecurring   bool
+	IsException   bool
+}
+
+// ParseDateTime parses the UTC date-time and date forms used by RRULE values.
+func ParseDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse(DateTimeLayout, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, time.UTC); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(DateLayout, value, time.UTC)
+}
+
+// Expand returns the occurrences of a schedule that overlap [from, to), with
+// cancellations and moved instances from its recurrence exceptions applied.
+// Non-recurring schedules yield at most one occurrence.
+func Expand(schedule models.TwSchedule, exceptions []models.TwRecurrenceException, from, to time.Time) ([]Occurrence, error) {
+	if schedule.StartTime == nil {
+		return nil, nil
+	}
+	start := schedule.StartTime.UTC()
+	duration := time.Duration(0)
+	if schedule.EndTime != nil && schedule.EndTime.After(start) {
+		duration = schedule.EndTime.UTC().Sub(start)
+	}
+
+	if schedule.RecurrencePattern == "" {
+		occurrence := Occurrence{
+			ScheduleId:    schedule.ID,
+			Start:         start,
+			End:           start.Add(duration),
+			OriginalStart: start,
+		}
+		if !Overlaps(occurrence.Start, occurrence.End, from, to) {
+			return nil, nil
+		}
+		return []Occurrence{occurrence}, nil
+	}
+
+	rule, err := Parse(schedule.RecurrencePattern)
+	if err != nil {
+		return nil, err
+	}
+
+	exceptionsByDay := make(map[string]models.TwRecurrenceException, len(exceptions))
+	for _, exception := range exceptions {
+		if exception.ScheduleId != schedule.ID || !exception.DeletedAt.IsZero() {
+			continue
+		}
+		exceptionsByDay[dayKey(exception.ExceptionDate)] = exception
+	}
+
+	var occurrences []Occurrence
+	for _, originalStart := range rule.Between(start, from.Add(-duration), to) {
+		if _, ok := exceptionsByDay[dayKey(originalStart)]; ok {
+			continue
+		}
+		occurrence := Occurrence{
+			ScheduleId:    schedule.ID,
+			Start:         originalStart,
+			End:           originalStart.Add(duration),
+			OriginalStart: originalStart,
+			IsRecurring:   true,
+		}
+		if Overlaps(occurrence.Start, occurrence.End, from, to) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	// Moved instances may land inside the window even when their original slot
+	// does not, so they are checked independently of the expansion above.
+	for _, exception := range exceptionsByDay {
+		if exception.IsCancelled || exception.NewStartTime.IsZero() {
+			continue
+		}
+		day := exception.ExceptionDate.UTC()
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		originals := rule.Between(start, dayStart, dayStart.AddDate(0, 0, 1))
+		if len(originals) == 0 {
+			continue
+		}
+		newStart := exception.NewStartTime.UTC()
+		newEnd := newStart.Add(duration)
+		if !exception.NewEndTime.IsZero() && exception.NewEndTime.After(exception.NewStartTime) {
+			newEnd = exception.NewEndTime.UTC()
+		}
+		if !Overlaps(newStart, newEnd, from, to) {
+			continue
+		}
+		occurrences = append(occurrences, Occurrence{
+			ScheduleId:    schedule.ID,
+			Start:         newStart,
+			End:           newEnd,
+			OriginalStart: originals[0],
+			IsRecurring:   true,
+			IsException:   true,
+		})
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Start.Before(occurrences[j].Start) })
+	return occurrences, nil
+}
+
+// Overlaps reports whether [start, end) intersects [from, to). Zero-length
+// ranges are treated as instants.
+func Overlaps(start, end, from, to time.Time) bool {
+	if !end.After(start) {
+		return !start.Before(from) && start.Before(to)
+	}
+	return start.Before(to) && end.After(from)
+}
+
+func dayKey(t time.Time) string {
+	return t.UTC().Format(DateLayout)
+}
@@ -0,0 +1,143 @@
+package recurrence
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	weekly := models.TwSchedule{ID: 7, StartTime: &start, EndTime: &end, RecurrencePattern: "FREQ=WEEKLY;COUNT=4"}
+	single := models.TwSchedule{ID: 8, StartTime: &start, EndTime: &end}
+
+	cancelled := models.TwRecurrenceException{
+		ScheduleId:    7,
+		ExceptionDate: time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC),
+		IsCancelled:   true,
+	}
+	moved := models.TwRecurrenceException{
+		ScheduleId:    7,
+		ExceptionDate: time.Date(2024, time.March, 18, 0, 0, 0, 0, time.UTC),
+		NewStartTime:  time.Date(2024, time.March, 19, 14, 0, 0, 0, time.UTC),
+		NewEndTime:    time.Date(2024, time.March, 19, 16, 0, 0, 0, time.UTC),
+	}
+	deleted := cancelled
+	deleted.DeletedAt = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	otherSchedule := cancelled
+	otherSchedule.ScheduleId = 9
+
+	type occurrence struct {
+		start, end, original string
+		exception            bool
+	}
+	tests := []struct {
+		name       string
+		schedule   models.TwSchedule
+		exceptions []models.TwRecurrenceException
+		from       string
+		to         string
+		want       []occurrence
+	}{
+		{
+			name:     "no exceptions",
+			schedule: weekly,
+			from:     "20240301T000000Z",
+			to:       "20240401T000000Z",
+			want: []occurrence{
+				{"20240304T090000Z", "20240304T100000Z", "20240304T090000Z", false},
+				{"20240311T090000Z", "20240311T100000Z", "20240311T090000Z", false},
+				{"20240318T090000Z", "20240318T100000Z", "20240318T090000Z", false},
+				{"20240325T090000Z", "20240325T100000Z", "20240325T090000Z", false},
+			},
+		},
+		{
+			name:       "cancelled and moved instances",
+			schedule:   weekly,
+			exceptions: []models.TwRecurrenceException{cancelled, moved},
+			from:       "20240301T000000Z",
+			to:         "20240401T000000Z",
+			want: []occurrence{
+				{"20240304T090000Z", "20240304T100000Z", "20240304T090000Z", false},
+				{"20240319T140000Z", "20240319T160000Z", "20240318T090000Z", true},
+				{"20240325T090000Z", "20240325T100000Z", "20240325T090000Z", false},
+			},
+		},
+		{
+			name:       "moved instance outside its original window",
+			schedule:   weekly,
+			exceptions: []models.TwRecurrenceException{moved},
+			from:       "20240319T000000Z",
+			to:         "20240320T000000Z",
+			want: []occurrence{
+				{"20240319T140000Z", "20240319T160000Z", "20240318T090000Z", true},
+			},
+		},
+		{
+			name:       "deleted and unrelated exceptions are ignored",
+			schedule:   weekly,
+			exceptions: []models.TwRecurrenceException{deleted, otherSchedule},
+			from:       "20240311T000000Z",
+			to:         "20240312T000000Z",
+			want: []occurrence{
+				{"20240311T090000Z", "20240311T100000Z", "20240311T090000Z", false},
+			},
+		},
+		{
+			name:     "occurrence overlapping the window start",
+			schedule: weekly,
+			from:     "20240311T093000Z",
+			to:       "20240312T000000Z",
+			want: []occurrence{
+				{"20240311T090000Z", "20240311T100000Z", "20240311T090000Z", false},
+			},
+		},
+		{
+			name:     "single schedule inside the window",
+			schedule: single,
+			from:     "20240301T000000Z",
+			to:       "20240401T000000Z",
+			want: []occurrence{
+				{"20240304T090000Z", "20240304T100000Z", "20240304T090000Z", false},
+			},
+		},
+		{
+			name:     "single schedule outside the window",
+			schedule: single,
+			from:     "20240305T000000Z",
+			to:       "20240401T000000Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.schedule, tt.exceptions, mustTime(t, tt.from), mustTime(t, tt.to))
+			if err != nil {
+				t.Fatalf("Expand: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expand() returned %d occurrences, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				o := got[i]
+				if o.ScheduleId != tt.schedule.ID ||
+					o.Start.Format(DateTimeLayout) != want.start ||
+					o.End.Format(DateTimeLayout) != want.end ||
+					o.OriginalStart.Format(DateTimeLayout) != want.original ||
+					o.IsException != want.exception ||
+					o.IsRecurring != (tt.schedule.RecurrencePattern != "") {
+					t.Errorf("occurrence %d = %+v, want %+v", i, o, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandRejectsInvalidPattern(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+	schedule := models.TwSchedule{ID: 1, StartTime: &start, RecurrencePattern: "FREQ=SECONDLY"}
+	if _, err := Expand(schedule, nil, start, start.AddDate(0, 1, 0)); err == nil {
+		t.Fatal("Expand succeeded with an invalid pattern")
+	}
+}
@@ -0,0 +1,468 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds how many FREQ periods are walked for a single expansion,
+// so that a rule without COUNT/UNTIL cannot loop forever.
+const maxPeriods = 100000
+
+// WeekdayNum is a BYDAY entry such as "MO", "2TU" or "-1FR".
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule is a parsed RFC 5545 RRULE.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse parses a recurrence pattern stored on TwSchedule. Both the bare
+// "FREQ=WEEKLY;BYDAY=MO" form and the "RRULE:FREQ=..." property form are accepted.
+func Parse(pattern string) (*Rule, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, errors.New("empty recurrence pattern")
+	}
+	for _, line := range strings.Split(pattern, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToUpper(line), "RRULE:") {
+			pattern = line[len("RRULE:"):]
+			break
+		}
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(pattern, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		switch key {
+		case "FREQ":
+			switch Frequency(strings.ToUpper(value)) {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = Frequency(strings.ToUpper(value))
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := ParseDateTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(item)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			days, err := parseIntList(value, -31, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid BYMONTHDAY %q", value)
+			}
+			rule.ByMonthDay = days
+		case "BYMONTH":
+			months, err := parseIntList(value, 1, 12)
+			if err != nil {
+				return nil, fmt.Errorf("invalid BYMONTH %q", value)
+			}
+			rule.ByMonth = months
+		case "BYSETPOS":
+			positions, err := parseIntList(value, -366, 366)
+			if err != nil {
+				return nil, fmt.Errorf("invalid BYSETPOS %q", value)
+			}
+			rule.BySetPos = positions
+		case "WKST":
+			wd, ok := weekdayCodes[strings.ToUpper(value)]
+			if !ok {
+				return nil, fmt.Errorf("invalid WKST %q", value)
+			}
+			rule.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+	if rule.Freq == "" {
+		return nil, errors.New("recurrence pattern is missing FREQ")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL must not both be set")
+	}
+	return rule, nil
+}
+
+// String renders the rule back to its RRULE value (without the "RRULE:" prefix).
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(DateTimeLayout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			code := weekdayCode(wd.Weekday)
+			if wd.N != 0 {
+				code = strconv.Itoa(wd.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the start times of every occurrence of the rule, anchored at
+// dtstart, that start in [from, to). COUNT is honoured from dtstart even when
+// the earlier occurrences lie outside the window.
+func (r *Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	emitted := 0
+	for i := 0; i < maxPeriods; i++ {
+		periodStart, candidates := r.expandPeriod(dtstart, i)
+		if !periodStart.Before(to) {
+			break
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(dtstart) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return result
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return result
+			}
+			emitted++
+			if !candidate.Before(to) {
+				return result
+			}
+			if !candidate.Before(from) {
+				result = append(result, candidate)
+			}
+		}
+	}
+	return result
+}
+
+// expandPeriod returns the start of the i-th FREQ period after dtstart together
+// with the sorted occurrence candidates that fall inside it.
+func (r *Rule) expandPeriod(dtstart time.Time, i int) (time.Time, []time.Time) {
+	loc := dtstart.Location()
+	hour, minute, sec := dtstart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, sec, dtstart.Nanosecond(), loc)
+	}
+
+	var periodStart time.Time
+	var candidates []time.Time
+	switch r.Freq {
+	case Daily:
+		day := dtstart.AddDate(0, 0, i*r.Interval)
+		periodStart = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			candidates = append(candidates, day)
+		}
+	case Weekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(dtstart.Year(), dtstart.Month(), dtstart.Day()-offset).AddDate(0, 0, i*7*r.Interval)
+		periodStart = time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(), 0, 0, 0, 0, loc)
+		for d := 0; d < 7; d++ {
+			day := weekStart.AddDate(0, 0, d)
+			if len(r.ByDay) == 0 {
+				if day.Weekday() != dtstart.Weekday() {
+					continue
+				}
+			} else if !r.matchesWeekday(day) {
+				continue
+			}
+			if r.matchesMonth(day) {
+				candidates = append(candidates, day)
+			}
+		}
+	case Monthly:
+		first := time.Date(dtstart.Year(), dtstart.Month()+time.Month(i*r.Interval), 1, 0, 0, 0, 0, loc)
+		periodStart = first
+		if !r.matchesMonth(first) {
+			return periodStart, nil
+		}
+		candidates = r.expandMonth(first.Year(), first.Month(), dtstart, at)
+	case Yearly:
+		year := dtstart.Year() + i*r.Interval
+		periodStart = time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		months := r.ByMonth
+		if len(months) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) > 0 && hasOrdinal(r.ByDay) {
+			// e.g. FREQ=YEARLY;BYDAY=20MO counts weekdays across the whole year.
+			candidates = r.expandYearByOrdinal(year, at)
+			break
+		}
+		if len(months) == 0 {
+			if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+				months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			} else {
+				months = []int{int(dtstart.Month())}
+			}
+		}
+		for _, m := range months {
+			candidates = append(candidates, r.expandMonth(year, time.Month(m), dtstart, at)...)
+		}
+	}
+
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].Before(candidates[b]) })
+	return periodStart, r.applySetPos(candidates)
+}
+
+func (r *Rule) expandMonth(year int, month time.Month, dtstart time.Time, at func(int, time.Month, int) time.Time) []time.Time {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	var days []int
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = daysInMonth + d + 1
+			}
+			if d >= 1 && d <= daysInMonth {
+				days = append(days, d)
+			}
+		}
+	case len(r.ByDay) > 0:
+		for d := 1; d <= daysInMonth; d++ {
+			days = append(days, d)
+		}
+	default:
+		if dtstart.Day() <= daysInMonth {
+			days = append(days, dtstart.Day())
+		}
+	}
+
+	var result []time.Time
+	for _, d := range days {
+		day := at(year, month, d)
+		if len(r.ByDay) > 0 && !r.matchesWeekdayInRange(day, daysInMonth, d) {
+			continue
+		}
+		result = append(result, day)
+	}
+	return result
+}
+
+func (r *Rule) expandYearByOrdinal(year int, at func(int, time.Month, int) time.Time) []time.Time {
+	daysInYear := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	var result []time.Time
+	for d := 1; d <= daysInYear; d++ {
+		day := at(year, time.January, d)
+		if r.matchesWeekdayInRange(day, daysInYear, d) {
+			result = append(result, day)
+		}
+	}
+	return result
+}
+
+// matchesWeekdayInRange checks BYDAY for a day at index pos within a period of
+// size days, honouring ordinals such as 2MO or -1FR.
+func (r *Rule) matchesWeekdayInRange(day time.Time, size int, pos int) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday != day.Weekday() {
+			continue
+		}
+		if wd.N == 0 {
+			return true
+		}
+		if wd.N > 0 && (pos-1)/7+1 == wd.N {
+			return true
+		}
+		if wd.N < 0 && (size-pos)/7+1 == -wd.N {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = daysInMonth + d + 1
+		}
+		if d == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) applySetPos(candidates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(candidates) == 0 {
+		return candidates
+	}
+	var result []time.Time
+	for _, pos := range r.BySetPos {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(candidates) + pos
+		}
+		if idx >= 0 && idx < len(candidates) {
+			result = append(result, candidates[idx])
+		}
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Before(result[b]) })
+	return result
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	wd, ok := weekdayCodes[value[len(value)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	n := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		parsed, err := strconv.Atoi(prefix)
+		if err != nil || parsed == 0 || parsed < -53 || parsed > 53 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", value)
+		}
+		n = parsed
+	}
+	return WeekdayNum{Weekday: wd, N: n}, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func hasOrdinal(days []WeekdayNum) bool {
+	for _, wd := range days {
+		if wd.N != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func weekdayCode(wd time.Weekday) string {
+	for code, day := range weekdayCodes {
+		if day == wd {
+			return code
+		}
+	}
+	return ""
+}
+
+func joinInts(values []int) string {
+	items := make([]string, 0, len(values))
+	for _, v := range values {
+		items = append(items, strconv.Itoa(v))
+	}
+	return strings.Join(items, ",")
+}
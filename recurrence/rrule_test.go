@@ -0,0 +1,283 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := ParseDateTime(value)
+	if err != nil {
+		t.Fatalf("parse %q: %v", value, err)
+	}
+	return parsed
+}
+
+func formatTimes(times []time.Time) []string {
+	result := make([]string, len(times))
+	for i, t := range times {
+		result[i] = t.UTC().Format(DateTimeLayout)
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The expected expansions below are taken from the examples in RFC 5545
+// section 3.8.5.3 where one exists.
+func TestRuleBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		dtstart string
+		from    string
+		to      string
+		want    []string
+	}{
+		{
+			name:    "daily count",
+			pattern: "FREQ=DAILY;COUNT=3",
+			dtstart: "19970902T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970902T090000Z", "19970903T090000Z", "19970904T090000Z"},
+		},
+		{
+			name:    "count is honoured from dtstart outside the window",
+			pattern: "FREQ=DAILY;COUNT=5",
+			dtstart: "19970902T090000Z",
+			from:    "19970904T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970904T090000Z", "19970905T090000Z", "19970906T090000Z"},
+		},
+		{
+			name:    "until is inclusive",
+			pattern: "FREQ=DAILY;UNTIL=19970904T090000Z",
+			dtstart: "19970902T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970902T090000Z", "19970903T090000Z", "19970904T090000Z"},
+		},
+		{
+			name:    "until before the time of day excludes that day",
+			pattern: "FREQ=DAILY;UNTIL=19970904T000000Z",
+			dtstart: "19970902T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970902T090000Z", "19970903T090000Z"},
+		},
+		{
+			name:    "weekly interval with week start monday",
+			pattern: "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			dtstart: "19970805T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970805T090000Z", "19970810T090000Z", "19970819T090000Z", "19970824T090000Z"},
+		},
+		{
+			name:    "weekly interval with week start sunday",
+			pattern: "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			dtstart: "19970805T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970805T090000Z", "19970817T090000Z", "19970819T090000Z", "19970831T090000Z"},
+		},
+		{
+			name:    "monthly first friday",
+			pattern: "FREQ=MONTHLY;COUNT=6;BYDAY=1FR",
+			dtstart: "19970905T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19990101T000000Z",
+			want: []string{
+				"19970905T090000Z", "19971003T090000Z", "19971107T090000Z",
+				"19971205T090000Z", "19980102T090000Z", "19980206T090000Z",
+			},
+		},
+		{
+			name:    "every other month on the first and last sunday",
+			pattern: "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU",
+			dtstart: "19970907T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19990101T000000Z",
+			want: []string{
+				"19970907T090000Z", "19970928T090000Z", "19971102T090000Z", "19971130T090000Z",
+				"19980104T090000Z", "19980125T090000Z", "19980301T090000Z", "19980329T090000Z",
+				"19980503T090000Z", "19980531T090000Z",
+			},
+		},
+		{
+			name:    "monthly second to last monday",
+			pattern: "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO",
+			dtstart: "19970922T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19990101T000000Z",
+			want: []string{
+				"19970922T090000Z", "19971020T090000Z", "19971117T090000Z",
+				"19971222T090000Z", "19980119T090000Z", "19980216T090000Z",
+			},
+		},
+		{
+			name:    "yearly twentieth monday",
+			pattern: "FREQ=YEARLY;COUNT=3;BYDAY=20MO",
+			dtstart: "19970519T090000Z",
+			from:    "19970101T000000Z",
+			to:      "20000101T000000Z",
+			want:    []string{"19970519T090000Z", "19980518T090000Z", "19990517T090000Z"},
+		},
+		{
+			name:    "bysetpos third of tuesday to thursday",
+			pattern: "FREQ=MONTHLY;COUNT=3;BYDAY=TU,WE,TH;BYSETPOS=3",
+			dtstart: "19970904T090000Z",
+			from:    "19970101T000000Z",
+			to:      "19980101T000000Z",
+			want:    []string{"19970904T090000Z", "19971007T090000Z", "19971106T090000Z"},
+		},
+		{
+			name:    "bysetpos last weekday of the month",
+			pattern: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			dtstart: "20240131T090000Z",
+			from:    "20240101T000000Z",
+			to:      "20240601T000000Z",
+			want: []string{
+				"20240131T090000Z", "20240229T090000Z", "20240329T090000Z",
+				"20240430T090000Z", "20240531T090000Z",
+			},
+		},
+		{
+			name:    "last day of the month",
+			pattern: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: "20240131T090000Z",
+			from:    "20240101T000000Z",
+			to:      "20240501T000000Z",
+			want:    []string{"20240131T090000Z", "20240229T090000Z", "20240331T090000Z", "20240430T090000Z"},
+		},
+		{
+			name:    "monthly on the 31st skips shorter months",
+			pattern: "FREQ=MONTHLY;COUNT=4",
+			dtstart: "20240131T090000Z",
+			from:    "20240101T000000Z",
+			to:      "20250101T000000Z",
+			want:    []string{"20240131T090000Z", "20240331T090000Z", "20240531T090000Z", "20240731T090000Z"},
+		},
+		{
+			name:    "yearly on february 29th only in leap years",
+			pattern: "FREQ=YEARLY;COUNT=2",
+			dtstart: "20240229T090000Z",
+			from:    "20240101T000000Z",
+			to:      "20330101T000000Z",
+			want:    []string{"20240229T090000Z", "20280229T090000Z"},
+		},
+		{
+			name:    "window end is exclusive",
+			pattern: "FREQ=DAILY",
+			dtstart: "20240101T090000Z",
+			from:    "20240102T090000Z",
+			to:      "20240104T090000Z",
+			want:    []string{"20240102T090000Z", "20240103T090000Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.pattern, err)
+			}
+			got := formatTimes(rule.Between(mustTime(t, tt.dtstart), mustTime(t, tt.from), mustTime(t, tt.to)))
+			if !equalStrings(got, tt.want) {
+				t.Errorf("Between() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleBetweenKeepsWallClockAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	tests := []struct {
+		name    string
+		pattern string
+		dtstart time.Time
+		want    []string
+	}{
+		{
+			name:    "spring forward",
+			pattern: "FREQ=WEEKLY;COUNT=3",
+			dtstart: time.Date(2024, time.March, 3, 9, 0, 0, 0, loc),
+			want:    []string{"20240303T140000Z", "20240310T130000Z", "20240317T130000Z"},
+		},
+		{
+			name:    "fall back",
+			pattern: "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2024, time.November, 2, 9, 0, 0, 0, loc),
+			want:    []string{"20241102T130000Z", "20241103T140000Z", "20241104T140000Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.pattern, err)
+			}
+			occurrences := rule.Between(tt.dtstart, tt.dtstart, tt.dtstart.AddDate(0, 1, 0))
+			if got := formatTimes(occurrences); !equalStrings(got, tt.want) {
+				t.Errorf("Between() = %v, want %v", got, tt.want)
+			}
+			for _, occurrence := range occurrences {
+				if occurrence.Hour() != 9 {
+					t.Errorf("occurrence %v is not at 09:00 local time", occurrence)
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+		want    string
+	}{
+		{name: "round trip", pattern: "FREQ=MONTHLY;INTERVAL=2;BYDAY=1SU,-1SU;COUNT=10", want: "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU"},
+		{name: "rrule prefix", pattern: "RRULE:FREQ=DAILY", want: "FREQ=DAILY"},
+		{name: "missing freq", pattern: "COUNT=3", wantErr: true},
+		{name: "unknown freq", pattern: "FREQ=HOURLY", wantErr: true},
+		{name: "count and until", pattern: "FREQ=DAILY;COUNT=3;UNTIL=19971224T000000Z", wantErr: true},
+		{name: "bad ordinal", pattern: "FREQ=MONTHLY;BYDAY=54MO", wantErr: true},
+		{name: "bad month day", pattern: "FREQ=MONTHLY;BYMONTHDAY=32", wantErr: true},
+		{name: "zero interval", pattern: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.pattern)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) succeeded, want error", tt.pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.pattern, err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"dbms/config"
 	"dbms/database"
 	h "dbms/handlers"
+	"dbms/services/board"
+	"dbms/services/notification"
 	"log"
 )
 
@@ -20,6 +22,11 @@ func RegisterServer() {
 		log.Fatalf("Could not initialize database: %v", err)
 	}
 
+	// Rebalance rank keys that grew too long in the background
+	board.StartRebalancer(db)
+	// Publish new notifications to the SSE streams
+	notification.StartTailer(db)
+
 	// Initialize router
 	r := h.RegisterHandlerV1(db)
 	// Start server
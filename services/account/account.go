@@ -0,0 +1,19 @@
+package account
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+)
+
+// ClearExpiredEmailLinks resets the pending link requests of user emails
+// whose link request has expired and returns how many were reset.
+func ClearExpiredEmailLinks(db *gorm.DB) (int64, error) {
+	result := db.Model(&models.TwUserEmail{}).
+		Where("expires_at <= NOW()").
+		Updates(map[string]interface{}{
+			"status":       nil,
+			"is_linked_to": nil,
+			"expires_at":   nil,
+		})
+	return result.RowsAffected, result.Error
+}
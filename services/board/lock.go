@@ -0,0 +1,29 @@
+package board
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockColumn loads a board column with SELECT ... FOR UPDATE so that
+// concurrent writers renumbering the schedules of that column are serialized.
+// It must be called inside a transaction.
+func LockColumn(tx *gorm.DB, boardColumnId int) (models.TwBoardColumn, error) {
+	var boardColumn models.TwBoardColumn
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", boardColumnId).
+		First(&boardColumn).Error
+	return boardColumn, err
+}
+
+// LockWorkspaceColumns locks every non-deleted board column of a workspace,
+// in ID order to avoid deadlocks, before their positions are rewritten.
+func LockWorkspaceColumns(tx *gorm.DB, workspaceId int) ([]models.TwBoardColumn, error) {
+	var boardColumns []models.TwBoardColumn
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("workspace_id = ? AND deleted_at IS NULL", workspaceId).
+		Order("id").
+		Find(&boardColumns).Error
+	return boardColumns, err
+}
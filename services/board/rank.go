@@ -0,0 +1,294 @@
+package board
+
+import (
+	"dbms/dms_models"
+	"dbms/lexorank"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// rankList describes an ordered list whose sort keys live in a side table:
+// schedules inside a board column, or board columns inside a workspace.
+type rankList struct {
+	itemTable string
+	rankTable string
+	itemKey   string
+	parentKey string
+	active    string
+}
+
+var (
+	scheduleList = rankList{
+		itemTable: "tw_schedules",
+		rankTable: "tw_schedule_ranks",
+		itemKey:   "schedule_id",
+		parentKey: "board_column_id",
+		active:    "tw_schedules.is_deleted = false AND tw_schedules.deleted_at IS NULL",
+	}
+	columnList = rankList{
+		itemTable: "tw_board_columns",
+		rankTable: "tw_board_column_ranks",
+		itemKey:   "board_column_id",
+		parentKey: "workspace_id",
+		active:    "tw_board_columns.deleted_at IS NULL",
+	}
+)
+
+// lastPosition is clamped to the end of any list.
+const lastPosition = int(^uint(0) >> 1)
+
+type rankedItem struct {
+	ID      int
+	RankKey *string
+}
+
+// ScheduleOrder joins the schedule ranks and orders tw_schedules by rank key.
+// Schedules without a rank yet (not migrated) sort last by legacy position.
+func ScheduleOrder(db *gorm.DB) *gorm.DB {
+	return scheduleList.order(db)
+}
+
+// ColumnOrder joins the board column ranks and orders tw_board_columns by
+// rank key, falling back to the legacy position.
+func ColumnOrder(db *gorm.DB) *gorm.DB {
+	return columnList.order(db)
+}
+
+func (l rankList) order(db *gorm.DB) *gorm.DB {
+	return db.Joins("LEFT JOIN " + l.rankTable + " ON " + l.rankTable + "." + l.itemKey + " = " + l.itemTable + ".id AND " +
+		l.rankTable + "." + l.parentKey + " = " + l.itemTable + "." + l.parentKey).
+		Order(l.rankTable + ".rank_key IS NULL, " + l.rankTable + ".rank_key, " + l.itemTable + ".position, " + l.itemTable + ".id")
+}
+
+// ordered returns the active items of a parent in display order, leaving out
+// excludeId.
+func (l rankList) ordered(tx *gorm.DB, parentId, excludeId int) ([]rankedItem, error) {
+	var items []rankedItem
+	err := tx.Table(l.itemTable).
+		Select(l.itemTable+".id, "+l.rankTable+".rank_key").
+		Where(l.itemTable+"."+l.parentKey+" = ? AND "+l.itemTable+".id != ?", parentId, excludeId).
+		Where(l.active).
+		Scopes(l.order).
+		Scan(&items).Error
+	return items, err
+}
+
+func (l rankList) set(tx *gorm.DB, itemId, parentId int, key string) error {
+	now := time.Now()
+	return tx.Table(l.rankTable).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: l.itemKey}},
+			DoUpdates: clause.AssignmentColumns([]string{l.parentKey, "rank_key", "updated_at"}),
+		}).
+		Create(map[string]interface{}{
+			l.itemKey:    itemId,
+			l.parentKey:  parentId,
+			"rank_key":   key,
+			"created_at": now,
+			"updated_at": now,
+		}).Error
+}
+
+// move gives itemId a key placing it at the 1-based position among the other
+// active items of parentId; positions out of range are clamped. Only the rank
+// row of the item is written, unless the list still has unranked items, in
+// which case the whole list is ranked first.
+func (l rankList) move(tx *gorm.DB, itemId, parentId, position int) (string, error) {
+	items, err := l.ordered(tx, parentId, itemId)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range items {
+		if item.RankKey == nil {
+			if err := l.rebalance(tx, parentId); err != nil {
+				return "", err
+			}
+			if items, err = l.ordered(tx, parentId, itemId); err != nil {
+				return "", err
+			}
+			break
+		}
+	}
+
+	index := position - 1
+	if index < 0 {
+		index = 0
+	}
+	if index > len(items) {
+		index = len(items)
+	}
+	var before, after string
+	if index > 0 {
+		before = *items[index-1].RankKey
+	}
+	if index < len(items) {
+		after = *items[index].RankKey
+	}
+	key, err := lexorank.Between(before, after)
+	if err != nil {
+		return "", err
+	}
+	if err := l.set(tx, itemId, parentId, key); err != nil {
+		return "", err
+	}
+	if lexorank.TooLong(key) {
+		requestRebalance(l, parentId)
+	}
+	return key, nil
+}
+
+// rebalance spreads fresh, short keys over the active items of a parent in
+// their current order and syncs the legacy integer positions with it.
+func (l rankList) rebalance(tx *gorm.DB, parentId int) error {
+	items, err := l.ordered(tx, parentId, 0)
+	if err != nil {
+		return err
+	}
+	keys := lexorank.Spread(len(items))
+	for i, item := range items {
+		if err := l.set(tx, item.ID, parentId, keys[i]); err != nil {
+			return err
+		}
+		if err := tx.Table(l.itemTable).Where("id = ?", item.ID).UpdateColumn("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MoveSchedule places a schedule at a 1-based position of a board column.
+func MoveSchedule(tx *gorm.DB, scheduleId, boardColumnId, position int) (string, error) {
+	return scheduleList.move(tx, scheduleId, boardColumnId, position)
+}
+
+// AppendSchedule places a schedule after every other card of a board column.
+func AppendSchedule(tx *gorm.DB, scheduleId, boardColumnId int) (string, error) {
+	return scheduleList.move(tx, scheduleId, boardColumnId, lastPosition)
+}
+
+// SchedulePositions maps the active schedules of a board column to their
+// 1-based position in rank order.
+func SchedulePositions(db *gorm.DB, boardColumnId int) (map[int]int, error) {
+	items, err := scheduleList.ordered(db, boardColumnId, 0)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[int]int, len(items))
+	for i, item := range items {
+		positions[item.ID] = i + 1
+	}
+	return positions, nil
+}
+
+// RemoveScheduleRank drops the rank of a deleted schedule.
+func RemoveScheduleRank(tx *gorm.DB, scheduleId int) error {
+	return tx.Where("schedule_id = ?", scheduleId).Delete(&dms_models.TwScheduleRank{}).Error
+}
+
+// RebalanceSchedules rewrites the rank keys of a board column.
+func RebalanceSchedules(tx *gorm.DB, boardColumnId int) error {
+	return scheduleList.rebalance(tx, boardColumnId)
+}
+
+// MoveColumn places a board column at a 1-based position of its workspace.
+func MoveColumn(tx *gorm.DB, boardColumnId, workspaceId, position int) (string, error) {
+	return columnList.move(tx, boardColumnId, workspaceId, position)
+}
+
+// AppendColumn places a board column after every other column of its
+// workspace.
+func AppendColumn(tx *gorm.DB, boardColumnId, workspaceId int) (string, error) {
+	return columnList.move(tx, boardColumnId, workspaceId, lastPosition)
+}
+
+// ColumnPositions maps the active board columns of a workspace to their
+// 1-based position in rank order.
+func ColumnPositions(db *gorm.DB, workspaceId int) (map[int]int, error) {
+	items, err := columnList.ordered(db, workspaceId, 0)
+	if err != nil {
+		return nil, err
+	}
+	positions := make(map[int]int, len(items))
+	for i, item := range items {
+		positions[item.ID] = i + 1
+	}
+	return positions, nil
+}
+
+// RemoveColumnRank drops the rank of a deleted board column.
+func RemoveColumnRank(tx *gorm.DB, boardColumnId int) error {
+	return tx.Where("board_column_id = ?", boardColumnId).Delete(&dms_models.TwBoardColumnRank{}).Error
+}
+
+// RebalanceColumns rewrites the rank keys of the board columns of a workspace.
+func RebalanceColumns(tx *gorm.DB, workspaceId int) error {
+	return columnList.rebalance(tx, workspaceId)
+}
+
+// BackfillRanks gives a rank key to every schedule and board column that has
+// none, keeping the order of their integer positions. Lists that are already
+// fully ranked are left untouched.
+func BackfillRanks(db *gorm.DB) error {
+	for _, l := range []rankList{columnList, scheduleList} {
+		var parentIds []int
+		if err := db.Table(l.itemTable).
+			Joins("LEFT JOIN "+l.rankTable+" ON "+l.rankTable+"."+l.itemKey+" = "+l.itemTable+".id").
+			Where(l.active).
+			Where(l.rankTable+".id IS NULL").
+			Distinct(l.itemTable+"."+l.parentKey).
+			Pluck(l.itemTable+"."+l.parentKey, &parentIds).Error; err != nil {
+			return err
+		}
+		for _, parentId := range parentIds {
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				return l.rebalance(tx, parentId)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type rebalanceRequest struct {
+	list     rankList
+	parentId int
+}
+
+var rebalanceQueue = make(chan rebalanceRequest, 256)
+
+// requestRebalance queues a list for rebalancing without blocking the caller;
+// when the queue is full the request is dropped and retried on the next long
+// key.
+func requestRebalance(l rankList, parentId int) {
+	select {
+	case rebalanceQueue <- rebalanceRequest{list: l, parentId: parentId}:
+	default:
+	}
+}
+
+// StartRebalancer runs the background worker that rebalances lists whose
+// keys grew past lexorank.MaxLength.
+func StartRebalancer(db *gorm.DB) {
+	go func() {
+		for request := range rebalanceQueue {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var lockErr error
+				if request.list == scheduleList {
+					_, lockErr = LockColumn(tx, request.parentId)
+				} else {
+					_, lockErr = LockWorkspaceColumns(tx, request.parentId)
+				}
+				if lockErr != nil {
+					return lockErr
+				}
+				return request.list.rebalance(tx, request.parentId)
+			})
+			if err != nil {
+				log.Printf("Could not rebalance %s of %s %d: %v", request.list.itemTable, request.list.parentKey, request.parentId, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,202 @@
+package board
+
+import (
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"dbms/lexorank"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testColumnId = 1
+
+func openRankDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return dbtest.Open(t, &models.TwSchedule{}, &dms_models.TwScheduleRank{})
+}
+
+// seedSchedules creates active schedules in the test column with the given
+// legacy positions, in ID order starting at 1, and no rank.
+func seedSchedules(t *testing.T, db *gorm.DB, positions ...int) {
+	t.Helper()
+	now := time.Now()
+	for i, position := range positions {
+		schedule := models.TwSchedule{ID: i + 1, WorkspaceId: 1, BoardColumnId: testColumnId, Title: "Card",
+			StartTime: &now, EndTime: &now, CreatedAt: &now, UpdatedAt: &now, Position: position}
+		if err := db.Omit(clause.Associations).Create(&schedule).Error; err != nil {
+			t.Fatalf("seed schedule: %v", err)
+		}
+	}
+}
+
+func setRank(t *testing.T, db *gorm.DB, scheduleId int, key string) {
+	t.Helper()
+	if err := scheduleList.set(db, scheduleId, testColumnId, key); err != nil {
+		t.Fatalf("set rank: %v", err)
+	}
+}
+
+// order returns the schedule IDs of the test column in display order.
+func order(t *testing.T, db *gorm.DB) []int {
+	t.Helper()
+	items, err := scheduleList.ordered(db, testColumnId, 0)
+	if err != nil {
+		t.Fatalf("order: %v", err)
+	}
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMoveSchedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		scheduleId int
+		position   int
+		want       []int
+	}{
+		{name: "to the head", scheduleId: 3, position: 1, want: []int{3, 1, 2}},
+		{name: "into the middle", scheduleId: 1, position: 2, want: []int{2, 1, 3}},
+		{name: "to the tail", scheduleId: 1, position: 3, want: []int{2, 3, 1}},
+		{name: "position below range", scheduleId: 2, position: -4, want: []int{2, 1, 3}},
+		{name: "position past the end", scheduleId: 2, position: 99, want: []int{1, 3, 2}},
+		{name: "onto its own position", scheduleId: 2, position: 2, want: []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openRankDB(t)
+			seedSchedules(t, db, 1, 2, 3)
+			for id := 1; id <= 3; id++ {
+				if _, err := AppendSchedule(db, id, testColumnId); err != nil {
+					t.Fatalf("append: %v", err)
+				}
+			}
+
+			if _, err := MoveSchedule(db, tt.scheduleId, testColumnId, tt.position); err != nil {
+				t.Fatalf("MoveSchedule: %v", err)
+			}
+			if got := order(t, db); !equalInts(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+			positions, err := SchedulePositions(db, testColumnId)
+			if err != nil {
+				t.Fatalf("SchedulePositions: %v", err)
+			}
+			for i, id := range tt.want {
+				if positions[id] != i+1 {
+					t.Errorf("position of %d = %d, want %d", id, positions[id], i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestMoveScheduleRanksLegacyListFirst(t *testing.T) {
+	db := openRankDB(t)
+	// Legacy positions disagree with the IDs; they decide the initial order.
+	seedSchedules(t, db, 3, 1, 2)
+
+	if _, err := MoveSchedule(db, 1, testColumnId, 1); err != nil {
+		t.Fatalf("MoveSchedule: %v", err)
+	}
+	if got, want := order(t, db), []int{1, 2, 3}; !equalInts(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+	if count := dbtest.Count(t, db, "tw_schedule_ranks"); count != 3 {
+		t.Errorf("%d schedules ranked, want 3", count)
+	}
+}
+
+func TestRebalanceSchedules(t *testing.T) {
+	db := openRankDB(t)
+	seedSchedules(t, db, 1, 2, 3, 4)
+	long := strings.Repeat("i", lexorank.MaxLength)
+	// Out of ID order, with keys past MaxLength.
+	setRank(t, db, 1, long+"3")
+	setRank(t, db, 2, long+"1")
+	setRank(t, db, 3, long+"2")
+	setRank(t, db, 4, long+"4")
+	if err := db.Model(&models.TwSchedule{}).Where("id = ?", 4).Update("is_deleted", true).Error; err != nil {
+		t.Fatalf("delete schedule: %v", err)
+	}
+
+	if err := RebalanceSchedules(db, testColumnId); err != nil {
+		t.Fatalf("RebalanceSchedules: %v", err)
+	}
+
+	want := []int{2, 3, 1}
+	if got := order(t, db); !equalInts(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	keys := lexorank.Spread(len(want))
+	for i, id := range want {
+		var rank dms_models.TwScheduleRank
+		if err := db.Where("schedule_id = ?", id).First(&rank).Error; err != nil {
+			t.Fatalf("load rank: %v", err)
+		}
+		if rank.RankKey != keys[i] {
+			t.Errorf("rank key of %d = %q, want %q", id, rank.RankKey, keys[i])
+		}
+		var schedule models.TwSchedule
+		if err := db.First(&schedule, id).Error; err != nil {
+			t.Fatalf("load schedule: %v", err)
+		}
+		if schedule.Position != i+1 {
+			t.Errorf("legacy position of %d = %d, want %d", id, schedule.Position, i+1)
+		}
+	}
+	// The deleted schedule keeps its old rank and does not take a slot.
+	var rank dms_models.TwScheduleRank
+	if err := db.Where("schedule_id = ?", 4).First(&rank).Error; err != nil {
+		t.Fatalf("load rank: %v", err)
+	}
+	if rank.RankKey != long+"4" {
+		t.Errorf("rank key of the deleted schedule = %q, want it untouched", rank.RankKey)
+	}
+}
+
+func TestMoveScheduleRequestsRebalanceForLongKeys(t *testing.T) {
+	db := openRankDB(t)
+	seedSchedules(t, db, 1, 2, 3)
+	setRank(t, db, 1, "i")
+	setRank(t, db, 2, "i"+strings.Repeat("0", lexorank.MaxLength-2)+"1")
+	setRank(t, db, 3, "j")
+	for len(rebalanceQueue) > 0 {
+		<-rebalanceQueue
+	}
+
+	key, err := MoveSchedule(db, 3, testColumnId, 2)
+	if err != nil {
+		t.Fatalf("MoveSchedule: %v", err)
+	}
+	if !lexorank.TooLong(key) {
+		t.Fatalf("key %q is not past MaxLength", key)
+	}
+	select {
+	case request := <-rebalanceQueue:
+		if request.list != scheduleList || request.parentId != testColumnId {
+			t.Errorf("rebalance requested for %s %d, want board column %d", request.list.itemTable, request.parentId, testColumnId)
+		}
+	default:
+		t.Errorf("no rebalance requested")
+	}
+}
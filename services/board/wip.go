@@ -0,0 +1,126 @@
+package board
+
+import (
+	"dbms/dms_models"
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"strconv"
+)
+
+// WipStatus is the card count of a board column and its WIP limit, if any.
+type WipStatus struct {
+	ScheduleCount int64 `json:"schedule_count"`
+	WipLimit      *int  `json:"wip_limit"`
+}
+
+// Full reports whether one more card would exceed the limit.
+func (s WipStatus) Full() bool {
+	return s.WipLimit != nil && s.ScheduleCount >= int64(*s.WipLimit)
+}
+
+// WipLimitError is returned when a card would be added to a full column.
+type WipLimitError struct {
+	BoardColumnId int
+	WipStatus
+}
+
+func (e *WipLimitError) Error() string {
+	return fmt.Sprintf("board column %d has reached its WIP limit of %d cards", e.BoardColumnId, *e.WipLimit)
+}
+
+// ColumnWip returns the WIP status of a board column.
+func ColumnWip(db *gorm.DB, boardColumnId int) (WipStatus, error) {
+	statuses, err := ColumnsWip(db, []int{boardColumnId})
+	if err != nil {
+		return WipStatus{}, err
+	}
+	return statuses[boardColumnId], nil
+}
+
+// ColumnsWip returns the WIP status of several board columns.
+func ColumnsWip(db *gorm.DB, boardColumnIds []int) (map[int]WipStatus, error) {
+	result := make(map[int]WipStatus, len(boardColumnIds))
+	if len(boardColumnIds) == 0 {
+		return result, nil
+	}
+
+	var counts []struct {
+		BoardColumnId int
+		Count         int64
+	}
+	if err := db.Table("tw_schedules").
+		Select("board_column_id, COUNT(*) AS count").
+		Where("board_column_id IN (?) AND is_deleted = false AND deleted_at IS NULL", boardColumnIds).
+		Group("board_column_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	var limits []dms_models.TwBoardColumnLimit
+	if err := db.Where("board_column_id IN (?)", boardColumnIds).Find(&limits).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range boardColumnIds {
+		result[id] = WipStatus{}
+	}
+	for _, count := range counts {
+		status := result[count.BoardColumnId]
+		status.ScheduleCount = count.Count
+		result[count.BoardColumnId] = status
+	}
+	for _, limit := range limits {
+		status := result[limit.BoardColumnId]
+		wipLimit := limit.WipLimit
+		status.WipLimit = &wipLimit
+		result[limit.BoardColumnId] = status
+	}
+	return result, nil
+}
+
+// EnforceWipLimit checks that a card can be added to a board column. The
+// column should be locked by the caller. When the column is full it returns
+// a *WipLimitError, unless override is set, in which case it reports the
+// status and overridden = true so that the caller can record it.
+func EnforceWipLimit(tx *gorm.DB, boardColumnId int, override bool) (WipStatus, bool, error) {
+	status, err := ColumnWip(tx, boardColumnId)
+	if err != nil {
+		return status, false, err
+	}
+	if !status.Full() {
+		return status, false, nil
+	}
+	if !override {
+		return status, false, &WipLimitError{BoardColumnId: boardColumnId, WipStatus: status}
+	}
+	return status, true, nil
+}
+
+// SetWipLimit sets the WIP limit of a board column; a nil limit removes it.
+func SetWipLimit(db *gorm.DB, boardColumnId int, wipLimit *int) error {
+	if wipLimit == nil {
+		return db.Where("board_column_id = ?", boardColumnId).Delete(&dms_models.TwBoardColumnLimit{}).Error
+	}
+	var limit dms_models.TwBoardColumnLimit
+	err := db.Where("board_column_id = ?", boardColumnId).First(&limit).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	limit.BoardColumnId = boardColumnId
+	limit.WipLimit = *wipLimit
+	return db.Save(&limit).Error
+}
+
+// WipOverrideLog records that a card was put into a full board column with
+// override_wip_limit.
+func WipOverrideLog(scheduleId, workspaceUserId, boardColumnId int, wip WipStatus) *models.TwScheduleLog {
+	return &models.TwScheduleLog{
+		ScheduleId:      scheduleId,
+		WorkspaceUserId: workspaceUserId,
+		Action:          "override wip limit",
+		FieldChanged:    "board_column_id",
+		NewValue:        strconv.Itoa(boardColumnId),
+		Description:     fmt.Sprintf("WIP limit of %d exceeded with %d cards in the column", *wip.WipLimit, wip.ScheduleCount),
+	}
+}
@@ -0,0 +1,197 @@
+package calendar
+
+import (
+	"dbms/recurrence"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval is a half-open time range [Start, End).
+type Interval struct {
+	Start time.Time `json:"start_time"`
+	End   time.Time `json:"end_time"`
+}
+
+// BusyInterval is an interval taken by an occurrence of a schedule.
+type BusyInterval struct {
+	Interval
+	ScheduleId  int    `json:"schedule_id"`
+	WorkspaceId int    `json:"workspace_id"`
+	Title       string `json:"title"`
+}
+
+// WorkspaceUsersByEmail maps each email to the joined workspace users of the
+// person owning it, including memberships of their other linked emails.
+func WorkspaceUsersByEmail(db *gorm.DB, emails []string) (map[string][]int, error) {
+	result := make(map[string][]int, len(emails))
+	if len(emails) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		Email    string
+		LinkedId int
+	}
+	if err := db.Table("tw_user_emails AS source_email").
+		Select("source_email.email AS email, linked.id AS linked_id").
+		Joins("JOIN tw_user_emails AS linked_email ON linked_email.user_id = source_email.user_id AND linked_email.deleted_at IS NULL").
+		Joins("JOIN tw_workspace_users AS linked ON linked.user_email_id = linked_email.id AND linked.deleted_at IS NULL").
+		Where("source_email.email IN (?) AND source_email.deleted_at IS NULL", emails).
+		Where("linked.status = 'joined' AND linked.is_active = true").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.Email] = append(result[row.Email], row.LinkedId)
+	}
+	return result, nil
+}
+
+// BusyIntervals expands the joined schedules of each group of workspace users
+// over [from, to) and returns the busy intervals of every group, keyed like
+// the input. Each group stands for one person.
+func BusyIntervals(db *gorm.DB, groups map[string][]int, from, to time.Time) (map[string][]BusyInterval, error) {
+	var allIds []int
+	for _, ids := range groups {
+		allIds = append(allIds, ids...)
+	}
+	participantSchedules, err := ParticipantSchedules(db, allIds, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var schedules []models.TwSchedule
+	for _, items := range participantSchedules {
+		schedules = append(schedules, items...)
+	}
+	exceptions, err := RecurrenceExceptions(db, schedules)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]BusyInterval, len(groups))
+	for key, ids := range groups {
+		busy := []BusyInterval{}
+		seen := make(map[int]bool)
+		for _, id := range ids {
+			for _, schedule := range participantSchedules[id] {
+				if seen[schedule.ID] {
+					continue
+				}
+				seen[schedule.ID] = true
+				occurrences, err := recurrence.Expand(schedule, exceptions[schedule.ID], from, to)
+				if err != nil {
+					continue
+				}
+				for _, occurrence := range occurrences {
+					if !occurrence.End.After(occurrence.Start) {
+						continue
+					}
+					busy = append(busy, BusyInterval{
+						Interval:    Interval{Start: occurrence.Start, End: occurrence.End},
+						ScheduleId:  schedule.ID,
+						WorkspaceId: schedule.WorkspaceId,
+						Title:       schedule.Title,
+					})
+				}
+			}
+		}
+		sort.Slice(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+		result[key] = busy
+	}
+	return result, nil
+}
+
+// MergeIntervals returns the union of the intervals, sorted and non-overlapping.
+func MergeIntervals(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sorted := append([]Interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+	merged := []Interval{sorted[0]}
+	for _, interval := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if !interval.Start.After(last.End) {
+			if interval.End.After(last.End) {
+				last.End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// SubtractIntervals removes the (merged, sorted) busy intervals from base.
+func SubtractIntervals(base Interval, busy []Interval) []Interval {
+	var result []Interval
+	cursor := base.Start
+	for _, interval := range busy {
+		if !interval.End.After(cursor) {
+			continue
+		}
+		if !interval.Start.Before(base.End) {
+			break
+		}
+		if interval.Start.After(cursor) {
+			result = append(result, Interval{Start: cursor, End: interval.Start})
+		}
+		cursor = interval.End
+	}
+	if cursor.Before(base.End) {
+		result = append(result, Interval{Start: cursor, End: base.End})
+	}
+	return result
+}
+
+// WorkingIntervals returns the working-hour ranges of every day in [from, to),
+// evaluated in loc and clipped to the window. startMinute and endMinute are
+// minutes after midnight.
+func WorkingIntervals(from, to time.Time, loc *time.Location, startMinute, endMinute int, includeWeekends bool) []Interval {
+	var result []Interval
+	localFrom := from.In(loc)
+	day := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !includeWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		start := day.Add(time.Duration(startMinute) * time.Minute)
+		end := day.Add(time.Duration(endMinute) * time.Minute)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			result = append(result, Interval{Start: start.UTC(), End: end.UTC()})
+		}
+	}
+	return result
+}
+
+// ParseClock parses an "HH:MM" time of day into minutes after midnight,
+// returning fallback for an empty value.
+func ParseClock(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,261 @@
+package calendar
+
+import (
+	"dbms/recurrence"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// DefaultRecurringWindow is how far ahead recurring schedules are expanded
+// when the caller does not give an explicit window.
+const DefaultRecurringWindow = 90 * 24 * time.Hour
+
+// ActiveInWindow keeps non-deleted schedules that can produce an occurrence in
+// [from, to): recurring ones starting before the window end, and one-off ones
+// overlapping it.
+func ActiveInWindow(from, to time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("tw_schedules.is_deleted = false AND tw_schedules.deleted_at IS NULL").
+			Where("tw_schedules.start_time IS NOT NULL AND tw_schedules.start_time < ?", to).
+			Where("((tw_schedules.recurrence_pattern IS NOT NULL AND tw_schedules.recurrence_pattern != '') OR COALESCE(tw_schedules.end_time, tw_schedules.start_time) >= ?)", from)
+	}
+}
+
+// RecurrenceExceptions returns the recurrence exceptions of the recurring
+// schedules in the list, grouped by schedule ID.
+func RecurrenceExceptions(db *gorm.DB, schedules []models.TwSchedule) (map[int][]models.TwRecurrenceException, error) {
+	var scheduleIds []int
+	for _, schedule := range schedules {
+		if schedule.RecurrencePattern != "" {
+			scheduleIds = append(scheduleIds, schedule.ID)
+		}
+	}
+	result := make(map[int][]models.TwRecurrenceException)
+	if len(scheduleIds) == 0 {
+		return result, nil
+	}
+	var exceptions []models.TwRecurrenceException
+	if err := db.Where("schedule_id IN (?) AND deleted_at IS NULL", scheduleIds).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+	for _, exception := range exceptions {
+		result[exception.ScheduleId] = append(result[exception.ScheduleId], exception)
+	}
+	return result, nil
+}
+
+// Window returns the range a schedule should be checked over: its own time
+// range for one-off schedules, and DefaultRecurringWindow from its first
+// upcoming occurrence for recurring ones.
+func Window(schedule models.TwSchedule) (time.Time, time.Time) {
+	if schedule.StartTime == nil {
+		return time.Time{}, time.Time{}
+	}
+	start := schedule.StartTime.UTC()
+	if schedule.RecurrencePattern == "" {
+		end := start.Add(time.Second)
+		if schedule.EndTime != nil && schedule.EndTime.After(start) {
+			end = schedule.EndTime.UTC()
+		}
+		return start, end
+	}
+	from := start
+	if now := time.Now().UTC(); now.After(from) {
+		from = now
+	}
+	return from, from.Add(DefaultRecurringWindow)
+}
+
+// LinkedWorkspaceUsers maps each workspace user to every workspace user of the
+// same person, i.e. all workspace memberships of the emails linked to its user.
+// Each ID maps at least to itself.
+func LinkedWorkspaceUsers(db *gorm.DB, workspaceUserIds []int) (map[int][]int, error) {
+	result := make(map[int][]int, len(workspaceUserIds))
+	for _, id := range workspaceUserIds {
+		result[id] = []int{id}
+	}
+	if len(workspaceUserIds) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		SourceId int
+		LinkedId int
+	}
+	if err := db.Table("tw_workspace_users AS source").
+		Select("source.id AS source_id, linked.id AS linked_id").
+		Joins("JOIN tw_user_emails AS source_email ON source_email.id = source.user_email_id").
+		Joins("JOIN tw_user_emails AS linked_email ON linked_email.user_id = source_email.user_id AND linked_email.deleted_at IS NULL").
+		Joins("JOIN tw_workspace_users AS linked ON linked.user_email_id = linked_email.id AND linked.deleted_at IS NULL").
+		Where("source.id IN (?) AND linked.id != source.id", workspaceUserIds).
+		Where("linked.status = 'joined' AND linked.is_active = true").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.SourceId] = append(result[row.SourceId], row.LinkedId)
+	}
+	return result, nil
+}
+
+// ParticipantSchedules returns, per workspace user, the schedules they have
+// joined that may have an occurrence in [from, to).
+func ParticipantSchedules(db *gorm.DB, workspaceUserIds []int, from, to time.Time) (map[int][]models.TwSchedule, error) {
+	result := make(map[int][]models.TwSchedule)
+	if len(workspaceUserIds) == 0 {
+		return result, nil
+	}
+
+	var participants []models.TwScheduleParticipant
+	if err := db.Table("tw_schedule_participants").
+		Select("tw_schedule_participants.schedule_id, tw_schedule_participants.workspace_user_id").
+		Joins("JOIN tw_schedules ON tw_schedules.id = tw_schedule_participants.schedule_id").
+		Where("tw_schedule_participants.workspace_user_id IN (?)", workspaceUserIds).
+		Where("tw_schedule_participants.invitation_status = 'joined' AND tw_schedule_participants.deleted_at IS NULL").
+		Scopes(ActiveInWindow(from, to)).
+		Scan(&participants).Error; err != nil {
+		return nil, err
+	}
+	if len(participants) == 0 {
+		return result, nil
+	}
+
+	scheduleIds := make([]int, 0, len(participants))
+	for _, participant := range participants {
+		scheduleIds = append(scheduleIds, participant.ScheduleId)
+	}
+	var schedules []models.TwSchedule
+	if err := db.Where("id IN (?)", scheduleIds).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	byId := make(map[int]models.TwSchedule, len(schedules))
+	for _, schedule := range schedules {
+		byId[schedule.ID] = schedule
+	}
+	for _, participant := range participants {
+		if schedule, ok := byId[participant.ScheduleId]; ok {
+			result[participant.WorkspaceUserId] = append(result[participant.WorkspaceUserId], schedule)
+		}
+	}
+	return result, nil
+}
+
+// Conflict is an occurrence of another schedule that overlaps an occurrence
+// of the schedule being checked.
+type Conflict struct {
+	WorkspaceUserId      int       `json:"workspace_user_id"`
+	ScheduleId           int       `json:"schedule_id"`
+	WorkspaceId          int       `json:"workspace_id"`
+	Title                string    `json:"title"`
+	StartTime            time.Time `json:"start_time"`
+	EndTime              time.Time `json:"end_time"`
+	ConflictingStartTime time.Time `json:"conflicting_start_time"`
+	ConflictingEndTime   time.Time `json:"conflicting_end_time"`
+}
+
+// FindConflicts returns, per workspace user, the occurrences of their other
+// joined schedules (across all workspaces of the same person) that overlap an
+// occurrence of schedule within [from, to). The schedule does not need to be
+// saved yet; its own ID is excluded from the comparison when set.
+func FindConflicts(db *gorm.DB, schedule models.TwSchedule, workspaceUserIds []int, from, to time.Time) (map[int][]Conflict, error) {
+	result := make(map[int][]Conflict)
+	if schedule.StartTime == nil || len(workspaceUserIds) == 0 {
+		return result, nil
+	}
+
+	exceptions, err := RecurrenceExceptions(db, []models.TwSchedule{schedule})
+	if err != nil {
+		return nil, err
+	}
+	occurrences, err := recurrence.Expand(schedule, exceptions[schedule.ID], from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		return result, nil
+	}
+
+	linked, err := LinkedWorkspaceUsers(db, workspaceUserIds)
+	if err != nil {
+		return nil, err
+	}
+	var allIds []int
+	for _, ids := range linked {
+		allIds = append(allIds, ids...)
+	}
+	participantSchedules, err := ParticipantSchedules(db, allIds, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var others []models.TwSchedule
+	for _, schedules := range participantSchedules {
+		others = append(others, schedules...)
+	}
+	otherExceptions, err := RecurrenceExceptions(db, others)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, workspaceUserId := range workspaceUserIds {
+		seen := make(map[int]bool)
+		for _, linkedId := range linked[workspaceUserId] {
+			for _, other := range participantSchedules[linkedId] {
+				if other.ID == schedule.ID || seen[other.ID] {
+					continue
+				}
+				seen[other.ID] = true
+				otherOccurrences, err := recurrence.Expand(other, otherExceptions[other.ID], from, to)
+				if err != nil {
+					continue
+				}
+				for _, otherOccurrence := range otherOccurrences {
+					for _, occurrence := range occurrences {
+						if !recurrence.Overlaps(otherOccurrence.Start, otherOccurrence.End, occurrence.Start, occurrence.End) {
+							continue
+						}
+						result[workspaceUserId] = append(result[workspaceUserId], Conflict{
+							WorkspaceUserId:      workspaceUserId,
+							ScheduleId:           other.ID,
+							WorkspaceId:          other.WorkspaceId,
+							Title:                other.Title,
+							StartTime:            otherOccurrence.Start,
+							EndTime:              otherOccurrence.End,
+							ConflictingStartTime: occurrence.Start,
+							ConflictingEndTime:   occurrence.End,
+						})
+					}
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// JoinedParticipants returns the workspace users that have joined a schedule.
+func JoinedParticipants(db *gorm.DB, scheduleId int) ([]int, error) {
+	var workspaceUserIds []int
+	if err := db.Model(&models.TwScheduleParticipant{}).
+		Where("schedule_id = ? AND invitation_status = 'joined' AND deleted_at IS NULL", scheduleId).
+		Pluck("workspace_user_id", &workspaceUserIds).Error; err != nil {
+		return nil, err
+	}
+	return workspaceUserIds, nil
+}
+
+// FlattenConflicts lists conflicts ordered by workspace user and start time.
+func FlattenConflicts(conflicts map[int][]Conflict) []Conflict {
+	result := []Conflict{}
+	for _, items := range conflicts {
+		result = append(result, items...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].WorkspaceUserId != result[j].WorkspaceUserId {
+			return result[i].WorkspaceUserId < result[j].WorkspaceUserId
+		}
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
+}
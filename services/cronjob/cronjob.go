@@ -0,0 +1,132 @@
+// Package cronjob keeps the run history of the cron jobs and the admin
+// controls (pause, resume, trigger) that the cron workers poll.
+package cronjob
+
+import (
+	"dbms/dms_models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Job is a job with its latest run.
+type Job struct {
+	dms_models.TwCronJob
+	LastRun *dms_models.TwCronJobRun `json:"last_run"`
+}
+
+// Register records the jobs a worker schedules, keeping the admin controls
+// of the ones already known.
+func Register(db *gorm.DB, jobs []dms_models.TwCronJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"spec", "updated_at"}),
+	}).Create(&jobs).Error
+}
+
+// Controls returns every job with its admin controls.
+func Controls(db *gorm.DB) ([]dms_models.TwCronJob, error) {
+	var jobs []dms_models.TwCronJob
+	err := db.Order("name").Find(&jobs).Error
+	return jobs, err
+}
+
+// List returns every job with its latest run.
+func List(db *gorm.DB) ([]Job, error) {
+	controls, err := Controls(db)
+	if err != nil {
+		return nil, err
+	}
+	var lastRuns []dms_models.TwCronJobRun
+	if err := db.Where("id IN (?)", db.Model(&dms_models.TwCronJobRun{}).
+		Select("MAX(id)").
+		Group("job_name")).
+		Find(&lastRuns).Error; err != nil {
+		return nil, err
+	}
+	byName := make(map[string]dms_models.TwCronJobRun, len(lastRuns))
+	for _, run := range lastRuns {
+		byName[run.JobName] = run
+	}
+
+	jobs := make([]Job, 0, len(controls))
+	for _, control := range controls {
+		job := Job{TwCronJob: control}
+		if run, ok := byName[control.Name]; ok {
+			job.LastRun = &run
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Runs returns the latest runs, newest first, optionally of one job only.
+func Runs(db *gorm.DB, jobName string, limit int) ([]dms_models.TwCronJobRun, error) {
+	var runs []dms_models.TwCronJobRun
+	query := db.Order("id DESC").Limit(limit)
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+func StartRun(db *gorm.DB, run *dms_models.TwCronJobRun) error {
+	return db.Create(run).Error
+}
+
+// FinishRun stores the outcome of a run started with StartRun.
+func FinishRun(db *gorm.DB, run *dms_models.TwCronJobRun) error {
+	return db.Model(&dms_models.TwCronJobRun{}).
+		Where("id = ?", run.ID).
+		Updates(map[string]interface{}{
+			"status":          run.Status,
+			"finished_at":     run.FinishedAt,
+			"items_processed": run.ItemsProcessed,
+			"error_count":     run.ErrorCount,
+			"errors":          run.Errors,
+		}).Error
+}
+
+// PruneRuns deletes the finished runs started before the given time.
+func PruneRuns(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("started_at < ? AND status <> ?", before, dms_models.CronJobRunRunning).
+		Delete(&dms_models.TwCronJobRun{})
+	return result.RowsAffected, result.Error
+}
+
+// SetPaused pauses or resumes a job. It returns gorm.ErrRecordNotFound for
+// an unknown job.
+func SetPaused(db *gorm.DB, name string, paused bool) (dms_models.TwCronJob, error) {
+	return update(db, name, map[string]interface{}{"paused": paused})
+}
+
+// RequestTrigger asks the leading worker to run a job as soon as it polls,
+// paused or not. It returns gorm.ErrRecordNotFound for an unknown job.
+func RequestTrigger(db *gorm.DB, name string) (dms_models.TwCronJob, error) {
+	return update(db, name, map[string]interface{}{"trigger_requested_at": gorm.Expr("NOW()")})
+}
+
+// ClaimTrigger clears a requested run and reports whether this caller got
+// it, so that a request runs the job once.
+func ClaimTrigger(db *gorm.DB, name string) (bool, error) {
+	result := db.Model(&dms_models.TwCronJob{}).
+		Where("name = ? AND trigger_requested_at IS NOT NULL", name).
+		Update("trigger_requested_at", nil)
+	return result.RowsAffected == 1, result.Error
+}
+
+func update(db *gorm.DB, name string, fields map[string]interface{}) (dms_models.TwCronJob, error) {
+	var job dms_models.TwCronJob
+	if err := db.Where("name = ?", name).First(&job).Error; err != nil {
+		return job, err
+	}
+	if err := db.Model(&job).Updates(fields).Error; err != nil {
+		return job, err
+	}
+	err := db.Where("name = ?", name).First(&job).Error
+	return job, err
+}
@@ -0,0 +1,89 @@
+// Package leader elects a single cron worker through a lease row, so that
+// the jobs run once however many replicas are deployed. Lease times are
+// taken from the database clock, so the workers' clocks do not matter.
+package leader
+
+import (
+	"dbms/dms_models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// CronLease is the lease of the cron jobs.
+const CronLease = "cron"
+
+// Status is a lease together with whether it is still held.
+type Status struct {
+	dms_models.TwCronLease
+	Active bool `json:"active"`
+}
+
+// Acquire takes the lease for holder, or renews it when holder already has
+// it, for ttl. It reports whether holder is the leader afterwards.
+func Acquire(db *gorm.DB, name, holder string, ttl time.Duration) (Status, bool, error) {
+	var status Status
+	if holder == "" {
+		return status, false, errors.New("holder is required")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		expired := time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC)
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&dms_models.TwCronLease{
+			Name:       name,
+			AcquiredAt: expired,
+			RenewedAt:  expired,
+			ExpiresAt:  expired,
+		}).Error; err != nil {
+			return err
+		}
+		// MySQL assigns left to right, so acquired_at must be computed before
+		// holder changes.
+		if err := tx.Exec(`UPDATE tw_cron_leases
+			SET acquired_at = IF(holder = ?, acquired_at, NOW(3)),
+				holder = ?,
+				renewed_at = NOW(3),
+				expires_at = NOW(3) + INTERVAL ? MICROSECOND
+			WHERE name = ? AND (holder = ? OR expires_at <= NOW(3))`,
+			holder, holder, ttl.Microseconds(), name, holder).Error; err != nil {
+			return err
+		}
+		var err error
+		status, err = current(tx, name)
+		return err
+	})
+	if err != nil {
+		return status, false, err
+	}
+	return status, status.Active && status.Holder == holder, nil
+}
+
+// Release gives up the lease if holder has it, so that another worker can
+// take over without waiting for it to expire.
+func Release(db *gorm.DB, name, holder string) error {
+	return db.Model(&dms_models.TwCronLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Update("expires_at", gorm.Expr("NOW(3)")).Error
+}
+
+// Current returns the lease. It returns gorm.ErrRecordNotFound when no
+// worker ever took it.
+func Current(db *gorm.DB, name string) (Status, error) {
+	return current(db, name)
+}
+
+func current(db *gorm.DB, name string) (Status, error) {
+	var status Status
+	result := db.Model(&dms_models.TwCronLease{}).
+		Select("*, expires_at > NOW(3) AS active").
+		Where("name = ?", name).
+		Limit(1).
+		Scan(&status)
+	if result.Error != nil {
+		return status, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return status, gorm.ErrRecordNotFound
+	}
+	return status, nil
+}
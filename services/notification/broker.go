@@ -0,0 +1,98 @@
+package notification
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"sync"
+)
+
+// subscriberBuffer is how many notifications a slow subscriber may lag
+// behind before it is dropped; it then resumes from the database with its
+// last event id.
+const subscriberBuffer = 64
+
+// Broker fans new notifications out to the subscribers of their recipient.
+// MemoryBroker serves a single instance; a shared implementation can be
+// plugged in with SetBroker to serve several.
+type Broker interface {
+	Publish(notification models.TwNotifications)
+	// Subscribe returns the notifications of the given user emails published
+	// from now on. The channel is closed when the subscription is cancelled
+	// or the subscriber falls too far behind.
+	Subscribe(userEmailIds []int) (<-chan models.TwNotifications, func())
+}
+
+var (
+	brokerMu      sync.RWMutex
+	defaultBroker Broker = NewMemoryBroker()
+)
+
+// DefaultBroker returns the broker new notifications are published to.
+func DefaultBroker() Broker {
+	brokerMu.RLock()
+	defer brokerMu.RUnlock()
+	return defaultBroker
+}
+
+func SetBroker(broker Broker) {
+	brokerMu.Lock()
+	defer brokerMu.Unlock()
+	defaultBroker = broker
+}
+
+type subscriber struct {
+	userEmailIds map[int]bool
+	ch           chan models.TwNotifications
+}
+
+// MemoryBroker is an in-process Broker.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[*subscriber]bool)}
+}
+
+func (b *MemoryBroker) Publish(notification models.TwNotifications) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if !sub.userEmailIds[notification.UserEmailId] {
+			continue
+		}
+		select {
+		case sub.ch <- notification:
+		default:
+			// Too slow, let it reconnect and catch up from the database
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+func (b *MemoryBroker) Subscribe(userEmailIds []int) (<-chan models.TwNotifications, func()) {
+	sub := &subscriber{
+		userEmailIds: make(map[int]bool, len(userEmailIds)),
+		ch:           make(chan models.TwNotifications, subscriberBuffer),
+	}
+	for _, id := range userEmailIds {
+		sub.userEmailIds[id] = true
+	}
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.subscribers[sub] {
+				delete(b.subscribers, sub)
+				close(sub.ch)
+			}
+		})
+	}
+	return sub.ch, cancel
+}
@@ -0,0 +1,79 @@
+package notification
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"testing"
+)
+
+// drain returns the ids of the notifications on a channel until it is
+// closed, or until it is empty when open is true.
+func drain(ch <-chan models.TwNotifications) (ids []int, open bool) {
+	for {
+		select {
+		case n, ok := <-ch:
+			if !ok {
+				return ids, false
+			}
+			ids = append(ids, n.ID)
+		default:
+			return ids, true
+		}
+	}
+}
+
+func TestMemoryBrokerFansOutByRecipient(t *testing.T) {
+	broker := NewMemoryBroker()
+	first, cancelFirst := broker.Subscribe([]int{1})
+	defer cancelFirst()
+	both, cancelBoth := broker.Subscribe([]int{1, 2})
+	defer cancelBoth()
+
+	broker.Publish(models.TwNotifications{ID: 10, UserEmailId: 1})
+	broker.Publish(models.TwNotifications{ID: 11, UserEmailId: 2})
+	broker.Publish(models.TwNotifications{ID: 12, UserEmailId: 3})
+
+	if ids, open := drain(first); len(ids) != 1 || ids[0] != 10 || !open {
+		t.Errorf("first subscriber got %v (open %v), want 10", ids, open)
+	}
+	if ids, open := drain(both); len(ids) != 2 || ids[0] != 10 || ids[1] != 11 || !open {
+		t.Errorf("second subscriber got %v (open %v), want 10 and 11", ids, open)
+	}
+}
+
+func TestMemoryBrokerDropsSlowSubscribers(t *testing.T) {
+	broker := NewMemoryBroker()
+	slow, cancelSlow := broker.Subscribe([]int{1})
+	defer cancelSlow()
+	other, cancelOther := broker.Subscribe([]int{2})
+	defer cancelOther()
+
+	for id := 1; id <= subscriberBuffer+1; id++ {
+		broker.Publish(models.TwNotifications{ID: id, UserEmailId: 1})
+	}
+	broker.Publish(models.TwNotifications{ID: 100, UserEmailId: 2})
+
+	// The slow subscriber keeps what fitted in its buffer and is dropped;
+	// it resumes from id subscriberBuffer.
+	ids, open := drain(slow)
+	if open || len(ids) != subscriberBuffer || ids[len(ids)-1] != subscriberBuffer {
+		t.Errorf("slow subscriber got %d notifications (open %v), want the first %d and the channel closed", len(ids), open, subscriberBuffer)
+	}
+	if ids, open := drain(other); len(ids) != 1 || !open {
+		t.Errorf("other subscriber got %v (open %v), want it unaffected", ids, open)
+	}
+
+	// Cancelling after the drop does not close the channel twice.
+	cancelSlow()
+	broker.Publish(models.TwNotifications{ID: 200, UserEmailId: 1})
+}
+
+func TestMemoryBrokerCancel(t *testing.T) {
+	broker := NewMemoryBroker()
+	events, cancel := broker.Subscribe([]int{1})
+	cancel()
+	cancel()
+	broker.Publish(models.TwNotifications{ID: 1, UserEmailId: 1})
+	if ids, open := drain(events); len(ids) != 0 || open {
+		t.Errorf("cancelled subscriber got %v (open %v), want the channel closed", ids, open)
+	}
+}
@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/dms_models"
+	"errors"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// DeadLetter is a dead-lettered notification with its delivery record.
+type DeadLetter struct {
+	Notification models.TwNotifications            `json:"notification"`
+	Delivery     dms_models.TwNotificationDelivery `json:"delivery"`
+}
+
+// Due returns up to limit unsent notifications whose time has come and that
+// are neither waiting for a retry nor dead-lettered, with their recipient
+// email. Notifications of users who turned digests on are left to
+// ClaimDigests.
+func Due(db *gorm.DB, now time.Time, limit int) ([]models.TwNotifications, error) {
+	var notifications []models.TwNotifications
+	err := db.
+		Joins("LEFT JOIN tw_notification_deliveries AS d ON d.notification_id = tw_notifications.id").
+		Where("tw_notifications.is_sent = ? AND tw_notifications.deleted_at IS NULL", false).
+		Where("tw_notifications.notified_at IS NOT NULL AND tw_notifications.notified_at <= ?", now).
+		Where("d.id IS NULL OR (d.dead_lettered_at IS NULL AND (d.next_attempt_at IS NULL OR d.next_attempt_at <= ?))", now).
+		Where("NOT EXISTS (SELECT 1 FROM tw_user_emails AS ue JOIN tw_notification_digest_settings AS ds ON ds.user_id = ue.user_id WHERE ue.id = tw_notifications.user_email_id AND ds.cadence <> ?)", dms_models.DigestCadenceOff).
+		Order("tw_notifications.notified_at, tw_notifications.id").
+		Limit(limit).
+		Preload("UserEmail").
+		Find(&notifications).Error
+	return notifications, err
+}
+
+// RetryDelay returns how long to wait after the given number of failed
+// attempts: RetryBase doubled for every earlier failure, at most RetryMax.
+func RetryDelay(policy config.NotificationConfig, attempts int) time.Duration {
+	delay := policy.RetryBase
+	for i := 1; i < attempts && delay < policy.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > policy.RetryMax {
+		delay = policy.RetryMax
+	}
+	return delay
+}
+
+// RecordFailure counts a failed delivery of a notification and schedules
+// the next attempt, or dead-letters the notification once it has failed
+// policy.MaxAttempts times.
+func RecordFailure(db *gorm.DB, notificationId int, reason string, policy config.NotificationConfig) (dms_models.TwNotificationDelivery, error) {
+	var delivery dms_models.TwNotificationDelivery
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.TwNotifications{}, notificationId).Error; err != nil {
+			return err
+		}
+		var err error
+		delivery, err = countFailure(tx, notificationId, reason, policy, time.Now())
+		return err
+	})
+	return delivery, err
+}
+
+// countFailure records a failed delivery attempt of a notification inside
+// tx, dead-lettering it once it has failed policy.MaxAttempts times.
+func countFailure(tx *gorm.DB, notificationId int, reason string, policy config.NotificationConfig, now time.Time) (dms_models.TwNotificationDelivery, error) {
+	var delivery dms_models.TwNotificationDelivery
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("notification_id = ?", notificationId).
+		First(&delivery).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return delivery, err
+	}
+	delivery.NotificationId = notificationId
+	delivery.Attempts++
+	delivery.LastError = reason
+	delivery.LastAttemptAt = &now
+	if delivery.Attempts >= policy.MaxAttempts {
+		delivery.NextAttemptAt = nil
+		delivery.DeadLetteredAt = &now
+	} else {
+		next := now.Add(RetryDelay(policy, delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	return delivery, tx.Save(&delivery).Error
+}
+
+// DeadLetters returns up to limit dead-lettered notifications, most recent
+// first.
+func DeadLetters(db *gorm.DB, limit int) ([]DeadLetter, error) {
+	var deliveries []dms_models.TwNotificationDelivery
+	if err := db.Where("dead_lettered_at IS NOT NULL").
+		Order("dead_lettered_at DESC, id DESC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return []DeadLetter{}, nil
+	}
+
+	ids := make([]int, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.NotificationId)
+	}
+	var notifications []models.TwNotifications
+	if err := db.Where("id IN ?", ids).Preload("UserEmail").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	byId := make(map[int]models.TwNotifications, len(notifications))
+	for _, n := range notifications {
+		byId[n.ID] = n
+	}
+
+	deadLetters := make([]DeadLetter, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		deadLetters = append(deadLetters, DeadLetter{Notification: byId[delivery.NotificationId], Delivery: delivery})
+	}
+	return deadLetters, nil
+}
+
+// Requeue gives dead-lettered notifications a fresh set of attempts, all of
+// them when notificationIds is empty. It returns how many were requeued.
+func Requeue(db *gorm.DB, notificationIds []int) (int64, error) {
+	query := db.Model(&dms_models.TwNotificationDelivery{}).Where("dead_lettered_at IS NOT NULL")
+	if len(notificationIds) > 0 {
+		query = query.Where("notification_id IN ?", notificationIds)
+	}
+	result := query.Updates(map[string]interface{}{
+		"attempts":         0,
+		"next_attempt_at":  nil,
+		"dead_lettered_at": nil,
+	})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,189 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"errors"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"testing"
+	"time"
+)
+
+// openDeliveryDB returns a database holding due notifications 1, 2 and 3 of
+// user email 1.
+func openDeliveryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwUserEmail{},
+		&models.TwNotifications{},
+		&dms_models.TwNotificationDelivery{},
+		&dms_models.TwNotificationDigestSetting{},
+	)
+	notified := time.Now().Add(-time.Minute)
+	rows := []interface{}{
+		&models.TwUserEmail{ID: 1, UserId: 1, Email: "user@example.com"},
+		&models.TwNotifications{ID: 1, UserEmailId: 1, Type: "schedule", NotifiedAt: &notified},
+		&models.TwNotifications{ID: 2, UserEmailId: 1, Type: "schedule", NotifiedAt: &notified},
+		&models.TwNotifications{ID: 3, UserEmailId: 1, Type: "schedule", NotifiedAt: &notified},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	return db
+}
+
+// dueIds returns the ids of the notifications Due hands out now.
+func dueIds(t *testing.T, db *gorm.DB) []int {
+	t.Helper()
+	notifications, err := Due(db, time.Now(), 10)
+	if err != nil {
+		t.Fatalf("Due: %v", err)
+	}
+	var ids []int
+	for _, n := range notifications {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := config.NotificationConfig{MaxAttempts: 10, RetryBase: time.Minute, RetryMax: time.Hour}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 6, want: 32 * time.Minute},
+		{attempts: 7, want: time.Hour},
+		{attempts: 50, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RetryDelay(policy, tt.attempts); got != tt.want {
+			t.Errorf("RetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRecordFailureDeadLettersAfterMaxAttempts(t *testing.T) {
+	db := openDeliveryDB(t)
+	policy := config.NotificationConfig{MaxAttempts: 3, RetryBase: time.Minute, RetryMax: time.Hour}
+
+	wantDelays := []time.Duration{time.Minute, 2 * time.Minute}
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		before := time.Now()
+		delivery, err := RecordFailure(db, 1, "smtp down", policy)
+		if err != nil {
+			t.Fatalf("failure %d: %v", attempt, err)
+		}
+		if delivery.Attempts != attempt || delivery.LastError != "smtp down" || delivery.LastAttemptAt == nil {
+			t.Errorf("failure %d: delivery = %+v", attempt, delivery)
+		}
+		if attempt < policy.MaxAttempts {
+			if delivery.DeadLetteredAt != nil || delivery.NextAttemptAt == nil {
+				t.Fatalf("failure %d: delivery = %+v, want a retry scheduled", attempt, delivery)
+			}
+			if wait := delivery.NextAttemptAt.Sub(before); wait < wantDelays[attempt-1] || wait > wantDelays[attempt-1]+time.Second {
+				t.Errorf("failure %d: retry in %s, want %s", attempt, wait, wantDelays[attempt-1])
+			}
+		} else if delivery.DeadLetteredAt == nil || delivery.NextAttemptAt != nil {
+			t.Errorf("failure %d: delivery = %+v, want it dead-lettered", attempt, delivery)
+		}
+		// Waiting for a retry or dead-lettered, it is no longer due.
+		if ids := dueIds(t, db); len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+			t.Errorf("failure %d: due notifications %v, want 2 and 3", attempt, ids)
+		}
+	}
+	if count := dbtest.Count(t, db, "tw_notification_deliveries"); count != 1 {
+		t.Errorf("%d delivery records, want 1", count)
+	}
+
+	deadLetters, err := DeadLetters(db, 10)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Notification.ID != 1 || deadLetters[0].Notification.UserEmail.Email != "user@example.com" {
+		t.Errorf("dead letters = %+v, want notification 1 with its recipient", deadLetters)
+	}
+}
+
+func TestRecordFailureOfUnknownNotification(t *testing.T) {
+	db := openDeliveryDB(t)
+	policy := config.NotificationConfig{MaxAttempts: 3, RetryBase: time.Minute, RetryMax: time.Hour}
+
+	if _, err := RecordFailure(db, 42, "smtp down", policy); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("RecordFailure = %v, want ErrRecordNotFound", err)
+	}
+	if count := dbtest.Count(t, db, "tw_notification_deliveries"); count != 0 {
+		t.Errorf("%d delivery records, want none", count)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	tests := []struct {
+		name            string
+		notificationIds []int
+		wantRequeued    int64
+		wantDue         []int
+	}{
+		{name: "some", notificationIds: []int{1, 3}, wantRequeued: 1, wantDue: []int{1}},
+		{name: "all", wantRequeued: 2, wantDue: []int{1, 2}},
+		{name: "not dead-lettered", notificationIds: []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDeliveryDB(t)
+			// 1 and 2 are dead-lettered, 3 waits for a retry.
+			policy := config.NotificationConfig{MaxAttempts: 1, RetryBase: time.Minute, RetryMax: time.Hour}
+			for _, id := range []int{1, 2} {
+				if _, err := RecordFailure(db, id, "smtp down", policy); err != nil {
+					t.Fatalf("fail %d: %v", id, err)
+				}
+			}
+			policy.MaxAttempts = 3
+			if _, err := RecordFailure(db, 3, "smtp down", policy); err != nil {
+				t.Fatalf("fail 3: %v", err)
+			}
+
+			requeued, err := Requeue(db, tt.notificationIds)
+			if err != nil {
+				t.Fatalf("Requeue: %v", err)
+			}
+			if requeued != tt.wantRequeued {
+				t.Errorf("requeued %d, want %d", requeued, tt.wantRequeued)
+			}
+			ids := dueIds(t, db)
+			if len(ids) != len(tt.wantDue) {
+				t.Fatalf("due notifications %v, want %v", ids, tt.wantDue)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantDue[i] {
+					t.Fatalf("due notifications %v, want %v", ids, tt.wantDue)
+				}
+			}
+			// A requeued notification gets a fresh set of attempts.
+			for _, id := range tt.wantDue {
+				delivery, err := RecordFailure(db, id, "smtp down", policy)
+				if err != nil {
+					t.Fatalf("fail %d again: %v", id, err)
+				}
+				if delivery.Attempts != 1 || delivery.DeadLetteredAt != nil {
+					t.Errorf("requeued delivery = %+v, want a first attempt", delivery)
+				}
+			}
+			if count := dbtest.Count(t, db, "tw_notification_deliveries", "notification_id = ? AND attempts = ? AND next_attempt_at IS NOT NULL", 3, 1); count != 1 {
+				t.Errorf("the retry of notification 3 was changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/dms_models"
+	"dbms/mail"
+	"dbms/services/calendar"
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"time"
+)
+
+// maxDigestNotifications bounds the notifications carried by the digests of
+// one claim; the rest go into the next digest.
+const maxDigestNotifications = 1000
+
+// DigestSetting returns the digest preference of a user, off when the user
+// has none.
+func DigestSetting(db *gorm.DB, userId int) (dms_models.TwNotificationDigestSetting, error) {
+	var setting dms_models.TwNotificationDigestSetting
+	err := db.Where("user_id = ?", userId).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dms_models.TwNotificationDigestSetting{UserId: userId, Cadence: dms_models.DigestCadenceOff}, nil
+	}
+	return setting, err
+}
+
+// SetDigestSetting validates and stores the digest preference of a user and
+// schedules the next digest after now. Daily digests default to 08:00 and
+// the timezone to UTC.
+func SetDigestSetting(db *gorm.DB, setting dms_models.TwNotificationDigestSetting, now time.Time) (dms_models.TwNotificationDigestSetting, error) {
+	switch setting.Cadence {
+	case "":
+		setting.Cadence = dms_models.DigestCadenceOff
+	case dms_models.DigestCadenceOff, dms_models.DigestCadenceHourly, dms_models.DigestCadenceDaily:
+	default:
+		return setting, &InvalidDigestSetting{Reason: "invalid cadence, expected off, hourly or daily"}
+	}
+	if setting.Cadence == dms_models.DigestCadenceDaily && setting.DailyAt == "" {
+		setting.DailyAt = "08:00"
+	}
+	if setting.DailyAt != "" {
+		if minute, err := calendar.ParseClock(setting.DailyAt, -1); err != nil || minute < 0 {
+			return setting, &InvalidDigestSetting{Reason: "invalid daily_at, expected HH:MM"}
+		}
+	}
+	if setting.Timezone == "" {
+		setting.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(setting.Timezone); err != nil {
+		return setting, &InvalidDigestSetting{Reason: "invalid timezone"}
+	}
+
+	var existing dms_models.TwNotificationDigestSetting
+	err := db.Where("user_id = ?", setting.UserId).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return setting, err
+	}
+	existing.UserId = setting.UserId
+	existing.Cadence = setting.Cadence
+	existing.DailyAt = setting.DailyAt
+	existing.Timezone = setting.Timezone
+	existing.NextAt = nil
+	if existing.Cadence != dms_models.DigestCadenceOff {
+		next, err := NextDigestAt(existing, now)
+		if err != nil {
+			return setting, err
+		}
+		existing.NextAt = &next
+	}
+	err = db.Save(&existing).Error
+	return existing, err
+}
+
+// NextDigestAt returns when the digest following the given time is due: the
+// next full hour for hourly digests, the next DailyAt for daily ones, both
+// in the setting's timezone.
+func NextDigestAt(setting dms_models.TwNotificationDigestSetting, after time.Time) (time.Time, error) {
+	loc := time.UTC
+	if setting.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(setting.Timezone); err != nil {
+			return after, err
+		}
+	}
+	local := after.In(loc)
+	switch setting.Cadence {
+	case dms_models.DigestCadenceHourly:
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, loc), nil
+	case dms_models.DigestCadenceDaily:
+		minute, err := calendar.ParseClock(setting.DailyAt, 8*60)
+		if err != nil {
+			return after, err
+		}
+		next := time.Date(local.Year(), local.Month(), local.Day(), minute/60, minute%60, 0, 0, loc)
+		if !next.After(after) {
+			next = time.Date(local.Year(), local.Month(), local.Day()+1, minute/60, minute%60, 0, 0, loc)
+		}
+		return next, nil
+	default:
+		return after, fmt.Errorf("no digest for cadence %q", setting.Cadence)
+	}
+}
+
+// DueDigestUsers returns up to limit users whose digest is due.
+func DueDigestUsers(db *gorm.DB, now time.Time, limit int) ([]int, error) {
+	var userIds []int
+	err := db.Model(&dms_models.TwNotificationDigestSetting{}).
+		Where("cadence <> ? AND next_at IS NOT NULL AND next_at <= ?", dms_models.DigestCadenceOff, now).
+		Order("next_at").
+		Limit(limit).
+		Pluck("user_id", &userIds).Error
+	return userIds, err
+}
+
+// ClaimDigests collects the unsent notifications of a user whose digest is
+// due into one pending digest per user email, marks them sent and schedules
+// the next digest. The caller emails the digests, then calls MarkDigestSent
+// or ReleaseDigest. Nothing is claimed when the digest is not due, e.g.
+// because another worker claimed it, and the digest is moved past quiet
+// hours.
+func ClaimDigests(db *gorm.DB, userId int, now time.Time) ([]dms_models.TwNotificationDigest, error) {
+	var digests []dms_models.TwNotificationDigest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var setting dms_models.TwNotificationDigestSetting
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND cadence <> ? AND next_at IS NOT NULL AND next_at <= ?", userId, dms_models.DigestCadenceOff, now).
+			First(&setting).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		quiet, err := QuietHours(tx, userId)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			end, inQuietHours, err := QuietHoursEnd(quiet, now)
+			if err != nil {
+				return err
+			}
+			if inQuietHours {
+				return tx.Model(&setting).Update("next_at", end).Error
+			}
+		}
+
+		var notifications []models.TwNotifications
+		err = tx.
+			Joins("JOIN tw_user_emails AS ue ON ue.id = tw_notifications.user_email_id").
+			Joins("LEFT JOIN tw_notification_deliveries AS d ON d.notification_id = tw_notifications.id").
+			Where("ue.user_id = ?", userId).
+			Where("tw_notifications.is_sent = ? AND tw_notifications.deleted_at IS NULL", false).
+			Where("tw_notifications.notified_at IS NOT NULL AND tw_notifications.notified_at <= ?", now).
+			Where("d.dead_lettered_at IS NULL").
+			Order("tw_notifications.notified_at, tw_notifications.id").
+			Limit(maxDigestNotifications).
+			Preload("UserEmail").
+			Find(&notifications).Error
+		if err != nil {
+			return err
+		}
+
+		// Apply the settings as they are now: notifications the user opted
+		// out of, or only wants in-app, are not emailed.
+		plans := make(map[string]Plan)
+		var inAppIds []int
+		byEmail := make(map[int][]models.TwNotifications)
+		var userEmailIds []int
+		for _, n := range notifications {
+			plan, ok := plans[n.Type]
+			if !ok {
+				if plan, err = PlanForUser(tx, userId, n.Type, now); err != nil {
+					return err
+				}
+				plans[n.Type] = plan
+			}
+			if !plan.Deliver || !plan.Email {
+				inAppIds = append(inAppIds, n.ID)
+				continue
+			}
+			if _, ok := byEmail[n.UserEmailId]; !ok {
+				userEmailIds = append(userEmailIds, n.UserEmailId)
+			}
+			byEmail[n.UserEmailId] = append(byEmail[n.UserEmailId], n)
+		}
+		if len(inAppIds) > 0 {
+			if err := tx.Model(&models.TwNotifications{}).Where("id IN ?", inAppIds).Update("is_sent", true).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, userEmailId := range userEmailIds {
+			notifications := byEmail[userEmailId]
+			digest, err := createDigest(tx, setting.Cadence, plans[notifications[0].Type].Locale, notifications)
+			if err != nil {
+				return err
+			}
+			digests = append(digests, digest)
+		}
+
+		next, err := NextDigestAt(setting, now)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"next_at": next}
+		if len(digests) > 0 {
+			updates["last_sent_at"] = now
+		}
+		return tx.Model(&setting).Updates(updates).Error
+	})
+	return digests, err
+}
+
+// createDigest stores a pending digest carrying the given notifications of
+// one user email and marks them sent.
+func createDigest(tx *gorm.DB, cadence, locale string, notifications []models.TwNotifications) (dms_models.TwNotificationDigest, error) {
+	workspaces, err := groupDigest(tx, notifications)
+	if err != nil {
+		return dms_models.TwNotificationDigest{}, err
+	}
+	email, err := mail.Render(mail.TemplateDigest, locale, mail.DigestData{
+		Recipient:  notifications[0].UserEmail.Email,
+		Cadence:    cadence,
+		Count:      len(notifications),
+		Workspaces: workspaces,
+	})
+	if err != nil {
+		return dms_models.TwNotificationDigest{}, err
+	}
+
+	digest := dms_models.TwNotificationDigest{
+		UserEmailId:       notifications[0].UserEmailId,
+		Email:             notifications[0].UserEmail.Email,
+		Cadence:           cadence,
+		Status:            dms_models.DigestPending,
+		NotificationCount: len(notifications),
+		Subject:           email.Subject,
+		HTML:              email.HTML,
+		Text:              email.Text,
+	}
+	if err := tx.Create(&digest).Error; err != nil {
+		return digest, err
+	}
+	ids := make([]int, 0, len(notifications))
+	items := make([]dms_models.TwNotificationDigestItem, 0, len(notifications))
+	for _, n := range notifications {
+		ids = append(ids, n.ID)
+		items = append(items, dms_models.TwNotificationDigestItem{DigestId: digest.ID, NotificationId: n.ID})
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return digest, err
+	}
+	err = tx.Model(&models.TwNotifications{}).Where("id IN ?", ids).Update("is_sent", true).Error
+	return digest, err
+}
+
+type digestItemKey struct {
+	Type string
+	Id   int
+}
+
+// groupDigest groups notifications by workspace and related item, keeping
+// the order in which they were created. Notifications without a workspace
+// come last.
+func groupDigest(db *gorm.DB, notifications []models.TwNotifications) ([]mail.DigestWorkspace, error) {
+	var scheduleIds, columnIds []int
+	for _, n := range notifications {
+		switch n.RelatedItemType {
+		case "schedule":
+			scheduleIds = append(scheduleIds, n.RelatedItemId)
+		case "board_column":
+			columnIds = append(columnIds, n.RelatedItemId)
+		}
+	}
+	var schedules []models.TwSchedule
+	if len(scheduleIds) > 0 {
+		if err := db.Select("id", "workspace_id", "title").Where("id IN ?", scheduleIds).Find(&schedules).Error; err != nil {
+			return nil, err
+		}
+	}
+	var columns []models.TwBoardColumn
+	if len(columnIds) > 0 {
+		if err := db.Select("id", "workspace_id", "name").Where("id IN ?", columnIds).Find(&columns).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	workspaceOf := make(map[digestItemKey]int)
+	titleOf := make(map[digestItemKey]string)
+	workspaceIds := make(map[int]bool)
+	for _, s := range schedules {
+		key := digestItemKey{"schedule", s.ID}
+		workspaceOf[key], titleOf[key] = s.WorkspaceId, s.Title
+		workspaceIds[s.WorkspaceId] = true
+	}
+	for _, c := range columns {
+		key := digestItemKey{"board_column", c.ID}
+		workspaceOf[key], titleOf[key] = c.WorkspaceId, c.Name
+		workspaceIds[c.WorkspaceId] = true
+	}
+	for _, n := range notifications {
+		if n.RelatedItemType == "workspace" {
+			workspaceOf[digestItemKey{"workspace", n.RelatedItemId}] = n.RelatedItemId
+			workspaceIds[n.RelatedItemId] = true
+		}
+	}
+	workspaceTitles := make(map[int]string)
+	if len(workspaceIds) > 0 {
+		ids := make([]int, 0, len(workspaceIds))
+		for id := range workspaceIds {
+			ids = append(ids, id)
+		}
+		var workspaces []models.TwWorkspace
+		if err := db.Select("id", "title").Where("id IN ?", ids).Find(&workspaces).Error; err != nil {
+			return nil, err
+		}
+		for _, w := range workspaces {
+			workspaceTitles[w.ID] = w.Title
+		}
+	}
+
+	var order []int
+	groups := make(map[int]*mail.DigestWorkspace)
+	itemIndex := make(map[int]map[digestItemKey]int)
+	for _, n := range notifications {
+		key := digestItemKey{n.RelatedItemType, n.RelatedItemId}
+		workspaceId := workspaceOf[key]
+		group, ok := groups[workspaceId]
+		if !ok {
+			group = &mail.DigestWorkspace{Title: workspaceTitles[workspaceId]}
+			groups[workspaceId] = group
+			itemIndex[workspaceId] = make(map[digestItemKey]int)
+			order = append(order, workspaceId)
+		}
+		index, ok := itemIndex[workspaceId][key]
+		if !ok {
+			// Notifications about the workspace itself, or without a
+			// related item, go under the untitled item
+			title := titleOf[key]
+			if title == "" && n.RelatedItemType != "" && n.RelatedItemType != "workspace" {
+				title = fmt.Sprintf("%s #%d", n.RelatedItemType, n.RelatedItemId)
+			}
+			index = len(group.Items)
+			itemIndex[workspaceId][key] = index
+			group.Items = append(group.Items, mail.DigestItem{Title: title})
+		}
+		title := n.Title
+		if title == "" {
+			title = n.Type
+		}
+		group.Items[index].Notifications = append(group.Items[index].Notifications, mail.DigestNotification{
+			Title:       title,
+			Description: n.Description,
+			Link:        n.Link,
+			CreatedAt:   n.CreatedAt,
+		})
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i] != 0 && order[j] == 0
+	})
+	workspaces := make([]mail.DigestWorkspace, 0, len(order))
+	for _, id := range order {
+		workspaces = append(workspaces, *groups[id])
+	}
+	return workspaces, nil
+}
+
+// MarkDigestSent records that a pending digest was emailed. It returns
+// gorm.ErrRecordNotFound when there is no such pending digest.
+func MarkDigestSent(db *gorm.DB, digestId int) error {
+	result := db.Model(&dms_models.TwNotificationDigest{}).
+		Where("id = ? AND status = ?", digestId, dms_models.DigestPending).
+		Updates(map[string]interface{}{"status": dms_models.DigestSent, "sent_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReleaseDigest records that a pending digest could not be emailed: every
+// notification it carried counts a failed attempt and becomes unsent again,
+// or is dead-lettered once it has failed policy.MaxAttempts times, and the
+// user's next digest is retried after the backoff of the most-tried
+// notification. It returns gorm.ErrRecordNotFound when there is no such
+// pending digest.
+func ReleaseDigest(db *gorm.DB, digestId int, reason string, policy config.NotificationConfig) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var digest dms_models.TwNotificationDigest
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", digestId, dms_models.DigestPending).
+			First(&digest).Error
+		if err != nil {
+			return err
+		}
+		var ids []int
+		if err := tx.Model(&dms_models.TwNotificationDigestItem{}).Where("digest_id = ?", digestId).Pluck("notification_id", &ids).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		attempts := 0
+		for _, id := range ids {
+			delivery, err := countFailure(tx, id, reason, policy, now)
+			if err != nil {
+				return err
+			}
+			if delivery.DeadLetteredAt == nil && delivery.Attempts > attempts {
+				attempts = delivery.Attempts
+			}
+		}
+		if len(ids) > 0 {
+			if err := tx.Model(&models.TwNotifications{}).Where("id IN ?", ids).Update("is_sent", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("digest_id = ?", digestId).Delete(&dms_models.TwNotificationDigestItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&digest).Updates(map[string]interface{}{"status": dms_models.DigestFailed, "error": reason}).Error; err != nil {
+			return err
+		}
+
+		var userEmail models.TwUserEmail
+		if err := tx.Select("id", "user_id").First(&userEmail, digest.UserEmailId).Error; err != nil {
+			return err
+		}
+		return tx.Model(&dms_models.TwNotificationDigestSetting{}).
+			Where("user_id = ? AND cadence <> ?", userEmail.UserId, dms_models.DigestCadenceOff).
+			Update("next_at", now.Add(RetryDelay(policy, attempts))).Error
+	})
+}
+
+// GetDigest returns a digest. It returns gorm.ErrRecordNotFound when the
+// digest does not exist.
+func GetDigest(db *gorm.DB, digestId int) (dms_models.TwNotificationDigest, error) {
+	var digest dms_models.TwNotificationDigest
+	err := db.First(&digest, digestId).Error
+	return digest, err
+}
+
+// DigestOf returns the digest that carried a notification. It returns
+// gorm.ErrRecordNotFound when the notification was not sent in a digest.
+func DigestOf(db *gorm.DB, notificationId int) (dms_models.TwNotificationDigest, error) {
+	var item dms_models.TwNotificationDigestItem
+	if err := db.Where("notification_id = ?", notificationId).First(&item).Error; err != nil {
+		return dms_models.TwNotificationDigest{}, err
+	}
+	return GetDigest(db, item.DigestId)
+}
+
+type InvalidDigestSetting struct {
+	Reason string
+}
+
+func (e *InvalidDigestSetting) Error() string {
+	return e.Reason
+}
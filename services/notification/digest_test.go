@@ -0,0 +1,124 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"testing"
+	"time"
+)
+
+// openDigestDB returns a database holding notifications 1 and 2 of user
+// email 1, whose user gets hourly digests. Notification 2 has already
+// failed priorAttempts times.
+func openDigestDB(t *testing.T, priorAttempts int) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwUserEmail{},
+		&models.TwNotifications{},
+		&dms_models.TwNotificationDelivery{},
+		&dms_models.TwNotificationDigest{},
+		&dms_models.TwNotificationDigestItem{},
+		&dms_models.TwNotificationDigestSetting{},
+	)
+	now := time.Now()
+	rows := []interface{}{
+		&models.TwUserEmail{ID: 1, UserId: 1, Email: "user@example.com"},
+		&models.TwNotifications{ID: 1, UserEmailId: 1, Type: "schedule", NotifiedAt: &now},
+		&models.TwNotifications{ID: 2, UserEmailId: 1, Type: "schedule", NotifiedAt: &now},
+		&dms_models.TwNotificationDigestSetting{UserId: 1, Cadence: dms_models.DigestCadenceHourly, Timezone: "UTC"},
+		&dms_models.TwNotificationDelivery{NotificationId: 2, Attempts: priorAttempts},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	return db
+}
+
+// seedPendingDigest stores a pending digest of user email 1 carrying the
+// given notifications the way ClaimDigests does, and schedules the user's
+// next digest.
+func seedPendingDigest(t *testing.T, db *gorm.DB, digestId int, notificationIds ...int) {
+	t.Helper()
+	now := time.Now()
+	rows := []interface{}{
+		&dms_models.TwNotificationDigest{ID: digestId, UserEmailId: 1, Email: "user@example.com", Status: dms_models.DigestPending, NotificationCount: len(notificationIds)},
+	}
+	for _, id := range notificationIds {
+		rows = append(rows, &dms_models.TwNotificationDigestItem{DigestId: digestId, NotificationId: id})
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	if err := db.Model(&models.TwNotifications{}).Where("id IN ?", notificationIds).Update("is_sent", true).Error; err != nil {
+		t.Fatalf("claim notifications: %v", err)
+	}
+	if err := db.Model(&dms_models.TwNotificationDigestSetting{}).Where("user_id = ?", 1).Update("next_at", now.Add(time.Hour)).Error; err != nil {
+		t.Fatalf("schedule next digest: %v", err)
+	}
+}
+
+func TestReleaseDigestDeadLettersAfterMaxAttempts(t *testing.T) {
+	// Notification 2 already failed once on its own.
+	db := openDigestDB(t, 1)
+	policy := config.NotificationConfig{MaxAttempts: 3, RetryBase: time.Minute, RetryMax: time.Hour}
+
+	// Once dead-lettered, notification 2 is no longer claimed into digests.
+	carried := [][]int{{1, 2}, {1, 2}, {1}}
+	wantAttempts := map[int][]int{1: {1, 2, 3}, 2: {2, 3, 3}}
+	for attempt := 0; attempt < 3; attempt++ {
+		digestId := attempt + 1
+		seedPendingDigest(t, db, digestId, carried[attempt]...)
+		if err := ReleaseDigest(db, digestId, "smtp down", policy); err != nil {
+			t.Fatalf("release %d: %v", digestId, err)
+		}
+		if count := dbtest.Count(t, db, "tw_notification_digest_items"); count != 0 {
+			t.Errorf("release %d left %d digest items", digestId, count)
+		}
+		for notificationId, want := range wantAttempts {
+			var delivery dms_models.TwNotificationDelivery
+			if err := db.Where("notification_id = ?", notificationId).First(&delivery).Error; err != nil {
+				t.Fatalf("load delivery: %v", err)
+			}
+			if delivery.Attempts != want[attempt] || delivery.LastError != "smtp down" {
+				t.Errorf("release %d: notification %d has %d attempts with error %q, want %d", digestId, notificationId, delivery.Attempts, delivery.LastError, want[attempt])
+			}
+			if deadLettered := delivery.DeadLetteredAt != nil; deadLettered != (want[attempt] >= policy.MaxAttempts) {
+				t.Errorf("release %d: notification %d dead-lettered = %v", digestId, notificationId, deadLettered)
+			}
+		}
+		if count := dbtest.Count(t, db, "tw_notifications", "is_sent = ?", true); count != 0 {
+			t.Errorf("release %d left %d notifications sent", digestId, count)
+		}
+	}
+
+	if count := dbtest.Count(t, db, "tw_notification_deliveries", "dead_lettered_at IS NOT NULL"); count != 2 {
+		t.Errorf("%d notifications dead-lettered, want 2", count)
+	}
+}
+
+func TestReleaseDigestBacksOff(t *testing.T) {
+	db := openDigestDB(t, 2)
+	policy := config.NotificationConfig{MaxAttempts: 5, RetryBase: time.Minute, RetryMax: time.Hour}
+	seedPendingDigest(t, db, 1, 1, 2)
+
+	before := time.Now()
+	if err := ReleaseDigest(db, 1, "smtp down", policy); err != nil {
+		t.Fatalf("ReleaseDigest: %v", err)
+	}
+	var setting dms_models.TwNotificationDigestSetting
+	if err := db.Where("user_id = ?", 1).First(&setting).Error; err != nil {
+		t.Fatalf("load setting: %v", err)
+	}
+	// Notification 2 failed for the third time: 1m * 2^2.
+	if wait := setting.NextAt.Sub(before); wait < 4*time.Minute || wait > 5*time.Minute {
+		t.Errorf("next digest in %s, want 4m", wait)
+	}
+}
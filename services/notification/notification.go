@@ -0,0 +1,54 @@
+package notification
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Create stores a notification to be delivered by the cron worker, applying
+// the recipient's settings: nothing is stored when the recipient opted out
+// of the type, and delivery is deferred past quiet hours.
+func Create(db *gorm.DB, notification *models.TwNotifications) (Plan, error) {
+	at := time.Now()
+	if notification.NotifiedAt != nil {
+		at = *notification.NotifiedAt
+	}
+	plan, err := PlanForUserEmail(db, notification.UserEmailId, notification.Type, at)
+	if err != nil {
+		return plan, err
+	}
+	if !plan.Deliver {
+		return plan, nil
+	}
+	if plan.Deferred {
+		notification.NotifiedAt = &plan.DeliverAt
+	}
+	return plan, db.Create(notification).Error
+}
+
+// Defer moves the delivery of an unsent notification to the given time.
+func Defer(db *gorm.DB, notificationId int, until time.Time) error {
+	return db.Model(&models.TwNotifications{}).
+		Where("id = ? AND is_sent = ?", notificationId, false).
+		Update("notified_at", until).Error
+}
+
+// Unsent returns the notifications that have not been delivered yet, with
+// their recipient email.
+func Unsent(db *gorm.DB) ([]models.TwNotifications, error) {
+	var notifications []models.TwNotifications
+	err := db.Where("is_sent = ?", false).Preload("UserEmail").Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkSent flags a notification as delivered. It returns
+// gorm.ErrRecordNotFound when the notification does not exist.
+func MarkSent(db *gorm.DB, notificationId int) error {
+	var notification models.TwNotifications
+	if err := db.First(&notification, notificationId).Error; err != nil {
+		return err
+	}
+	notification.IsSent = true
+	return db.Save(&notification).Error
+}
@@ -0,0 +1,166 @@
+package notification
+
+import (
+	"dbms/dms_models"
+	"github.com/timewise-team/timewise-models/dtos/core_dtos/schedule_participant_dtos"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Reminders returns every non-deleted reminder with the workspace user,
+// workspace, email and schedule needed to deliver it.
+func Reminders(db *gorm.DB) ([]models.TwReminder, error) {
+	var reminders []models.TwReminder
+	err := db.
+		Where("deleted_at IS NULL").
+		Preload("WorkspaceUser").
+		Preload("WorkspaceUser.Workspace").
+		Preload("WorkspaceUser.UserEmail").
+		Preload("WorkspaceUser.UserEmail.User").
+		Preload("Schedule").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// MarkReminderSent flags a reminder as sent and returns it. It returns
+// gorm.ErrRecordNotFound when the reminder does not exist.
+func MarkReminderSent(db *gorm.DB, reminderId int) (models.TwReminder, error) {
+	var reminder models.TwReminder
+	if err := db.Where("id = ? AND deleted_at IS NULL", reminderId).First(&reminder).Error; err != nil {
+		return reminder, err
+	}
+	err := db.Model(&reminder).Updates(map[string]interface{}{
+		"updated_at": gorm.Expr("NOW()"),
+		"is_sent":    true,
+	}).Error
+	return reminder, err
+}
+
+// ScheduleParticipants returns the active participants of a schedule with
+// their email and profile.
+func ScheduleParticipants(db *gorm.DB, scheduleId int) ([]schedule_participant_dtos.ScheduleParticipantInfo, error) {
+	var scheduleParticipants []schedule_participant_dtos.ScheduleParticipantInfo
+	err := db.Table("tw_schedule_participants AS sp").
+		Select(`
+            sp.id AS id,
+            sp.schedule_id,
+            sp.workspace_user_id,
+			sp.status,
+			sp.assign_at,
+			sp.assign_by,
+			sp.response_time,
+			sp.invitation_sent_at,
+			sp.invitation_status,
+			wu.role,
+			wu.status AS status_workspace_user,
+			wu.is_verified,
+			ue.id as user_id,
+			ue.email,
+			u.first_name,
+			u.last_name,
+			u.profile_picture
+        `).
+		Joins("JOIN tw_workspace_users AS wu ON wu.id =sp.workspace_user_id").
+		Joins("JOIN tw_user_emails AS ue ON wu.user_email_id = ue.id").
+		Joins("JOIN tw_users AS u ON ue.user_id = u.id").
+		Where("sp.schedule_id = ?", scheduleId).
+		Where("sp.deleted_at IS NULL AND sp.invitation_status != 'removed'").
+		Where("wu.deleted_at IS NULL").
+		Where("ue.deleted_at IS NULL").
+		Where("u.deleted_at IS NULL").
+		Where("wu.is_active = true AND wu.is_verified = true AND wu.status = 'joined'").
+		Scan(&scheduleParticipants).Error
+	return scheduleParticipants, err
+}
+
+// DueReminders returns up to limit unsent reminders of active schedules due
+// at or before the given time, oldest first, with the data needed to deliver
+// them.
+func DueReminders(db *gorm.DB, before time.Time, limit int) ([]models.TwReminder, error) {
+	var reminders []models.TwReminder
+	err := db.
+		Joins("JOIN tw_schedules ON tw_schedules.id = tw_reminders.schedule_id").
+		Where("tw_reminders.deleted_at IS NULL AND tw_reminders.is_sent = ?", false).
+		Where("tw_schedules.is_deleted = false AND tw_schedules.deleted_at IS NULL").
+		Where("tw_reminders.reminder_time <= ?", before).
+		Order("tw_reminders.reminder_time, tw_reminders.id").
+		Limit(limit).
+		Preload("WorkspaceUser").
+		Preload("WorkspaceUser.Workspace").
+		Preload("WorkspaceUser.UserEmail").
+		Preload("WorkspaceUser.UserEmail.User").
+		Preload("Schedule").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+// CancelScheduleReminders deletes the unsent reminders of the given
+// schedules, e.g. when they are archived together with their board column,
+// and returns their IDs so that RestoreReminders can bring them back.
+func CancelScheduleReminders(db *gorm.DB, scheduleIds []int) ([]int, error) {
+	if len(scheduleIds) == 0 {
+		return nil, nil
+	}
+	var reminderIds []int
+	if err := db.Model(&models.TwReminder{}).
+		Where("schedule_id IN ? AND is_sent = ? AND deleted_at IS NULL", scheduleIds, false).
+		Order("id").
+		Pluck("id", &reminderIds).Error; err != nil || len(reminderIds) == 0 {
+		return nil, err
+	}
+	err := db.Model(&models.TwReminder{}).
+		Where("id IN ?", reminderIds).
+		Update("deleted_at", gorm.Expr("NOW()")).Error
+	return reminderIds, err
+}
+
+// RestoreReminders undeletes reminders cancelled by CancelScheduleReminders.
+// Only the unsent reminders of the given schedules are restored.
+func RestoreReminders(db *gorm.DB, scheduleIds, reminderIds []int) error {
+	if len(scheduleIds) == 0 || len(reminderIds) == 0 {
+		return nil
+	}
+	return db.Model(&models.TwReminder{}).
+		Where("id IN ? AND schedule_id IN ? AND is_sent = ? AND deleted_at IS NOT NULL", reminderIds, scheduleIds, false).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"updated_at": gorm.Expr("NOW()"),
+		}).Error
+}
+
+// ClaimReminder atomically marks an unsent reminder as sent. Only one caller
+// gets true for a given reminder, so concurrent or overlapping workers never
+// deliver it twice.
+func ClaimReminder(db *gorm.DB, reminderId int) (bool, error) {
+	result := db.Model(&models.TwReminder{}).
+		Where("id = ? AND is_sent = ? AND deleted_at IS NULL", reminderId, false).
+		Updates(map[string]interface{}{
+			"updated_at": gorm.Expr("NOW()"),
+			"is_sent":    true,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReleaseReminder gives a claimed reminder back, so that it is picked up
+// again on the next run.
+func ReleaseReminder(db *gorm.DB, reminderId int) error {
+	return db.Model(&models.TwReminder{}).
+		Where("id = ?", reminderId).
+		Updates(map[string]interface{}{
+			"updated_at": gorm.Expr("NOW()"),
+			"is_sent":    false,
+		}).Error
+}
+
+func RecordReminderDelivery(db *gorm.DB, delivery *dms_models.TwReminderDelivery) error {
+	return db.Create(delivery).Error
+}
+
+// ReminderDeliveries returns the delivery attempts of a reminder, oldest
+// first.
+func ReminderDeliveries(db *gorm.DB, reminderId int) ([]dms_models.TwReminderDelivery, error) {
+	var deliveries []dms_models.TwReminderDelivery
+	err := db.Where("reminder_id = ?", reminderId).Order("id").Find(&deliveries).Error
+	return deliveries, err
+}
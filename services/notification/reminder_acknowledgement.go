@@ -0,0 +1,374 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/dms_models"
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// reminderOnlyMe is the type of the reminders delivered to their creator
+// only; the other reminders go to every participant of the schedule.
+const reminderOnlyMe = "only me"
+
+// escalationBatch bounds the reminders checked by one EscalateReminders call.
+const escalationBatch = 100
+
+// ErrNotReminderRecipient is returned when a workspace user snoozes or
+// acknowledges a reminder that is not delivered to them.
+var ErrNotReminderRecipient = errors.New("the workspace user does not receive this reminder")
+
+type InvalidReminderAction struct {
+	Reason string
+}
+
+func (e *InvalidReminderAction) Error() string {
+	return e.Reason
+}
+
+// deliveredStatuses are the reminder deliveries that reached the recipient,
+// by email or in-app.
+var deliveredStatuses = []string{
+	dms_models.ReminderDeliverySent,
+	dms_models.ReminderDeliveryDeferred,
+	dms_models.ReminderDeliveryInApp,
+}
+
+// rootReminder returns the reminder a snoozed copy was made from, or the
+// reminder itself.
+func rootReminder(db *gorm.DB, reminderId int) (models.TwReminder, error) {
+	var snooze dms_models.TwReminderSnooze
+	err := db.Where("snooze_reminder_id = ?", reminderId).First(&snooze).Error
+	switch {
+	case err == nil:
+		reminderId = snooze.ReminderId
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return models.TwReminder{}, err
+	}
+	var reminder models.TwReminder
+	err = db.Where("id = ? AND deleted_at IS NULL", reminderId).First(&reminder).Error
+	return reminder, err
+}
+
+// checkReminderRecipient returns ErrNotReminderRecipient unless the reminder
+// is delivered to the workspace user: its creator for an "only me" reminder,
+// the participants of the schedule otherwise.
+func checkReminderRecipient(db *gorm.DB, reminder models.TwReminder, workspaceUserId int) error {
+	if reminder.WorkspaceUserID == workspaceUserId {
+		return nil
+	}
+	if reminder.Type == reminderOnlyMe {
+		return ErrNotReminderRecipient
+	}
+	var count int64
+	err := db.Model(&models.TwScheduleParticipant{}).
+		Where("schedule_id = ? AND workspace_user_id = ?", reminder.ScheduleId, workspaceUserId).
+		Where("deleted_at IS NULL AND invitation_status != 'removed'").
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotReminderRecipient
+	}
+	return nil
+}
+
+// SnoozeReminder reminds the workspace user of a reminder again at until,
+// wall-clock time. The reminder is snoozed into a personal "only me" copy,
+// so that the other participants are not reminded twice; snoozing again
+// moves the pending copy. An unsent "only me" reminder is postponed, a
+// participant reminder can only be snoozed once it was sent. It returns the
+// copy, or gorm.ErrRecordNotFound when the reminder does not exist.
+func SnoozeReminder(db *gorm.DB, reminderId, workspaceUserId int, until, now time.Time, maxSnooze time.Duration) (models.TwReminder, error) {
+	var snoozed models.TwReminder
+	if workspaceUserId <= 0 {
+		return snoozed, &InvalidReminderAction{Reason: "workspace_user_id is required"}
+	}
+	if !until.After(now) {
+		return snoozed, &InvalidReminderAction{Reason: "The reminder can only be snoozed to a later time"}
+	}
+	if until.Sub(now) > maxSnooze {
+		return snoozed, &InvalidReminderAction{Reason: fmt.Sprintf("The reminder can be snoozed for at most %s", maxSnooze)}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		reminder, err := rootReminder(tx, reminderId)
+		if err != nil {
+			return err
+		}
+		if err := checkReminderRecipient(tx, reminder, workspaceUserId); err != nil {
+			return err
+		}
+		if !reminder.IsSent {
+			if reminder.Type != reminderOnlyMe {
+				return &InvalidReminderAction{Reason: "The reminder has not been sent yet"}
+			}
+			// Postponed: the copy replaces it
+			if _, err := ClaimReminder(tx, reminder.ID); err != nil {
+				return err
+			}
+		}
+
+		err = tx.Table("tw_reminders AS r").
+			Select("r.*").
+			Joins("JOIN tw_reminder_snoozes AS s ON s.snooze_reminder_id = r.id").
+			Where("s.reminder_id = ? AND s.workspace_user_id = ?", reminder.ID, workspaceUserId).
+			Where("r.deleted_at IS NULL AND r.is_sent = ?", false).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Take(&snoozed).Error
+		switch {
+		case err == nil:
+			err = tx.Model(&models.TwReminder{}).Where("id = ?", snoozed.ID).Updates(map[string]interface{}{
+				"reminder_time": until,
+				"updated_at":    gorm.Expr("NOW()"),
+			}).Error
+			if err != nil {
+				return err
+			}
+			snoozed.ReminderTime = until
+			return tx.Model(&dms_models.TwReminderSnooze{}).
+				Where("snooze_reminder_id = ?", snoozed.ID).
+				Update("until", until).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			snoozed = models.TwReminder{
+				ScheduleId:      reminder.ScheduleId,
+				ReminderTime:    until,
+				Method:          reminder.Method,
+				Type:            reminderOnlyMe,
+				WorkspaceUserID: workspaceUserId,
+			}
+			if err := tx.Create(&snoozed).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Create(&dms_models.TwReminderSnooze{
+			ReminderId:       reminder.ID,
+			SnoozeReminderId: snoozed.ID,
+			WorkspaceUserID:  workspaceUserId,
+			Until:            until,
+		}).Error
+	})
+	return snoozed, err
+}
+
+// AcknowledgeReminder records that the workspace user saw a reminder, or the
+// reminder a snoozed copy was made from. The user's pending snoozes of it
+// are dropped, and an unsent "only me" reminder is not sent anymore. It
+// returns gorm.ErrRecordNotFound when the reminder does not exist.
+func AcknowledgeReminder(db *gorm.DB, reminderId, workspaceUserId int) (dms_models.TwReminderAcknowledgement, error) {
+	acknowledgement := dms_models.TwReminderAcknowledgement{WorkspaceUserID: workspaceUserId}
+	if workspaceUserId <= 0 {
+		return acknowledgement, &InvalidReminderAction{Reason: "workspace_user_id is required"}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		reminder, err := rootReminder(tx, reminderId)
+		if err != nil {
+			return err
+		}
+		if err := checkReminderRecipient(tx, reminder, workspaceUserId); err != nil {
+			return err
+		}
+		acknowledgement.ReminderId = reminder.ID
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&acknowledgement).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("reminder_id = ? AND workspace_user_id = ?", reminder.ID, workspaceUserId).
+			First(&acknowledgement).Error; err != nil {
+			return err
+		}
+
+		pending := tx.Model(&dms_models.TwReminderSnooze{}).
+			Select("snooze_reminder_id").
+			Where("reminder_id = ? AND workspace_user_id = ?", reminder.ID, workspaceUserId)
+		if err := tx.Model(&models.TwReminder{}).
+			Where("id IN (?) AND is_sent = ? AND deleted_at IS NULL", pending, false).
+			Update("deleted_at", gorm.Expr("NOW()")).Error; err != nil {
+			return err
+		}
+		if reminder.Type == reminderOnlyMe && !reminder.IsSent {
+			_, err = ClaimReminder(tx, reminder.ID)
+		}
+		return err
+	})
+	return acknowledgement, err
+}
+
+// ReminderAcknowledgements returns who acknowledged a reminder, oldest
+// first.
+func ReminderAcknowledgements(db *gorm.DB, reminderId int) ([]dms_models.TwReminderAcknowledgement, error) {
+	var acknowledgements []dms_models.TwReminderAcknowledgement
+	err := db.Where("reminder_id = ?", reminderId).Order("id").Find(&acknowledgements).Error
+	return acknowledgements, err
+}
+
+// EscalateReminders notifies the creators of high priority schedules of the
+// participants who did not acknowledge a reminder within the escalation
+// window of its delivery. A participant who snoozed the reminder has the
+// window again from the delivery of the snoozed copy. Each reminder is
+// escalated once; it returns how many creators were notified.
+func EscalateReminders(db *gorm.DB, cfg config.ReminderConfig, now time.Time) (int, error) {
+	if cfg.EscalationWindow <= 0 || len(cfg.EscalationPriorities) == 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-cfg.EscalationWindow)
+	// Older reminders were delivered before escalation was turned on, or
+	// went through their snoozes unnoticed.
+	oldest := cutoff.Add(-cfg.MaxSnooze - 24*time.Hour)
+
+	var reminders []models.TwReminder
+	err := db.Table("tw_reminders AS r").
+		Select("r.*").
+		Joins("JOIN tw_schedules AS s ON s.id = r.schedule_id").
+		Where("r.deleted_at IS NULL AND r.is_sent = ? AND r.type != ?", true, reminderOnlyMe).
+		Where("s.deleted_at IS NULL AND s.is_deleted = false AND LOWER(s.priority) IN ?", cfg.EscalationPriorities).
+		Where("NOT EXISTS (SELECT 1 FROM tw_reminder_escalations e WHERE e.reminder_id = r.id)").
+		Where(`EXISTS (SELECT 1 FROM tw_reminder_deliveries d WHERE d.reminder_id = r.id
+			AND d.status IN ? AND d.created_at <= ? AND d.created_at >= ?)`, deliveredStatuses, cutoff, oldest).
+		Order("r.id").
+		Limit(escalationBatch).
+		Preload("Schedule").
+		Find(&reminders).Error
+	if err != nil {
+		return 0, err
+	}
+
+	notified := 0
+	for _, reminder := range reminders {
+		escalated, err := escalateReminder(db, reminder, cutoff)
+		if err != nil {
+			return notified, err
+		}
+		if escalated {
+			notified++
+		}
+	}
+	return notified, nil
+}
+
+// escalateReminder notifies the creator of the reminder's schedule of the
+// participants who did not acknowledge it by cutoff. Nothing is recorded
+// while a participant's snoozed copy is within its window.
+func escalateReminder(db *gorm.DB, reminder models.TwReminder, cutoff time.Time) (bool, error) {
+	var delivered []string
+	err := db.Model(&dms_models.TwReminderDelivery{}).
+		Where("reminder_id = ? AND status IN ?", reminder.ID, deliveredStatuses).
+		Distinct().
+		Pluck("recipient", &delivered).Error
+	if err != nil {
+		return false, err
+	}
+	reached := make(map[string]bool, len(delivered))
+	for _, email := range delivered {
+		reached[email] = true
+	}
+
+	participants, err := ScheduleParticipants(db, reminder.ScheduleId)
+	if err != nil {
+		return false, err
+	}
+	var acknowledged []int
+	if err := db.Model(&dms_models.TwReminderAcknowledgement{}).
+		Where("reminder_id = ?", reminder.ID).
+		Pluck("workspace_user_id", &acknowledged).Error; err != nil {
+		return false, err
+	}
+	done := make(map[int]bool, len(acknowledged))
+	for _, workspaceUserId := range acknowledged {
+		done[workspaceUserId] = true
+	}
+	snoozing, err := snoozingUsers(db, reminder.ID, cutoff)
+	if err != nil {
+		return false, err
+	}
+
+	var unacknowledged []string
+	for _, participant := range participants {
+		if participant.WorkspaceUserId == reminder.Schedule.CreatedBy || !reached[participant.Email] || done[participant.WorkspaceUserId] {
+			continue
+		}
+		if snoozing[participant.WorkspaceUserId] {
+			// Checked again once the snoozed copy had its window
+			return false, nil
+		}
+		unacknowledged = append(unacknowledged, participant.Email)
+	}
+
+	escalation := dms_models.TwReminderEscalation{
+		ReminderId:     reminder.ID,
+		Status:         dms_models.ReminderEscalationSkipped,
+		Unacknowledged: strings.Join(unacknowledged, ","),
+	}
+	notified := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&escalation)
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Escalated concurrently
+			return result.Error
+		}
+		if len(unacknowledged) == 0 {
+			return nil
+		}
+		var creator models.TwWorkspaceUser
+		err := tx.Where("id = ? AND deleted_at IS NULL", reminder.Schedule.CreatedBy).First(&creator).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		n := models.TwNotifications{
+			UserEmailId:     creator.UserEmailId,
+			Type:            "reminder_escalation",
+			Title:           fmt.Sprintf("Reminder not acknowledged: %s", reminder.Schedule.Title),
+			Message:         fmt.Sprintf("%d participant(s) did not acknowledge the reminder of %s: %s", len(unacknowledged), reminder.Schedule.Title, strings.Join(unacknowledged, ", ")),
+			RelatedItemId:   reminder.ScheduleId,
+			RelatedItemType: "schedule",
+		}
+		plan, err := Create(tx, &n)
+		if err != nil || !plan.Deliver {
+			// The creator opted out of reminders
+			return err
+		}
+		notified = true
+		return tx.Model(&escalation).Updates(map[string]interface{}{
+			"status":          dms_models.ReminderEscalationNotified,
+			"notification_id": n.ID,
+		}).Error
+	})
+	return notified, err
+}
+
+// snoozingUsers returns the workspace users whose latest snoozed copy of a
+// reminder is pending or was delivered after cutoff.
+func snoozingUsers(db *gorm.DB, reminderId int, cutoff time.Time) (map[int]bool, error) {
+	var snoozes []struct {
+		WorkspaceUserID int
+		IsSent          bool
+		// DeliveredBefore is whether the copy was first delivered by cutoff.
+		DeliveredBefore bool
+	}
+	err := db.Table("tw_reminder_snoozes AS s").
+		Select("s.workspace_user_id, r.is_sent, EXISTS (SELECT 1 FROM tw_reminder_deliveries d WHERE d.reminder_id = r.id AND d.status IN ? AND d.created_at <= ?) AS delivered_before", deliveredStatuses, cutoff).
+		Joins("JOIN tw_reminders AS r ON r.id = s.snooze_reminder_id").
+		Where("s.reminder_id = ? AND r.deleted_at IS NULL", reminderId).
+		Scan(&snoozes).Error
+	if err != nil {
+		return nil, err
+	}
+	snoozing := make(map[int]bool)
+	for _, snooze := range snoozes {
+		if !snooze.IsSent || !snooze.DeliveredBefore {
+			snoozing[snooze.WorkspaceUserID] = true
+		}
+	}
+	return snoozing, nil
+}
@@ -0,0 +1,356 @@
+package notification
+
+import (
+	"dbms/config"
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"errors"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"testing"
+	"time"
+)
+
+// The workspace users of openReminderDB: the creator of schedule 1 and two
+// participants. An outsider is in the workspace but not in the schedule.
+const (
+	creatorId     = 1
+	participantId = 2
+	latecomerId   = 3
+	outsiderId    = 4
+)
+
+var reminderConfig = config.ReminderConfig{
+	EscalationWindow:     time.Hour,
+	EscalationPriorities: []string{"high"},
+	MaxSnooze:            24 * time.Hour,
+}
+
+// openReminderDB returns a database holding high priority schedule 1, the
+// unsent "only me" reminder 1 of the participant, and the participant
+// reminder 2, delivered to everybody two hours ago.
+func openReminderDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwUser{},
+		&models.TwUserEmail{},
+		&models.TwWorkspaceUser{},
+		&models.TwSchedule{},
+		&models.TwScheduleParticipant{},
+		&models.TwReminder{},
+		&models.TwNotifications{},
+		&models.TwNotificationSettings{},
+		&dms_models.TwNotificationDigestSetting{},
+		&dms_models.TwNotificationQuietHours{},
+		&dms_models.TwReminderDelivery{},
+		&dms_models.TwReminderSnooze{},
+		&dms_models.TwReminderAcknowledgement{},
+		&dms_models.TwReminderEscalation{},
+	)
+	now := WallClockNow()
+	delivered := time.Now().Add(-2 * time.Hour)
+	var rows []interface{}
+	for _, id := range []int{creatorId, participantId, latecomerId, outsiderId} {
+		rows = append(rows,
+			&models.TwUser{ID: id},
+			&models.TwUserEmail{ID: id, UserId: id, Email: emailOf(id)},
+			&models.TwWorkspaceUser{ID: id, UserEmailId: id, WorkspaceId: 1, Role: "member", Status: "joined", IsActive: true, IsVerified: true},
+		)
+	}
+	rows = append(rows,
+		&models.TwSchedule{ID: 1, WorkspaceId: 1, Title: "Release", CreatedBy: creatorId, Priority: "High"},
+		&models.TwReminder{ID: 1, ScheduleId: 1, ReminderTime: now.Add(time.Hour), Type: reminderOnlyMe, WorkspaceUserID: participantId},
+		&models.TwReminder{ID: 2, ScheduleId: 1, ReminderTime: now.Add(-2 * time.Hour), Type: "participants", IsSent: true, WorkspaceUserID: creatorId},
+	)
+	for _, id := range []int{creatorId, participantId, latecomerId} {
+		rows = append(rows,
+			&models.TwScheduleParticipant{ScheduleId: 1, WorkspaceUserId: id, InvitationStatus: "joined"},
+			&dms_models.TwReminderDelivery{CreatedAt: delivered, ReminderId: 2, Recipient: emailOf(id), Status: dms_models.ReminderDeliverySent},
+		)
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	return db
+}
+
+func emailOf(workspaceUserId int) string {
+	return map[int]string{
+		creatorId:     "creator@example.com",
+		participantId: "participant@example.com",
+		latecomerId:   "latecomer@example.com",
+		outsiderId:    "outsider@example.com",
+	}[workspaceUserId]
+}
+
+func loadReminder(t *testing.T, db *gorm.DB, id int) models.TwReminder {
+	t.Helper()
+	var reminder models.TwReminder
+	if err := db.First(&reminder, id).Error; err != nil {
+		t.Fatalf("load reminder %d: %v", id, err)
+	}
+	return reminder
+}
+
+func TestSnoozeReminder(t *testing.T) {
+	now := WallClockNow()
+	until := now.Add(30 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name            string
+		reminderId      int
+		workspaceUserId int
+		until           time.Time
+		wantErr         func(error) bool
+		// wantRootSent is whether the snoozed reminder is sent afterwards.
+		wantRootSent bool
+	}{
+		{name: "unsent only me reminder is postponed", reminderId: 1, workspaceUserId: participantId, until: until, wantRootSent: true},
+		{name: "sent participant reminder", reminderId: 2, workspaceUserId: latecomerId, until: until, wantRootSent: true},
+		{name: "only me reminder of someone else", reminderId: 1, workspaceUserId: creatorId, until: until, wantErr: isNotRecipient},
+		{name: "not a participant", reminderId: 2, workspaceUserId: outsiderId, until: until, wantErr: isNotRecipient},
+		{name: "in the past", reminderId: 2, workspaceUserId: latecomerId, until: now.Add(-time.Minute), wantErr: isInvalidAction},
+		{name: "longer than the maximum", reminderId: 2, workspaceUserId: latecomerId, until: now.Add(25 * time.Hour), wantErr: isInvalidAction},
+		{name: "unknown reminder", reminderId: 9, workspaceUserId: latecomerId, until: until, wantErr: func(err error) bool { return errors.Is(err, gorm.ErrRecordNotFound) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openReminderDB(t)
+			snoozed, err := SnoozeReminder(db, tt.reminderId, tt.workspaceUserId, tt.until, now, reminderConfig.MaxSnooze)
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Errorf("error = %v", err)
+				}
+				if count := dbtest.Count(t, db, "tw_reminders"); count != 2 {
+					t.Errorf("%d reminders, want no copy", count)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SnoozeReminder: %v", err)
+			}
+
+			if snoozed.Type != reminderOnlyMe || snoozed.WorkspaceUserID != tt.workspaceUserId || snoozed.IsSent || !snoozed.ReminderTime.Equal(tt.until) {
+				t.Errorf("copy = %+v, want an unsent only me reminder of the user at %s", snoozed, tt.until)
+			}
+			if root := loadReminder(t, db, tt.reminderId); root.IsSent != tt.wantRootSent {
+				t.Errorf("snoozed reminder sent = %v, want %v", root.IsSent, tt.wantRootSent)
+			}
+			if count := dbtest.Count(t, db, "tw_reminder_snoozes", "reminder_id = ? AND snooze_reminder_id = ?", tt.reminderId, snoozed.ID); count != 1 {
+				t.Errorf("the copy is not linked to the snoozed reminder")
+			}
+		})
+	}
+}
+
+func TestSnoozeUnsentParticipantReminder(t *testing.T) {
+	db := openReminderDB(t)
+	if err := db.Model(&models.TwReminder{}).Where("id = ?", 2).Update("is_sent", false).Error; err != nil {
+		t.Fatalf("unsend reminder: %v", err)
+	}
+	now := WallClockNow()
+	_, err := SnoozeReminder(db, 2, latecomerId, now.Add(time.Hour), now, reminderConfig.MaxSnooze)
+	if !isInvalidAction(err) {
+		t.Errorf("error = %v, want the reminder not sent yet", err)
+	}
+	if loadReminder(t, db, 2).IsSent {
+		t.Errorf("the participant reminder was claimed for everybody")
+	}
+}
+
+func TestSnoozeReminderAgainMovesThePendingCopy(t *testing.T) {
+	db := openReminderDB(t)
+	now := WallClockNow()
+	first, err := SnoozeReminder(db, 2, latecomerId, now.Add(10*time.Minute), now, reminderConfig.MaxSnooze)
+	if err != nil {
+		t.Fatalf("first snooze: %v", err)
+	}
+	// Snoozing the copy snoozes the reminder it was made from.
+	later := now.Add(time.Hour).Truncate(time.Second)
+	second, err := SnoozeReminder(db, first.ID, latecomerId, later, now, reminderConfig.MaxSnooze)
+	if err != nil {
+		t.Fatalf("second snooze: %v", err)
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("second snooze made copy %d, want copy %d moved", second.ID, first.ID)
+	}
+	if moved := loadReminder(t, db, first.ID); !moved.ReminderTime.Equal(later) {
+		t.Errorf("copy due at %s, want %s", moved.ReminderTime, later)
+	}
+	if count := dbtest.Count(t, db, "tw_reminders"); count != 3 {
+		t.Errorf("%d reminders, want the two reminders and one copy", count)
+	}
+	var snoozes []dms_models.TwReminderSnooze
+	if err := db.Where("reminder_id = ?", 2).Find(&snoozes).Error; err != nil {
+		t.Fatalf("load snoozes: %v", err)
+	}
+	if len(snoozes) != 1 || snoozes[0].SnoozeReminderId != first.ID || !snoozes[0].Until.Equal(later) {
+		t.Errorf("snoozes = %+v, want the copy snoozed until %s", snoozes, later)
+	}
+
+	// Once the copy was sent, snoozing again makes a new one.
+	if err := db.Model(&models.TwReminder{}).Where("id = ?", first.ID).Update("is_sent", true).Error; err != nil {
+		t.Fatalf("send copy: %v", err)
+	}
+	third, err := SnoozeReminder(db, 2, latecomerId, later, now, reminderConfig.MaxSnooze)
+	if err != nil {
+		t.Fatalf("third snooze: %v", err)
+	}
+	if third.ID == first.ID {
+		t.Errorf("snoozing after the copy was sent moved the sent copy")
+	}
+	if count := dbtest.Count(t, db, "tw_reminder_snoozes", "reminder_id = ?", 2); count != 2 {
+		t.Errorf("%d snoozes of reminder 2, want 2", count)
+	}
+}
+
+func TestAcknowledgeReminderCopy(t *testing.T) {
+	db := openReminderDB(t)
+	now := WallClockNow()
+	snoozed, err := SnoozeReminder(db, 2, latecomerId, now.Add(time.Hour), now, reminderConfig.MaxSnooze)
+	if err != nil {
+		t.Fatalf("SnoozeReminder: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		acknowledgement, err := AcknowledgeReminder(db, snoozed.ID, latecomerId)
+		if err != nil {
+			t.Fatalf("AcknowledgeReminder: %v", err)
+		}
+		if acknowledgement.ReminderId != 2 || acknowledgement.WorkspaceUserID != latecomerId || acknowledgement.ID == 0 {
+			t.Errorf("acknowledgement = %+v, want it recorded on reminder 2", acknowledgement)
+		}
+	}
+	if count := dbtest.Count(t, db, "tw_reminder_acknowledgements"); count != 1 {
+		t.Errorf("%d acknowledgements, want 1", count)
+	}
+	if count := dbtest.Count(t, db, "tw_reminders", "id = ? AND deleted_at IS NULL", snoozed.ID); count != 0 {
+		t.Errorf("the pending copy was not dropped")
+	}
+	if _, err := AcknowledgeReminder(db, 2, outsiderId); !isNotRecipient(err) {
+		t.Errorf("acknowledging as an outsider: error = %v", err)
+	}
+}
+
+func TestAcknowledgeUnsentOnlyMeReminder(t *testing.T) {
+	db := openReminderDB(t)
+	if _, err := AcknowledgeReminder(db, 1, participantId); err != nil {
+		t.Fatalf("AcknowledgeReminder: %v", err)
+	}
+	if !loadReminder(t, db, 1).IsSent {
+		t.Errorf("the acknowledged reminder is still to be sent")
+	}
+}
+
+func TestEscalateReminders(t *testing.T) {
+	db := openReminderDB(t)
+	now := WallClockNow()
+	if _, err := AcknowledgeReminder(db, 2, participantId); err != nil {
+		t.Fatalf("AcknowledgeReminder: %v", err)
+	}
+	snoozed, err := SnoozeReminder(db, 2, latecomerId, now.Add(time.Hour), now, reminderConfig.MaxSnooze)
+	if err != nil {
+		t.Fatalf("SnoozeReminder: %v", err)
+	}
+
+	// The latecomer's snoozed copy is pending.
+	if escalated, err := EscalateReminders(db, reminderConfig, time.Now()); err != nil || escalated != 0 {
+		t.Fatalf("EscalateReminders = %d, %v, want to wait for the snoozed copy", escalated, err)
+	}
+	if count := dbtest.Count(t, db, "tw_reminder_escalations"); count != 0 {
+		t.Fatalf("%d escalations recorded while a copy is pending", count)
+	}
+
+	// The copy went out recently, its window is still open.
+	if err := db.Model(&models.TwReminder{}).Where("id = ?", snoozed.ID).Update("is_sent", true).Error; err != nil {
+		t.Fatalf("send copy: %v", err)
+	}
+	delivery := dms_models.TwReminderDelivery{CreatedAt: time.Now().Add(-10 * time.Minute), ReminderId: snoozed.ID, Recipient: emailOf(latecomerId), Status: dms_models.ReminderDeliverySent}
+	if err := db.Create(&delivery).Error; err != nil {
+		t.Fatalf("deliver copy: %v", err)
+	}
+	if escalated, err := EscalateReminders(db, reminderConfig, time.Now()); err != nil || escalated != 0 {
+		t.Fatalf("EscalateReminders = %d, %v, want to wait for the window of the copy", escalated, err)
+	}
+
+	// The copy went out longer ago than the window, still unacknowledged.
+	if err := db.Model(&delivery).Update("created_at", time.Now().Add(-90*time.Minute)).Error; err != nil {
+		t.Fatalf("age delivery: %v", err)
+	}
+	for run := 1; run <= 2; run++ {
+		escalated, err := EscalateReminders(db, reminderConfig, time.Now())
+		if err != nil {
+			t.Fatalf("run %d: EscalateReminders: %v", run, err)
+		}
+		if want := map[int]int{1: 1, 2: 0}[run]; escalated != want {
+			t.Errorf("run %d escalated %d reminders, want %d", run, escalated, want)
+		}
+	}
+
+	var escalation dms_models.TwReminderEscalation
+	if err := db.Where("reminder_id = ?", 2).First(&escalation).Error; err != nil {
+		t.Fatalf("load escalation: %v", err)
+	}
+	// The creator and the participant who acknowledged are left out.
+	if escalation.Status != dms_models.ReminderEscalationNotified || escalation.Unacknowledged != emailOf(latecomerId) {
+		t.Errorf("escalation = %+v, want the creator notified of the latecomer", escalation)
+	}
+	var notifications []models.TwNotifications
+	if err := db.Where("type = ?", "reminder_escalation").Find(&notifications).Error; err != nil {
+		t.Fatalf("load notifications: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].UserEmailId != creatorId || notifications[0].ID != escalation.NotificationId {
+		t.Errorf("notifications = %+v, want one to the creator", notifications)
+	}
+}
+
+func TestEscalateReminderOnce(t *testing.T) {
+	db := openReminderDB(t)
+	var reminder models.TwReminder
+	if err := db.Preload("Schedule").First(&reminder, 2).Error; err != nil {
+		t.Fatalf("load reminder: %v", err)
+	}
+	cutoff := time.Now().Add(-reminderConfig.EscalationWindow)
+
+	// Two workers escalating the same reminder: the second one finds the
+	// escalation recorded.
+	for worker, want := range []bool{true, false} {
+		escalated, err := escalateReminder(db, reminder, cutoff)
+		if err != nil {
+			t.Fatalf("worker %d: %v", worker, err)
+		}
+		if escalated != want {
+			t.Errorf("worker %d escalated = %v, want %v", worker, escalated, want)
+		}
+	}
+	if count := dbtest.Count(t, db, "tw_notifications", "type = ?", "reminder_escalation"); count != 1 {
+		t.Errorf("the creator got %d escalations, want 1", count)
+	}
+}
+
+func TestEscalateRemindersSkipsOtherPriorities(t *testing.T) {
+	db := openReminderDB(t)
+	if err := db.Model(&models.TwSchedule{}).Where("id = ?", 1).Update("priority", "low").Error; err != nil {
+		t.Fatalf("lower priority: %v", err)
+	}
+	if escalated, err := EscalateReminders(db, reminderConfig, time.Now()); err != nil || escalated != 0 {
+		t.Errorf("EscalateReminders = %d, %v, want nothing escalated", escalated, err)
+	}
+	if count := dbtest.Count(t, db, "tw_reminder_escalations"); count != 0 {
+		t.Errorf("%d escalations recorded", count)
+	}
+}
+
+func isNotRecipient(err error) bool {
+	return errors.Is(err, ErrNotReminderRecipient)
+}
+
+func isInvalidAction(err error) bool {
+	var invalid *InvalidReminderAction
+	return errors.As(err, &invalid)
+}
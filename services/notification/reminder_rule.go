@@ -0,0 +1,314 @@
+package notification
+
+import (
+	"dbms/dms_models"
+	"dbms/recurrence"
+	"dbms/services/calendar"
+	"errors"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+const (
+	// ruleHorizon is how far ahead rules produce reminders for recurring
+	// schedules; SyncRecurringReminderRules extends it as time passes.
+	ruleHorizon = calendar.DefaultRecurringWindow
+	// ruleLookback is how far back occurrences are recalculated, so that
+	// reminders after the start of an occurrence in progress are kept in
+	// line. Older reminders are left as they are.
+	ruleLookback = 7 * 24 * time.Hour
+	// maxRuleOffset bounds how long before or after its anchor a reminder
+	// may be due.
+	maxRuleOffset = 30 * 24 * time.Hour
+)
+
+// WallClockNow returns the current local wall-clock time labelled as UTC,
+// which is how schedule and reminder times are stored.
+func WallClockNow() time.Time {
+	return WallClock(time.Now())
+}
+
+// WallClock returns the local wall-clock time of t labelled as UTC.
+func WallClock(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+type InvalidReminderRule struct {
+	Reason string
+}
+
+func (e *InvalidReminderRule) Error() string {
+	return e.Reason
+}
+
+func validateReminderRule(rule *dms_models.TwReminderRule) error {
+	if rule.ScheduleId <= 0 {
+		return &InvalidReminderRule{Reason: "schedule_id is required"}
+	}
+	switch rule.Anchor {
+	case "":
+		rule.Anchor = dms_models.ReminderAnchorStart
+	case dms_models.ReminderAnchorStart, dms_models.ReminderAnchorEnd:
+	default:
+		return &InvalidReminderRule{Reason: "invalid anchor, expected start or end"}
+	}
+	offset := time.Duration(rule.OffsetMinutes) * time.Minute
+	if offset > maxRuleOffset || offset < -maxRuleOffset {
+		return &InvalidReminderRule{Reason: "offset_minutes must be within 30 days"}
+	}
+	return nil
+}
+
+// ReminderRules returns the relative reminders of a schedule.
+func ReminderRules(db *gorm.DB, scheduleId int) ([]dms_models.TwReminderRule, error) {
+	var rules []dms_models.TwReminderRule
+	err := db.Where("schedule_id = ?", scheduleId).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// CreateReminderRule stores a relative reminder and creates its reminders.
+// It returns gorm.ErrRecordNotFound when the schedule does not exist.
+func CreateReminderRule(db *gorm.DB, rule *dms_models.TwReminderRule, now time.Time) error {
+	if err := validateReminderRule(rule); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("is_deleted = false AND deleted_at IS NULL").First(&models.TwSchedule{}, rule.ScheduleId).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		return SyncScheduleReminders(tx, rule.ScheduleId, now)
+	})
+}
+
+// UpdateReminderRule changes the anchor, offset, method and type of a
+// relative reminder and recalculates its reminders. It returns
+// gorm.ErrRecordNotFound when the rule does not exist.
+func UpdateReminderRule(db *gorm.DB, rule *dms_models.TwReminderRule, now time.Time) error {
+	if err := validateReminderRule(rule); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing dms_models.TwReminderRule
+		if err := tx.First(&existing, rule.ID).Error; err != nil {
+			return err
+		}
+		existing.Anchor = rule.Anchor
+		existing.OffsetMinutes = rule.OffsetMinutes
+		existing.Method = rule.Method
+		existing.Type = rule.Type
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		*rule = existing
+		return SyncScheduleReminders(tx, rule.ScheduleId, now)
+	})
+}
+
+// DeleteReminderRule deletes a relative reminder with its unsent
+// reminders. It returns gorm.ErrRecordNotFound when the rule does not exist.
+func DeleteReminderRule(db *gorm.DB, ruleId int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rule dms_models.TwReminderRule
+		if err := tx.First(&rule, ruleId).Error; err != nil {
+			return err
+		}
+		var links []dms_models.TwReminderRuleOccurrence
+		if err := tx.Where("rule_id = ?", rule.ID).Find(&links).Error; err != nil {
+			return err
+		}
+		if err := removeRuleReminders(tx, links); err != nil {
+			return err
+		}
+		if err := tx.Where("rule_id = ?", rule.ID).Delete(&dms_models.TwReminderRuleOccurrence{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&rule).Error
+	})
+}
+
+// SyncScheduleReminders recalculates the reminders of the relative
+// reminders of a schedule from its current times: one reminder per rule and
+// occurrence, moved along with the occurrence. Reminders already sent, or
+// deleted by hand, are not recreated. The unsent reminders of a deleted
+// schedule are removed.
+func SyncScheduleReminders(db *gorm.DB, scheduleId int, now time.Time) error {
+	rules, err := ReminderRules(db, scheduleId)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+
+	var schedule models.TwSchedule
+	err = db.First(&schedule, scheduleId).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	from, to := now.Add(-ruleLookback), now.Add(ruleHorizon+maxRuleOffset)
+	var occurrences []recurrence.Occurrence
+	if err == nil && !schedule.IsDeleted && schedule.DeletedAt == nil {
+		exceptions, err := calendar.RecurrenceExceptions(db, []models.TwSchedule{schedule})
+		if err != nil {
+			return err
+		}
+		if occurrences, err = recurrence.Expand(schedule, exceptions[schedule.ID], from, to); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range rules {
+		if err := syncRuleReminders(db, rule, occurrences, from, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncRuleReminders(db *gorm.DB, rule dms_models.TwReminderRule, occurrences []recurrence.Occurrence, from, now time.Time) error {
+	// The reminder time of each occurrence, by original start
+	offset := time.Duration(rule.OffsetMinutes) * time.Minute
+	desired := make(map[time.Time]time.Time)
+	for _, occurrence := range occurrences {
+		anchor := occurrence.Start
+		if rule.Anchor == dms_models.ReminderAnchorEnd && occurrence.End.After(occurrence.Start) {
+			anchor = occurrence.End
+		}
+		at := anchor.Add(-offset)
+		if at.Before(now) {
+			if !anchor.After(now) {
+				continue
+			}
+			// Moved too close to the anchor: remind right away
+			at = now
+		}
+		desired[occurrence.OriginalStart.UTC()] = at
+	}
+
+	var links []dms_models.TwReminderRuleOccurrence
+	if err := db.Where("rule_id = ?", rule.ID).Find(&links).Error; err != nil {
+		return err
+	}
+	reminders := make(map[int]models.TwReminder)
+	if len(links) > 0 {
+		ids := make([]int, 0, len(links))
+		for _, link := range links {
+			ids = append(ids, link.ReminderId)
+		}
+		var existing []models.TwReminder
+		if err := db.Where("id IN ?", ids).Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, reminder := range existing {
+			reminders[reminder.ID] = reminder
+		}
+	}
+
+	var stale []dms_models.TwReminderRuleOccurrence
+	for _, link := range links {
+		key := link.OccurrenceStart.UTC()
+		at, wanted := desired[key]
+		delete(desired, key)
+		reminder, ok := reminders[link.ReminderId]
+		if !ok || reminder.IsSent || !reminder.DeletedAt.IsZero() {
+			// Sent or deleted by hand, leave it
+			continue
+		}
+		if !wanted {
+			if key.Before(from) {
+				// Out of the recalculated range
+				continue
+			}
+			stale = append(stale, link)
+			continue
+		}
+		if reminder.ReminderTime.Equal(at) && reminder.Method == rule.Method && reminder.Type == rule.Type {
+			continue
+		}
+		if err := db.Model(&models.TwReminder{}).Where("id = ?", reminder.ID).Updates(map[string]interface{}{
+			"reminder_time": at,
+			"method":        rule.Method,
+			"type":          rule.Type,
+			"updated_at":    gorm.Expr("NOW()"),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	if len(stale) > 0 {
+		if err := removeRuleReminders(db, stale); err != nil {
+			return err
+		}
+		ids := make([]int, 0, len(stale))
+		for _, link := range stale {
+			ids = append(ids, link.ID)
+		}
+		if err := db.Where("id IN ?", ids).Delete(&dms_models.TwReminderRuleOccurrence{}).Error; err != nil {
+			return err
+		}
+	}
+
+	starts := make([]time.Time, 0, len(desired))
+	for start := range desired {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for _, start := range starts {
+		reminder := models.TwReminder{
+			ScheduleId:      rule.ScheduleId,
+			ReminderTime:    desired[start],
+			Method:          rule.Method,
+			Type:            rule.Type,
+			WorkspaceUserID: rule.WorkspaceUserID,
+		}
+		if err := db.Create(&reminder).Error; err != nil {
+			return err
+		}
+		link := dms_models.TwReminderRuleOccurrence{RuleId: rule.ID, OccurrenceStart: start, ReminderId: reminder.ID}
+		if err := db.Create(&link).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeRuleReminders deletes the unsent reminders of the given links.
+func removeRuleReminders(db *gorm.DB, links []dms_models.TwReminderRuleOccurrence) error {
+	if len(links) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.ReminderId)
+	}
+	return db.Model(&models.TwReminder{}).
+		Where("id IN ? AND is_sent = ? AND deleted_at IS NULL", ids, false).
+		Update("deleted_at", gorm.Expr("NOW()")).Error
+}
+
+// SyncRecurringReminderRules recalculates the reminders of every recurring
+// schedule with relative reminders, so that they keep producing reminders
+// ruleHorizon ahead. It returns how many schedules were synced.
+func SyncRecurringReminderRules(db *gorm.DB, now time.Time) (int, error) {
+	var scheduleIds []int
+	err := db.Model(&dms_models.TwReminderRule{}).
+		Joins("JOIN tw_schedules ON tw_schedules.id = tw_reminder_rules.schedule_id").
+		Where("tw_schedules.is_deleted = false AND tw_schedules.deleted_at IS NULL").
+		Where("tw_schedules.recurrence_pattern IS NOT NULL AND tw_schedules.recurrence_pattern != ''").
+		Distinct().
+		Pluck("tw_reminder_rules.schedule_id", &scheduleIds).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, scheduleId := range scheduleIds {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return SyncScheduleReminders(tx, scheduleId, now)
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(scheduleIds), nil
+}
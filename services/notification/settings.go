@@ -0,0 +1,253 @@
+package notification
+
+import (
+	"dbms/dms_models"
+	"dbms/mail"
+	"dbms/services/calendar"
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Settings that govern the notification types. Types that are not listed
+// are always delivered.
+const (
+	SettingTag            = "notification_on_tag"
+	SettingComment        = "notification_on_comment"
+	SettingDueDate        = "notification_on_due_date"
+	SettingScheduleChange = "notification_on_schedule_change"
+)
+
+var typeSettings = map[string]string{
+	"tag":                 SettingTag,
+	"mention":             SettingTag,
+	"assign":              SettingTag,
+	"comment":             SettingComment,
+	"reminder":            SettingDueDate,
+	"reminder_escalation": SettingDueDate,
+	"due_date":            SettingDueDate,
+	"schedule":            SettingScheduleChange,
+	"schedule_change":     SettingScheduleChange,
+	"schedule_update":     SettingScheduleChange,
+}
+
+// Plan is what happens to a notification of a given type for a user: whether
+// it is kept at all, whether it is emailed and from when.
+type Plan struct {
+	UserId int    `json:"user_id"`
+	Type   string `json:"type"`
+	// Setting is the setting that opts the user in or out of the type.
+	Setting string `json:"setting,omitempty"`
+	// Deliver is false when the user opted out of the type.
+	Deliver bool `json:"deliver"`
+	// Email is false when the user only wants in-app notifications.
+	Email     bool      `json:"email"`
+	DeliverAt time.Time `json:"deliver_at"`
+	// Deferred is true when DeliverAt was moved to the end of quiet hours.
+	Deferred bool `json:"deferred"`
+	// Digest is the cadence of the digest that carries the email instead of
+	// a single email, when the user turned digests on.
+	Digest string `json:"digest,omitempty"`
+	// Locale is the language of the emails to the user.
+	Locale string `json:"locale"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PlanForUser applies the notification settings and quiet hours of a user to
+// a notification of the given type due at the given time. Users without
+// settings get every notification, by email, right away.
+func PlanForUser(db *gorm.DB, userId int, notificationType string, at time.Time) (Plan, error) {
+	plan := Plan{
+		UserId:    userId,
+		Type:      notificationType,
+		Setting:   typeSettings[notificationType],
+		Deliver:   true,
+		Email:     true,
+		DeliverAt: at,
+		Locale:    mail.DefaultLocale,
+	}
+
+	var user models.TwUser
+	err := db.Select("id", "locale").First(&user, userId).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return plan, err
+	default:
+		plan.Locale = mail.Locale(user.Locale)
+	}
+
+	var settings models.TwNotificationSettings
+	err = db.Where("user_id = ? AND deleted_at IS NULL", userId).First(&settings).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return plan, err
+	default:
+		if !optedIn(settings, plan.Setting) {
+			plan.Deliver = false
+			plan.Email = false
+			plan.Reason = fmt.Sprintf("%s is off", plan.Setting)
+			return plan, nil
+		}
+		if !settings.NotificationOnEmail {
+			plan.Email = false
+			plan.Reason = "notification_on_email is off, in-app only"
+		}
+	}
+
+	if plan.Email {
+		digest, err := DigestSetting(db, userId)
+		if err != nil {
+			return plan, err
+		}
+		if digest.Cadence != dms_models.DigestCadenceOff {
+			plan.Digest = digest.Cadence
+			plan.Reason = fmt.Sprintf("collected into the %s digest", digest.Cadence)
+		}
+	}
+
+	var quiet dms_models.TwNotificationQuietHours
+	err = db.Where("user_id = ?", userId).First(&quiet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return plan, nil
+	}
+	if err != nil {
+		return plan, err
+	}
+	end, inQuietHours, err := QuietHoursEnd(quiet, at)
+	if err != nil {
+		return plan, err
+	}
+	if inQuietHours {
+		plan.DeliverAt = end
+		plan.Deferred = true
+		if plan.Reason == "" {
+			plan.Reason = fmt.Sprintf("quiet hours %s-%s (%s)", quiet.Start, quiet.End, quiet.Timezone)
+		}
+	}
+	return plan, nil
+}
+
+// PlanForUserEmail is PlanForUser for the user owning a user email.
+func PlanForUserEmail(db *gorm.DB, userEmailId int, notificationType string, at time.Time) (Plan, error) {
+	var userEmail models.TwUserEmail
+	if err := db.Select("id", "user_id").First(&userEmail, userEmailId).Error; err != nil {
+		return Plan{}, err
+	}
+	return PlanForUser(db, userEmail.UserId, notificationType, at)
+}
+
+func optedIn(settings models.TwNotificationSettings, setting string) bool {
+	switch setting {
+	case SettingTag:
+		return settings.NotificationOnTag
+	case SettingComment:
+		return settings.NotificationOnComment
+	case SettingDueDate:
+		return settings.NotificationOnDueDate
+	case SettingScheduleChange:
+		return settings.NotificationOnScheduleChange
+	default:
+		return true
+	}
+}
+
+// QuietHoursEnd reports whether the given time falls within the quiet hours
+// and, if so, when they end.
+func QuietHoursEnd(quiet dms_models.TwNotificationQuietHours, at time.Time) (time.Time, bool, error) {
+	start, err := calendar.ParseClock(quiet.Start, -1)
+	if err != nil {
+		return at, false, err
+	}
+	end, err := calendar.ParseClock(quiet.End, -1)
+	if err != nil {
+		return at, false, err
+	}
+	if start < 0 || end < 0 || start == end {
+		return at, false, nil
+	}
+	loc := time.UTC
+	if quiet.Timezone != "" {
+		if loc, err = time.LoadLocation(quiet.Timezone); err != nil {
+			return at, false, err
+		}
+	}
+
+	local := at.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endOn := func(days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, end/60, end%60, 0, 0, loc)
+	}
+	endOfToday := endOn(0)
+	if start < end {
+		if minute >= start && minute < end {
+			return endOfToday, true, nil
+		}
+		return at, false, nil
+	}
+	// Quiet hours spanning midnight
+	if minute >= start {
+		return endOn(1), true, nil
+	}
+	if minute < end {
+		return endOfToday, true, nil
+	}
+	return at, false, nil
+}
+
+// QuietHours returns the quiet hours of a user. It returns
+// gorm.ErrRecordNotFound when the user has none.
+func QuietHours(db *gorm.DB, userId int) (dms_models.TwNotificationQuietHours, error) {
+	var quiet dms_models.TwNotificationQuietHours
+	err := db.Where("user_id = ?", userId).First(&quiet).Error
+	return quiet, err
+}
+
+// SetQuietHours validates and stores the quiet hours of a user. An empty
+// start and end removes them.
+func SetQuietHours(db *gorm.DB, quiet dms_models.TwNotificationQuietHours) (dms_models.TwNotificationQuietHours, error) {
+	if quiet.Start == "" && quiet.End == "" {
+		err := db.Where("user_id = ?", quiet.UserId).Delete(&dms_models.TwNotificationQuietHours{}).Error
+		return dms_models.TwNotificationQuietHours{UserId: quiet.UserId}, err
+	}
+	start, err := calendar.ParseClock(quiet.Start, -1)
+	if err != nil || start < 0 {
+		return quiet, &InvalidQuietHours{Reason: "invalid start, expected HH:MM"}
+	}
+	end, err := calendar.ParseClock(quiet.End, -1)
+	if err != nil || end < 0 {
+		return quiet, &InvalidQuietHours{Reason: "invalid end, expected HH:MM"}
+	}
+	if start == end {
+		return quiet, &InvalidQuietHours{Reason: "start and end must differ"}
+	}
+	if quiet.Timezone == "" {
+		quiet.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(quiet.Timezone); err != nil {
+		return quiet, &InvalidQuietHours{Reason: "invalid timezone"}
+	}
+
+	var existing dms_models.TwNotificationQuietHours
+	err = db.Where("user_id = ?", quiet.UserId).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return quiet, err
+	}
+	existing.UserId = quiet.UserId
+	existing.Start = quiet.Start
+	existing.End = quiet.End
+	existing.Timezone = quiet.Timezone
+	err = db.Save(&existing).Error
+	return existing, err
+}
+
+type InvalidQuietHours struct {
+	Reason string
+}
+
+func (e *InvalidQuietHours) Error() string {
+	return e.Reason
+}
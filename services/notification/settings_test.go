@@ -0,0 +1,251 @@
+package notification
+
+import (
+	"dbms/database/dbtest"
+	"dbms/dms_models"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursEnd(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			panic(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name      string
+		quiet     dms_models.TwNotificationQuietHours
+		at        time.Time
+		wantQuiet bool
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{name: "within a daytime window", quiet: quietHours("12:00", "14:00", ""), at: at("2024-05-10T13:00:00Z"), wantQuiet: true, wantEnd: at("2024-05-10T14:00:00Z")},
+		{name: "at the start", quiet: quietHours("12:00", "14:00", "UTC"), at: at("2024-05-10T12:00:00Z"), wantQuiet: true, wantEnd: at("2024-05-10T14:00:00Z")},
+		{name: "at the end", quiet: quietHours("12:00", "14:00", "UTC"), at: at("2024-05-10T14:00:00Z")},
+		{name: "outside a daytime window", quiet: quietHours("12:00", "14:00", "UTC"), at: at("2024-05-10T09:00:00Z")},
+		{name: "before midnight", quiet: quietHours("22:00", "07:00", "UTC"), at: at("2024-05-10T23:30:00Z"), wantQuiet: true, wantEnd: at("2024-05-11T07:00:00Z")},
+		{name: "after midnight", quiet: quietHours("22:00", "07:00", "UTC"), at: at("2024-05-11T03:00:00Z"), wantQuiet: true, wantEnd: at("2024-05-11T07:00:00Z")},
+		{name: "outside a window spanning midnight", quiet: quietHours("22:00", "07:00", "UTC"), at: at("2024-05-10T21:59:00Z")},
+		{name: "end of a window spanning midnight", quiet: quietHours("22:00", "07:00", "UTC"), at: at("2024-05-11T07:00:00Z")},
+		{
+			// 23:00 in Hanoi
+			name:      "in the user's timezone",
+			quiet:     quietHours("22:00", "07:00", "Asia/Ho_Chi_Minh"),
+			at:        at("2024-05-10T16:00:00Z"),
+			wantQuiet: true,
+			wantEnd:   at("2024-05-11T00:00:00Z"),
+		},
+		{name: "quiet in UTC but not in the user's timezone", quiet: quietHours("22:00", "07:00", "Asia/Ho_Chi_Minh"), at: at("2024-05-11T01:00:00Z")},
+		{
+			// 23:00 CET, the clocks go forward at 02:00: the night lasts 7 hours.
+			name:      "across the start of DST",
+			quiet:     quietHours("22:00", "07:00", "Europe/Paris"),
+			at:        at("2024-03-30T22:00:00Z"),
+			wantQuiet: true,
+			wantEnd:   at("2024-03-31T05:00:00Z"),
+		},
+		{
+			// 01:00 CET, before the clocks go forward
+			name:      "after midnight on the night DST starts",
+			quiet:     quietHours("22:00", "07:00", "Europe/Paris"),
+			at:        at("2024-03-31T00:00:00Z"),
+			wantQuiet: true,
+			wantEnd:   at("2024-03-31T05:00:00Z"),
+		},
+		{
+			// 23:00 CEST, the clocks go back at 03:00: the night lasts 9 hours.
+			name:      "across the end of DST",
+			quiet:     quietHours("22:00", "07:00", "Europe/Paris"),
+			at:        at("2024-10-26T21:00:00Z"),
+			wantQuiet: true,
+			wantEnd:   at("2024-10-27T06:00:00Z"),
+		},
+		{
+			// 02:30 does not exist that night, it ends at 03:30 CEST.
+			name:      "ending in the DST gap",
+			quiet:     quietHours("22:00", "02:30", "Europe/Paris"),
+			at:        at("2024-03-30T22:00:00Z"),
+			wantQuiet: true,
+			wantEnd:   at("2024-03-31T01:30:00Z"),
+		},
+		{name: "no quiet hours", quiet: quietHours("", "", ""), at: at("2024-05-10T23:00:00Z")},
+		{name: "empty window", quiet: quietHours("22:00", "22:00", "UTC"), at: at("2024-05-10T22:00:00Z")},
+		{name: "invalid start", quiet: quietHours("25:00", "07:00", "UTC"), at: at("2024-05-10T23:00:00Z"), wantErr: true},
+		{name: "unknown timezone", quiet: quietHours("22:00", "07:00", "Mars/Olympus"), at: at("2024-05-10T23:00:00Z"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, quiet, err := QuietHoursEnd(tt.quiet, tt.at)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("QuietHoursEnd succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QuietHoursEnd: %v", err)
+			}
+			if quiet != tt.wantQuiet {
+				t.Fatalf("quiet = %v, want %v", quiet, tt.wantQuiet)
+			}
+			wantEnd := tt.at
+			if tt.wantQuiet {
+				wantEnd = tt.wantEnd
+			}
+			if !end.Equal(wantEnd) {
+				t.Errorf("end = %s, want %s", end.UTC(), wantEnd)
+			}
+		})
+	}
+}
+
+func quietHours(start, end, timezone string) dms_models.TwNotificationQuietHours {
+	return dms_models.TwNotificationQuietHours{UserId: 1, Start: start, End: end, Timezone: timezone}
+}
+
+// openSettingsDB returns a database holding user 1, reading Vietnamese, with
+// the given settings.
+func openSettingsDB(t *testing.T, rows ...interface{}) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t,
+		&models.TwUser{},
+		&models.TwNotificationSettings{},
+		&dms_models.TwNotificationDigestSetting{},
+		&dms_models.TwNotificationQuietHours{},
+	)
+	for _, row := range append([]interface{}{&models.TwUser{ID: 1, Locale: "vi-VN"}}, rows...) {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	return db
+}
+
+func TestPlanForUser(t *testing.T) {
+	at := time.Date(2024, 5, 10, 23, 0, 0, 0, time.UTC)
+	allOn := &models.TwNotificationSettings{
+		UserId:                       1,
+		NotificationOnTag:            true,
+		NotificationOnComment:        true,
+		NotificationOnDueDate:        true,
+		NotificationOnScheduleChange: true,
+		NotificationOnEmail:          true,
+	}
+	withSettings := func(change func(*models.TwNotificationSettings)) *models.TwNotificationSettings {
+		settings := *allOn
+		change(&settings)
+		return &settings
+	}
+	nightQuiet := &dms_models.TwNotificationQuietHours{UserId: 1, Start: "22:00", End: "07:00", Timezone: "UTC"}
+	dailyDigest := &dms_models.TwNotificationDigestSetting{UserId: 1, Cadence: dms_models.DigestCadenceDaily, DailyAt: "08:00", Timezone: "UTC"}
+
+	tests := []struct {
+		name             string
+		notificationType string
+		rows             []interface{}
+		want             Plan
+	}{
+		{
+			name:             "no settings",
+			notificationType: "reminder",
+			want:             Plan{Setting: SettingDueDate, Deliver: true, Email: true, DeliverAt: at},
+		},
+		{
+			name:             "opted out",
+			notificationType: "reminder",
+			rows:             []interface{}{withSettings(func(s *models.TwNotificationSettings) { s.NotificationOnDueDate = false })},
+			want:             Plan{Setting: SettingDueDate, DeliverAt: at, Reason: "notification_on_due_date is off"},
+		},
+		{
+			name:             "opted out of another type",
+			notificationType: "comment",
+			rows:             []interface{}{withSettings(func(s *models.TwNotificationSettings) { s.NotificationOnDueDate = false })},
+			want:             Plan{Setting: SettingComment, Deliver: true, Email: true, DeliverAt: at},
+		},
+		{
+			name:             "type without setting",
+			notificationType: "invitation",
+			rows: []interface{}{withSettings(func(s *models.TwNotificationSettings) {
+				*s = models.TwNotificationSettings{UserId: 1, NotificationOnEmail: true}
+			})},
+			want: Plan{Deliver: true, Email: true, DeliverAt: at},
+		},
+		{
+			name:             "in-app only",
+			notificationType: "mention",
+			rows:             []interface{}{withSettings(func(s *models.TwNotificationSettings) { s.NotificationOnEmail = false })},
+			want:             Plan{Setting: SettingTag, Deliver: true, DeliverAt: at, Reason: "notification_on_email is off, in-app only"},
+		},
+		{
+			name:             "digest",
+			notificationType: "schedule",
+			rows:             []interface{}{allOn, dailyDigest},
+			want:             Plan{Setting: SettingScheduleChange, Deliver: true, Email: true, DeliverAt: at, Digest: dms_models.DigestCadenceDaily, Reason: "collected into the daily digest"},
+		},
+		{
+			name:             "digest of an in-app only user",
+			notificationType: "schedule",
+			rows:             []interface{}{withSettings(func(s *models.TwNotificationSettings) { s.NotificationOnEmail = false }), dailyDigest},
+			want:             Plan{Setting: SettingScheduleChange, Deliver: true, DeliverAt: at, Reason: "notification_on_email is off, in-app only"},
+		},
+		{
+			name:             "deferred past quiet hours",
+			notificationType: "reminder",
+			rows:             []interface{}{allOn, nightQuiet},
+			want:             Plan{Setting: SettingDueDate, Deliver: true, Email: true, DeliverAt: time.Date(2024, 5, 11, 7, 0, 0, 0, time.UTC), Deferred: true, Reason: "quiet hours 22:00-07:00 (UTC)"},
+		},
+		{
+			name:             "deferred digest",
+			notificationType: "reminder",
+			rows:             []interface{}{allOn, dailyDigest, nightQuiet},
+			want:             Plan{Setting: SettingDueDate, Deliver: true, Email: true, DeliverAt: time.Date(2024, 5, 11, 7, 0, 0, 0, time.UTC), Deferred: true, Digest: dms_models.DigestCadenceDaily, Reason: "collected into the daily digest"},
+		},
+		{
+			name:             "opted out during quiet hours",
+			notificationType: "reminder",
+			rows:             []interface{}{withSettings(func(s *models.TwNotificationSettings) { s.NotificationOnDueDate = false }), nightQuiet},
+			want:             Plan{Setting: SettingDueDate, DeliverAt: at, Reason: "notification_on_due_date is off"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openSettingsDB(t, tt.rows...)
+			plan, err := PlanForUser(db, 1, tt.notificationType, at)
+			if err != nil {
+				t.Fatalf("PlanForUser: %v", err)
+			}
+			want := tt.want
+			want.UserId = 1
+			want.Type = tt.notificationType
+			want.Locale = "vi"
+			if !plan.DeliverAt.Equal(want.DeliverAt) {
+				t.Errorf("DeliverAt = %s, want %s", plan.DeliverAt, want.DeliverAt)
+			}
+			plan.DeliverAt, want.DeliverAt = time.Time{}, time.Time{}
+			if plan != want {
+				t.Errorf("plan = %+v, want %+v", plan, want)
+			}
+		})
+	}
+}
+
+func TestPlanForUserWithoutAccount(t *testing.T) {
+	db := openSettingsDB(t)
+	plan, err := PlanForUser(db, 2, "reminder", time.Now())
+	if err != nil {
+		t.Fatalf("PlanForUser: %v", err)
+	}
+	if !plan.Deliver || !plan.Email || plan.Locale != "en" {
+		t.Errorf("plan = %+v, want an English email", plan)
+	}
+}
@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+const (
+	tailInterval = time.Second
+	// tailLookback re-reads recent notifications, so that rows committed
+	// out of id order are not missed.
+	tailLookback = 10 * time.Second
+	tailBatch    = 500
+)
+
+// StartTailer publishes every notification stored from now on to the
+// default broker, whichever process stored it: the API, the cron worker or
+// another instance.
+func StartTailer(db *gorm.DB) {
+	go func() {
+		ticker := time.NewTicker(tailInterval)
+		defer ticker.Stop()
+
+		var t *tailer
+		for {
+			var err error
+			if t, err = newTailer(db, time.Now()); err == nil {
+				break
+			}
+			log.Printf("Could not start the notification tailer: %v", err)
+			<-ticker.C
+		}
+		for now := range ticker.C {
+			if err := t.poll(DefaultBroker(), now); err != nil {
+				log.Printf("Could not read new notifications: %v", err)
+			}
+		}
+	}()
+}
+
+type tailer struct {
+	db      *gorm.DB
+	lastId  int
+	started time.Time
+	// published holds the recently published notifications, so that the
+	// lookback does not publish them twice.
+	published map[int]time.Time
+}
+
+// newTailer returns a tailer starting after the existing notifications.
+func newTailer(db *gorm.DB, now time.Time) (*tailer, error) {
+	t := &tailer{db: db, started: now, published: make(map[int]time.Time)}
+	err := db.Model(&models.TwNotifications{}).Select("COALESCE(MAX(id), 0)").Scan(&t.lastId).Error
+	return t, err
+}
+
+// poll publishes the notifications stored since the previous poll: the
+// rows after the last id, a batch at a time until they are all read, and
+// the recent rows below it that were committed out of id order.
+func (t *tailer) poll(broker Broker, now time.Time) error {
+	since := now.Add(-tailLookback)
+	if since.Before(t.started) {
+		since = t.started
+	}
+	afterId := 0
+	for {
+		var late []models.TwNotifications
+		if err := t.db.
+			Where("id > ? AND id <= ? AND created_at >= ? AND deleted_at IS NULL", afterId, t.lastId, since).
+			Order("id").
+			Limit(tailBatch).
+			Find(&late).Error; err != nil {
+			return err
+		}
+		for _, n := range late {
+			t.publish(broker, n, now)
+			afterId = n.ID
+		}
+		if len(late) < tailBatch {
+			break
+		}
+	}
+
+	for {
+		var fresh []models.TwNotifications
+		if err := t.db.
+			Where("id > ? AND deleted_at IS NULL", t.lastId).
+			Order("id").
+			Limit(tailBatch).
+			Find(&fresh).Error; err != nil {
+			return err
+		}
+		for _, n := range fresh {
+			t.publish(broker, n, now)
+			t.lastId = n.ID
+		}
+		if len(fresh) < tailBatch {
+			break
+		}
+	}
+
+	for id, at := range t.published {
+		if now.Sub(at) > 2*tailLookback {
+			delete(t.published, id)
+		}
+	}
+	return nil
+}
+
+func (t *tailer) publish(broker Broker, n models.TwNotifications, now time.Time) {
+	if _, ok := t.published[n.ID]; ok {
+		return
+	}
+	t.published[n.ID] = now
+	broker.Publish(n)
+}
+
+// Since returns up to limit notifications of the given user emails with an
+// id greater than afterId, oldest first, to resume a stream.
+func Since(db *gorm.DB, userEmailIds []int, afterId int, limit int) ([]models.TwNotifications, error) {
+	var notifications []models.TwNotifications
+	err := db.
+		Where("user_email_id IN ? AND id > ? AND deleted_at IS NULL", userEmailIds, afterId).
+		Order("id").
+		Limit(limit).
+		Find(&notifications).Error
+	return notifications, err
+}
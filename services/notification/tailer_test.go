@@ -0,0 +1,109 @@
+package notification
+
+import (
+	"dbms/database/dbtest"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// recordingBroker keeps the ids of the published notifications.
+type recordingBroker struct {
+	published []int
+}
+
+func (b *recordingBroker) Publish(notification models.TwNotifications) {
+	b.published = append(b.published, notification.ID)
+}
+
+func (b *recordingBroker) Subscribe(userEmailIds []int) (<-chan models.TwNotifications, func()) {
+	panic("not used by the tailer")
+}
+
+func storeNotifications(t *testing.T, db *gorm.DB, notifications ...models.TwNotifications) {
+	t.Helper()
+	if err := db.CreateInBatches(notifications, 100).Error; err != nil {
+		t.Fatalf("store notifications: %v", err)
+	}
+}
+
+func TestTailerPublishesEveryNewNotification(t *testing.T) {
+	db := dbtest.Open(t, &models.TwNotifications{})
+	storeNotifications(t, db, models.TwNotifications{UserEmailId: 1, Type: "existing"})
+	start := time.Now()
+	tail, err := newTailer(db, start)
+	if err != nil {
+		t.Fatalf("newTailer: %v", err)
+	}
+	broker := &recordingBroker{}
+
+	// A burst of more than a batch within the lookback window
+	burst := make([]models.TwNotifications, tailBatch+20)
+	for i := range burst {
+		burst[i] = models.TwNotifications{UserEmailId: 1, Type: "burst"}
+	}
+	storeNotifications(t, db, burst...)
+	if err := tail.poll(broker, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(broker.published) != len(burst) {
+		t.Fatalf("published %d notifications, want %d", len(broker.published), len(burst))
+	}
+
+	// The next notification is not stuck behind the burst, still within
+	// the lookback window.
+	storeNotifications(t, db, models.TwNotifications{UserEmailId: 1, Type: "next"})
+	if err := tail.poll(broker, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if got := len(broker.published); got != len(burst)+1 || broker.published[got-1] != len(burst)+2 {
+		t.Errorf("published %d notifications ending with %d, want the next one published once", got, broker.published[got-1])
+	}
+	for i, id := range broker.published {
+		if id != i+2 {
+			t.Fatalf("published[%d] = %d, want %d: the existing notification is skipped and every other published once", i, id, i+2)
+		}
+	}
+}
+
+func TestTailerPublishesNotificationsCommittedOutOfOrder(t *testing.T) {
+	db := dbtest.Open(t, &models.TwNotifications{})
+	tail, err := newTailer(db, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("newTailer: %v", err)
+	}
+	broker := &recordingBroker{}
+	storeNotifications(t, db,
+		models.TwNotifications{ID: 1, UserEmailId: 1},
+		models.TwNotifications{ID: 3, UserEmailId: 1},
+	)
+	if err := tail.poll(broker, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	// Notification 2 was committed after notification 3.
+	storeNotifications(t, db, models.TwNotifications{ID: 2, UserEmailId: 1})
+	for i := 0; i < 2; i++ {
+		if err := tail.poll(broker, time.Now()); err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+	}
+	if len(broker.published) != 3 || broker.published[2] != 2 {
+		t.Errorf("published %v, want 1, 3 and then 2", broker.published)
+	}
+
+	// Older than the lookback, it is left to the clients resuming from
+	// their last event id.
+	storeNotifications(t, db, models.TwNotifications{ID: 5, UserEmailId: 1})
+	if err := tail.poll(broker, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	storeNotifications(t, db, models.TwNotifications{ID: 4, UserEmailId: 1, CreatedAt: time.Now().Add(-time.Minute)})
+	if err := tail.poll(broker, time.Now()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(broker.published) != 4 || broker.published[3] != 5 {
+		t.Errorf("published %v, want 1, 3, 2 and 5", broker.published)
+	}
+}
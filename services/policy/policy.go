@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"strings"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
+
+type Resource string
+
+const (
+	Schedule    Resource = "schedule"
+	BoardColumn Resource = "board_column"
+	Member      Resource = "member"
+	Document    Resource = "document"
+	Comment     Resource = "comment"
+	Webhook     Resource = "webhook"
+)
+
+type Action string
+
+const (
+	View   Action = "view"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	// Invite, Remove and UpdateRole apply to workspace members.
+	Invite     Action = "invite"
+	Remove     Action = "remove"
+	UpdateRole Action = "update_role"
+)
+
+// Grant is how far a role may perform an action.
+type Grant int
+
+const (
+	Deny Grant = iota
+	// Own allows the action only on items the workspace user created.
+	Own
+	Allow
+)
+
+type permission struct {
+	resource Resource
+	action   Action
+}
+
+// matrix maps each role to its grants; anything missing is denied.
+var matrix = map[string]map[permission]Grant{
+	RoleOwner: {
+		{Schedule, View}: Allow, {Schedule, Create}: Allow, {Schedule, Update}: Allow, {Schedule, Delete}: Allow,
+		{BoardColumn, View}: Allow, {BoardColumn, Create}: Allow, {BoardColumn, Update}: Allow, {BoardColumn, Delete}: Allow,
+		{Member, View}: Allow, {Member, Invite}: Allow, {Member, Remove}: Allow, {Member, UpdateRole}: Allow,
+		{Document, View}: Allow, {Document, Create}: Allow, {Document, Update}: Allow, {Document, Delete}: Allow,
+		{Comment, View}: Allow, {Comment, Create}: Allow, {Comment, Update}: Own, {Comment, Delete}: Allow,
+		{Webhook, View}: Allow, {Webhook, Create}: Allow, {Webhook, Update}: Allow, {Webhook, Delete}: Allow,
+	},
+	RoleAdmin: {
+		{Schedule, View}: Allow, {Schedule, Create}: Allow, {Schedule, Update}: Allow, {Schedule, Delete}: Allow,
+		{BoardColumn, View}: Allow, {BoardColumn, Create}: Allow, {BoardColumn, Update}: Allow, {BoardColumn, Delete}: Allow,
+		{Member, View}: Allow, {Member, Invite}: Allow, {Member, Remove}: Allow, {Member, UpdateRole}: Allow,
+		{Document, View}: Allow, {Document, Create}: Allow, {Document, Update}: Allow, {Document, Delete}: Allow,
+		{Comment, View}: Allow, {Comment, Create}: Allow, {Comment, Update}: Own, {Comment, Delete}: Allow,
+		{Webhook, View}: Allow, {Webhook, Create}: Allow, {Webhook, Update}: Allow, {Webhook, Delete}: Allow,
+	},
+	RoleMember: {
+		{Schedule, View}: Allow, {Schedule, Create}: Allow, {Schedule, Update}: Allow, {Schedule, Delete}: Own,
+		{BoardColumn, View}: Allow,
+		{Member, View}:      Allow,
+		{Document, View}:    Allow, {Document, Create}: Allow, {Document, Update}: Own, {Document, Delete}: Own,
+		{Comment, View}: Allow, {Comment, Create}: Allow, {Comment, Update}: Own, {Comment, Delete}: Own,
+	},
+	RoleGuest: {
+		{Schedule, View}:    Allow,
+		{BoardColumn, View}: Allow,
+		{Member, View}:      Allow,
+		{Document, View}:    Allow,
+		{Comment, View}:     Allow, {Comment, Create}: Allow, {Comment, Update}: Own, {Comment, Delete}: Own,
+	},
+}
+
+// rank orders roles for role changes: a workspace user may only manage roles
+// below their own.
+var rank = map[string]int{RoleGuest: 1, RoleMember: 2, RoleAdmin: 3, RoleOwner: 4}
+
+// Denied is returned when the policy forbids an action; Reason is meant to
+// be shown to the caller.
+type Denied struct {
+	Reason string
+}
+
+func (d *Denied) Error() string {
+	return d.Reason
+}
+
+func denied(format string, args ...interface{}) error {
+	return &Denied{Reason: fmt.Sprintf(format, args...)}
+}
+
+// NormalizeRole lower-cases a stored role; older rows use "Guest" etc.
+func NormalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
+
+// GrantFor returns the grant of a role for an action on a resource.
+func GrantFor(role string, resource Resource, action Action) Grant {
+	return matrix[NormalizeRole(role)][permission{resource, action}]
+}
+
+// Check reports whether role may perform action on resource. isOwner tells
+// whether the acting workspace user created the item.
+func Check(role string, resource Resource, action Action, isOwner bool) error {
+	role = NormalizeRole(role)
+	if _, ok := matrix[role]; !ok {
+		return denied("Unknown role %q", role)
+	}
+	switch GrantFor(role, resource, action) {
+	case Allow:
+		return nil
+	case Own:
+		if isOwner {
+			return nil
+		}
+		return denied("A workspace %s may only %s their own %s", role, action, resourceName(resource))
+	default:
+		return denied("A workspace %s may not %s %s", role, action, resourceName(resource))
+	}
+}
+
+// CheckRoleChange reports whether actorRole may change a member from
+// currentRole to newRole: the actor must be allowed to update roles, may not
+// change their own role, and may only move members between roles ranked
+// below their own (owners may appoint other owners).
+func CheckRoleChange(actorRole, currentRole, newRole string, self bool) error {
+	if err := Check(actorRole, Member, UpdateRole, false); err != nil {
+		return err
+	}
+	actorRole, currentRole, newRole = NormalizeRole(actorRole), NormalizeRole(currentRole), NormalizeRole(newRole)
+	if _, ok := rank[newRole]; !ok {
+		return denied("Unknown role %q", newRole)
+	}
+	if self {
+		return denied("A workspace user may not change their own role")
+	}
+	if actorRole == RoleOwner {
+		return nil
+	}
+	if rank[currentRole] >= rank[actorRole] {
+		return denied("A workspace %s may not change the role of a %s", actorRole, currentRole)
+	}
+	if rank[newRole] >= rank[actorRole] {
+		return denied("A workspace %s may not grant the %s role", actorRole, newRole)
+	}
+	return nil
+}
+
+// ActingUser loads the workspace user performing a request and checks that
+// it is an active, joined member of the workspace.
+func ActingUser(db *gorm.DB, workspaceUserId, workspaceId int) (models.TwWorkspaceUser, error) {
+	var workspaceUser models.TwWorkspaceUser
+	err := db.Where("id = ? AND workspace_id = ? AND deleted_at IS NULL", workspaceUserId, workspaceId).First(&workspaceUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return workspaceUser, denied("Workspace user %d is not a member of workspace %d", workspaceUserId, workspaceId)
+	}
+	if err != nil {
+		return workspaceUser, err
+	}
+	if workspaceUser.Status != "joined" || !workspaceUser.IsActive {
+		return workspaceUser, denied("Workspace user %d is not an active member of workspace %d", workspaceUserId, workspaceId)
+	}
+	return workspaceUser, nil
+}
+
+// Authorize loads the acting workspace user and checks the policy for an
+// item created by ownerId (0 when the item has no owner).
+func Authorize(db *gorm.DB, workspaceUserId, workspaceId int, resource Resource, action Action, ownerId int) (models.TwWorkspaceUser, error) {
+	workspaceUser, err := ActingUser(db, workspaceUserId, workspaceId)
+	if err != nil {
+		return workspaceUser, err
+	}
+	return workspaceUser, Check(workspaceUser.Role, resource, action, ownerId != 0 && ownerId == workspaceUser.ID)
+}
+
+// AuthorizeForSchedule is Authorize for items that belong to a schedule,
+// such as documents and comments, in the schedule's workspace. It returns
+// gorm.ErrRecordNotFound when the schedule does not exist.
+func AuthorizeForSchedule(db *gorm.DB, workspaceUserId, scheduleId int, resource Resource, action Action, ownerId int) (models.TwWorkspaceUser, error) {
+	var schedule models.TwSchedule
+	if err := db.Select("id", "workspace_id").Where("id = ? AND deleted_at IS NULL", scheduleId).First(&schedule).Error; err != nil {
+		return models.TwWorkspaceUser{}, err
+	}
+	return Authorize(db, workspaceUserId, schedule.WorkspaceId, resource, action, ownerId)
+}
+
+func resourceName(resource Resource) string {
+	switch resource {
+	case BoardColumn:
+		return "board columns"
+	case Member:
+		return "members"
+	default:
+		return string(resource) + "s"
+	}
+}
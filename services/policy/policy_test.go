@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"dbms/database/dbtest"
+	"errors"
+	"fmt"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"testing"
+	"time"
+)
+
+var (
+	roles     = []string{RoleOwner, RoleAdmin, RoleMember, RoleGuest}
+	resources = []Resource{Schedule, BoardColumn, Member, Document, Comment, Webhook}
+	actions   = []Action{View, Create, Update, Delete, Invite, Remove, UpdateRole}
+)
+
+// expected lists every grant that is not Deny, written out independently of
+// matrix so that a change to either shows up here.
+var expected = map[string]map[string]Grant{
+	RoleOwner: {
+		"schedule/view": Allow, "schedule/create": Allow, "schedule/update": Allow, "schedule/delete": Allow,
+		"board_column/view": Allow, "board_column/create": Allow, "board_column/update": Allow, "board_column/delete": Allow,
+		"member/view": Allow, "member/invite": Allow, "member/remove": Allow, "member/update_role": Allow,
+		"document/view": Allow, "document/create": Allow, "document/update": Allow, "document/delete": Allow,
+		"comment/view": Allow, "comment/create": Allow, "comment/update": Own, "comment/delete": Allow,
+		"webhook/view": Allow, "webhook/create": Allow, "webhook/update": Allow, "webhook/delete": Allow,
+	},
+	RoleAdmin: {
+		"schedule/view": Allow, "schedule/create": Allow, "schedule/update": Allow, "schedule/delete": Allow,
+		"board_column/view": Allow, "board_column/create": Allow, "board_column/update": Allow, "board_column/delete": Allow,
+		"member/view": Allow, "member/invite": Allow, "member/remove": Allow, "member/update_role": Allow,
+		"document/view": Allow, "document/create": Allow, "document/update": Allow, "document/delete": Allow,
+		"comment/view": Allow, "comment/create": Allow, "comment/update": Own, "comment/delete": Allow,
+		"webhook/view": Allow, "webhook/create": Allow, "webhook/update": Allow, "webhook/delete": Allow,
+	},
+	RoleMember: {
+		"schedule/view": Allow, "schedule/create": Allow, "schedule/update": Allow, "schedule/delete": Own,
+		"board_column/view": Allow,
+		"member/view":       Allow,
+		"document/view":     Allow, "document/create": Allow, "document/update": Own, "document/delete": Own,
+		"comment/view": Allow, "comment/create": Allow, "comment/update": Own, "comment/delete": Own,
+	},
+	RoleGuest: {
+		"schedule/view":     Allow,
+		"board_column/view": Allow,
+		"member/view":       Allow,
+		"document/view":     Allow,
+		"comment/view":      Allow, "comment/create": Allow, "comment/update": Own, "comment/delete": Own,
+	},
+}
+
+func TestCheck(t *testing.T) {
+	for _, role := range roles {
+		for _, resource := range resources {
+			for _, action := range actions {
+				want := expected[role][fmt.Sprintf("%s/%s", resource, action)]
+				for _, isOwner := range []bool{false, true} {
+					name := fmt.Sprintf("%s %s %s owner=%v", role, action, resource, isOwner)
+					t.Run(name, func(t *testing.T) {
+						if got := GrantFor(role, resource, action); got != want {
+							t.Fatalf("GrantFor = %d, want %d", got, want)
+						}
+						allowed := want == Allow || (want == Own && isOwner)
+						err := Check(role, resource, action, isOwner)
+						if allowed && err != nil {
+							t.Errorf("Check denied: %v", err)
+						}
+						if !allowed {
+							var deniedErr *Denied
+							if !errors.As(err, &deniedErr) {
+								t.Errorf("Check = %v, want *Denied", err)
+							}
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestCheckNormalizesRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		wantErr bool
+	}{
+		{name: "capitalized", role: "Admin"},
+		{name: "upper case with spaces", role: " OWNER "},
+		{name: "capitalized guest", role: "Guest", wantErr: true},
+		{name: "unknown role", role: "superuser", wantErr: true},
+		{name: "empty role", role: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.role, BoardColumn, Delete, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%q) = %v, want error %v", tt.role, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckRoleChange(t *testing.T) {
+	tests := []struct {
+		name                            string
+		actorRole, currentRole, newRole string
+		self                            bool
+		wantErr                         bool
+	}{
+		{name: "owner promotes a member to admin", actorRole: RoleOwner, currentRole: RoleMember, newRole: RoleAdmin},
+		{name: "owner appoints another owner", actorRole: RoleOwner, currentRole: RoleAdmin, newRole: RoleOwner},
+		{name: "owner demotes another owner", actorRole: RoleOwner, currentRole: RoleOwner, newRole: RoleMember},
+		{name: "owner changes their own role", actorRole: RoleOwner, currentRole: RoleOwner, newRole: RoleAdmin, self: true, wantErr: true},
+		{name: "admin promotes a guest to member", actorRole: RoleAdmin, currentRole: RoleGuest, newRole: RoleMember},
+		{name: "admin demotes a member to guest", actorRole: RoleAdmin, currentRole: RoleMember, newRole: RoleGuest},
+		{name: "admin grants admin", actorRole: RoleAdmin, currentRole: RoleMember, newRole: RoleAdmin, wantErr: true},
+		{name: "admin grants owner", actorRole: RoleAdmin, currentRole: RoleMember, newRole: RoleOwner, wantErr: true},
+		{name: "admin demotes another admin", actorRole: RoleAdmin, currentRole: RoleAdmin, newRole: RoleMember, wantErr: true},
+		{name: "admin demotes an owner", actorRole: RoleAdmin, currentRole: RoleOwner, newRole: RoleMember, wantErr: true},
+		{name: "admin changes their own role", actorRole: RoleAdmin, currentRole: RoleAdmin, newRole: RoleGuest, self: true, wantErr: true},
+		{name: "member changes a guest", actorRole: RoleMember, currentRole: RoleGuest, newRole: RoleMember, wantErr: true},
+		{name: "guest changes a guest", actorRole: RoleGuest, currentRole: RoleGuest, newRole: RoleMember, wantErr: true},
+		{name: "unknown new role", actorRole: RoleOwner, currentRole: RoleMember, newRole: "superuser", wantErr: true},
+		{name: "mixed-case roles", actorRole: "Admin", currentRole: "Guest", newRole: "Member"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRoleChange(tt.actorRole, tt.currentRole, tt.newRole, tt.self)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckRoleChange = %v, want error %v", err, tt.wantErr)
+			}
+			var deniedErr *Denied
+			if err != nil && !errors.As(err, &deniedErr) {
+				t.Errorf("CheckRoleChange = %v, want *Denied", err)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	db := dbtest.Open(t, &models.TwWorkspaceUser{}, &models.TwSchedule{})
+	now := time.Now()
+	rows := []interface{}{
+		&models.TwWorkspaceUser{ID: 1, WorkspaceId: 1, Role: "member", Status: "joined", IsActive: true},
+		&models.TwWorkspaceUser{ID: 2, WorkspaceId: 1, Role: "admin", Status: "pending", IsActive: true},
+		&models.TwWorkspaceUser{ID: 3, WorkspaceId: 2, Role: "owner", Status: "joined", IsActive: true},
+		&models.TwSchedule{ID: 1, WorkspaceId: 1, Title: "Card", StartTime: &now, EndTime: &now, CreatedAt: &now, UpdatedAt: &now},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		workspaceUserId int
+		ownerId         int
+		wantDenied      bool
+	}{
+		{name: "member deletes their own schedule", workspaceUserId: 1, ownerId: 1},
+		{name: "member deletes another's schedule", workspaceUserId: 1, ownerId: 4, wantDenied: true},
+		{name: "member deletes a schedule without owner", workspaceUserId: 1, wantDenied: true},
+		{name: "pending admin", workspaceUserId: 2, ownerId: 4, wantDenied: true},
+		{name: "owner of another workspace", workspaceUserId: 3, ownerId: 4, wantDenied: true},
+		{name: "unknown workspace user", workspaceUserId: 99, ownerId: 4, wantDenied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AuthorizeForSchedule(db, tt.workspaceUserId, 1, Schedule, Delete, tt.ownerId)
+			var deniedErr *Denied
+			if got := errors.As(err, &deniedErr); got != tt.wantDenied {
+				t.Errorf("AuthorizeForSchedule = %v, want denied %v", err, tt.wantDenied)
+			}
+		})
+	}
+
+	if _, err := AuthorizeForSchedule(db, 1, 99, Schedule, View, 0); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("AuthorizeForSchedule on a missing schedule = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,303 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"dbms/config"
+	"dbms/dms_models"
+	"encoding/hex"
+	"encoding/json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"time"
+)
+
+// The headers of a delivery. HeaderSignature is the hex HMAC-SHA256 of
+//
+//	timestamp + "." + body
+//
+// with the subscription's secret, timestamp being HeaderTimestamp.
+const (
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// claimLease is how long a claimed delivery is hidden from the other
+// workers; a worker that dies while sending it leaves it to be retried
+// after that.
+const claimLease = 5 * time.Minute
+
+// maxResponseLog bounds the response body kept in the delivery log.
+const maxResponseLog = 1024
+
+// Envelope is the body of a delivery.
+type Envelope struct {
+	ID          int             `json:"id"`
+	Type        string          `json:"type"`
+	WorkspaceId int             `json:"workspace_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// Dispatch is a claimed delivery with what the worker needs to send it.
+type Dispatch struct {
+	DeliveryId int    `json:"delivery_id"`
+	EventType  string `json:"event_type"`
+	Attempt    int    `json:"attempt"`
+	URL        string `json:"url"`
+	Secret     string `json:"secret"`
+	Body       string `json:"body"`
+}
+
+// Headers returns the headers of the delivery signed at the given time.
+func (d Dispatch) Headers(at time.Time) map[string]string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return map[string]string{
+		"Content-Type":  "application/json",
+		HeaderDelivery:  strconv.Itoa(d.DeliveryId),
+		HeaderEvent:     d.EventType,
+		HeaderTimestamp: timestamp,
+		HeaderSignature: Sign(d.Secret, timestamp, []byte(d.Body)),
+	}
+}
+
+// Sign returns the signature of a delivery body, see HeaderSignature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Attempt is the outcome of one request of a delivery.
+type Attempt struct {
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	Response   string `json:"response"`
+	DurationMs int    `json:"duration_ms"`
+	Worker     string `json:"worker"`
+}
+
+// Succeeded reports whether the request got a 2xx response.
+func (a Attempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// DeliveryLog is a delivery with its attempts, oldest first.
+type DeliveryLog struct {
+	dms_models.TwWebhookDelivery
+	Event    dms_models.TwWebhookEvent             `json:"event"`
+	Attempts []dms_models.TwWebhookDeliveryAttempt `json:"attempt_log"`
+}
+
+// DispatchEvents fans up to limit events of the outbox out to the
+// subscriptions that want them, as pending deliveries, and returns how many
+// events were dispatched.
+func DispatchEvents(db *gorm.DB, now time.Time, limit int) (int, error) {
+	dispatched := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var events []dms_models.TwWebhookEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("dispatched_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		subscriptions := make(map[int][]dms_models.TwWebhookSubscription)
+		for _, event := range events {
+			active, ok := subscriptions[event.WorkspaceId]
+			if !ok {
+				if err := tx.Where("workspace_id = ? AND is_active = ? AND deleted_at IS NULL", event.WorkspaceId, true).
+					Find(&active).Error; err != nil {
+					return err
+				}
+				subscriptions[event.WorkspaceId] = active
+			}
+			for _, subscription := range active {
+				if !wants(subscription, event.Type) {
+					continue
+				}
+				delivery := dms_models.TwWebhookDelivery{
+					EventId:        event.ID,
+					SubscriptionId: subscription.ID,
+					EventType:      event.Type,
+					Status:         dms_models.WebhookDeliveryPending,
+					NextAttemptAt:  &now,
+				}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&event).Update("dispatched_at", now).Error; err != nil {
+				return err
+			}
+			dispatched++
+		}
+		return nil
+	})
+	return dispatched, err
+}
+
+func wants(subscription dms_models.TwWebhookSubscription, eventType string) bool {
+	for _, wanted := range splitEventTypes(subscription.EventTypes) {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimDeliveries returns up to limit pending deliveries that are due,
+// hiding them from the other workers for claimLease. Deliveries of inactive
+// or deleted subscriptions are left pending.
+func ClaimDeliveries(db *gorm.DB, now time.Time, limit int) ([]Dispatch, error) {
+	var dispatches []Dispatch
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var deliveries []dms_models.TwWebhookDelivery
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Joins("JOIN tw_webhook_subscriptions AS s ON s.id = tw_webhook_deliveries.subscription_id").
+			Where("tw_webhook_deliveries.status = ? AND tw_webhook_deliveries.next_attempt_at <= ?", dms_models.WebhookDeliveryPending, now).
+			Where("s.is_active = ? AND s.deleted_at IS NULL", true).
+			Order("tw_webhook_deliveries.next_attempt_at, tw_webhook_deliveries.id").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		for _, delivery := range deliveries {
+			var event dms_models.TwWebhookEvent
+			if err := tx.First(&event, delivery.EventId).Error; err != nil {
+				return err
+			}
+			var subscription dms_models.TwWebhookSubscription
+			if err := tx.First(&subscription, delivery.SubscriptionId).Error; err != nil {
+				return err
+			}
+			body, err := json.Marshal(Envelope{
+				ID:          event.ID,
+				Type:        event.Type,
+				WorkspaceId: event.WorkspaceId,
+				CreatedAt:   event.CreatedAt,
+				Data:        json.RawMessage(event.Payload),
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&delivery).Update("next_attempt_at", now.Add(claimLease)).Error; err != nil {
+				return err
+			}
+			dispatches = append(dispatches, Dispatch{
+				DeliveryId: delivery.ID,
+				EventType:  delivery.EventType,
+				Attempt:    delivery.Attempts + 1,
+				URL:        subscription.URL,
+				Secret:     subscription.Secret,
+				Body:       string(body),
+			})
+		}
+		return nil
+	})
+	return dispatches, err
+}
+
+// retryDelay returns how long to wait after the given number of failed
+// attempts: RetryBase doubled for every earlier failure, at most RetryMax.
+func retryDelay(policy config.WebhookConfig, attempts int) time.Duration {
+	delay := policy.RetryBase
+	for i := 1; i < attempts && delay < policy.RetryMax; i++ {
+		delay *= 2
+	}
+	if delay > policy.RetryMax {
+		delay = policy.RetryMax
+	}
+	return delay
+}
+
+// RecordAttempt logs a request of a delivery and marks the delivery
+// delivered, schedules the next attempt, or gives it up once it has failed
+// policy.MaxAttempts times. It returns gorm.ErrRecordNotFound when the
+// delivery does not exist.
+func RecordAttempt(db *gorm.DB, deliveryId int, attempt Attempt, now time.Time, policy config.WebhookConfig) (dms_models.TwWebhookDelivery, error) {
+	var delivery dms_models.TwWebhookDelivery
+	if len(attempt.Response) > maxResponseLog {
+		attempt.Response = attempt.Response[:maxResponseLog]
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&delivery, deliveryId).Error; err != nil {
+			return err
+		}
+		delivery.Attempts++
+		if err := tx.Create(&dms_models.TwWebhookDeliveryAttempt{
+			DeliveryId: delivery.ID,
+			Attempt:    delivery.Attempts,
+			StatusCode: attempt.StatusCode,
+			Error:      attempt.Error,
+			Response:   attempt.Response,
+			DurationMs: attempt.DurationMs,
+			Worker:     attempt.Worker,
+		}).Error; err != nil {
+			return err
+		}
+
+		delivery.LastStatusCode = attempt.StatusCode
+		delivery.LastError = attempt.Error
+		switch {
+		case attempt.Succeeded():
+			delivery.Status = dms_models.WebhookDeliveryDelivered
+			delivery.DeliveredAt = &now
+			delivery.NextAttemptAt = nil
+		case delivery.Attempts >= policy.MaxAttempts:
+			delivery.Status = dms_models.WebhookDeliveryFailed
+			delivery.NextAttemptAt = nil
+		default:
+			next := now.Add(retryDelay(policy, delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+		return tx.Save(&delivery).Error
+	})
+	return delivery, err
+}
+
+// Redeliver queues a delivery again with a fresh set of attempts, e.g. once
+// the receiver of a failed one is fixed. It returns gorm.ErrRecordNotFound
+// when the delivery does not exist.
+func Redeliver(db *gorm.DB, deliveryId int, now time.Time) (dms_models.TwWebhookDelivery, error) {
+	var delivery dms_models.TwWebhookDelivery
+	if err := db.First(&delivery, deliveryId).Error; err != nil {
+		return delivery, err
+	}
+	delivery.Status = dms_models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	delivery.DeliveredAt = nil
+	return delivery, db.Save(&delivery).Error
+}
+
+// Deliveries returns up to limit deliveries of a subscription, most recent
+// first, optionally of one status.
+func Deliveries(db *gorm.DB, subscriptionId int, status string, limit int) ([]dms_models.TwWebhookDelivery, error) {
+	var deliveries []dms_models.TwWebhookDelivery
+	query := db.Where("subscription_id = ?", subscriptionId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetDeliveryLog returns a delivery with its event and attempts, or
+// gorm.ErrRecordNotFound when it does not exist.
+func GetDeliveryLog(db *gorm.DB, deliveryId int) (DeliveryLog, error) {
+	var log DeliveryLog
+	if err := db.First(&log.TwWebhookDelivery, deliveryId).Error; err != nil {
+		return log, err
+	}
+	if err := db.First(&log.Event, log.EventId).Error; err != nil {
+		return log, err
+	}
+	err := db.Where("delivery_id = ?", deliveryId).Order("id").Find(&log.Attempts).Error
+	return log, err
+}
@@ -0,0 +1,237 @@
+// Package webhook sends the events of a workspace to the URLs subscribed to
+// them. The handlers emit events into an outbox table in the transaction of
+// the change, and the cron worker fans them out to the subscriptions and
+// delivers them signed, retrying failures with exponential backoff.
+package webhook
+
+import (
+	"dbms/dms_models"
+	"encoding/json"
+	"github.com/timewise-team/timewise-models/models"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	EventScheduleCreated    = "schedule.created"
+	EventScheduleUpdated    = "schedule.updated"
+	EventScheduleDeleted    = "schedule.deleted"
+	EventScheduleMoved      = "schedule.moved"
+	EventCommentCreated     = "comment.created"
+	EventParticipantInvited = "participant.invited"
+	EventMemberRoleChanged  = "member.role_changed"
+	EventDocumentUploaded   = "document.uploaded"
+)
+
+// EventTypes are the event types a subscription can list.
+var EventTypes = []string{
+	EventScheduleCreated,
+	EventScheduleUpdated,
+	EventScheduleDeleted,
+	EventScheduleMoved,
+	EventCommentCreated,
+	EventParticipantInvited,
+	EventMemberRoleChanged,
+	EventDocumentUploaded,
+}
+
+func knownEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit stores an event of a workspace in the outbox. Call it with the
+// transaction of the change, so that the event is sent if and only if the
+// change is committed. Nothing is stored when no active subscription of the
+// workspace wants the event type.
+func Emit(tx *gorm.DB, workspaceId int, eventType string, data interface{}) error {
+	var count int64
+	err := tx.Model(&dms_models.TwWebhookSubscription{}).
+		Where("workspace_id = ? AND is_active = ? AND deleted_at IS NULL", workspaceId, true).
+		Where("CONCAT(',', event_types, ',') LIKE ?", "%,"+eventType+",%").
+		Count(&count).Error
+	if err != nil || count == 0 {
+		return err
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&dms_models.TwWebhookEvent{
+		WorkspaceId: workspaceId,
+		Type:        eventType,
+		Payload:     string(payload),
+	}).Error
+}
+
+// EmitForSchedule emits an event of the workspace of a schedule.
+func EmitForSchedule(tx *gorm.DB, scheduleId int, eventType string, data interface{}) error {
+	var schedule models.TwSchedule
+	if err := tx.Select("id", "workspace_id").First(&schedule, scheduleId).Error; err != nil {
+		return err
+	}
+	return Emit(tx, schedule.WorkspaceId, eventType, data)
+}
+
+// Schedule is the data of the schedule.* events.
+type Schedule struct {
+	ID                int        `json:"id"`
+	WorkspaceId       int        `json:"workspace_id"`
+	BoardColumnId     int        `json:"board_column_id"`
+	Title             string     `json:"title"`
+	Description       string     `json:"description"`
+	StartTime         *time.Time `json:"start_time"`
+	EndTime           *time.Time `json:"end_time"`
+	Location          string     `json:"location"`
+	Status            string     `json:"status"`
+	Priority          string     `json:"priority"`
+	Position          int        `json:"position"`
+	AllDay            bool       `json:"all_day"`
+	Visibility        string     `json:"visibility"`
+	RecurrencePattern string     `json:"recurrence_pattern"`
+	CreatedBy         int        `json:"created_by"`
+	IsDeleted         bool       `json:"is_deleted"`
+}
+
+func NewSchedule(schedule models.TwSchedule) Schedule {
+	return Schedule{
+		ID:                schedule.ID,
+		WorkspaceId:       schedule.WorkspaceId,
+		BoardColumnId:     schedule.BoardColumnId,
+		Title:             schedule.Title,
+		Description:       schedule.Description,
+		StartTime:         schedule.StartTime,
+		EndTime:           schedule.EndTime,
+		Location:          schedule.Location,
+		Status:            schedule.Status,
+		Priority:          schedule.Priority,
+		Position:          schedule.Position,
+		AllDay:            schedule.AllDay,
+		Visibility:        schedule.Visibility,
+		RecurrencePattern: schedule.RecurrencePattern,
+		CreatedBy:         schedule.CreatedBy,
+		IsDeleted:         schedule.IsDeleted,
+	}
+}
+
+// Change is a field changed by a schedule.updated or schedule.moved event,
+// as recorded in the schedule log.
+type Change struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+// ScheduleChange is the data of the schedule.updated, schedule.moved and
+// schedule.deleted events.
+type ScheduleChange struct {
+	Schedule        Schedule `json:"schedule"`
+	WorkspaceUserId int      `json:"workspace_user_id"`
+	Changes         []Change `json:"changes,omitempty"`
+}
+
+// NewScheduleChange collects the field changes of the schedule logs.
+func NewScheduleChange(schedule models.TwSchedule, workspaceUserId int, logs []models.TwScheduleLog) ScheduleChange {
+	change := ScheduleChange{Schedule: NewSchedule(schedule), WorkspaceUserId: workspaceUserId}
+	for _, log := range logs {
+		if log.FieldChanged == "" || log.Action != "update schedule" {
+			continue
+		}
+		change.Changes = append(change.Changes, Change{Field: log.FieldChanged, OldValue: log.OldValue, NewValue: log.NewValue})
+	}
+	return change
+}
+
+// Comment is the data of the comment.created event.
+type Comment struct {
+	ID              int       `json:"id"`
+	ScheduleId      int       `json:"schedule_id"`
+	WorkspaceUserId int       `json:"workspace_user_id"`
+	Commenter       string    `json:"commenter"`
+	Content         string    `json:"content"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func NewComment(comment models.TwComment) Comment {
+	return Comment{
+		ID:              comment.ID,
+		ScheduleId:      comment.ScheduleId,
+		WorkspaceUserId: comment.WorkspaceUserId,
+		Commenter:       comment.Commenter,
+		Content:         comment.Content,
+		CreatedAt:       comment.CreatedAt,
+	}
+}
+
+// Participant is the data of the participant.invited event.
+type Participant struct {
+	ID               int        `json:"id"`
+	ScheduleId       int        `json:"schedule_id"`
+	WorkspaceUserId  int        `json:"workspace_user_id"`
+	AssignBy         int        `json:"assign_by"`
+	Status           string     `json:"status"`
+	InvitationStatus string     `json:"invitation_status"`
+	InvitationSentAt *time.Time `json:"invitation_sent_at"`
+}
+
+func NewParticipant(participant models.TwScheduleParticipant) Participant {
+	return Participant{
+		ID:               participant.ID,
+		ScheduleId:       participant.ScheduleId,
+		WorkspaceUserId:  participant.WorkspaceUserId,
+		AssignBy:         participant.AssignBy,
+		Status:           participant.Status,
+		InvitationStatus: participant.InvitationStatus,
+		InvitationSentAt: participant.InvitationSentAt,
+	}
+}
+
+// RoleChange is the data of the member.role_changed event.
+type RoleChange struct {
+	WorkspaceUserId int    `json:"workspace_user_id"`
+	Email           string `json:"email"`
+	OldRole         string `json:"old_role"`
+	NewRole         string `json:"new_role"`
+	ChangedBy       int    `json:"changed_by"`
+}
+
+// Document is the data of the document.uploaded event.
+type Document struct {
+	ID          int       `json:"id"`
+	ScheduleId  int       `json:"schedule_id"`
+	FileName    string    `json:"file_name"`
+	FileType    string    `json:"file_type"`
+	FileSize    int       `json:"file_size"`
+	DownloadUrl string    `json:"download_url"`
+	UploadedBy  int       `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+func NewDocument(document models.TwDocument) Document {
+	return Document{
+		ID:          document.ID,
+		ScheduleId:  document.ScheduleId,
+		FileName:    document.FileName,
+		FileType:    document.FileType,
+		FileSize:    document.FileSize,
+		DownloadUrl: document.DownloadUrl,
+		UploadedBy:  document.UploadedBy,
+		UploadedAt:  document.UploadedAt,
+	}
+}
+
+// splitEventTypes parses the comma separated event types of a subscription.
+func splitEventTypes(value string) []string {
+	var eventTypes []string
+	for _, eventType := range strings.Split(value, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	return eventTypes
+}
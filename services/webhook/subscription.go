@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"dbms/dms_models"
+	"encoding/hex"
+	"fmt"
+	"gorm.io/gorm"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type InvalidSubscription struct {
+	Reason string
+}
+
+func (e *InvalidSubscription) Error() string {
+	return e.Reason
+}
+
+// SubscriptionWithSecret is a subscription together with its secret, which
+// is only shown when the subscription is created or its secret rotated.
+type SubscriptionWithSecret struct {
+	dms_models.TwWebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// SubscriptionChange is a change of a subscription; nil fields are kept.
+type SubscriptionChange struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// ForbiddenAddress reports whether webhooks may not be delivered to ip:
+// loopback, private, link-local, unspecified and multicast addresses would
+// let a subscription reach the network the service runs in.
+func ForbiddenAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// validateURL rejects URLs that are not absolute http(s) URLs or whose host
+// is localhost or a forbidden IP address. Host names are only resolved when
+// delivering, where the webhook client refuses forbidden addresses.
+func validateURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return &InvalidSubscription{Reason: "url must be an absolute http or https URL"}
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &InvalidSubscription{Reason: "url must not point to localhost"}
+	}
+	if ip := net.ParseIP(host); ip != nil && ForbiddenAddress(ip) {
+		return &InvalidSubscription{Reason: "url must not point to a loopback, private or link-local address"}
+	}
+	return nil
+}
+
+// joinEventTypes validates and deduplicates event types, returning them
+// comma separated.
+func joinEventTypes(eventTypes []string) (string, error) {
+	if len(eventTypes) == 0 {
+		return "", &InvalidSubscription{Reason: "event_types is required"}
+	}
+	seen := make(map[string]bool, len(eventTypes))
+	var joined []string
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !knownEventType(eventType) {
+			return "", &InvalidSubscription{Reason: fmt.Sprintf("unknown event type %q, expected one of %s", eventType, strings.Join(EventTypes, ", "))}
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			joined = append(joined, eventType)
+		}
+	}
+	return strings.Join(joined, ","), nil
+}
+
+func newSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CreateSubscription subscribes a URL to events of a workspace. A secret is
+// generated unless one is given.
+func CreateSubscription(db *gorm.DB, workspaceId int, rawURL, secret string, eventTypes []string, createdBy int) (SubscriptionWithSecret, error) {
+	var subscription SubscriptionWithSecret
+	if workspaceId <= 0 {
+		return subscription, &InvalidSubscription{Reason: "workspace_id is required"}
+	}
+	if err := validateURL(rawURL); err != nil {
+		return subscription, err
+	}
+	joined, err := joinEventTypes(eventTypes)
+	if err != nil {
+		return subscription, err
+	}
+	if secret == "" {
+		if secret, err = newSecret(); err != nil {
+			return subscription, err
+		}
+	} else if len(secret) < 16 {
+		return subscription, &InvalidSubscription{Reason: "secret must be at least 16 characters"}
+	}
+
+	subscription.TwWebhookSubscription = dms_models.TwWebhookSubscription{
+		WorkspaceId: workspaceId,
+		URL:         rawURL,
+		Secret:      secret,
+		EventTypes:  joined,
+		IsActive:    true,
+		CreatedBy:   createdBy,
+	}
+	subscription.Secret = secret
+	err = db.Create(&subscription.TwWebhookSubscription).Error
+	return subscription, err
+}
+
+// Subscriptions returns the subscriptions of a workspace.
+func Subscriptions(db *gorm.DB, workspaceId int) ([]dms_models.TwWebhookSubscription, error) {
+	var subscriptions []dms_models.TwWebhookSubscription
+	err := db.Where("workspace_id = ? AND deleted_at IS NULL", workspaceId).Order("id").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// GetSubscription returns gorm.ErrRecordNotFound when the subscription does
+// not exist or was deleted.
+func GetSubscription(db *gorm.DB, subscriptionId int) (dms_models.TwWebhookSubscription, error) {
+	var subscription dms_models.TwWebhookSubscription
+	err := db.Where("id = ? AND deleted_at IS NULL", subscriptionId).First(&subscription).Error
+	return subscription, err
+}
+
+// UpdateSubscription applies a change to a subscription and returns it.
+func UpdateSubscription(db *gorm.DB, subscriptionId int, change SubscriptionChange) (dms_models.TwWebhookSubscription, error) {
+	subscription, err := GetSubscription(db, subscriptionId)
+	if err != nil {
+		return subscription, err
+	}
+	updates := map[string]interface{}{}
+	if change.URL != nil {
+		if err := validateURL(*change.URL); err != nil {
+			return subscription, err
+		}
+		updates["url"] = *change.URL
+	}
+	if change.EventTypes != nil {
+		joined, err := joinEventTypes(change.EventTypes)
+		if err != nil {
+			return subscription, err
+		}
+		updates["event_types"] = joined
+	}
+	if change.IsActive != nil {
+		updates["is_active"] = *change.IsActive
+	}
+	if len(updates) == 0 {
+		return subscription, nil
+	}
+	if err := db.Model(&subscription).Updates(updates).Error; err != nil {
+		return subscription, err
+	}
+	return GetSubscription(db, subscriptionId)
+}
+
+// RotateSecret replaces the secret of a subscription with a new one. The
+// deliveries signed from then on use the new secret.
+func RotateSecret(db *gorm.DB, subscriptionId int) (SubscriptionWithSecret, error) {
+	var rotated SubscriptionWithSecret
+	subscription, err := GetSubscription(db, subscriptionId)
+	if err != nil {
+		return rotated, err
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return rotated, err
+	}
+	if err := db.Model(&subscription).Update("secret", secret).Error; err != nil {
+		return rotated, err
+	}
+	rotated.TwWebhookSubscription = subscription
+	rotated.Secret = secret
+	return rotated, nil
+}
+
+// DeleteSubscription soft deletes a subscription; its pending deliveries
+// are not sent anymore.
+func DeleteSubscription(db *gorm.DB, subscriptionId int) error {
+	result := db.Model(&dms_models.TwWebhookSubscription{}).
+		Where("id = ? AND deleted_at IS NULL", subscriptionId).
+		Update("deleted_at", gorm.Expr("NOW()"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
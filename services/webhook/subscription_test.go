@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{name: "https host name", url: "https://hooks.example.com/timewise"},
+		{name: "http public address", url: "http://93.184.216.34:8080/hook"},
+		{name: "public IPv6 address", url: "https://[2606:2800:220:1:248:1893:25c8:1946]/hook"},
+		{name: "relative URL", url: "/hook", wantErr: true},
+		{name: "other scheme", url: "ftp://example.com/hook", wantErr: true},
+		{name: "localhost", url: "http://localhost:8080/hook", wantErr: true},
+		{name: "localhost with trailing dot", url: "http://LOCALHOST./hook", wantErr: true},
+		{name: "localhost subdomain", url: "http://api.localhost/hook", wantErr: true},
+		{name: "IPv4 loopback", url: "http://127.0.0.1/hook", wantErr: true},
+		{name: "IPv6 loopback", url: "http://[::1]/hook", wantErr: true},
+		{name: "IPv4-mapped loopback", url: "http://[::ffff:127.0.0.1]/hook", wantErr: true},
+		{name: "private 10/8", url: "http://10.1.2.3/hook", wantErr: true},
+		{name: "private 172.16/12", url: "http://172.20.0.1/hook", wantErr: true},
+		{name: "private 192.168/16", url: "https://192.168.1.10/hook", wantErr: true},
+		{name: "IPv6 unique local", url: "http://[fd00::1]/hook", wantErr: true},
+		{name: "link-local metadata service", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "IPv6 link-local", url: "http://[fe80::1]/hook", wantErr: true},
+		{name: "unspecified", url: "http://0.0.0.0/hook", wantErr: true},
+		{name: "multicast", url: "http://224.0.0.1/hook", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateURL(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+			var invalid *InvalidSubscription
+			if err != nil && !errors.As(err, &invalid) {
+				t.Errorf("validateURL(%q) = %v, want *InvalidSubscription", tt.url, err)
+			}
+		})
+	}
+}